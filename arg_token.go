@@ -2,23 +2,10 @@ package mips32
 
 import (
 	"errors"
-	"regexp"
 	"strconv"
 	"strings"
 )
 
-var (
-	constantNumberPattern = "(-?[0-9]*|-?0x[0-9a-fA-F]*)"
-	symbolNamePattern     = "([a-zA-Z0-9_]*)"
-)
-
-var (
-	constantRegexp        = regexp.MustCompile("^" + constantNumberPattern + "$")
-	symbolRegexp          = regexp.MustCompile("^" + symbolNamePattern + "$")
-	memoryRegexp          = regexp.MustCompile("^(" + constantNumberPattern + "|)\\(.+\\)$")
-	memorySubfieldsRegexp = regexp.MustCompile("^(.*)\\((.*)\\)$")
-)
-
 var registerNames = map[string]int{
 	"0": 0, "zero": 0, "1": 1, "at": 1, "2": 2, "v0": 2, "3": 3, "v1": 3, "4": 4, "a0": 4, "5": 5,
 	"a1": 5, "6": 6, "a2": 6, "7": 7, "a3": 7, "8": 8, "t0": 8, "9": 9, "t1": 9, "10": 10, "t2": 10,
@@ -52,11 +39,14 @@ type MemoryReference struct {
 	Offset   int16
 }
 
-// An ArgToken represents a register, a number, a symbol, or a memory location.
+// An ArgToken represents a register, a number, a symbol, an expression, or a memory location.
 // For instance, the instruction "SB $5, 5($6)" contains two tokens.
 //
 // An ArgToken may be able to serve as multiple types of arguments.
 // For example, the ArgToken for "0x5" could be a Constant5, a Constant16, or a CodePointer.
+//
+// Expressions which refer to symbols, like "x+4" or "%hi(x)" (see EvaluateExpression), cannot
+// serve as any type of argument until ParseExecutable replaces them with their values.
 type ArgToken struct {
 	isRegister bool
 	register   int
@@ -67,6 +57,8 @@ type ArgToken struct {
 	isSymbol bool
 	symbol   string
 
+	expression string
+
 	isMemory    bool
 	memRegister int
 	memOffset   int16
@@ -75,16 +67,47 @@ type ArgToken struct {
 // ParseArgToken parses a human-readable token string.
 // The string must not have any leading or trailing whitespace.
 func ParseArgToken(tokenStr string) (token *ArgToken, err error) {
-	if regToken, err := parseRegisterArgToken(tokenStr); err == nil {
-		return regToken, nil
-	} else if constantRegexp.MatchString(tokenStr) {
-		return parseConstantArgToken(tokenStr)
-	} else if symbolRegexp.MatchString(tokenStr) {
-		return parseSymbolArgToken(tokenStr)
-	} else if memoryRegexp.MatchString(tokenStr) {
-		return parseMemoryArgToken(tokenStr)
+	token = &ArgToken{}
+	if err := parseArgToken(tokenStr, token); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("unable to parse token: " + tokenStr)
+	return token, nil
+}
+
+// parseArgToken is like ParseArgToken, but it fills in an existing token so that the tokenizer
+// can allocate tokens in batches.
+func parseArgToken(tokenStr string, token *ArgToken) error {
+	if strings.HasPrefix(tokenStr, "$") {
+		if regNum, ok := lookupRegister(tokenStr[1:]); ok {
+			*token = ArgToken{isRegister: true, register: regNum}
+			return nil
+		}
+	}
+	if isConstantText(tokenStr) {
+		num, err := parseConstant(tokenStr)
+		if err != nil {
+			return err
+		}
+		*token = ArgToken{isConstant: true, constant: num}
+		return nil
+	} else if isSymbolText(tokenStr) {
+		*token = ArgToken{isSymbol: true, symbol: tokenStr}
+		return nil
+	} else if offsetStr, regStr, ok := splitMemoryText(tokenStr); ok {
+		return parseMemoryArgToken(offsetStr, regStr, token)
+	} else if hasSymbols, err := checkOperandExpression(tokenStr); err == nil {
+		if hasSymbols {
+			*token = ArgToken{expression: tokenStr}
+			return nil
+		}
+		num, err := evaluateOperand(tokenStr, nil)
+		if err != nil {
+			return err
+		}
+		*token = ArgToken{isConstant: true, constant: num}
+		return nil
+	}
+	return errors.New("unable to parse token: " + tokenStr)
 }
 
 // Register returns the register index represented by this token.
@@ -150,53 +173,52 @@ func (t *ArgToken) AbsoluteCodePointer() (ptr CodePointer, ok bool) {
 // MemoryReference returns the MemoryReference represented by this token.
 // If this token cannot be treated as a MemoryReference, ok will be false.
 func (t *ArgToken) MemoryReference() (ref MemoryReference, ok bool) {
-	return MemoryReference{Register: t.memRegister, Offset: t.memOffset}, t.isMemory
+	return MemoryReference{Register: t.memRegister, Offset: t.memOffset},
+		t.isMemory && t.expression == ""
 }
 
-func parseRegisterArgToken(tokenStr string) (token *ArgToken, err error) {
-	regNum, err := parseRegister(tokenStr)
+// parseMemoryArgToken parses a memory reference whose offset may be a constant or an
+// expression.
+func parseMemoryArgToken(offsetStr, regStr string, token *ArgToken) error {
+	reg, err := parseRegister(regStr)
 	if err != nil {
-		return nil, err
-	} else {
-		return &ArgToken{isRegister: true, register: regNum}, nil
+		return err
 	}
-}
 
-func parseConstantArgToken(tokenStr string) (token *ArgToken, err error) {
-	num, err := parseConstant(tokenStr)
-	if err != nil {
-		return nil, err
+	var offNum uint32
+	if isConstantText(offsetStr) {
+		if len(offsetStr) != 0 {
+			if offNum, err = parseConstant(offsetStr); err != nil {
+				return err
+			}
+		}
+	} else if hasSymbols, err := checkOperandExpression(offsetStr); err != nil {
+		return err
+	} else if hasSymbols {
+		*token = ArgToken{isMemory: true, memRegister: reg, expression: offsetStr}
+		return nil
+	} else if offNum, err = evaluateOperand(offsetStr, nil); err != nil {
+		return err
 	}
-	return &ArgToken{isConstant: true, constant: num}, nil
-}
-
-func parseSymbolArgToken(tokenStr string) (token *ArgToken, err error) {
-	return &ArgToken{isSymbol: true, symbol: tokenStr}, nil
+	return memoryArgToken(offNum, offsetStr, reg, token)
 }
 
-func parseMemoryArgToken(tokenStr string) (token *ArgToken, err error) {
-	pieces := memorySubfieldsRegexp.FindStringSubmatch(tokenStr)
-	if pieces == nil {
-		return nil, errors.New("invalid memory reference: " + tokenStr)
-	}
-
-	reg, err := parseRegister(pieces[2])
-	if err != nil {
-		return
+// memoryArgToken creates a memory reference token, checking that the offset fits in 16 bits.
+func memoryArgToken(offNum uint32, offsetStr string, reg int, token *ArgToken) error {
+	if (offNum&0xffff8000) != 0xffff8000 && (offNum&0xffff8000) != 0 {
+		return errors.New("memory offset out of bounds: " + offsetStr)
 	}
+	*token = ArgToken{isMemory: true, memOffset: int16(offNum), memRegister: reg}
+	return nil
+}
 
-	var offset int16
-	if len(pieces[1]) != 0 {
-		if offNum, err := parseConstant(pieces[1]); err != nil {
-			return nil, err
-		} else if (offNum&0xffff8000) != 0xffff8000 && (offNum&0xffff8000) != 0 {
-			return nil, errors.New("memory offset out of bounds: " + pieces[1])
-		} else {
-			offset = int16(offNum)
-		}
+// lookupRegister finds a register by its name (without the "$"), ignoring case.
+func lookupRegister(name string) (int, bool) {
+	if regNum, ok := registerNames[name]; ok {
+		return regNum, true
 	}
-
-	return &ArgToken{isMemory: true, memOffset: offset, memRegister: reg}, nil
+	regNum, ok := registerNames[strings.ToLower(name)]
+	return regNum, ok
 }
 
 func parseRegister(tokenStr string) (regIndex int, err error) {
@@ -204,7 +226,7 @@ func parseRegister(tokenStr string) (regIndex int, err error) {
 		return 0, errors.New("missing $ in register name: " + tokenStr)
 	}
 	rawName := tokenStr[1:]
-	if regNum, ok := registerNames[rawName]; ok {
+	if regNum, ok := lookupRegister(rawName); ok {
 		return regNum, nil
 	} else {
 		return 0, errors.New("invalid register name: " + tokenStr)
@@ -221,3 +243,57 @@ func parseConstant(tokenStr string) (constant uint32, err error) {
 	}
 	return uint32(resNum), nil
 }
+
+// isConstantText checks if a string looks like a constant: an optional minus sign followed
+// either by decimal digits or by "0x" and hexadecimal digits.
+// The digits may be missing, in which case parseConstant reports the error.
+func isConstantText(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "0x") {
+		for i := 2; i < len(s); i++ {
+			c := s[i]
+			if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') && !('A' <= c && c <= 'F') {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSymbolText checks if a string consists of letters, digits, and underscores.
+// The empty string passes this check.
+func isSymbolText(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') &&
+			c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitMemoryText splits a memory reference like "-4($sp)" or "x+4($sp)" into its offset and
+// its register.
+// If the string is not shaped like a memory reference, ok is false.
+func splitMemoryText(s string) (offsetStr, regStr string, ok bool) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || len(s) < open+3 || s[len(s)-1] != ')' || strings.IndexByte(s, '\n') >= 0 {
+		return
+	}
+	last := strings.LastIndexByte(s, '(')
+	offsetStr, regStr = s[:last], s[last+1:len(s)-1]
+	if !isConstantText(s[:open]) && !strings.HasPrefix(regStr, "$") {
+		// This may be an expression in parentheses, like "(x+4)".
+		return "", "", false
+	}
+	return offsetStr, regStr, true
+}
@@ -276,7 +276,39 @@ func TestParseArgToken(t *testing.T) {
 		}
 	}
 
-	badTokens := []string{"Monkey Brain", "$r32", "$r-1", "$32", "0x8000($r1)", "-0x8001($r1)"}
+	constantTokens := map[string]uint32{
+		"1+2":             3,
+		"(1 << 4) | 1":    0x11,
+		"%hi(0x12348000)": 0x1235,
+		"%lo(0x12348000)": 0xffff8000,
+		"%lo(0x8000) + 1": 0x8001,
+	}
+	for tok, expected := range constantTokens {
+		token, err := ParseArgToken(tok)
+		if err != nil {
+			t.Error("failed to parse "+tok+":", err)
+		} else if !token.isConstant || token.constant != expected {
+			t.Errorf("%s: expected constant %#x but got %v", tok, expected, token)
+		}
+	}
+	if token, err := ParseArgToken("x+4($t0)"); err != nil {
+		t.Error(err)
+	} else if _, ok := token.MemoryReference(); ok || !token.isMemory || token.memRegister != 8 {
+		t.Error("unexpected token for x+4($t0):", token)
+	}
+	if token, err := ParseArgToken("(2+2)($t0)"); err != nil {
+		t.Error(err)
+	} else if ref, ok := token.MemoryReference(); !ok || ref.Offset != 4 || ref.Register != 8 {
+		t.Error("unexpected token for (2+2)($t0):", token)
+	}
+	if token, err := ParseArgToken("%hi(x) + 1"); err != nil {
+		t.Error(err)
+	} else if _, ok := token.UnsignedConstant16(); ok {
+		t.Error("expressions with symbols are not constants")
+	}
+
+	badTokens := []string{"Monkey Brain", "$r32", "$r-1", "$32", "0x8000($r1)", "-0x8001($r1)",
+		"$t0+1", "%mid(x)", "1/0", "x+", "x($t32)", "x+($t0)", "0x8000+0($t0)"}
 	for _, tok := range badTokens {
 		if _, err := ParseArgToken(tok); err == nil {
 			t.Error("parsed invalid token:", tok)
@@ -299,6 +331,10 @@ func TestRegisterNameParsing(t *testing.T) {
 		"$s8":   30,
 		"$k0":   26,
 		"$k1":   27,
+		"$ZERO": 0,
+		"$Sp":   29,
+		"$T0":   8,
+		"$R31":  31,
 	}
 	for i := 0; i < 32; i++ {
 		iName := strconv.Itoa(i)
@@ -0,0 +1,389 @@
+package mips32
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Dialect is a flavor of MIPS assembly.
+type Dialect int
+
+const (
+	// DialectStandard is MIPS assembly where every branch and jump has a delay slot.
+	DialectStandard Dialect = iota
+
+	// DialectNoDelaySlots is for the MIPS variant without delay slots used by some courses.
+	// It is assembled by inserting a NOP after every branch and jump (see InsertDelaySlotNOPs).
+	DialectNoDelaySlots
+)
+
+// A Revision is a version of the MIPS instruction set.
+type Revision int
+
+const (
+	// RevisionMIPS32 allows every instruction in Templates.
+	RevisionMIPS32 Revision = iota
+
+	// RevisionMIPS1 rejects instructions which were added after MIPS I (MOVN and MOVZ).
+	RevisionMIPS1
+)
+
+// AssembleOptions configures Assemble.
+// The zero value assembles programs exactly like TokenizeSource and ParseExecutable.
+type AssembleOptions struct {
+	Dialect  Dialect
+	Revision Revision
+
+	// BaseAddress is where code goes before the first ".text" directive.
+	// It is ignored if the layout has regions, since code starts in the first region.
+	BaseAddress uint32
+
+	// Layout places ".section" directives, as in ParseExecutableLayout.
+	Layout *Layout
+
+	// CaseInsensitiveSymbols makes symbols which only differ in case refer to the same thing.
+	// Every reference is renamed to match the symbol's first declaration.
+	CaseInsensitiveSymbols bool
+
+	// Constants defines names which can be used in place of constant operands, as in
+	// "ADDIU $sp, $sp, FRAME_SIZE".
+	// Constants take precedence over symbols with the same name.
+	Constants map[string]uint32
+
+	// LittleEndian lays out the values of ".half" and ".byte" directives for little endian
+	// memory (see LittleEndianData).
+	// It should match the LittleEndian field of the emulator which runs the program, since
+	// Executable.LoadInto copies the data into memory.
+	LittleEndian bool
+
+	// Optimize runs the peephole optimizer (see Optimize) after any delay slot NOPs are
+	// inserted.
+	Optimize bool
+
+	// OnOptimize, if non-nil, is called with each change which the optimizer makes.
+	OnOptimize func(*Optimization)
+
+	// Relax rewrites branches and jumps which cannot reach their targets (see RelaxBranches).
+	Relax bool
+
+	// Strict turns any warning from AssembleWithWarnings or Lint into an error.
+	Strict bool
+}
+
+// Assemble tokenizes and parses a source file according to some options.
+// If opts is nil, the default options are used.
+func Assemble(source string, opts *AssembleOptions) (*Executable, error) {
+	exc, _, err := AssembleWithWarnings(source, opts)
+	return exc, err
+}
+
+// AssembleWithWarnings is like Assemble, but it also returns warnings about things which are
+// allowed but probably mistakes.
+//
+// The assembler warns about constant branch offsets and jump targets which are not multiples of
+// 4 (since their low bits are ignored), uses of $at (which is reserved for the assembler), and
+// symbols which have the same names as registers.
+// These are separate from the warnings reported by Lint.
+func AssembleWithWarnings(source string, opts *AssembleOptions) (*Executable, []*Warning,
+	error) {
+	if opts == nil {
+		opts = &AssembleOptions{}
+	}
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.BaseAddress&3 != 0 {
+		return nil, nil, errors.New("misaligned base address")
+	} else if opts.BaseAddress != 0 && (opts.Layout == nil || len(opts.Layout.Regions) == 0) {
+		base := &TokenizedDirective{Name: "text", Constant: opts.BaseAddress}
+		lines = append([]TokenizedLine{{Directive: base}}, lines...)
+	}
+	if len(opts.Constants) > 0 {
+		lines = substituteConstants(lines, opts.Constants)
+	}
+	if opts.CaseInsensitiveSymbols {
+		lines = foldSymbolCase(lines)
+	}
+	if opts.LittleEndian {
+		lines = LittleEndianData(lines)
+	}
+	if opts.Revision == RevisionMIPS1 {
+		for _, line := range lines {
+			inst := line.Instruction
+			if inst != nil && (inst.Name == "MOVN" || inst.Name == "MOVZ") {
+				return nil, nil, lineError(line.LineNumber,
+					inst.Name+" is not available in MIPS I")
+			}
+		}
+	}
+	// The warnings are about the code as written, not the code the assembler generates.
+	sourceLines := lines
+
+	if opts.Dialect == DialectNoDelaySlots {
+		lines = InsertDelaySlotNOPs(lines)
+	}
+	if opts.Optimize {
+		var report []*Optimization
+		lines, report, err = Optimize(lines)
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.OnOptimize != nil {
+			for _, opt := range report {
+				opts.OnOptimize(opt)
+			}
+		}
+	}
+	if opts.Relax {
+		lines, err = RelaxBranches(lines, opts.Layout)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	exc, err := ParseExecutableLayout(lines, opts.Layout)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := assemblerWarnings(sourceLines, exc)
+	if opts.Strict {
+		lintWarnings := lint(exc, referencedSymbols(lines))
+		all := append(append([]*Warning{}, warnings...), lintWarnings...)
+		sort.Stable(warningList(all))
+		if len(all) > 0 {
+			return nil, nil, lineError(all[0].Line, all[0].Message)
+		}
+	}
+	return exc, warnings, nil
+}
+
+// assemblerWarnings finds the warnings reported by AssembleWithWarnings.
+func assemblerWarnings(lines []TokenizedLine, exc *Executable) []*Warning {
+	lineAddresses := map[int]uint32{}
+	for addr, line := range exc.LineNumbers {
+		if old, ok := lineAddresses[line]; !ok || addr < old {
+			lineAddresses[line] = addr
+		}
+	}
+	var warnings []*Warning
+	addWarning := func(line int, msg string) {
+		warnings = append(warnings, &Warning{
+			Address: lineAddresses[line],
+			Line:    line,
+			Message: msg,
+		})
+	}
+
+	for _, line := range lines {
+		if line.SymbolMarker != nil {
+			if _, ok := lookupRegister(*line.SymbolMarker); ok {
+				warnings = append(warnings, &Warning{
+					Address: exc.Symbols[*line.SymbolMarker],
+					Line:    line.LineNumber,
+					Message: "symbol " + *line.SymbolMarker + " has the same name as a register",
+				})
+			}
+		}
+		if line.Instruction == nil {
+			continue
+		}
+		var usesAT bool
+		for _, arg := range line.Instruction.Arguments {
+			if (arg.isRegister && arg.register == 1) || (arg.isMemory && arg.memRegister == 1) {
+				usesAT = true
+			}
+		}
+		if usesAT {
+			addWarning(line.LineNumber, "$at is reserved for the assembler")
+		}
+		inst, err := ParseTokenizedInstruction(line.Instruction)
+		if err != nil || inst.CodePointer.IsSymbol || inst.CodePointer.Constant&3 == 0 {
+			continue
+		}
+		switch inst.Name {
+		case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+			addWarning(line.LineNumber, "branch offset "+
+				strconv.Itoa(int(int32(inst.CodePointer.Constant)))+
+				" is not a multiple of 4; its low bits are ignored")
+		case "J", "JAL":
+			addWarning(line.LineNumber, "jump target "+eightDigitHex(inst.CodePointer.Constant)+
+				" is not a multiple of 4; its low bits are ignored")
+		}
+	}
+	sort.Stable(warningList(warnings))
+	return warnings
+}
+
+// substituteConstants replaces symbol operands which name constants with the constants.
+func substituteConstants(lines []TokenizedLine, constants map[string]uint32) []TokenizedLine {
+	return mapSymbolOperands(lines, func(token *ArgToken) *ArgToken {
+		if value, ok := constants[token.symbol]; ok {
+			return &ArgToken{isConstant: true, constant: value}
+		}
+		return token
+	})
+}
+
+// foldSymbolCase renames symbols so that every spelling of a symbol matches its first
+// declaration.
+func foldSymbolCase(lines []TokenizedLine) []TokenizedLine {
+	spellings := map[string]string{}
+	for _, line := range lines {
+		if line.SymbolMarker != nil {
+			lower := strings.ToLower(*line.SymbolMarker)
+			if _, ok := spellings[lower]; !ok {
+				spellings[lower] = *line.SymbolMarker
+			}
+		}
+	}
+	res := mapSymbolOperands(lines, func(token *ArgToken) *ArgToken {
+		if spelling, ok := spellings[strings.ToLower(token.symbol)]; ok {
+			return &ArgToken{isSymbol: true, symbol: spelling}
+		}
+		return token
+	})
+	for i, line := range res {
+		if line.SymbolMarker != nil {
+			spelling := spellings[strings.ToLower(*line.SymbolMarker)]
+			res[i].SymbolMarker = &spelling
+		}
+	}
+	return res
+}
+
+// LittleEndianData rearranges the values of ".half", ".byte", ".ascii", and ".asciiz"
+// directives so that they appear in order in little endian memory, without modifying the
+// original lines.
+//
+// ParseExecutable packs these values into words in big endian order, so this reverses the
+// values within each word, padding the last word with zeroes.
+// String directives are turned into ".byte" directives.
+func LittleEndianData(lines []TokenizedLine) []TokenizedLine {
+	res := make([]TokenizedLine, len(lines))
+	for i, line := range lines {
+		res[i] = line
+		dir := line.Directive
+		if dir == nil || !(isDataDirective(dir.Name) || isStringDirective(dir.Name)) {
+			continue
+		}
+		perWord := 4 / dataValueSize(dir.Name)
+		if perWord == 1 {
+			continue
+		}
+		values := dir.dataValues()
+		swapped := &TokenizedDirective{Name: dir.Name}
+		if isStringDirective(dir.Name) {
+			swapped.Name = "byte"
+		}
+		swapped.Values = make([]DirectiveValue, dataWordCount(dir.Name, len(values))*perWord)
+		for j, value := range values {
+			wordStart := j - j%perWord
+			swapped.Values[wordStart+perWord-1-j%perWord] = value
+		}
+		res[i].Directive = swapped
+	}
+	return res
+}
+
+// referencedSymbols finds the symbols which are used by the source, including those which the
+// executable does not record, like the operands of ".word" directives.
+func referencedSymbols(lines []TokenizedLine) map[string]bool {
+	res := map[string]bool{}
+	for _, line := range lines {
+		if line.Directive != nil {
+			for _, value := range line.Directive.Values {
+				if value.Symbol != "" {
+					res[value.Symbol] = true
+				}
+			}
+		} else if line.Instruction != nil {
+			for _, arg := range line.Instruction.Arguments {
+				if arg.isSymbol {
+					res[arg.symbol] = true
+				} else if arg.expression != "" {
+					mapExpressionSymbols(arg.expression, func(name string) string {
+						res[name] = true
+						return name
+					})
+				}
+			}
+		}
+	}
+	return res
+}
+
+// mapSymbolOperands applies a function to every symbol operand, including the symbols in
+// expressions, without modifying the original lines.
+func mapSymbolOperands(lines []TokenizedLine, f func(*ArgToken) *ArgToken) []TokenizedLine {
+	res := make([]TokenizedLine, len(lines))
+	for i, line := range lines {
+		res[i] = line
+		if line.Directive != nil && len(line.Directive.Values) > 0 {
+			res[i].Directive = mapValueSymbols(line.Directive, f)
+			continue
+		} else if line.Directive != nil && isSymbolDirective(line.Directive.Name) {
+			// These directives need a symbol, so they are left alone if it becomes a constant.
+			if arg := f(&ArgToken{isSymbol: true, symbol: line.Directive.Symbol}); arg.isSymbol {
+				dir := *line.Directive
+				dir.Symbol = arg.symbol
+				res[i].Directive = &dir
+			}
+			continue
+		} else if line.Instruction == nil {
+			continue
+		}
+		inst := new(TokenizedInstruction)
+		*inst = *line.Instruction
+		inst.Arguments = make([]*ArgToken, len(line.Instruction.Arguments))
+		for j, arg := range line.Instruction.Arguments {
+			if arg.isSymbol {
+				arg = f(arg)
+			} else if arg.expression != "" {
+				arg = mapExpressionToken(arg, f)
+			}
+			inst.Arguments[j] = arg
+		}
+		res[i].Instruction = inst
+	}
+	return res
+}
+
+// mapExpressionToken applies a function to the symbols in an expression operand.
+// Symbols which become constants are written in hexadecimal.
+func mapExpressionToken(token *ArgToken, f func(*ArgToken) *ArgToken) *ArgToken {
+	res := *token
+	res.expression = mapExpressionSymbols(token.expression, func(name string) string {
+		arg := f(&ArgToken{isSymbol: true, symbol: name})
+		if arg.isConstant {
+			return "0x" + strconv.FormatUint(uint64(arg.constant), 16)
+		} else if arg.isSymbol {
+			return arg.symbol
+		}
+		return name
+	})
+	return &res
+}
+
+// mapValueSymbols applies a function to the symbols in the values of a data directive, adding
+// the offset of a value if its symbol is replaced with a constant.
+func mapValueSymbols(dir *TokenizedDirective, f func(*ArgToken) *ArgToken) *TokenizedDirective {
+	res := *dir
+	res.Values = make([]DirectiveValue, len(dir.Values))
+	for i, value := range dir.Values {
+		if value.Symbol != "" {
+			arg := f(&ArgToken{isSymbol: true, symbol: value.Symbol})
+			if arg.isConstant {
+				value.Constant += arg.constant
+				value.Symbol = ""
+			} else if arg.isSymbol {
+				value.Symbol = arg.symbol
+			}
+		}
+		res.Values[i] = value
+	}
+	return &res
+}
@@ -0,0 +1,49 @@
+package mips32
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// AssembleAll assembles several source files with the same options, as Assemble would.
+// The files are tokenized and parsed concurrently, using up to one goroutine per CPU.
+//
+// The executables are returned in the same order as the sources, with a nil entry for each file
+// that failed to assemble.
+// If any file failed, the error is for the first such file in the list (prefixed with its index
+// in sources), so the result does not depend on the order in which the goroutines finish.
+func AssembleAll(sources []string, opts *AssembleOptions) ([]*Executable, error) {
+	results := make([]*Executable, len(sources))
+	errs := make([]error, len(sources))
+
+	numWorkers := runtime.GOMAXPROCS(0)
+	if numWorkers > len(sources) {
+		numWorkers = len(sources)
+	}
+	indices := make(chan int, len(sources))
+	for i := range sources {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				results[idx], errs[idx] = Assemble(sources[idx], opts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return results, errors.New("source " + strconv.Itoa(i) + ": " + err.Error())
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,44 @@
+package mips32
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestAssembleAll(t *testing.T) {
+	var sources []string
+	for i := 0; i < 20; i++ {
+		sources = append(sources, ".text 0x1000\nADDIU $t0, $0, "+strconv.Itoa(i)+"\nloop:\n"+
+			"BNE $t0, $0, loop\nADDIU $t0, $t0, -1")
+	}
+	results, err := AssembleAll(sources, &AssembleOptions{BaseAddress: 0x400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(sources) {
+		t.Fatalf("expected %d results but got %d", len(sources), len(results))
+	}
+	for i, source := range sources {
+		expected, err := Assemble(source, &AssembleOptions{BaseAddress: 0x400})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results[i].Segments, expected.Segments) ||
+			!reflect.DeepEqual(results[i].Symbols, expected.Symbols) {
+			t.Errorf("source %d: unexpected executable", i)
+		}
+	}
+}
+
+func TestAssembleAllErrors(t *testing.T) {
+	sources := []string{"NOP", "NOP\nFOO $t0", "NOP", "ADDU $t0"}
+	results, err := AssembleAll(sources, nil)
+	expected := "source 1: line 2: unknown instruction: FOO"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q but got %v", expected, err)
+	}
+	if results[0] == nil || results[1] != nil || results[2] == nil || results[3] != nil {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
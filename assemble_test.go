@@ -0,0 +1,255 @@
+package mips32
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAssembleOptions(t *testing.T) {
+	source := `Loop:
+ADDIU $t0, $t0, STEP
+BNE $t0, $t1, loop
+MOVN $t2, $t3, $t4`
+	exc, err := Assemble(source, &AssembleOptions{
+		Dialect:                DialectNoDelaySlots,
+		BaseAddress:            0x400000,
+		CaseInsensitiveSymbols: true,
+		Constants:              map[string]uint32{"STEP": 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc.Symbols["Loop"] != 0x400000 || len(exc.Symbols) != 1 {
+		t.Error("unexpected symbols:", exc.Symbols)
+	}
+	if inst := exc.Get(0x400000); inst.SignedConstant16 != 4 {
+		t.Error("constant was not substituted")
+	}
+	if inst := exc.Get(0x400004); inst.Name != "BNE" || inst.CodePointer.Symbol != "Loop" {
+		t.Error("unexpected branch:", inst)
+	}
+	if inst := exc.Get(0x400008); inst.Name != "NOP" || exc.LineNumbers[0x400008] != 3 {
+		t.Error("expected a NOP in the delay slot")
+	}
+
+	exc, err = Assemble("ADDIU $t0, $0, %lo(data) + STEP\nDATA:\nNOP", &AssembleOptions{
+		BaseAddress:            0x100,
+		CaseInsensitiveSymbols: true,
+		Constants:              map[string]uint32{"STEP": 4},
+		Strict:                 true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst := exc.Get(0x100); inst.SignedConstant16 != 0x108 {
+		t.Error("unexpected expression value:", inst)
+	}
+
+	step := map[string]uint32{"STEP": 1}
+	failures := []*AssembleOptions{
+		nil,
+		{Constants: step, Revision: RevisionMIPS1},
+		{Constants: step, Strict: true},
+		{BaseAddress: 2},
+	}
+	expected := []string{
+		"line 2: bad instruction usage for ADDIU",
+		"line 4: MOVN is not available in MIPS I",
+		"line 2: unused label: Loop",
+		"misaligned base address",
+	}
+	for i, opts := range failures {
+		if _, err := Assemble(source, opts); err == nil || err.Error() != expected[i] {
+			t.Errorf("options %d: expected error %q but got %v", i, expected[i], err)
+		}
+	}
+}
+
+func TestAssembleOptionsKeepSpans(t *testing.T) {
+	lines, err := TokenizeSource("foo:\n  ADDIU $t0, $t0, STEP\n  J FOO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped := foldSymbolCase(substituteConstants(lines, map[string]uint32{"STEP": 4}))
+	for i, line := range mapped {
+		if inst := line.Instruction; inst != nil {
+			original := lines[i].Instruction
+			if inst.NameSpan != original.NameSpan ||
+				!reflect.DeepEqual(inst.ArgumentSpans, original.ArgumentSpans) {
+				t.Errorf("line %d: expected spans %v %v but got %v %v", i, original.NameSpan,
+					original.ArgumentSpans, inst.NameSpan, inst.ArgumentSpans)
+			}
+		}
+	}
+	if arg := mapped[1].Instruction.Arguments[2]; !arg.isConstant || arg.constant != 4 {
+		t.Errorf("unexpected lines: %v", mapped)
+	} else if arg := mapped[2].Instruction.Arguments[0]; arg.symbol != "foo" {
+		t.Errorf("unexpected lines: %v", mapped)
+	}
+}
+
+func TestAssembleOptimize(t *testing.T) {
+	source := `ADDU $t0, $t0, $0
+LUI $t1, 0
+ORI $t1, $t1, 5`
+	var report []*Optimization
+	exc, err := Assemble(source, &AssembleOptions{
+		Optimize: true,
+		OnOptimize: func(opt *Optimization) {
+			report = append(report, opt)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc.End() != 4 || exc.Get(0).Name != "ORI" {
+		t.Errorf("unexpected instructions: %v", exc.Segments)
+	}
+	if len(report) != 2 {
+		t.Errorf("expected two optimizations but got %v", report)
+	}
+}
+
+func TestAssembleWordSymbols(t *testing.T) {
+	source := `ORI $a0, $0, handlers
+LW $t0, 4($a0)
+JR $t0
+NOP
+Handlers:
+.word ONREAD
+.word onWrite+4
+.word SIZE
+onRead:
+NOP
+onWrite:
+NOP`
+	exc, err := Assemble(source, &AssembleOptions{
+		CaseInsensitiveSymbols: true,
+		Constants:              map[string]uint32{"SIZE": 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []uint32{0x1c, 0x24, 8} {
+		addr := 0x10 + uint32(i*4)
+		if word, _ := exc.Get(addr).Encode(addr, nil); word != expected {
+			t.Errorf("word %d: expected %08x but got %08x", i, expected, word)
+		}
+	}
+
+	// A label which is only used by a ".word" directive is not unused.
+	source = ".text 0x1000\nORI $a0, $0, table\nJR $ra\nNOP\ntable:\n.word target\ntarget:\nNOP"
+	if _, err := Assemble(source, &AssembleOptions{Strict: true}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAssembleLittleEndianData(t *testing.T) {
+	source := ".byte 1, 2, 3, 4, 5\n.half 0x1234, 0x5678, 0x9abc\n.asciiz \"hey\""
+	expectedBytes := map[bool][]byte{
+		false: {1, 2, 3, 4, 5, 0, 0, 0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0, 0,
+			'h', 'e', 'y', 0},
+		true: {1, 2, 3, 4, 5, 0, 0, 0, 0x34, 0x12, 0x78, 0x56, 0xbc, 0x9a, 0, 0,
+			'h', 'e', 'y', 0},
+	}
+	for little, expected := range expectedBytes {
+		exc, err := Assemble(source, &AssembleOptions{LittleEndian: little})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := exc.EncodeBytes(little)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(chunks[0], expected) {
+			t.Errorf("little endian %v: unexpected bytes %v", little, chunks[0])
+		}
+	}
+}
+
+func TestAssembleDataLoads(t *testing.T) {
+	source := `ORI $t0, $0, DATA
+LBU $t1, 2($t0)
+LBU $t2, 7($t0)
+LB $t3, 9($t0)
+.text 0x100
+DATA:
+.byte 1, 2, 3, 4
+.half 0x1234, 0x5678
+.byte 0x80, 0xff`
+	for _, little := range []bool{false, true} {
+		exc, err := Assemble(source, &AssembleOptions{LittleEndian: little})
+		if err != nil {
+			t.Fatal(err)
+		}
+		emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, LittleEndian: little}
+		if err := exc.LoadInto(emu.Memory, little); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			if err := emu.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Bytes are in order with either byte order, but a half keeps its value, so the byte
+		// at its second address depends on the byte order.
+		secondByte := uint32(0x78)
+		if little {
+			secondByte = 0x56
+		}
+		regs := emu.RegisterFile
+		if regs[9] != 3 || regs[10] != secondByte || regs[11] != 0xffffffff {
+			t.Errorf("little endian %v: unexpected registers %x", little, regs[9:12])
+		}
+	}
+}
+
+func TestAssembleWithWarnings(t *testing.T) {
+	source := `ADDIU $t0, $0, 1
+t0:
+LUI $at, 1
+SW $t0, 4($1)
+BEQ $0, $0, 6
+NOP
+J 0x102
+NOP`
+	exc, warnings, err := AssembleWithWarnings(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc.Get(4).Name != "LUI" {
+		t.Error("unexpected executable")
+	}
+	var messages []string
+	for _, warning := range warnings {
+		messages = append(messages, warning.String())
+	}
+	expected := []string{
+		"line 2: symbol t0 has the same name as a register",
+		"line 3: $at is reserved for the assembler",
+		"line 4: $at is reserved for the assembler",
+		"line 5: branch offset 6 is not a multiple of 4; its low bits are ignored",
+		"line 7: jump target 0x00000102 is not a multiple of 4; its low bits are ignored",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected %q but got %q", expected, messages)
+	}
+
+	_, _, err = AssembleWithWarnings(source, &AssembleOptions{Strict: true})
+	if err == nil || err.Error() != "line 2: symbol t0 has the same name as a register" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func BenchmarkAssembleLarge(b *testing.B) {
+	source := largeBenchmarkSource()
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Assemble(source, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
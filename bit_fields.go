@@ -0,0 +1,92 @@
+package mips32
+
+import "strings"
+
+// An EncodingFormat is one of the three layouts of MIPS instruction words.
+type EncodingFormat int
+
+const (
+	RFormat EncodingFormat = iota
+	IFormat
+	JFormat
+)
+
+func (e EncodingFormat) String() string {
+	switch e {
+	case RFormat:
+		return "R"
+	case IFormat:
+		return "I"
+	default:
+		return "J"
+	}
+}
+
+// A BitField is a named range of bits in an instruction word.
+type BitField struct {
+	Name string
+
+	// High and Low are the indices of the most and least significant bits in the field.
+	High int
+	Low  int
+
+	Value uint32
+}
+
+// Width returns the number of bits in the field.
+func (b BitField) Width() int {
+	return b.High - b.Low + 1
+}
+
+// Binary returns the bits of the field as a string of 0s and 1s.
+func (b BitField) Binary() string {
+	var res strings.Builder
+	for i := b.Width() - 1; i >= 0; i-- {
+		if b.Value&(1<<uint(i)) != 0 {
+			res.WriteByte('1')
+		} else {
+			res.WriteByte('0')
+		}
+	}
+	return res.String()
+}
+
+// InstructionFields splits an instruction word into the fields of its format, from the most
+// significant field to the least significant one.
+//
+// R-format words have the fields opcode, rs, rt, rd, shamt, and funct.
+// I-format words have the fields opcode, rs, rt, and immediate.
+// J-format words have the fields opcode and target.
+func InstructionFields(word uint32) (EncodingFormat, []BitField) {
+	field := func(name string, high, low int) BitField {
+		return BitField{
+			Name:  name,
+			High:  high,
+			Low:   low,
+			Value: (word >> uint(low)) & (1<<uint(high-low+1) - 1),
+		}
+	}
+	opcode := field("opcode", 31, 26)
+	switch opcode.Value {
+	case 0:
+		return RFormat, []BitField{opcode, field("rs", 25, 21), field("rt", 20, 16),
+			field("rd", 15, 11), field("shamt", 10, 6), field("funct", 5, 0)}
+	case 2, 3:
+		return JFormat, []BitField{opcode, field("target", 25, 0)}
+	default:
+		return IFormat, []BitField{opcode, field("rs", 25, 21), field("rt", 20, 16),
+			field("immediate", 15, 0)}
+	}
+}
+
+// Fields encodes the instruction and splits it into bit fields, like InstructionFields.
+// The arguments are the same as for Encode.
+func (inst *Instruction) Fields(instAddr uint32, symbols map[string]uint32) (EncodingFormat,
+	[]BitField, error) {
+	word, err := inst.Encode(instAddr, symbols)
+	if err != nil {
+		return 0, nil, err
+	}
+	format, fields := InstructionFields(word)
+	return format, fields, nil
+}
@@ -0,0 +1,61 @@
+package mips32
+
+import "testing"
+
+func TestInstructionFields(t *testing.T) {
+	type fieldValues map[string]uint32
+	tests := []struct {
+		source string
+		format EncodingFormat
+		values fieldValues
+	}{
+		{"ADDU $t0, $t1, $t2", RFormat,
+			fieldValues{"opcode": 0, "rs": 9, "rt": 10, "rd": 8, "shamt": 0, "funct": 0x21}},
+		{"SLL $t0, $t1, 3", RFormat,
+			fieldValues{"opcode": 0, "rs": 0, "rt": 9, "rd": 8, "shamt": 3, "funct": 0}},
+		{"ADDIU $sp, $sp, -8", IFormat,
+			fieldValues{"opcode": 9, "rs": 29, "rt": 29, "immediate": 0xfff8}},
+		{"JAL 0x40", JFormat, fieldValues{"opcode": 3, "target": 0x10}},
+	}
+	for _, test := range tests {
+		lines, err := TokenizeSource(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exc, err := ParseExecutable(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		format, fields, err := exc.Get(0).Fields(0, exc.Symbols)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != test.format {
+			t.Errorf("%s: expected format %s but got %s", test.source, test.format, format)
+		}
+		if len(fields) != len(test.values) {
+			t.Errorf("%s: unexpected fields %v", test.source, fields)
+			continue
+		}
+		nextBit := 31
+		for _, field := range fields {
+			if field.High != nextBit {
+				t.Errorf("%s: field %s starts at bit %d", test.source, field.Name, field.High)
+			}
+			nextBit = field.Low - 1
+			if value, ok := test.values[field.Name]; !ok || value != field.Value {
+				t.Errorf("%s: unexpected %s field %d", test.source, field.Name, field.Value)
+			}
+		}
+		if nextBit != -1 {
+			t.Errorf("%s: fields do not cover the word", test.source)
+		}
+	}
+}
+
+func TestBitFieldBinary(t *testing.T) {
+	field := BitField{Name: "rs", High: 25, Low: 21, Value: 9}
+	if field.Width() != 5 || field.Binary() != "01001" {
+		t.Errorf("unexpected width %d or binary %s", field.Width(), field.Binary())
+	}
+}
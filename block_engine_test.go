@@ -0,0 +1,187 @@
+package mips32
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBlockEngineMatchesStep(t *testing.T) {
+	for n := 1; n < 40; n++ {
+		slow := steppingTestEmulator(t)
+		fast := steppingTestEmulator(t)
+		engine := NewBlockEngine(fast)
+		for !slow.Done() {
+			for i := 0; i < n && !slow.Done(); i++ {
+				if err := slow.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := engine.StepN(n); err != nil {
+				t.Fatal(err)
+			}
+			if slow.RegisterFile != fast.RegisterFile ||
+				slow.ProgramCounter != fast.ProgramCounter || slow.JumpNext != fast.JumpNext {
+				t.Fatalf("n=%d: state differs at pc=%d", n, slow.ProgramCounter)
+			}
+		}
+		if !fast.Done() || fast.RegisterFile[16] != 6 {
+			t.Errorf("n=%d: unexpected final state", n)
+		}
+	}
+}
+
+func TestBlockEngineCompiledOps(t *testing.T) {
+	source := `ORI $s0, $0, 0x1ffe
+ADDIU $t0, $0, -3
+LOOP:
+SW $t0, 2($s0)
+LW $t1, 2($s0)
+SB $t0, 7($s0)
+LB $t2, 7($s0)
+LBU $t3, 7($s0)
+SW $t0, 3($s0)
+LW $t4, 3($s0)
+BGEZ $t0, POSITIVE
+NOP
+JAL FUNC
+ADDU $s1, $s1, $t1
+J NEXT
+NOP
+POSITIVE:
+BLEZ $t0, ZERO
+NOP
+BGTZ $t0, NEXT
+ADDU $s2, $s2, $t0
+ZERO:
+ORI $t5, $0, NEXT
+JALR $s3, $t5
+NOP
+NEXT:
+BLTZ $t0, LOOP
+ADDIU $t0, $t0, 1
+ADDIU $t6, $t6, 1
+ORI $t7, $0, 3
+BNE $t6, $t7, LOOP
+NOP
+BEQ $0, $0, END
+NOP
+FUNC:
+ADDU $s4, $s4, $t2
+JR $ra
+ADDU $s5, $s5, $t3
+END:`
+	for _, little := range []bool{false, true} {
+		for _, backend := range []bool{false, true} {
+			newEmulator := func() *Emulator {
+				exc, err := Assemble(source, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, LittleEndian: little}
+				if backend {
+					emu.Memory = NewFlatMemory(0x1000, 0x2000)
+				}
+				return emu
+			}
+			slow := newEmulator()
+			fast := newEmulator()
+			slowSteps, slowErr := slow.StepN(1000)
+			fastSteps, fastErr := NewBlockEngine(fast).StepN(1000)
+			if slowErr != nil || fastErr != nil {
+				t.Fatal(slowErr, fastErr)
+			}
+			if !slow.Done() || slowSteps != fastSteps || slow.RegisterFile != fast.RegisterFile ||
+				slow.ProgramCounter != fast.ProgramCounter {
+				t.Errorf("little=%v backend=%v: states differ", little, backend)
+			}
+			var slowMem, fastMem [8]byte
+			ReadBytes(slow.Memory, 0x2000, slowMem[:])
+			ReadBytes(fast.Memory, 0x2000, fastMem[:])
+			if slowMem != fastMem {
+				t.Errorf("little=%v backend=%v: memory differs", little, backend)
+			}
+		}
+	}
+}
+
+func TestBlockEngineErrors(t *testing.T) {
+	sources := []string{
+		"J END\nJ END\nEND:",
+		"BEQ $0, $0, END\nBNE $0, $t0, END\nEND:",
+		"ORI $t0, $0, 6\nJR $t0\nNOP",
+		"ORI $t0, $0, 0x102\nLW $t1, 0($t0)",
+	}
+	for _, source := range sources {
+		exc, err := Assemble(source, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slow := &Emulator{Memory: NewLazyMemory(), Executable: exc, ForceMemAlignment: true}
+		fast := &Emulator{Memory: NewLazyMemory(), Executable: exc, ForceMemAlignment: true}
+		slowSteps, slowErr := slow.StepN(10)
+		fastSteps, fastErr := NewBlockEngine(fast).StepN(10)
+		if slowErr == nil || fastErr == nil || slowErr.Error() != fastErr.Error() ||
+			slowSteps != fastSteps {
+			t.Errorf("%q: expected %d, %v but got %d, %v", source, slowSteps, slowErr,
+				fastSteps, fastErr)
+		}
+	}
+}
+
+func TestBlockEngineSyscall(t *testing.T) {
+	tokens, err := TokenizeSource(`ADDIU $t0, $0, 3
+LOOP:
+ADDIU $t0, $t0, -1
+BNE $t0, $0, LOOP
+NOP
+ADDIU $v0, $0, 10
+SYSCALL
+ADDIU $t1, $0, 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, Syscalls: &SPIMSyscalls{}}
+	if err := NewBlockEngine(emu).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !emu.Halted || emu.RegisterFile[9] != 0 {
+		t.Error("program did not stop at the exit syscall")
+	}
+}
+
+func TestBlockEngineModifiedExecutable(t *testing.T) {
+	tokens, err := TokenizeSource("ADDIU $t0, $t0, 1\nADDIU $t1, $t1, 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	engine := NewBlockEngine(emu)
+	if _, err := engine.StepN(1); err != nil {
+		t.Fatal(err)
+	}
+	exc.Segments[0] = []Instruction{
+		{Name: "NOP"},
+		{Name: "ORI", Registers: []int{9, 0}, UnsignedConstant16: 7},
+	}
+	if _, err := engine.StepN(1); err != nil {
+		t.Fatal(err)
+	} else if emu.RegisterFile[9] != 7 {
+		t.Error("expected replaced segment to run, but t1 is", emu.RegisterFile[9])
+	}
+}
+
+func BenchmarkBlockEngine(b *testing.B) {
+	engine := NewBlockEngine(benchmarkEmulator(b))
+	b.ResetTimer()
+	if _, err := engine.StepN(b.N); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package mips32
+
+import "strconv"
+
+// Branch offsets are relative to the delay slot, and are limited by their 16-bit word offsets.
+const (
+	minBranchOffset = -0x20000
+	maxBranchOffset = 0x1fffc
+)
+
+// invertedBranches maps each branch to the branch with the opposite condition.
+var invertedBranches = map[string]string{
+	"BEQ":  "BNE",
+	"BNE":  "BEQ",
+	"BGEZ": "BLTZ",
+	"BLTZ": "BGEZ",
+	"BGTZ": "BLEZ",
+	"BLEZ": "BGTZ",
+}
+
+// checkControlRanges makes sure that every branch and jump can reach its target.
+// Targets which are undefined symbols are left for Encode to report.
+func (e *Executable) checkControlRanges() error {
+	for _, segment := range e.sortedSegmentAddresses() {
+		for i := range e.Segments[segment] {
+			addr := segment + uint32(i*4)
+			if msg := controlRangeProblem(&e.Segments[segment][i], addr, e.Symbols); msg != "" {
+				return lineError(e.LineNumbers[addr], msg)
+			}
+		}
+	}
+	return nil
+}
+
+// controlRangeProblem describes why a branch or jump cannot reach its target, along with a
+// suggested fix.
+// It returns "" if the target is reachable, or if the instruction is not a branch or jump.
+func controlRangeProblem(inst *Instruction, addr uint32, symbols map[string]uint32) string {
+	ptr := inst.CodePointer
+	target := ptr.Constant
+	description := eightDigitHex(ptr.Constant)
+	if ptr.IsSymbol {
+		symAddr, ok := symbols[ptr.Symbol]
+		if !ok {
+			return ""
+		}
+		target = symAddr
+		description = ptr.Symbol + " (" + eightDigitHex(symAddr) + ")"
+	}
+
+	switch inst.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+		// The tokenizer only accepts constant offsets which fit.
+		if ptr.Absolute || !ptr.IsSymbol {
+			return ""
+		}
+		offset := int64(int32(target - (addr + 4)))
+		if offset >= minBranchOffset && offset <= maxBranchOffset {
+			return ""
+		}
+		return "branch to " + description + " is out of range: it is " +
+			strconv.FormatInt(offset, 10) + " bytes from the delay slot, but branches can only " +
+			"reach " + strconv.Itoa(minBranchOffset) + " to " + strconv.Itoa(maxBranchOffset) +
+			" bytes; use " + invertedBranches[inst.Name] + " to branch around \"J " +
+			ptr.Symbol + "\" instead"
+	case "J", "JAL":
+		if !ptr.Absolute {
+			return ""
+		}
+		region := (addr + 4) & 0xf0000000
+		if target&0xf0000000 == region {
+			return ""
+		}
+		register := "JR"
+		if inst.Name == "JAL" {
+			register = "JALR"
+		}
+		return "jump to " + description + " is out of range: jumps can only reach the 256 MB " +
+			"region from " + eightDigitHex(region) + " to " + eightDigitHex(region|0x0fffffff) +
+			"; load the address into a register and use " + register + " instead"
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package mips32
+
+import "testing"
+
+func TestBranchRangeErrors(t *testing.T) {
+	sources := map[string]string{
+		"BEQ $t0, $t1, FAR\nNOP\n.text 0x20000\nFAR:\nNOP": "",
+		"BEQ $t0, $t1, FAR\nNOP\n.text 0x20008\nFAR:\nNOP": "line 1: branch to FAR " +
+			"(0x00020008) is out of range: it is 131076 bytes from the delay slot, but branches " +
+			"can only reach -131072 to 131068 bytes; use BNE to branch around \"J FAR\" instead",
+		"NOP\n.text 0x40000\nBGTZ $t0, BACK\n.text 0x10000\nBACK:\nNOP": "line 3: branch to BACK " +
+			"(0x00010000) is out of range: it is -196612 bytes from the delay slot, but branches " +
+			"can only reach -131072 to 131068 bytes; use BLEZ to branch around \"J BACK\" instead",
+		"JAL FAR\nNOP\n.text 0x10000000\nFAR:\nNOP": "line 1: jump to FAR (0x10000000) is " +
+			"out of range: jumps can only reach the 256 MB region from 0x00000000 to 0x0fffffff; " +
+			"load the address into a register and use JALR instead",
+		"J 0x20000000": "line 1: jump to 0x20000000 is out of range: jumps can only reach the " +
+			"256 MB region from 0x00000000 to 0x0fffffff; load the address into a register and " +
+			"use JR instead",
+	}
+	for source, expected := range sources {
+		lines, err := TokenizeSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ParseExecutable(lines)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", source, err)
+			}
+		} else if err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
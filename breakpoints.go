@@ -0,0 +1,80 @@
+package mips32
+
+import "sort"
+
+// Breakpoints is a set of addresses at which a debugger should pause.
+//
+// The zero value is an empty set.
+type Breakpoints struct {
+	addrs map[uint32]bool
+}
+
+// Set adds a breakpoint at an address.
+func (b *Breakpoints) Set(addr uint32) {
+	if b.addrs == nil {
+		b.addrs = map[uint32]bool{}
+	}
+	b.addrs[addr] = true
+}
+
+// Clear removes the breakpoint at an address, if there is one.
+func (b *Breakpoints) Clear(addr uint32) {
+	delete(b.addrs, addr)
+}
+
+// Toggle adds a breakpoint if there is not one already, or removes it if there is.
+// It returns true if the address now has a breakpoint.
+func (b *Breakpoints) Toggle(addr uint32) bool {
+	if b.Has(addr) {
+		b.Clear(addr)
+		return false
+	}
+	b.Set(addr)
+	return true
+}
+
+// Has checks if there is a breakpoint at an address.
+func (b *Breakpoints) Has(addr uint32) bool {
+	return b.addrs[addr]
+}
+
+// List returns the addresses of all the breakpoints in ascending order.
+func (b *Breakpoints) List() []uint32 {
+	l := make(uint32List, 0, len(b.addrs))
+	for addr := range b.addrs {
+		l = append(l, addr)
+	}
+	sort.Sort(l)
+	return l
+}
+
+// Relocate moves the breakpoints from one executable to a new version of it.
+//
+// Each breakpoint is remembered relative to the closest symbol before it in oldExc.
+// If newExc has a symbol by the same name, the breakpoint moves by the same amount as the symbol.
+// Otherwise, the breakpoint stays at the same address.
+func (b *Breakpoints) Relocate(oldExc, newExc *Executable) {
+	pairs := oldExc.sortedSymbolAddrPairs()
+	newAddrs := map[uint32]bool{}
+	for addr := range b.addrs {
+		idx := sort.Search(len(pairs), func(i int) bool {
+			return pairs[i].Address > addr
+		}) - 1
+		if idx >= 0 {
+			// Use the first symbol in name order when several share an address.
+			symAddr := pairs[idx].Address
+			symbol := pairs[idx].Symbol
+			for i := idx - 1; i >= 0 && pairs[i].Address == symAddr; i-- {
+				if pairs[i].Symbol < symbol {
+					symbol = pairs[i].Symbol
+				}
+			}
+			if newSymAddr, ok := newExc.Symbols[symbol]; ok {
+				newAddrs[newSymAddr+(addr-symAddr)] = true
+				continue
+			}
+		}
+		newAddrs[addr] = true
+	}
+	b.addrs = newAddrs
+}
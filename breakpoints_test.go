@@ -0,0 +1,38 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBreakpoints(t *testing.T) {
+	var b Breakpoints
+	if b.Has(4) || len(b.List()) != 0 {
+		t.Fatal("zero value should be empty")
+	}
+	b.Set(8)
+	b.Set(4)
+	if !b.Toggle(12) || b.Toggle(8) {
+		t.Error("unexpected toggle result")
+	}
+	if !reflect.DeepEqual(b.List(), []uint32{4, 12}) {
+		t.Error("unexpected list:", b.List())
+	}
+	b.Clear(4)
+	if b.Has(4) || !b.Has(12) {
+		t.Error("unexpected breakpoints:", b.List())
+	}
+}
+
+func TestBreakpointsRelocate(t *testing.T) {
+	oldExc := &Executable{Symbols: map[string]uint32{"A": 0, "B": 0x10, "C": 0x20}}
+	newExc := &Executable{Symbols: map[string]uint32{"A": 0, "B": 0x18}}
+	var b Breakpoints
+	for _, addr := range []uint32{0x4, 0x14, 0x24} {
+		b.Set(addr)
+	}
+	b.Relocate(oldExc, newExc)
+	if !reflect.DeepEqual(b.List(), []uint32{0x4, 0x1c, 0x24}) {
+		t.Error("unexpected breakpoints:", b.List())
+	}
+}
@@ -0,0 +1,140 @@
+package mips32
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A Program builds an executable from Go code, without going through assembly source.
+//
+// Each method adds one statement and returns the Program, so that calls can be chained:
+//
+//	exc, err := NewProgram().Text(0x1000).Label("loop").
+//		Inst("ADDIU", "$t0", "$t0", 1).
+//		Inst("BNE", "$t0", "$t1", "loop").
+//		Inst("NOP").
+//		Build()
+//
+// Statements are numbered from 1 in the order they are added, and these numbers are used in
+// place of line numbers in errors and in the LineNumbers of the executable.
+// Once a statement fails, later statements are ignored and Build returns the first error.
+type Program struct {
+	lines []TokenizedLine
+	err   error
+}
+
+// NewProgram creates an empty Program.
+func NewProgram() *Program {
+	return &Program{}
+}
+
+// Text starts a new segment at an address, like a ".text" directive.
+func (p *Program) Text(addr uint32) *Program {
+	return p.add(TokenizedLine{Directive: &TokenizedDirective{Name: "text", Constant: addr}})
+}
+
+// Section starts or continues a section, like a ".section" directive.
+// Sections are only allowed when the program is built with BuildLayout.
+func (p *Program) Section(name string) *Program {
+	if !isSymbolText(name) || name == "" {
+		return p.fail(errors.New("invalid section name: " + name))
+	}
+	return p.add(TokenizedLine{Directive: &TokenizedDirective{Name: "section", Symbol: name}})
+}
+
+// Label declares a symbol at the address of the next instruction.
+func (p *Program) Label(name string) *Program {
+	if !isSymbolText(name) || name == "" {
+		return p.fail(errors.New("invalid symbol name: " + name))
+	}
+	return p.add(TokenizedLine{SymbolMarker: &name})
+}
+
+// Word adds a raw word, like a ".word" directive.
+func (p *Program) Word(word uint32) *Program {
+	return p.add(TokenizedLine{Directive: &TokenizedDirective{
+		Name:   "word",
+		Values: []DirectiveValue{{Constant: word}},
+	}})
+}
+
+// Inst adds an instruction.
+//
+// Each argument is either a string, which is parsed like an operand in assembly source (e.g.
+// "$t0", "-4($sp)", or "loop"), or an integer, which is used as a constant.
+func (p *Program) Inst(name string, args ...interface{}) *Program {
+	inst := &TokenizedInstruction{
+		Name:      strings.ToUpper(name),
+		Arguments: make([]*ArgToken, len(args)),
+	}
+	for i, arg := range args {
+		token, err := builderArgToken(arg)
+		if err != nil {
+			return p.fail(errors.New("operand " + strconv.Itoa(i+1) + ": " + err.Error()))
+		}
+		inst.Arguments[i] = token
+	}
+	if _, err := parseInstructionSymbols(inst, anySymbolValue); err != nil {
+		return p.fail(err)
+	}
+	return p.add(TokenizedLine{Instruction: inst})
+}
+
+// Lines returns the statements added so far as tokenized lines.
+// This can be used to render the program as assembly source.
+func (p *Program) Lines() []TokenizedLine {
+	return append([]TokenizedLine{}, p.lines...)
+}
+
+// Err returns the first error encountered while adding statements, if there was one.
+func (p *Program) Err() error {
+	return p.err
+}
+
+// Build creates an executable from the program, as ParseExecutable would.
+func (p *Program) Build() (*Executable, error) {
+	return p.BuildLayout(nil)
+}
+
+// BuildLayout is like Build, but it places sections according to a layout, as
+// ParseExecutableLayout would.
+func (p *Program) BuildLayout(layout *Layout) (*Executable, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return ParseExecutableLayout(p.lines, layout)
+}
+
+func (p *Program) add(line TokenizedLine) *Program {
+	if p.err == nil {
+		line.LineNumber = len(p.lines) + 1
+		p.lines = append(p.lines, line)
+	}
+	return p
+}
+
+func (p *Program) fail(err error) *Program {
+	if p.err == nil {
+		p.err = lineError(len(p.lines)+1, err.Error())
+	}
+	return p
+}
+
+func builderArgToken(arg interface{}) (*ArgToken, error) {
+	switch arg := arg.(type) {
+	case string:
+		return ParseArgToken(arg)
+	case int:
+		return &ArgToken{isConstant: true, constant: uint32(arg)}, nil
+	case int16:
+		return &ArgToken{isConstant: true, constant: uint32(arg)}, nil
+	case int32:
+		return &ArgToken{isConstant: true, constant: uint32(arg)}, nil
+	case uint16:
+		return &ArgToken{isConstant: true, constant: uint32(arg)}, nil
+	case uint32:
+		return &ArgToken{isConstant: true, constant: arg}, nil
+	}
+	return nil, errors.New("unsupported operand type")
+}
@@ -0,0 +1,66 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProgramBuild(t *testing.T) {
+	exc, err := NewProgram().Text(0x1000).
+		Inst("addiu", "$t1", "$0", 3).
+		Label("loop").
+		Inst("ADDIU", "$t0", "$t0", int16(-1)).
+		Inst("SW", "$t0", "-4($sp)").
+		Inst("BNE", "$t0", "$t1", "loop").
+		Inst("NOP").
+		Word(0x12345678).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := TokenizeSource(`.text 0x1000
+ADDIU $t1, $0, 3
+loop:
+ADDIU $t0, $t0, -1
+SW $t0, -4($sp)
+BNE $t0, $t1, loop
+NOP
+.word 0x12345678`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exc.Segments, expected.Segments) ||
+		!reflect.DeepEqual(exc.Symbols, expected.Symbols) {
+		t.Error("executable does not match the equivalent source")
+	}
+	if exc.LineNumbers[0x1004] != 4 {
+		t.Errorf("expected statement 4 but got %d", exc.LineNumbers[0x1004])
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	programs := []*Program{
+		NewProgram().Inst("NOP").Inst("ADDIU", "$t0", 1),
+		NewProgram().Inst("ADDIU", "$t0", "$t0", 1.5).Inst("NOP"),
+		NewProgram().Label("a b"),
+		NewProgram().Section("data"),
+		NewProgram().Label("x").Label("x").Inst("NOP"),
+	}
+	expected := []string{
+		"line 2: bad instruction usage for ADDIU",
+		"line 1: operand 3: unsupported operand type",
+		"line 1: invalid symbol name: a b",
+		"line 1: no layout for section: data",
+		"line 2: repeated symbol declaration: x",
+	}
+	for i, program := range programs {
+		if _, err := program.Build(); err == nil || err.Error() != expected[i] {
+			t.Errorf("program %d: expected error %q but got %v", i, expected[i], err)
+		}
+	}
+}
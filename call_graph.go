@@ -0,0 +1,227 @@
+package mips32
+
+import (
+	"sort"
+	"strings"
+)
+
+// A CallSite is a JAL or JALR instruction.
+type CallSite struct {
+	// Address is the address of the call instruction.
+	Address uint32 `json:"address"`
+
+	// Caller is the entry point of the function containing the call.
+	Caller uint32 `json:"caller"`
+
+	// Callee is the called address.
+	// It is only meaningful if Resolved is true.
+	Callee uint32 `json:"callee"`
+
+	// Resolved is false for JALR instructions whose target register could not be traced back
+	// to a constant.
+	Resolved bool `json:"resolved"`
+}
+
+// A CallGraph describes which functions in an executable call which other functions.
+//
+// Functions are identified by their entry points.
+// The entry points are the start of the first segment, the target of every resolved call, and
+// every symbol which code cannot fall through to (such as a symbol after a JR $ra).
+type CallGraph struct {
+	// Functions is the sorted list of entry points.
+	Functions []uint32 `json:"functions"`
+
+	// Sites lists every call, sorted by address.
+	Sites []CallSite `json:"sites"`
+}
+
+// BuildCallGraph finds the calls in an executable.
+//
+// JAL targets are always known.
+// A JALR target is known if its register is set to a constant (for example, with LUI and ORI)
+// earlier in the same basic block.
+func BuildCallGraph(e *Executable) *CallGraph {
+	res := &CallGraph{}
+	entries := map[uint32]bool{}
+	segments := e.sortedSegmentAddresses()
+	if len(segments) > 0 {
+		entries[segments[0]] = true
+	}
+
+	for _, addr := range e.Symbols {
+		if e.Get(addr) != nil {
+			if prev := e.Get(addr - 8); prev != nil && isUnconditionalJump(prev) {
+				entries[addr] = true
+			}
+		}
+	}
+
+	cfg := BuildCFG(e)
+	for _, block := range cfg.Blocks {
+		var known [32]bool
+		var values [32]uint32
+		known[0] = true
+		for addr := block.Start; addr < block.End; addr += 4 {
+			inst := e.Get(addr)
+			switch inst.Name {
+			case "JAL":
+				target, ok := e.controlTarget(inst, addr)
+				res.Sites = append(res.Sites, CallSite{Address: addr, Callee: target, Resolved: ok})
+			case "JALR":
+				reg := inst.Registers[len(inst.Registers)-1]
+				res.Sites = append(res.Sites, CallSite{
+					Address:  addr,
+					Callee:   values[reg],
+					Resolved: known[reg],
+				})
+			}
+			trackConstant(inst, &known, &values)
+		}
+	}
+
+	for _, site := range res.Sites {
+		if site.Resolved {
+			entries[site.Callee] = true
+		}
+	}
+	for entry := range entries {
+		res.Functions = append(res.Functions, entry)
+	}
+	sort.Sort(uint32List(res.Functions))
+	for i := range res.Sites {
+		res.Sites[i].Caller = res.FunctionContaining(res.Sites[i].Address)
+	}
+	return res
+}
+
+// FunctionContaining returns the entry point of the function containing an address, which is
+// the closest entry point at or before the address.
+// If there is no such entry point, the address itself is returned.
+func (c *CallGraph) FunctionContaining(addr uint32) uint32 {
+	idx := sort.Search(len(c.Functions), func(i int) bool {
+		return c.Functions[i] > addr
+	})
+	if idx == 0 {
+		return addr
+	}
+	return c.Functions[idx-1]
+}
+
+// Callees returns the sorted, de-duplicated entry points called by a function.
+// Unresolved calls are not included.
+func (c *CallGraph) Callees(function uint32) []uint32 {
+	var res []uint32
+	for _, site := range c.Sites {
+		if site.Caller == function && site.Resolved {
+			res = append(res, site.Callee)
+		}
+	}
+	return uniqueSortedUint32s(res)
+}
+
+// Callers returns the sorted, de-duplicated entry points of the functions which call a
+// function.
+func (c *CallGraph) Callers(function uint32) []uint32 {
+	var res []uint32
+	for _, site := range c.Sites {
+		if site.Resolved && site.Callee == function {
+			res = append(res, site.Caller)
+		}
+	}
+	return uniqueSortedUint32s(res)
+}
+
+// Reachable finds the functions which may be called, directly or indirectly, by the given
+// functions.
+// The result includes the given functions themselves.
+func (c *CallGraph) Reachable(roots ...uint32) map[uint32]bool {
+	res := map[uint32]bool{}
+	queue := append([]uint32{}, roots...)
+	for len(queue) > 0 {
+		function := queue[0]
+		queue = queue[1:]
+		if res[function] {
+			continue
+		}
+		res[function] = true
+		queue = append(queue, c.Callees(function)...)
+	}
+	return res
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+// Functions are labeled with their symbols when possible.
+// A function with unresolved calls has a dashed edge to a node labeled "?".
+func (c *CallGraph) DOT(e *Executable) string {
+	var res strings.Builder
+	res.WriteString("digraph calls {\n")
+	res.WriteString("  node [shape=box];\n")
+	for _, function := range c.Functions {
+		label := symbolAt(e, function)
+		if label == "" {
+			label = eightDigitHex(function)
+		}
+		res.WriteString("  \"" + eightDigitHex(function) + "\" [label=\"" + dotEscape(label) +
+			"\"];\n")
+	}
+	unresolved := map[uint32]bool{}
+	for _, function := range c.Functions {
+		for _, callee := range c.Callees(function) {
+			res.WriteString("  \"" + eightDigitHex(function) + "\" -> \"" +
+				eightDigitHex(callee) + "\";\n")
+		}
+	}
+	for _, site := range c.Sites {
+		if !site.Resolved && !unresolved[site.Caller] {
+			unresolved[site.Caller] = true
+			res.WriteString("  \"" + eightDigitHex(site.Caller) + "\" -> \"?\" [style=dashed];\n")
+		}
+	}
+	if len(unresolved) > 0 {
+		res.WriteString("  \"?\" [shape=plaintext];\n")
+	}
+	res.WriteString("}\n")
+	return res.String()
+}
+
+// trackConstant updates the registers which are known to hold constants after an instruction.
+func trackConstant(inst *Instruction, known *[32]bool, values *[32]uint32) {
+	reg, ok := destinationRegister(inst)
+	if !ok || reg == 0 {
+		return
+	}
+	var value uint32
+	isConstant := true
+	switch inst.Name {
+	case "LUI":
+		value = uint32(inst.UnsignedConstant16) << 16
+	case "ORI":
+		isConstant = known[inst.Registers[1]]
+		value = values[inst.Registers[1]] | uint32(inst.UnsignedConstant16)
+	case "ADDIU":
+		isConstant = known[inst.Registers[1]]
+		value = values[inst.Registers[1]] + uint32(int32(inst.SignedConstant16))
+	case "ADDU", "OR":
+		isConstant = known[inst.Registers[1]] && known[inst.Registers[2]]
+		if inst.Name == "ADDU" {
+			value = values[inst.Registers[1]] + values[inst.Registers[2]]
+		} else {
+			value = values[inst.Registers[1]] | values[inst.Registers[2]]
+		}
+	default:
+		isConstant = false
+	}
+	known[reg] = isConstant
+	values[reg] = value
+}
+
+func uniqueSortedUint32s(list []uint32) []uint32 {
+	sort.Sort(uint32List(list))
+	var res []uint32
+	for i, x := range list {
+		if i == 0 || x != list[i-1] {
+			res = append(res, x)
+		}
+	}
+	return res
+}
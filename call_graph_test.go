@@ -0,0 +1,76 @@
+package mips32
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	lines, err := TokenizeSource(`
+MAIN:
+JAL FOO
+NOP
+LUI $t0, 0
+ORI $t0, $t0, 0x20
+JALR $t0
+NOP
+JALR $t1
+NOP
+FOO:
+JAL BAR
+NOP
+JR $ra
+NOP
+BAR:
+JR $ra
+NOP
+UNUSED:
+JAL BAR
+NOP
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := BuildCallGraph(exc)
+
+	if !reflect.DeepEqual(graph.Functions, []uint32{0, 0x20, 0x30, 0x38}) {
+		t.Error("unexpected functions:", graph.Functions)
+	}
+	expectedSites := []CallSite{
+		{Address: 0x0, Caller: 0x0, Callee: 0x20, Resolved: true},
+		{Address: 0x10, Caller: 0x0, Callee: 0x20, Resolved: true},
+		{Address: 0x18, Caller: 0x0},
+		{Address: 0x20, Caller: 0x20, Callee: 0x30, Resolved: true},
+		{Address: 0x38, Caller: 0x38, Callee: 0x30, Resolved: true},
+	}
+	if !reflect.DeepEqual(graph.Sites, expectedSites) {
+		t.Error("unexpected sites:", graph.Sites)
+	}
+
+	if callees := graph.Callees(0); !reflect.DeepEqual(callees, []uint32{0x20}) {
+		t.Error("unexpected callees:", callees)
+	}
+	if callers := graph.Callers(0x30); !reflect.DeepEqual(callers, []uint32{0x20, 0x38}) {
+		t.Error("unexpected callers:", callers)
+	}
+	reachable := graph.Reachable(0x20)
+	if !reflect.DeepEqual(reachable, map[uint32]bool{0x20: true, 0x30: true}) {
+		t.Error("unexpected reachable set:", reachable)
+	}
+
+	dot := graph.DOT(exc)
+	for _, line := range []string{
+		`"0x00000000" [label="MAIN"];`,
+		`"0x00000000" -> "0x00000020";`,
+		`"0x00000000" -> "?" [style=dashed];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("DOT output is missing %s:\n%s", line, dot)
+		}
+	}
+}
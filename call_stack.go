@@ -0,0 +1,64 @@
+package mips32
+
+// A Frame is an entry in a CallStack.
+type Frame struct {
+	// Function is the address that was called.
+	Function uint32
+
+	// ReturnAddress is where execution will continue once the function returns.
+	ReturnAddress uint32
+
+	// StackPointer is the value of $sp at the time of the call.
+	StackPointer uint32
+}
+
+// A CallStack reconstructs the active function calls of a program as it runs.
+//
+// To use a CallStack, call Observe after each instruction is executed, typically from an
+// Emulator's AfterExecute hook.
+//
+// The zero value is an empty call stack.
+type CallStack struct {
+	// Frames lists the active calls, outermost first.
+	Frames []Frame
+}
+
+// Observe updates the call stack after an instruction has been executed at an address.
+//
+// JAL and JALR push a frame, and "JR $ra" pops frames up to the one being returned to.
+// As a fallback for code which does not return with "JR $ra", frames are also discarded once
+// $sp rises above its value at the time of the call, since that means the callee's stack has been
+// freed.
+// This heuristic compares stack pointers as signed numbers, so that a stack which starts at 0 and
+// grows downward behaves as expected.
+func (c *CallStack) Observe(e *Emulator, inst *Instruction, addr uint32) {
+	if inst != nil {
+		switch inst.Name {
+		case "JAL", "JALR":
+			c.Frames = append(c.Frames, Frame{
+				Function:      e.JumpTarget,
+				ReturnAddress: addr + 8,
+				StackPointer:  e.RegisterFile[29],
+			})
+			return
+		case "JR":
+			if inst.Registers[0] == 31 {
+				for i := len(c.Frames) - 1; i >= 0; i-- {
+					if c.Frames[i].ReturnAddress == e.JumpTarget {
+						c.Frames = c.Frames[:i]
+						break
+					}
+				}
+			}
+		}
+	}
+	sp := int32(e.RegisterFile[29])
+	for len(c.Frames) > 0 && sp > int32(c.Frames[len(c.Frames)-1].StackPointer) {
+		c.Frames = c.Frames[:len(c.Frames)-1]
+	}
+}
+
+// Reset removes all of the frames.
+func (c *CallStack) Reset() {
+	c.Frames = nil
+}
@@ -0,0 +1,64 @@
+package mips32
+
+import "testing"
+
+func TestCallStack(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	var stack CallStack
+	emu.AfterExecute = func(inst *Instruction, addr uint32) {
+		stack.Observe(emu, inst, addr)
+	}
+
+	fact := emu.Executable.Symbols["FACT"]
+	maxDepth := 0
+	checked := false
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if len(stack.Frames) > maxDepth {
+			maxDepth = len(stack.Frames)
+		}
+		if emu.ProgramCounter == emu.Executable.Symbols["DONE"] && len(stack.Frames) == 3 {
+			checked = true
+			expected := []Frame{
+				{Function: fact, ReturnAddress: 0x10, StackPointer: 0x1000},
+				{Function: fact, ReturnAddress: emu.Executable.Symbols["RECURSE"] + 20,
+					StackPointer: 0xff8},
+				{Function: fact, ReturnAddress: emu.Executable.Symbols["RECURSE"] + 20,
+					StackPointer: 0xff0},
+			}
+			for i, frame := range stack.Frames {
+				if frame != expected[i] {
+					t.Errorf("frame %d: expected %v but got %v", i, expected[i], frame)
+				}
+			}
+		}
+	}
+	if !checked {
+		t.Error("never reached DONE with three frames")
+	}
+	if maxDepth != 4 {
+		t.Error("unexpected maximum depth:", maxDepth)
+	}
+	if len(stack.Frames) != 0 {
+		t.Error("unexpected frames at end:", stack.Frames)
+	}
+}
+
+func TestCallStackStackPointer(t *testing.T) {
+	emu := &Emulator{Memory: NewLazyMemory()}
+	var stack CallStack
+	emu.RegisterFile[29] = 0x100
+	stack.Observe(emu, &Instruction{Name: "JAL"}, 0)
+	emu.RegisterFile[29] = 0xf0
+	stack.Observe(emu, &Instruction{Name: "NOP"}, 8)
+	if len(stack.Frames) != 1 {
+		t.Fatal("frame should still exist")
+	}
+	emu.RegisterFile[29] = 0x110
+	stack.Observe(emu, &Instruction{Name: "NOP"}, 12)
+	if len(stack.Frames) != 0 {
+		t.Error("frame should have been discarded")
+	}
+}
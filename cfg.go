@@ -0,0 +1,227 @@
+package mips32
+
+import (
+	"sort"
+	"strings"
+)
+
+// A BasicBlock is a run of instructions which always execute one after another.
+//
+// A block which ends with a branch or jump includes the instruction in its delay slot.
+type BasicBlock struct {
+	// Start is the address of the first instruction.
+	Start uint32 `json:"start"`
+
+	// End is the address after the last instruction.
+	End uint32 `json:"end"`
+
+	// Label is the symbol at the start of the block, or "" if there is none.
+	Label string `json:"label,omitempty"`
+
+	// Successors lists the starts of the blocks which may run after this one, not counting
+	// calls.
+	// A block which ends with a call (JAL or JALR) is followed by the block after the call.
+	Successors []uint32 `json:"successors"`
+
+	// Calls lists the starts of the blocks called with JAL at the end of this block.
+	Calls []uint32 `json:"calls,omitempty"`
+}
+
+// A ControlFlowGraph splits an executable into basic blocks, which are linked by the branches and
+// jumps between them.
+type ControlFlowGraph struct {
+	// Blocks are sorted by address.
+	Blocks []*BasicBlock `json:"blocks"`
+}
+
+// BuildCFG finds the basic blocks in an executable.
+//
+// Blocks start at the beginning of each segment, at symbols, at branch and jump targets, and
+// after the delay slot of each branch and jump.
+// The targets of JR and JALR cannot be known ahead of time, so they are not represented
+// (except that a JALR is assumed to return).
+func BuildCFG(e *Executable) *ControlFlowGraph {
+	leaders := map[uint32]bool{}
+	for _, segment := range e.sortedSegmentAddresses() {
+		leaders[segment] = true
+		for i := range e.Segments[segment] {
+			inst := &e.Segments[segment][i]
+			addr := segment + uint32(i*4)
+			if target, ok := e.controlTarget(inst, addr); ok && e.Get(target) != nil {
+				leaders[target] = true
+			}
+			if isControlInstruction(inst) && e.Get(addr+8) != nil {
+				leaders[addr+8] = true
+			}
+		}
+	}
+	for _, addr := range e.Symbols {
+		if e.Get(addr) != nil {
+			leaders[addr] = true
+		}
+	}
+
+	// A target in a delay slot would split a branch from its delay slot.
+	for _, segment := range e.sortedSegmentAddresses() {
+		for i := 1; i < len(e.Segments[segment]); i++ {
+			if isControlInstruction(&e.Segments[segment][i-1]) {
+				delete(leaders, segment+uint32(i*4))
+			}
+		}
+	}
+
+	res := &ControlFlowGraph{}
+	for _, segment := range e.sortedSegmentAddresses() {
+		end := segment + uint32(len(e.Segments[segment])*4)
+		var block *BasicBlock
+		for addr := segment; addr < end; addr += 4 {
+			if block == nil && !leaders[addr] {
+				// A branch's delay slot may hold another branch, whose own delay slot was
+				// removed from the leaders above.
+				leaders[addr] = true
+			}
+			if leaders[addr] {
+				if block != nil {
+					block.End = addr
+					block.Successors = []uint32{addr}
+				}
+				block = &BasicBlock{Start: addr, Label: symbolAt(e, addr)}
+				res.Blocks = append(res.Blocks, block)
+			}
+			inst := e.Get(addr)
+			if !isControlInstruction(inst) {
+				continue
+			}
+			block.End = addr + 8
+			if block.End > end {
+				block.End = end
+			}
+			fallthroughAddr := addr + 8
+			target, hasTarget := e.controlTarget(inst, addr)
+			hasTarget = hasTarget && leaders[target]
+			switch {
+			case inst.Name == "JAL" || inst.Name == "JALR":
+				if hasTarget {
+					block.Calls = []uint32{target}
+				}
+				block.Successors = []uint32{fallthroughAddr}
+			case inst.Name == "JR":
+				block.Successors = []uint32{}
+			case isUnconditionalJump(inst):
+				block.Successors = []uint32{}
+				if hasTarget {
+					block.Successors = append(block.Successors, target)
+				}
+			default:
+				block.Successors = []uint32{fallthroughAddr}
+				if hasTarget && target != fallthroughAddr {
+					block.Successors = append(block.Successors, target)
+				}
+			}
+			block = nil
+			addr += 4
+		}
+		if block != nil {
+			block.End = end
+			block.Successors = []uint32{}
+		}
+	}
+
+	// Remove edges which lead out of the executable.
+	for _, block := range res.Blocks {
+		var successors []uint32
+		for _, s := range block.Successors {
+			if leaders[s] {
+				successors = append(successors, s)
+			}
+		}
+		block.Successors = append([]uint32{}, successors...)
+	}
+	return res
+}
+
+// Block returns the block which contains an address, or nil if there is none.
+func (c *ControlFlowGraph) Block(addr uint32) *BasicBlock {
+	idx := sort.Search(len(c.Blocks), func(i int) bool {
+		return c.Blocks[i].End > addr
+	})
+	if idx < len(c.Blocks) && c.Blocks[idx].Start <= addr {
+		return c.Blocks[idx]
+	}
+	return nil
+}
+
+// Reachable finds the starts of the blocks which can run after the block starting at an
+// address, including the block itself.
+// Both successors and calls are followed.
+func (c *ControlFlowGraph) Reachable(start uint32) map[uint32]bool {
+	return c.reachableFrom([]uint32{start})
+}
+
+// reachableFrom is like Reachable, but it starts from several addresses at once.
+func (c *ControlFlowGraph) reachableFrom(starts []uint32) map[uint32]bool {
+	res := map[uint32]bool{}
+	queue := append([]uint32{}, starts...)
+	for len(queue) > 0 {
+		addr := queue[0]
+		queue = queue[1:]
+		block := c.Block(addr)
+		if block == nil || res[block.Start] {
+			continue
+		}
+		res[block.Start] = true
+		queue = append(queue, block.Successors...)
+		queue = append(queue, block.Calls...)
+	}
+	return res
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+// Each block is labeled with its instructions; calls are drawn as dashed edges.
+func (c *ControlFlowGraph) DOT(e *Executable) string {
+	var res strings.Builder
+	res.WriteString("digraph cfg {\n")
+	res.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	for _, block := range c.Blocks {
+		var label strings.Builder
+		if block.Label != "" {
+			label.WriteString(block.Label + ":\\l")
+		}
+		for addr := block.Start; addr < block.End; addr += 4 {
+			text := "NOP"
+			if rendered, err := e.Get(addr).Render(); err == nil {
+				text = rendered.String()
+			}
+			label.WriteString(dotEscape(eightDigitHex(addr)+"  "+text) + "\\l")
+		}
+		res.WriteString("  \"" + eightDigitHex(block.Start) + "\" [label=\"" + label.String() +
+			"\"];\n")
+	}
+	for _, block := range c.Blocks {
+		for _, s := range block.Successors {
+			res.WriteString("  \"" + eightDigitHex(block.Start) + "\" -> \"" + eightDigitHex(s) +
+				"\";\n")
+		}
+		for _, s := range block.Calls {
+			res.WriteString("  \"" + eightDigitHex(block.Start) + "\" -> \"" + eightDigitHex(s) +
+				"\" [style=dashed];\n")
+		}
+	}
+	res.WriteString("}\n")
+	return res.String()
+}
+
+// symbolAt returns the alphabetically first symbol at an address, or "" if there is none.
+func symbolAt(e *Executable, addr uint32) string {
+	var res string
+	for name, symAddr := range e.Symbols {
+		if symAddr == addr && (res == "" || name < res) {
+			res = name
+		}
+	}
+	return res
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s)
+}
@@ -0,0 +1,98 @@
+package mips32
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildCFG(t *testing.T) {
+	lines, err := TokenizeSource(`
+MAIN:
+ADDIU $t0, $0, 5
+LOOP:
+JAL FUNC
+NOP
+ADDIU $t0, $t0, -1
+BNE $t0, $0, LOOP
+NOP
+J MAIN
+NOP
+FUNC:
+JR $ra
+NOP
+DEAD:
+NOP
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := BuildCFG(exc)
+	expected := []*BasicBlock{
+		{Start: 0x0, End: 0x4, Label: "MAIN", Successors: []uint32{0x4}},
+		{Start: 0x4, End: 0xc, Label: "LOOP", Successors: []uint32{0xc}, Calls: []uint32{0x20}},
+		{Start: 0xc, End: 0x18, Successors: []uint32{0x18, 0x4}},
+		{Start: 0x18, End: 0x20, Successors: []uint32{0x0}},
+		{Start: 0x20, End: 0x28, Label: "FUNC", Successors: []uint32{}},
+		{Start: 0x28, End: 0x2c, Label: "DEAD", Successors: []uint32{}},
+	}
+	if !reflect.DeepEqual(cfg.Blocks, expected) {
+		data, _ := json.Marshal(cfg)
+		t.Fatal("unexpected blocks:", string(data))
+	}
+
+	if b := cfg.Block(0x10); b == nil || b.Start != 0xc {
+		t.Error("unexpected block for 0x10:", b)
+	}
+	if b := cfg.Block(0x2c); b != nil {
+		t.Error("unexpected block for 0x2c:", b)
+	}
+
+	reachable := cfg.Reachable(0)
+	for _, block := range cfg.Blocks {
+		if reachable[block.Start] != (block.Start != 0x28) {
+			t.Errorf("block 0x%x: reachable=%v", block.Start, reachable[block.Start])
+		}
+	}
+
+	dot := cfg.DOT(exc)
+	for _, line := range []string{
+		`"0x00000004" -> "0x00000020" [style=dashed];`,
+		`"0x0000000c" -> "0x00000004";`,
+		`"0x00000020" [label="FUNC:\l0x00000020  JR $31\l0x00000024  NOP\l"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("DOT output is missing %s:\n%s", line, dot)
+		}
+	}
+}
+
+func TestBuildCFGDelaySlotTarget(t *testing.T) {
+	lines, err := TokenizeSource(`
+BEQ $t0, $0, SLOT
+SLOT:
+NOP
+NOP
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := BuildCFG(exc)
+	expected := []*BasicBlock{
+		{Start: 0x0, End: 0x8, Successors: []uint32{0x8}},
+		{Start: 0x8, End: 0xc, Successors: []uint32{}},
+	}
+	if !reflect.DeepEqual(cfg.Blocks, expected) {
+		data, _ := json.Marshal(cfg)
+		t.Fatal("unexpected blocks:", string(data))
+	}
+}
@@ -0,0 +1,134 @@
+package mips32
+
+import (
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// A ChecksumAlgorithm computes 32-bit checksums of machine code.
+type ChecksumAlgorithm int
+
+const (
+	// CRC32Checksum is the IEEE CRC-32 used by zlib and Ethernet.
+	CRC32Checksum ChecksumAlgorithm = iota
+
+	// Fletcher32Checksum is Fletcher's checksum over big-endian 16-bit words.
+	// Data with an odd length is padded with a zero byte.
+	Fletcher32Checksum
+)
+
+// ParseChecksumAlgorithm parses the name of a checksum algorithm: "crc32" or "fletcher32".
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	switch name {
+	case "crc32":
+		return CRC32Checksum, nil
+	case "fletcher32":
+		return Fletcher32Checksum, nil
+	}
+	return 0, errors.New("unknown checksum algorithm: " + name)
+}
+
+// String returns the name which ParseChecksumAlgorithm accepts.
+func (c ChecksumAlgorithm) String() string {
+	if c == Fletcher32Checksum {
+		return "fletcher32"
+	}
+	return "crc32"
+}
+
+// Sum computes the checksum of some data.
+func (c ChecksumAlgorithm) Sum(data []byte) uint32 {
+	if c != Fletcher32Checksum {
+		return crc32.ChecksumIEEE(data)
+	}
+	var sum1, sum2 uint32
+	for i := 0; i < len(data); i += 2 {
+		word := uint32(data[i]) << 8
+		if i+1 < len(data) {
+			word |= uint32(data[i+1])
+		}
+		sum1 = (sum1 + word) % 0xffff
+		sum2 = (sum2 + sum1) % 0xffff
+	}
+	return sum2<<16 | sum1
+}
+
+// A SegmentChecksum records the checksum of one chunk of an image.
+type SegmentChecksum struct {
+	Address uint32
+	Size    uint32
+	Sum     uint32
+}
+
+// String formats the checksum like "00001000 0000001c 5a3c9e01", i.e. the address, size, and
+// checksum in hexadecimal.
+func (s SegmentChecksum) String() string {
+	return eightDigitHex(s.Address)[2:] + " " + eightDigitHex(s.Size)[2:] + " " +
+		eightDigitHex(s.Sum)[2:]
+}
+
+// ImageChecksums computes the checksum of each chunk of an image, such as the result of
+// EncodeBytes or ReadIntelHex.
+// The checksums are sorted by address.
+func ImageChecksums(chunks map[uint32][]byte, alg ChecksumAlgorithm) []SegmentChecksum {
+	res := make([]SegmentChecksum, 0, len(chunks))
+	for addr, data := range chunks {
+		res = append(res, SegmentChecksum{
+			Address: addr,
+			Size:    uint32(len(data)),
+			Sum:     alg.Sum(data),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Address < res[j].Address
+	})
+	return res
+}
+
+// VerifyImageChecksums checks that an image has exactly the chunks described by some checksums,
+// and that each chunk's data matches its checksum.
+// The error names the first chunk (by address) which does not match.
+func VerifyImageChecksums(chunks map[uint32][]byte, alg ChecksumAlgorithm,
+	sums []SegmentChecksum) error {
+	expected := map[uint32]SegmentChecksum{}
+	for _, sum := range sums {
+		expected[sum.Address] = sum
+	}
+	for _, actual := range ImageChecksums(chunks, alg) {
+		sum, ok := expected[actual.Address]
+		location := "segment at " + eightDigitHex(actual.Address)
+		if !ok {
+			return errors.New("unexpected " + location)
+		} else if sum.Size != actual.Size {
+			return errors.New(location + " has size " +
+				strconv.FormatUint(uint64(actual.Size), 10) + " but expected " +
+				strconv.FormatUint(uint64(sum.Size), 10))
+		} else if sum.Sum != actual.Sum {
+			return errors.New(alg.String() + " mismatch for " + location + ": expected " +
+				eightDigitHex(sum.Sum) + " but got " + eightDigitHex(actual.Sum))
+		}
+		delete(expected, actual.Address)
+	}
+	if len(expected) > 0 {
+		missing := make(uint32List, 0, len(expected))
+		for addr := range expected {
+			missing = append(missing, addr)
+		}
+		sort.Sort(missing)
+		return errors.New("missing segment at " + eightDigitHex(missing[0]))
+	}
+	return nil
+}
+
+// Checksums encodes the executable with the given byte order and computes the checksum of each
+// segment.
+func (e *Executable) Checksums(littleEndian bool, alg ChecksumAlgorithm) ([]SegmentChecksum,
+	error) {
+	chunks, err := e.EncodeBytes(littleEndian)
+	if err != nil {
+		return nil, err
+	}
+	return ImageChecksums(chunks, alg), nil
+}
@@ -0,0 +1,87 @@
+package mips32
+
+import "testing"
+
+func TestChecksumAlgorithms(t *testing.T) {
+	tests := []struct {
+		alg      ChecksumAlgorithm
+		data     string
+		expected uint32
+	}{
+		{CRC32Checksum, "123456789", 0xcbf43926},
+		{CRC32Checksum, "", 0},
+		{Fletcher32Checksum, "abcde", 0x4ff029c7},
+		{Fletcher32Checksum, "abcdef", 0x50562a2d},
+		{Fletcher32Checksum, "\xff\xff\xff\xff", 0},
+	}
+	for _, test := range tests {
+		if actual := test.alg.Sum([]byte(test.data)); actual != test.expected {
+			t.Errorf("%s(%q): expected %08x but got %08x", test.alg, test.data, test.expected,
+				actual)
+		}
+	}
+	for _, name := range []string{"crc32", "fletcher32"} {
+		if alg, err := ParseChecksumAlgorithm(name); err != nil || alg.String() != name {
+			t.Errorf("%s: parsed as %s (error %v)", name, alg, err)
+		}
+	}
+	if _, err := ParseChecksumAlgorithm("md5"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+func TestImageChecksums(t *testing.T) {
+	exc, err := Assemble(".text 0x2000\n.word 1\n.text 0x1000\nNOP\nJ 0x2000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, err := exc.Checksums(false, CRC32Checksum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SegmentChecksum{
+		{Address: 0x1000, Size: 8, Sum: CRC32Checksum.Sum([]byte{0, 0, 0, 0, 8, 0, 8, 0})},
+		{Address: 0x2000, Size: 4, Sum: CRC32Checksum.Sum([]byte{0, 0, 0, 1})},
+	}
+	if len(sums) != len(expected) || sums[0] != expected[0] || sums[1] != expected[1] {
+		t.Fatalf("expected %v but got %v", expected, sums)
+	}
+	if s := sums[1].String(); s != "00002000 00000004 "+eightDigitHex(expected[1].Sum)[2:] {
+		t.Errorf("unexpected string: %s", s)
+	}
+
+	chunks, err := exc.EncodeBytes(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyImageChecksums(chunks, CRC32Checksum, sums); err != nil {
+		t.Error(err)
+	}
+	if err := VerifyImageChecksums(chunks, Fletcher32Checksum, sums); err == nil {
+		t.Error("expected an error for the wrong algorithm")
+	}
+
+	chunks[0x2000][3] = 2
+	chunks[0x3000] = []byte{1}
+	if err := VerifyImageChecksums(chunks, CRC32Checksum, sums); err == nil ||
+		err.Error() != "crc32 mismatch for segment at 0x00002000: expected "+
+			eightDigitHex(expected[1].Sum)+" but got "+
+			eightDigitHex(CRC32Checksum.Sum(chunks[0x2000])) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	chunks[0x2000] = chunks[0x2000][:2]
+	err = VerifyImageChecksums(chunks, CRC32Checksum, sums)
+	if err == nil || err.Error() != "segment at 0x00002000 has size 2 but expected 4" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	delete(chunks, 0x2000)
+	err = VerifyImageChecksums(chunks, CRC32Checksum, sums)
+	if err == nil || err.Error() != "unexpected segment at 0x00003000" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	delete(chunks, 0x3000)
+	err = VerifyImageChecksums(chunks, CRC32Checksum, sums)
+	if err == nil || err.Error() != "missing segment at 0x00002000" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package mips32
+
+import "errors"
+
+// Clone creates a copy of the executable which can be modified without affecting the original.
+//
+// The instructions themselves are not copied until one of the executables adds to or replaces a
+// segment, so cloning is cheap even for large programs.
+// Instructions should not be modified in place, since that would affect both executables.
+func (e *Executable) Clone() *Executable {
+	res := &Executable{
+		Segments: make(map[uint32][]Instruction, len(e.Segments)),
+		Symbols:  make(map[string]uint32, len(e.Symbols)),
+	}
+	for addr, seg := range e.Segments {
+		// Limiting the capacity makes appends copy the segment.
+		res.Segments[addr] = seg[:len(seg):len(seg)]
+	}
+	for name, addr := range e.Symbols {
+		res.Symbols[name] = addr
+	}
+	if e.LineNumbers != nil {
+		res.LineNumbers = make(map[uint32]int, len(e.LineNumbers))
+		for addr, line := range e.LineNumbers {
+			res.LineNumbers[addr] = line
+		}
+	}
+	if e.SymbolInfo != nil {
+		res.SymbolInfo = make(map[string]SymbolInfo, len(e.SymbolInfo))
+		for name, info := range e.SymbolInfo {
+			res.SymbolInfo[name] = info
+		}
+	}
+	if index := e.index.Load(); index != nil {
+		res.index.Store(index)
+	}
+	return res
+}
+
+// Clone creates a copy of the memory.
+//
+// Pages are shared between the two memories until one of them writes to a page, at which point
+// that memory gets its own copy of the page.
+// This makes cloning cheap, since it only copies the list of pages.
+//
+// Clone must not be called while other goroutines are using the memory, but afterwards the two
+// memories can be used from different goroutines.
+func (l *LazyMemory) Clone() *LazyMemory {
+	res := &LazyMemory{
+		pages:  make(map[uint32][]byte, len(l.pages)),
+		shared: make(map[uint32]bool, len(l.pages)),
+	}
+	if l.shared == nil {
+		l.shared = map[uint32]bool{}
+	}
+	for addr, data := range l.pages {
+		res.pages[addr] = data
+		res.shared[addr] = true
+		l.shared[addr] = true
+	}
+	return res
+}
+
+// Clone creates a copy of the memory.
+func (f *FlatMemory) Clone() *FlatMemory {
+	return &FlatMemory{Base: f.Base, Data: append([]byte{}, f.Data...)}
+}
+
+// Clone creates a copy of the emulator, so that a loaded program can be run several times
+// (e.g. once per test case) without loading it again.
+//
+// The memory must be a *LazyMemory or a *FlatMemory, and it is cloned with its Clone method.
+// The executable is shared, since the emulator never modifies it, but the clone decodes it into
+// its own cache, so the clone and the original may run on different goroutines.
+//
+// The Syscalls handler and the AfterExecute hook are shared as well.
+// If they keep state (such as the heap pointer of SPIMSyscalls), the clone should be given its
+// own.
+func (e *Emulator) Clone() (*Emulator, error) {
+	res := *e
+	res.LastDelta = e.LastDelta.Clone()
+	res.code = decodeCache{}
+	switch memory := e.Memory.(type) {
+	case *LazyMemory:
+		res.Memory = memory.Clone()
+	case *FlatMemory:
+		res.Memory = memory.Clone()
+	case nil:
+	default:
+		return nil, errors.New("cannot clone memory of this type")
+	}
+	return &res, nil
+}
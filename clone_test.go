@@ -0,0 +1,145 @@
+package mips32
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestExecutableClone(t *testing.T) {
+	tokens, err := TokenizeSource("START:\nADDIU $t0, $0, 1\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := exc.Clone()
+	clone.Symbols["OTHER"] = 4
+	clone.Segments[0] = append(clone.Segments[0], Instruction{Name: "NOP"})
+	clone.LineNumbers[8] = 4
+	if _, ok := exc.Symbols["OTHER"]; ok || len(exc.Segments[0]) != 2 || len(exc.LineNumbers) != 2 {
+		t.Error("modifying the clone changed the original")
+	}
+	if inst := clone.Get(8); inst == nil || inst.Name != "NOP" {
+		t.Error("unexpected instruction in clone:", inst)
+	}
+	if clone.End() != 12 || exc.End() != 8 {
+		t.Error("unexpected ends", clone.End(), exc.End())
+	}
+}
+
+func TestLazyMemoryClone(t *testing.T) {
+	m := NewLazyMemory()
+	m.Set(0x1000, 1)
+	m.Set(0x2000, 2)
+	clone := m.Clone()
+	clone.Set(0x1000, 3)
+	m.Set(0x2000, 4)
+	m.Set(0x3000, 5)
+	if m.Get(0x1000) != 1 || m.Get(0x2000) != 4 || m.Get(0x3000) != 5 {
+		t.Error("unexpected original contents")
+	}
+	if clone.Get(0x1000) != 3 || clone.Get(0x2000) != 2 || clone.Get(0x3000) != 0 {
+		t.Error("unexpected clone contents")
+	}
+
+	// A clone of a clone must not share pages which its parent has since written to.
+	clone2 := clone.Clone()
+	clone.Set(0x1001, 6)
+	if clone2.Get(0x1001) != 0 || clone.Get(0x1001) != 6 || m.Get(0x1001) != 0 {
+		t.Error("unexpected contents after second clone")
+	}
+}
+
+func TestEmulatorClone(t *testing.T) {
+	tokens, err := TokenizeSource(`ADDIU $t0, $0, 0x100
+LW $t1, 0($t0)
+ADDIU $t1, $t1, 1
+SW $t1, 0($t0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	emu.Step()
+	emu.Memory.Set(0x103, 5)
+
+	for i := 0; i < 2; i++ {
+		clone, err := emu.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for !clone.Done() {
+			if err := clone.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if clone.Memory.Get(0x103) != 6 {
+			t.Error("unexpected result in clone", i)
+		}
+	}
+	if emu.ProgramCounter != 4 || emu.Memory.Get(0x103) != 5 {
+		t.Error("running the clones changed the original")
+	}
+
+	emu.Memory = &MMIORouter{}
+	if _, err := emu.Clone(); err == nil {
+		t.Error("expected error for unsupported memory")
+	}
+}
+
+func TestEmulatorCloneConcurrent(t *testing.T) {
+	tokens, err := TokenizeSource(`ADDIU $t0, $0, 0x100
+ADDIU $t2, $0, 50
+LOOP:
+LW $t1, 0($t0)
+ADDIU $t1, $t1, 1
+SW $t1, 0($t0)
+ADDIU $t2, $t2, -1
+BNE $t2, $0, LOOP
+NOP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	if _, err := emu.StepN(3); err != nil {
+		t.Fatal(err)
+	}
+
+	emus := []*Emulator{emu}
+	for i := 0; i < 4; i++ {
+		clone, err := emu.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		emus = append(emus, clone)
+	}
+	errs := make([]error, len(emus))
+	var wg sync.WaitGroup
+	for i, e := range emus {
+		wg.Add(1)
+		go func(i int, e *Emulator) {
+			defer wg.Done()
+			for !e.Done() && errs[i] == nil {
+				_, errs[i] = e.StepN(7)
+			}
+		}(i, e)
+	}
+	wg.Wait()
+
+	for i, e := range emus {
+		if errs[i] != nil {
+			t.Error("emulator", i, "failed:", errs[i])
+		} else if e.Memory.Get(0x103) != 50 {
+			t.Error("unexpected result in emulator", i)
+		}
+	}
+}
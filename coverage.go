@@ -0,0 +1,194 @@
+package mips32
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage records which instructions of an executable have run, and which ways its conditional
+// branches have gone.
+//
+// To use Coverage, call Observe after each instruction is executed, typically from an Emulator's
+// AfterExecute hook.
+type Coverage struct {
+	Executable *Executable
+
+	// Counts maps addresses to the number of times their instructions ran.
+	Counts map[uint32]int
+
+	// Taken maps the addresses of conditional branches to the number of times they were taken.
+	Taken map[uint32]int
+}
+
+// NewCoverage creates an empty Coverage for an executable.
+func NewCoverage(e *Executable) *Coverage {
+	return &Coverage{
+		Executable: e,
+		Counts:     map[uint32]int{},
+		Taken:      map[uint32]int{},
+	}
+}
+
+// Reset forgets everything which has run.
+func (c *Coverage) Reset() {
+	c.Counts = map[uint32]int{}
+	c.Taken = map[uint32]int{}
+}
+
+// Observe records an instruction which has been executed at an address.
+// Addresses outside of the executable are ignored.
+func (c *Coverage) Observe(e *Emulator, inst *Instruction, addr uint32) {
+	if inst == nil || c.Executable.Get(addr) == nil {
+		return
+	}
+	c.Counts[addr]++
+	if isConditionalBranch(inst) && e.JumpNext {
+		c.Taken[addr]++
+	}
+}
+
+// Covered returns true if the instruction at an address has run.
+func (c *Coverage) Covered(addr uint32) bool {
+	return c.Counts[addr] > 0
+}
+
+// A CoverageReport summarizes a Coverage.
+type CoverageReport struct {
+	Instructions        int `json:"instructions"`
+	CoveredInstructions int `json:"coveredInstructions"`
+
+	// Blocks are the basic blocks found by BuildCFG.
+	// A block is covered if its first instruction ran.
+	Blocks        int `json:"blocks"`
+	CoveredBlocks int `json:"coveredBlocks"`
+
+	// Each conditional branch has two outcomes: taken and not taken.
+	// Branches which can only go one way, such as "BEQ $0, $0, X", are not counted.
+	BranchOutcomes        int `json:"branchOutcomes"`
+	CoveredBranchOutcomes int `json:"coveredBranchOutcomes"`
+
+	// UncoveredBlocks lists the starts of the blocks which never ran.
+	UncoveredBlocks []uint32 `json:"uncoveredBlocks"`
+
+	// PartialBranches lists the conditional branches which ran, but only ever went one way.
+	PartialBranches []uint32 `json:"partialBranches"`
+}
+
+// Report summarizes the coverage.
+func (c *Coverage) Report() *CoverageReport {
+	res := &CoverageReport{UncoveredBlocks: []uint32{}, PartialBranches: []uint32{}}
+	for _, addr := range c.instructionAddresses() {
+		res.Instructions++
+		if c.Covered(addr) {
+			res.CoveredInstructions++
+		}
+		if isConditionalBranch(c.Executable.Get(addr)) {
+			res.BranchOutcomes += 2
+			taken, notTaken := c.Taken[addr], c.Counts[addr]-c.Taken[addr]
+			if taken > 0 {
+				res.CoveredBranchOutcomes++
+			}
+			if notTaken > 0 {
+				res.CoveredBranchOutcomes++
+			}
+			if (taken > 0) != (notTaken > 0) {
+				res.PartialBranches = append(res.PartialBranches, addr)
+			}
+		}
+	}
+	for _, block := range BuildCFG(c.Executable).Blocks {
+		res.Blocks++
+		if c.Covered(block.Start) {
+			res.CoveredBlocks++
+		} else {
+			res.UncoveredBlocks = append(res.UncoveredBlocks, block.Start)
+		}
+	}
+	return res
+}
+
+// String summarizes the report on one line, such as
+// "instructions: 10/12, blocks: 3/4, branch outcomes: 1/2".
+func (c *CoverageReport) String() string {
+	fraction := func(a, b int) string {
+		return strconv.Itoa(a) + "/" + strconv.Itoa(b)
+	}
+	return "instructions: " + fraction(c.CoveredInstructions, c.Instructions) +
+		", blocks: " + fraction(c.CoveredBlocks, c.Blocks) +
+		", branch outcomes: " + fraction(c.CoveredBranchOutcomes, c.BranchOutcomes)
+}
+
+// LCOV formats the coverage as an lcov tracefile, which tools like genhtml can turn into an
+// annotated listing of the source.
+// The sourceFile is the name of the assembly file, and the executable's line numbers are used
+// to map instructions to lines.
+// Instructions without line numbers are left out.
+func (c *Coverage) LCOV(sourceFile string) string {
+	lineCounts := map[int]int{}
+	var lines []int
+	var res strings.Builder
+	res.WriteString("TN:\nSF:" + sourceFile + "\n")
+
+	var branchesFound, branchesHit int
+	for _, addr := range c.instructionAddresses() {
+		line := c.Executable.LineNumbers[addr]
+		if line == 0 {
+			continue
+		}
+		if _, ok := lineCounts[line]; !ok {
+			lines = append(lines, line)
+		}
+		lineCounts[line] += c.Counts[addr]
+		if !isConditionalBranch(c.Executable.Get(addr)) {
+			continue
+		}
+		outcomes := []int{c.Taken[addr], c.Counts[addr] - c.Taken[addr]}
+		for i, count := range outcomes {
+			countStr := "-"
+			if c.Counts[addr] > 0 {
+				countStr = strconv.Itoa(count)
+			}
+			res.WriteString("BRDA:" + strconv.Itoa(line) + "," + strconv.Itoa(int(addr)) + "," +
+				strconv.Itoa(i) + "," + countStr + "\n")
+			branchesFound++
+			if count > 0 {
+				branchesHit++
+			}
+		}
+	}
+	res.WriteString("BRF:" + strconv.Itoa(branchesFound) + "\n")
+	res.WriteString("BRH:" + strconv.Itoa(branchesHit) + "\n")
+
+	sort.Ints(lines)
+	var linesHit int
+	for _, line := range lines {
+		res.WriteString("DA:" + strconv.Itoa(line) + "," + strconv.Itoa(lineCounts[line]) + "\n")
+		if lineCounts[line] > 0 {
+			linesHit++
+		}
+	}
+	res.WriteString("LF:" + strconv.Itoa(len(lines)) + "\n")
+	res.WriteString("LH:" + strconv.Itoa(linesHit) + "\n")
+	res.WriteString("end_of_record\n")
+	return res.String()
+}
+
+func (c *Coverage) instructionAddresses() []uint32 {
+	var res []uint32
+	for _, segment := range c.Executable.sortedSegmentAddresses() {
+		for i := range c.Executable.Segments[segment] {
+			res = append(res, segment+uint32(i*4))
+		}
+	}
+	return res
+}
+
+// isConditionalBranch returns true for branches which may or may not be taken.
+func isConditionalBranch(inst *Instruction) bool {
+	switch inst.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+		return !isUnconditionalJump(inst)
+	}
+	return false
+}
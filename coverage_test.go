@@ -0,0 +1,86 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	lines, err := TokenizeSource(`ADDIU $t0, $0, 2
+LOOP:
+ADDIU $t0, $t0, -1
+BNE $t0, $0, LOOP
+NOP
+BEQ $t0, $0, DONE
+NOP
+ADDIU $t1, $0, 1
+DONE:
+NOP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	coverage := NewCoverage(exc)
+	emu.AfterExecute = func(inst *Instruction, addr uint32) {
+		coverage.Observe(emu, inst, addr)
+	}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectedCounts := map[uint32]int{0: 1, 4: 2, 8: 2, 0xc: 2, 0x10: 1, 0x14: 1, 0x1c: 1}
+	if !reflect.DeepEqual(coverage.Counts, expectedCounts) {
+		t.Error("unexpected counts:", coverage.Counts)
+	}
+	if !reflect.DeepEqual(coverage.Taken, map[uint32]int{8: 1, 0x10: 1}) {
+		t.Error("unexpected taken counts:", coverage.Taken)
+	}
+
+	report := coverage.Report()
+	expected := &CoverageReport{
+		Instructions:          8,
+		CoveredInstructions:   7,
+		Blocks:                5,
+		CoveredBlocks:         4,
+		BranchOutcomes:        4,
+		CoveredBranchOutcomes: 3,
+		UncoveredBlocks:       []uint32{0x18},
+		PartialBranches:       []uint32{0x10},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %+v but got %+v", expected, report)
+	}
+	if s := report.String(); s != "instructions: 7/8, blocks: 4/5, branch outcomes: 3/4" {
+		t.Error("unexpected summary:", s)
+	}
+
+	expectedLCOV := `TN:
+SF:test.s
+BRDA:4,8,0,1
+BRDA:4,8,1,1
+BRDA:6,16,0,1
+BRDA:6,16,1,0
+BRF:4
+BRH:3
+DA:1,1
+DA:3,2
+DA:4,2
+DA:5,2
+DA:6,1
+DA:7,1
+DA:8,0
+DA:10,1
+LF:8
+LH:7
+end_of_record
+`
+	if lcov := coverage.LCOV("test.s"); lcov != expectedLCOV {
+		t.Errorf("unexpected lcov output:\n%s", lcov)
+	}
+}
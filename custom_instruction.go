@@ -0,0 +1,202 @@
+package mips32
+
+import (
+	"errors"
+	"strings"
+)
+
+// A CustomInstruction describes an instruction which is not part of the MIPS instruction set,
+// such as a user-defined instruction in the SPECIAL2 opcode space (as used by CorExtend).
+type CustomInstruction struct {
+	// Name is the mnemonic, which must consist of uppercase letters.
+	Name string
+
+	// Arguments lists the operands in assembly order.
+	// Only Register, Constant5, SignedConstant16, and UnsignedConstant16 are supported.
+	//
+	// An instruction with a 16-bit constant uses the I-type format, with up to two registers
+	// which are encoded as rt and rs, in that order.
+	// Any other instruction uses the R-type format, with up to three registers which are encoded
+	// as rd, rs, and rt, in that order, and an optional Constant5 in the shift amount field.
+	Arguments []ArgumentType
+
+	// Opcode is the 6-bit opcode field.
+	Opcode uint32
+
+	// Funct is the 6-bit function field, which is only used by R-type instructions.
+	Funct uint32
+
+	// Execute performs the instruction in an emulator.
+	// When it is called, the program counter has already been advanced past the instruction.
+	Execute func(e *Emulator, inst *Instruction) error
+}
+
+// customInstructions maps names to the instructions added with RegisterInstruction.
+var customInstructions = map[string]*CustomInstruction{}
+
+// customOpcodes maps opcodes to the instructions added with RegisterInstruction.
+var customOpcodes = map[uint32][]*CustomInstruction{}
+
+// RegisterInstruction adds a custom instruction to the assembler, the disassembler, and the
+// emulator.
+//
+// The instruction's name must not already be in use, and its encoding must not overlap with any
+// other instruction.
+//
+// This is not safe to call while other goroutines are using the package, so it is best called
+// from an init function.
+func RegisterInstruction(c *CustomInstruction) error {
+	if c.Name == "" || !isInstName(c.Name) || strings.ToUpper(c.Name) != c.Name {
+		return errors.New("invalid instruction name: " + c.Name)
+	}
+	if len(templatesNamed(c.Name)) > 0 {
+		return errors.New("instruction already exists: " + c.Name)
+	}
+	if c.Execute == nil {
+		return errors.New("missing Execute function for " + c.Name)
+	} else if c.Opcode >= 0x40 || c.Funct >= 0x40 {
+		return errors.New("opcode or function field out of range for " + c.Name)
+	}
+
+	var registers, constant5s, constant16s int
+	for _, arg := range c.Arguments {
+		switch arg {
+		case Register:
+			registers++
+		case Constant5:
+			constant5s++
+		case SignedConstant16, UnsignedConstant16:
+			constant16s++
+		default:
+			return errors.New("unsupported argument type for " + c.Name)
+		}
+	}
+	if constant16s > 1 || constant5s > 1 || (constant16s == 1 && (constant5s > 0 ||
+		registers > 2)) || registers > 3 {
+		return errors.New("too many arguments for " + c.Name)
+	}
+
+	for _, other := range customOpcodes[c.Opcode] {
+		if other.immediate() || c.immediate() {
+			return errors.New("encoding of " + c.Name + " overlaps with " + other.Name)
+		}
+	}
+	base := c.Opcode << 26
+	if !c.immediate() {
+		base |= c.Funct
+	}
+	if decoded := DecodeInstruction(base); decoded.Name != ".word" {
+		return errors.New("encoding of " + c.Name + " overlaps with " + decoded.Name)
+	}
+
+	Templates = append(Templates, Template{Name: c.Name, Arguments: c.Arguments})
+	customInstructions[c.Name] = c
+	customOpcodes[c.Opcode] = append(customOpcodes[c.Opcode], c)
+	return nil
+}
+
+// immediate returns true if the instruction uses the I-type format.
+func (c *CustomInstruction) immediate() bool {
+	for _, arg := range c.Arguments {
+		if arg == SignedConstant16 || arg == UnsignedConstant16 {
+			return true
+		}
+	}
+	return false
+}
+
+// fields returns the bit offsets of the register fields, and a mask of the bits which are
+// determined by the instruction's arguments or by its opcode and function fields.
+func (c *CustomInstruction) fields() (shifts []uint, mask uint32) {
+	mask = 0x3f << 26
+	if c.immediate() {
+		shifts = []uint{16, 21}
+		mask |= 0xffff
+	} else {
+		shifts = []uint{11, 21, 16}
+		mask |= 0x3f
+		for _, arg := range c.Arguments {
+			if arg == Constant5 {
+				mask |= 0x1f << 6
+			}
+		}
+	}
+	var registers int
+	for _, arg := range c.Arguments {
+		if arg == Register {
+			mask |= 0x1f << shifts[registers]
+			registers++
+		}
+	}
+	return shifts[:registers], mask
+}
+
+func (c *CustomInstruction) encode(inst *Instruction) (uint32, error) {
+	shifts, _ := c.fields()
+	if len(inst.Registers) != len(shifts) {
+		return 0, registerCountError(inst.Name)
+	}
+	word := c.Opcode << 26
+	for i, reg := range inst.Registers {
+		word |= uint32(reg) << shifts[i]
+	}
+	for _, arg := range c.Arguments {
+		switch arg {
+		case SignedConstant16:
+			word |= uint32(uint16(inst.SignedConstant16))
+		case UnsignedConstant16:
+			word |= uint32(inst.UnsignedConstant16)
+		case Constant5:
+			word |= uint32(inst.Constant5&0x1f) << 6
+		}
+	}
+	if !c.immediate() {
+		word |= c.Funct
+	}
+	return word, nil
+}
+
+func (c *CustomInstruction) decode(word uint32) (*Instruction, bool) {
+	shifts, mask := c.fields()
+	if word>>26 != c.Opcode || word&^mask != 0 || (!c.immediate() && word&0x3f != c.Funct) {
+		return nil, false
+	}
+	var registers [maxInstructionRegisters]int
+	for i, shift := range shifts {
+		registers[i] = int((word >> shift) & 0x1f)
+	}
+	res := newInstruction(c.Name, registers[:len(shifts)]...)
+	for _, arg := range c.Arguments {
+		switch arg {
+		case SignedConstant16:
+			res.SignedConstant16 = int16(word)
+		case UnsignedConstant16:
+			res.UnsignedConstant16 = uint16(word)
+		case Constant5:
+			res.Constant5 = uint8((word >> 6) & 0x1f)
+		}
+	}
+	return res, true
+}
+
+// decodeCustomInstruction decodes a word as a custom instruction, if it is one.
+func decodeCustomInstruction(word uint32) (*Instruction, bool) {
+	for _, c := range customOpcodes[word>>26] {
+		if inst, ok := c.decode(word); ok {
+			return inst, true
+		}
+	}
+	return nil, false
+}
+
+// executeCustomInstruction runs a custom instruction, if the name belongs to one.
+func (e *Emulator) executeCustomInstruction(inst *Instruction) (bool, error) {
+	c, ok := customInstructions[inst.Name]
+	if !ok {
+		return false, nil
+	}
+	if err := c.Execute(e, inst); err != nil {
+		return true, e.instructionError(inst.Name + ": " + err.Error())
+	}
+	return true, nil
+}
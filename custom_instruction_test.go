@@ -0,0 +1,126 @@
+package mips32
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var registerTestInstructionOnce sync.Once
+var registerTestInstructionErr error
+
+// registerTestInstruction registers TESTADDT, which sets rd to rs + rt + 3.
+func registerTestInstruction(t *testing.T) {
+	registerTestInstructionOnce.Do(func() {
+		registerTestInstructionErr = RegisterInstruction(&CustomInstruction{
+			Name:      "TESTADDT",
+			Arguments: []ArgumentType{Register, Register, Register},
+			Opcode:    0x1c,
+			Funct:     0x30,
+			Execute: func(e *Emulator, inst *Instruction) error {
+				rd, rs, rt := inst.Registers[0], inst.Registers[1], inst.Registers[2]
+				e.RegisterFile[rd] = e.RegisterFile[rs] + e.RegisterFile[rt] + 3
+				return nil
+			},
+		})
+	})
+	if registerTestInstructionErr != nil {
+		t.Fatal(registerTestInstructionErr)
+	}
+}
+
+func TestCustomInstructionCoding(t *testing.T) {
+	registerTestInstruction(t)
+	lines, err := TokenizeSource("TESTADDT $t0, $t1, $t2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := ParseTokenizedInstruction(lines[0].Instruction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	word, err := inst.Encode(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := uint32(0x1c<<26 | 9<<21 | 10<<16 | 8<<11 | 0x30)
+	if word != expected {
+		t.Fatalf("expected %08x but got %08x", expected, word)
+	}
+	decoded := DecodeInstruction(word)
+	if decoded.Name != "TESTADDT" || !reflect.DeepEqual(decoded.Registers, []int{8, 9, 10}) {
+		t.Errorf("unexpected decoding: %+v", decoded)
+	}
+
+	// Words which set bits outside of the instruction's fields are not custom instructions.
+	if name := DecodeInstruction(word | 1<<6).Name; name != ".word" {
+		t.Errorf("expected .word but got %s", name)
+	}
+}
+
+func TestCustomInstructionExecute(t *testing.T) {
+	registerTestInstruction(t)
+	lines, err := TokenizeSource(`ADDIU $t1, $0, 5
+ADDIU $t2, $0, 7
+TESTADDT $t0, $t1, $t2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emu.RegisterFile[8] != 15 {
+		t.Errorf("expected 15 but got %d", emu.RegisterFile[8])
+	}
+}
+
+func TestRegisterInstructionErrors(t *testing.T) {
+	registerTestInstruction(t)
+	execute := func(e *Emulator, inst *Instruction) error { return nil }
+	instructions := []*CustomInstruction{
+		{Name: "TESTADDT", Opcode: 0x1c, Funct: 0x31, Execute: execute},
+		{Name: "ADDU", Opcode: 0x1c, Funct: 0x31, Execute: execute},
+		{Name: "TestOp", Opcode: 0x1c, Funct: 0x31, Execute: execute},
+		{Name: "TESTOP", Opcode: 0x1c, Funct: 0x31},
+		{Name: "TESTOP", Opcode: 0x40, Execute: execute},
+		{Name: "TESTOP", Opcode: 0, Funct: 0x21, Execute: execute},
+		{Name: "TESTOP", Opcode: 0x1c, Funct: 0x30, Execute: execute},
+		{
+			Name:      "TESTOP",
+			Arguments: []ArgumentType{Register, Register, Register, Register},
+			Opcode:    0x1c,
+			Funct:     0x31,
+			Execute:   execute,
+		},
+		{
+			Name:      "TESTOP",
+			Arguments: []ArgumentType{MemoryAddress},
+			Opcode:    0x1c,
+			Funct:     0x31,
+			Execute:   execute,
+		},
+	}
+	expected := []string{
+		"instruction already exists: TESTADDT",
+		"instruction already exists: ADDU",
+		"invalid instruction name: TestOp",
+		"missing Execute function for TESTOP",
+		"opcode or function field out of range for TESTOP",
+		"encoding of TESTOP overlaps with ADDU",
+		"encoding of TESTOP overlaps with TESTADDT",
+		"too many arguments for TESTOP",
+		"unsupported argument type for TESTOP",
+	}
+	for i, c := range instructions {
+		if err := RegisterInstruction(c); err == nil || err.Error() != expected[i] {
+			t.Errorf("instruction %d: expected error %q but got %v", i, expected[i], err)
+		}
+	}
+}
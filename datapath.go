@@ -0,0 +1,198 @@
+package mips32
+
+import "strings"
+
+// ALUOp values, as produced by the main control unit of the textbook single-cycle datapath.
+const (
+	ALUOpAdd      = 0
+	ALUOpSubtract = 1
+	ALUOpFunct    = 2
+
+	// ALUOpImmediate is not part of the textbook datapath.
+	// It is used for immediate instructions other than ADDIU, whose ALU operation depends on
+	// the opcode.
+	ALUOpImmediate = 3
+)
+
+// DatapathSignals are the control signals and values on the wires of the classic single-cycle
+// MIPS datapath (as in Patterson and Hennessy) while an instruction runs.
+//
+// The textbook datapath only handles a few instructions.
+// Other instructions are given the signals of the closest textbook instruction; for instance,
+// ORI looks like ADDI except for its ALUOperation, and JAL is a jump which also writes $ra.
+//
+// The textbook datapath has no delay slots, so NextPC is the address that would run next
+// without one: the branch or jump target if it is taken, or else PC+4.
+type DatapathSignals struct {
+	// Control signals.
+	RegDst   bool `json:"regDst"`
+	ALUSrc   bool `json:"aluSrc"`
+	MemToReg bool `json:"memToReg"`
+	RegWrite bool `json:"regWrite"`
+	MemRead  bool `json:"memRead"`
+	MemWrite bool `json:"memWrite"`
+	Branch   bool `json:"branch"`
+	Jump     bool `json:"jump"`
+	ALUOp    int  `json:"aluOp"`
+
+	// ALUOperation is the operation performed by the ALU, such as "add", "sub", "and", "or",
+	// "slt", or "sll".
+	// It is empty if the ALU is unused.
+	ALUOperation string `json:"aluOperation"`
+
+	PC          uint32 `json:"pc"`
+	Instruction uint32 `json:"instruction"`
+
+	ReadRegister1 int    `json:"readRegister1"`
+	ReadRegister2 int    `json:"readRegister2"`
+	ReadData1     uint32 `json:"readData1"`
+	ReadData2     uint32 `json:"readData2"`
+
+	// Immediate is the instruction's 16-bit immediate, sign-extended.
+	Immediate uint32 `json:"immediate"`
+
+	ALUInput1 uint32 `json:"aluInput1"`
+	ALUInput2 uint32 `json:"aluInput2"`
+	ALUResult uint32 `json:"aluResult"`
+	Zero      bool   `json:"zero"`
+
+	// MemoryReadData is the value loaded from memory, if MemRead is set.
+	MemoryReadData uint32 `json:"memoryReadData"`
+
+	// WriteRegister and WriteData are the register written and its new value, if RegWrite is
+	// set.
+	WriteRegister int    `json:"writeRegister"`
+	WriteData     uint32 `json:"writeData"`
+
+	NextPC uint32 `json:"nextPC"`
+}
+
+// Datapath computes the datapath signals for an instruction which is about to run at the
+// emulator's program counter.
+//
+// The emulator is not modified.
+// An error is returned if the instruction cannot be encoded.
+func Datapath(inst *Instruction, e *Emulator) (*DatapathSignals, error) {
+	if inst == nil {
+		inst = &Instruction{Name: "NOP"}
+	}
+	pc := e.ProgramCounter
+	word, err := inst.Encode(pc, e.symbols())
+	if err != nil {
+		return nil, err
+	}
+
+	rs := int((word >> 21) & 0x1f)
+	rt := int((word >> 16) & 0x1f)
+	rd := int((word >> 11) & 0x1f)
+	res := &DatapathSignals{
+		PC:            pc,
+		Instruction:   word,
+		ReadRegister1: rs,
+		ReadRegister2: rt,
+		ReadData1:     e.RegisterFile[rs],
+		ReadData2:     e.RegisterFile[rt],
+		Immediate:     uint32(int32(int16(word & 0xffff))),
+		NextPC:        pc + 4,
+	}
+
+	after := *e
+	after.Memory = &overlayMemory{base: e.Memory, writes: map[uint32]byte{}}
+	after.Syscalls = nil
+	after.AfterExecute = nil
+	if inst.Name != "SYSCALL" {
+		if err := after.Execute(inst); err != nil {
+			return nil, err
+		}
+	}
+
+	writeRegister := func(reg int) {
+		res.RegWrite = true
+		res.WriteRegister = reg
+		res.WriteData = after.RegisterFile[reg]
+	}
+	useALU := func(op string, in1, in2, result uint32) {
+		res.ALUOperation = op
+		res.ALUInput1 = in1
+		res.ALUInput2 = in2
+		res.ALUResult = result
+		res.Zero = result == 0
+	}
+
+	switch inst.Name {
+	case "ADDU", "AND", "NOR", "OR", "SUBU", "XOR", "SLT", "SLTU":
+		op := map[string]string{"ADDU": "add", "AND": "and", "NOR": "nor", "OR": "or",
+			"SUBU": "sub", "XOR": "xor", "SLT": "slt", "SLTU": "sltu"}[inst.Name]
+		res.RegDst = true
+		res.ALUOp = ALUOpFunct
+		writeRegister(rd)
+		useALU(op, res.ReadData1, res.ReadData2, res.WriteData)
+	case "SLL", "SRL", "SRA", "SLLV", "SRLV", "SRAV":
+		shift := uint32((word >> 6) & 0x1f)
+		if inst.Name[len(inst.Name)-1] == 'V' {
+			shift = res.ReadData1 & 0x1f
+		}
+		op := strings.ToLower(inst.Name[:3])
+		res.RegDst = true
+		res.ALUOp = ALUOpFunct
+		writeRegister(rd)
+		useALU(op, res.ReadData2, shift, res.WriteData)
+	case "MOVN", "MOVZ":
+		res.RegDst = true
+		res.ALUOp = ALUOpFunct
+		if (res.ReadData2 != 0) == (inst.Name == "MOVN") {
+			writeRegister(rd)
+		}
+		useALU("pass", res.ReadData1, res.ReadData2, res.ReadData1)
+	case "ADDIU", "ANDI", "ORI", "XORI", "SLTI", "SLTIU", "LUI":
+		res.ALUSrc = true
+		writeRegister(rt)
+		in2 := res.Immediate
+		if inst.Name == "ANDI" || inst.Name == "ORI" || inst.Name == "XORI" ||
+			inst.Name == "LUI" {
+			in2 = word & 0xffff
+		}
+		if inst.Name == "ADDIU" {
+			res.ALUOp = ALUOpAdd
+		} else {
+			res.ALUOp = ALUOpImmediate
+		}
+		op := map[string]string{"ADDIU": "add", "ANDI": "and", "ORI": "or", "XORI": "xor",
+			"SLTI": "slt", "SLTIU": "sltu", "LUI": "lui"}[inst.Name]
+		useALU(op, res.ReadData1, in2, res.WriteData)
+	case "LB", "LBU", "LW":
+		res.ALUSrc = true
+		res.MemToReg = true
+		res.MemRead = true
+		res.ALUOp = ALUOpAdd
+		writeRegister(rt)
+		res.MemoryReadData = res.WriteData
+		useALU("add", res.ReadData1, res.Immediate, res.ReadData1+res.Immediate)
+	case "SB", "SW":
+		res.ALUSrc = true
+		res.MemWrite = true
+		res.ALUOp = ALUOpAdd
+		useALU("add", res.ReadData1, res.Immediate, res.ReadData1+res.Immediate)
+	case "BEQ", "BNE", "BGEZ", "BGTZ", "BLEZ", "BLTZ":
+		res.Branch = true
+		res.ALUOp = ALUOpSubtract
+		in2 := res.ReadData2
+		if inst.Name != "BEQ" && inst.Name != "BNE" {
+			in2 = 0
+		}
+		useALU("sub", res.ReadData1, in2, res.ReadData1-in2)
+		if after.JumpNext {
+			res.NextPC = after.JumpTarget
+		}
+	case "J", "JAL", "JR", "JALR":
+		res.Jump = true
+		res.NextPC = after.JumpTarget
+		if inst.Name == "JAL" {
+			writeRegister(31)
+		} else if inst.Name == "JALR" {
+			res.RegDst = true
+			writeRegister(rd)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package mips32
+
+import "testing"
+
+func TestDatapath(t *testing.T) {
+	lines, err := TokenizeSource(`
+ADDU $t2, $t0, $t1
+LW $t3, 4($sp)
+SW $t0, -4($sp)
+BEQ $t0, $t1, END
+ORI $t4, $t0, 0x10
+END:
+JAL END
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	emu.RegisterFile[8] = 5
+	emu.RegisterFile[9] = 3
+	emu.RegisterFile[29] = 0x100
+	emu.Memory.Set(0x107, 42)
+
+	signals := func(pc uint32) *DatapathSignals {
+		emu.ProgramCounter = pc
+		res, err := Datapath(exc.Get(pc), emu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	addu := signals(0)
+	if !addu.RegDst || addu.ALUSrc || !addu.RegWrite || addu.ALUOp != ALUOpFunct ||
+		addu.ALUOperation != "add" || addu.ALUInput1 != 5 || addu.ALUInput2 != 3 ||
+		addu.WriteRegister != 10 || addu.WriteData != 8 || addu.NextPC != 4 {
+		t.Errorf("unexpected ADDU signals: %+v", addu)
+	}
+
+	lw := signals(4)
+	if lw.RegDst || !lw.ALUSrc || !lw.MemToReg || !lw.MemRead || lw.MemWrite ||
+		lw.ALUResult != 0x104 || lw.MemoryReadData != 42 || lw.WriteRegister != 11 {
+		t.Errorf("unexpected LW signals: %+v", lw)
+	}
+
+	sw := signals(8)
+	if sw.RegWrite || !sw.MemWrite || sw.Immediate != 0xfffffffc || sw.ALUResult != 0xfc ||
+		sw.ReadData2 != 5 {
+		t.Errorf("unexpected SW signals: %+v", sw)
+	}
+
+	beq := signals(12)
+	if !beq.Branch || beq.ALUOp != ALUOpSubtract || beq.ALUResult != 2 || beq.Zero ||
+		beq.NextPC != 16 {
+		t.Errorf("unexpected BEQ signals: %+v", beq)
+	}
+	emu.RegisterFile[9] = 5
+	beq = signals(12)
+	if !beq.Zero || beq.NextPC != 20 {
+		t.Errorf("unexpected taken BEQ signals: %+v", beq)
+	}
+
+	ori := signals(16)
+	if ori.ALUOp != ALUOpImmediate || ori.ALUOperation != "or" || ori.WriteData != 0x15 {
+		t.Errorf("unexpected ORI signals: %+v", ori)
+	}
+
+	jal := signals(20)
+	if !jal.Jump || !jal.RegWrite || jal.WriteRegister != 31 || jal.WriteData != 28 ||
+		jal.NextPC != 20 {
+		t.Errorf("unexpected JAL signals: %+v", jal)
+	}
+
+	if emu.RegisterFile[10] != 0 || emu.Memory.Get(0xff) != 0 {
+		t.Error("emulator was modified")
+	}
+}
@@ -0,0 +1,122 @@
+package mips32
+
+import "sort"
+
+// deadCodeMessage is the lint warning for a block found by DeadCode.
+const deadCodeMessage = "code is never reached from the entry point or a global symbol"
+
+// DeadCode finds the basic blocks which can never run.
+//
+// Execution is assumed to begin at the lowest address of the executable, or at a symbol declared
+// with ".globl".
+// Blocks are reachable if a branch, jump, call, or fall-through leads to them from one of those
+// places.
+// Since the targets of JR and JALR are unknown, code which is only reached through them (e.g.
+// through a table of function pointers) is dead unless a global symbol labels it.
+//
+// Data labeled by a data symbol (see SymbolInfo) is never reported, but unlabeled data is
+// indistinguishable from code.
+// The blocks are sorted by address.
+func DeadCode(e *Executable) []*BasicBlock {
+	return deadCode(e, BuildCFG(e), nil)
+}
+
+// StripDeadCode creates a copy of the executable without the blocks reported by DeadCode.
+//
+// The remaining instructions keep their addresses, so segments may be split in two.
+// Symbols are kept even if they labeled removed code.
+func StripDeadCode(e *Executable) *Executable {
+	res := e.Clone()
+	for _, block := range DeadCode(e) {
+		segment, _ := res.segmentAt(res.sortedSegmentAddresses(), block.Start)
+		insts := res.Segments[segment]
+		startIdx := (block.Start - segment) / 4
+		endIdx := (block.End - segment) / 4
+		if startIdx > 0 {
+			res.Segments[segment] = insts[:startIdx:startIdx]
+		} else {
+			delete(res.Segments, segment)
+		}
+		if endIdx < uint32(len(insts)) {
+			res.Segments[block.End] = insts[endIdx:]
+		}
+		if res.LineNumbers != nil {
+			for addr := block.Start; addr < block.End; addr += 4 {
+				delete(res.LineNumbers, addr)
+			}
+		}
+	}
+	return res
+}
+
+// deadCode is like DeadCode, but it takes the executable's CFG and treats some extra symbols,
+// such as symbols referred to by ".word" directives, as entry points.
+func deadCode(e *Executable, cfg *ControlFlowGraph, extraRoots map[string]bool) []*BasicBlock {
+	segments := e.sortedSegmentAddresses()
+	if len(segments) == 0 {
+		return nil
+	}
+	roots := []uint32{segments[0]}
+	for name, addr := range e.Symbols {
+		if e.SymbolInfo[name].Global || extraRoots[name] {
+			roots = append(roots, addr)
+		}
+	}
+	reachable := cfg.reachableFrom(roots)
+	data := e.dataRanges()
+
+	var res []*BasicBlock
+	for _, block := range cfg.Blocks {
+		if !reachable[block.Start] && !data.contains(block.Start) {
+			res = append(res, block)
+		}
+	}
+	return res
+}
+
+// An addressRange is a half-open range [Start, End) of addresses.
+type addressRange struct {
+	Start uint64
+	End   uint64
+}
+
+// addressRanges is a sorted list of disjoint ranges.
+type addressRanges []addressRange
+
+func (a addressRanges) contains(addr uint32) bool {
+	idx := sort.Search(len(a), func(i int) bool {
+		return a[i].End > uint64(addr)
+	})
+	return idx < len(a) && a[idx].Start <= uint64(addr)
+}
+
+// dataRanges finds the memory labeled by data symbols.
+func (e *Executable) dataRanges() addressRanges {
+	var ranges addressRanges
+	for name, info := range e.SymbolInfo {
+		addr, ok := e.Symbols[name]
+		if !ok || info.Kind != DataSymbol {
+			continue
+		}
+		size := uint64(info.Size)
+		if size < 4 {
+			size = 4
+		}
+		ranges = append(ranges, addressRange{Start: uint64(addr), End: uint64(addr) + size})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	var res addressRanges
+	for _, r := range ranges {
+		if len(res) > 0 && r.Start <= res[len(res)-1].End {
+			if r.End > res[len(res)-1].End {
+				res[len(res)-1].End = r.End
+			}
+		} else {
+			res = append(res, r)
+		}
+	}
+	return res
+}
@@ -0,0 +1,90 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeadCode(t *testing.T) {
+	exc, err := Assemble(`main:
+	JAL used
+	NOP
+	LUI $t0, 0
+	JR $t0
+	NOP
+unused:
+	JAL used
+	NOP
+used:
+	JR $ra
+	NOP
+table:
+	.word 1, 2
+.globl handler
+handler:
+	BEQ $0, $0, after
+	NOP
+	ADDIU $t0, $0, 1
+after:
+	JR $ra
+	NOP
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts []uint32
+	for _, block := range DeadCode(exc) {
+		starts = append(starts, block.Start)
+	}
+	if expected := []uint32{0x14, 0x34}; !reflect.DeepEqual(starts, expected) {
+		t.Errorf("expected dead blocks at %v but got %v", expected, starts)
+	}
+
+	stripped := StripDeadCode(exc)
+	expectedStarts := uint32List{0, 0x1c, 0x38}
+	if segments := stripped.sortedSegmentAddresses(); !reflect.DeepEqual(segments,
+		expectedStarts) {
+		t.Fatalf("unexpected segments: %v", segments)
+	}
+	if stripped.Get(0x14) != nil || stripped.Get(0x34) != nil || stripped.Get(0x1c) == nil {
+		t.Error("unexpected stripped instructions")
+	}
+	if _, ok := stripped.LineNumbers[0x14]; ok {
+		t.Error("line number was not removed")
+	}
+	if exc.Get(0x14) == nil || len(DeadCode(stripped)) != 0 {
+		t.Error("stripping modified the original or left dead code")
+	}
+	if _, err := stripped.Encode(); err != nil {
+		t.Error(err)
+	}
+
+	// Symbols referred to by data are treated as entry points, and code which the existing
+	// "unreachable code" warning covers is not reported again.
+	for _, used := range []bool{false, true} {
+		var messages []string
+		for _, w := range lint(exc, map[string]bool{"unused": used}) {
+			if w.Message == deadCodeMessage {
+				messages = append(messages, w.String())
+			}
+		}
+		var expected []string
+		if !used {
+			expected = []string{"line 8: " + deadCodeMessage}
+		}
+		if !reflect.DeepEqual(messages, expected) {
+			t.Errorf("expected %v but got %v", expected, messages)
+		}
+	}
+}
+
+func TestBuildCFGNestedDelaySlot(t *testing.T) {
+	exc, err := Assemble("BEQ $t0, $0, END\nJ END\nJR $ra\nNOP\nEND:\nNOP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := BuildCFG(exc)
+	if len(cfg.Blocks) != 3 || cfg.Blocks[1].Start != 8 || !cfg.Reachable(0)[8] {
+		t.Errorf("unexpected blocks: %v", cfg.Blocks)
+	}
+}
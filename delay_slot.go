@@ -0,0 +1,25 @@
+package mips32
+
+// InsertDelaySlotNOPs adds a NOP after every branch and jump in a tokenized program.
+//
+// This lets programs written for a MIPS variant without delay slots (as used by some courses)
+// run as intended, since the instruction after each branch or jump no longer runs before the
+// branch takes effect.
+// The NOPs are given the line numbers of their branches and jumps.
+func InsertDelaySlotNOPs(lines []TokenizedLine) []TokenizedLine {
+	res := make([]TokenizedLine, 0, len(lines))
+	for _, line := range lines {
+		res = append(res, line)
+		if line.Instruction == nil {
+			continue
+		}
+		switch line.Instruction.Name {
+		case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE", "J", "JAL", "JALR", "JR":
+			res = append(res, TokenizedLine{
+				LineNumber:  line.LineNumber,
+				Instruction: &TokenizedInstruction{Name: "NOP", Arguments: []*ArgToken{}},
+			})
+		}
+	}
+	return res
+}
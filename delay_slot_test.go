@@ -0,0 +1,33 @@
+package mips32
+
+import "testing"
+
+func TestInsertDelaySlotNOPs(t *testing.T) {
+	tokens, err := TokenizeSource(`ADDIU $t0, $0, 3
+LOOP:
+ADDIU $t0, $t0, -1
+BNE $t0, $0, LOOP
+ADDIU $t1, $t1, 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(InsertDelaySlotNOPs(tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc.End() != 20 || exc.Get(12).Name != "NOP" || exc.LineNumbers[12] != 4 ||
+		exc.LineNumbers[16] != 5 {
+		t.Fatal("unexpected executable")
+	}
+
+	// Without the NOP, the delay slot would increment $t1 on every iteration.
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emu.RegisterFile[9] != 1 {
+		t.Errorf("expected $t1 to be 1 but got %d", emu.RegisterFile[9])
+	}
+}
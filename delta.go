@@ -0,0 +1,125 @@
+package mips32
+
+// A Delta records the effects of running an instruction.
+//
+// A Delta also stores enough information to undo and redo the instruction with Emulator.Undo and
+// Emulator.Redo.
+type Delta struct {
+	// Registers lists the registers whose values changed, in increasing order.
+	Registers []int
+
+	// Memory lists the word-aligned addresses of the memory words which were written to, in the
+	// order they were first written.
+	// A word is included even if the values written were the same as its old contents.
+	Memory []uint32
+
+	oldControl controlState
+	newControl controlState
+	registers  []registerChange
+	bytes      []byteChange
+}
+
+// controlState is the part of an emulator's state which determines what runs next.
+type controlState struct {
+	programCounter uint32
+	delaySlot      bool
+	jumpNext       bool
+	jumpTarget     uint32
+	halted         bool
+}
+
+type registerChange struct {
+	register int
+	oldValue uint32
+	newValue uint32
+}
+
+type byteChange struct {
+	addr     uint32
+	oldValue byte
+	newValue byte
+}
+
+// Undo reverts the registers, memory, and program counter to how they were before the
+// instruction which produced a Delta.
+// Deltas must be undone in the reverse of the order in which they were produced.
+//
+// Effects outside of the emulator, such as output from syscalls, are not undone.
+func (e *Emulator) Undo(d *Delta) {
+	for i := len(d.bytes) - 1; i >= 0; i-- {
+		e.Memory.Set(d.bytes[i].addr, d.bytes[i].oldValue)
+	}
+	for _, change := range d.registers {
+		e.RegisterFile[change.register] = change.oldValue
+	}
+	e.setControlState(d.oldControl)
+}
+
+// Redo re-applies a Delta which was undone with Undo, without running the instruction again.
+func (e *Emulator) Redo(d *Delta) {
+	for _, change := range d.bytes {
+		e.Memory.Set(change.addr, change.newValue)
+	}
+	for _, change := range d.registers {
+		e.RegisterFile[change.register] = change.newValue
+	}
+	e.setControlState(d.newControl)
+}
+
+func (e *Emulator) controlState() controlState {
+	return controlState{
+		programCounter: e.ProgramCounter,
+		delaySlot:      e.DelaySlot,
+		jumpNext:       e.JumpNext,
+		jumpTarget:     e.JumpTarget,
+		halted:         e.Halted,
+	}
+}
+
+func (e *Emulator) setControlState(s controlState) {
+	e.ProgramCounter = s.programCounter
+	e.DelaySlot = s.delaySlot
+	e.JumpNext = s.jumpNext
+	e.JumpTarget = s.jumpTarget
+	e.Halted = s.halted
+}
+
+// Clone returns a copy of a Delta which does not share its slices with the original.
+func (d *Delta) Clone() Delta {
+	res := *d
+	res.Registers = append([]int(nil), d.Registers...)
+	res.Memory = append([]uint32(nil), d.Memory...)
+	res.registers = append([]registerChange(nil), d.registers...)
+	res.bytes = append([]byteChange(nil), d.bytes...)
+	return res
+}
+
+// reset empties a Delta for the next instruction, keeping its slices for reuse.
+func (d *Delta) reset(state controlState) {
+	d.Registers = d.Registers[:0]
+	d.Memory = d.Memory[:0]
+	d.oldControl = state
+	d.newControl = state
+	d.registers = d.registers[:0]
+	d.bytes = d.bytes[:0]
+}
+
+func (d *Delta) setRegisters(oldFile, newFile RegisterFile) {
+	for i, val := range newFile {
+		if oldFile[i] != val {
+			d.Registers = append(d.Registers, i)
+			d.registers = append(d.registers, registerChange{i, oldFile[i], val})
+		}
+	}
+}
+
+func (d *Delta) addByte(addr uint32, oldValue, newValue byte) {
+	d.bytes = append(d.bytes, byteChange{addr, oldValue, newValue})
+	wordAddr := addr &^ 3
+	for _, addr := range d.Memory {
+		if addr == wordAddr {
+			return
+		}
+	}
+	d.Memory = append(d.Memory, wordAddr)
+}
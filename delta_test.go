@@ -0,0 +1,74 @@
+package mips32
+
+import "testing"
+
+func TestEmulatorDelta(t *testing.T) {
+	emu := &Emulator{Memory: NewLazyMemory()}
+	emu.RegisterFile[5] = 0x1002
+	emu.RegisterFile[6] = 0x12345678
+
+	insts := []*Instruction{
+		{Name: "ADDIU", Registers: []int{4, 0}, SignedConstant16: 7},
+		{Name: "SW", Registers: []int{6}, MemoryReference: MemoryReference{Register: 5}},
+		{Name: "SB", Registers: []int{6}, MemoryReference: MemoryReference{Register: 5}},
+		{Name: "ADDIU", Registers: []int{4, 4}, SignedConstant16: 0},
+	}
+	expected := []Delta{
+		{Registers: []int{4}},
+		{Memory: []uint32{0x1000, 0x1004}},
+		{Memory: []uint32{0x1000}},
+		{},
+	}
+	for i, inst := range insts {
+		if err := emu.Execute(inst); err != nil {
+			t.Fatal(err)
+		}
+		if !sameInts(emu.LastDelta.Registers, expected[i].Registers) {
+			t.Errorf("instruction %d: unexpected registers %v", i, emu.LastDelta.Registers)
+		}
+		if len(emu.LastDelta.Memory) != len(expected[i].Memory) {
+			t.Errorf("instruction %d: unexpected memory %v", i, emu.LastDelta.Memory)
+			continue
+		}
+		for j, addr := range expected[i].Memory {
+			if emu.LastDelta.Memory[j] != addr {
+				t.Errorf("instruction %d: unexpected memory %v", i, emu.LastDelta.Memory)
+			}
+		}
+	}
+}
+
+func TestUndoSavedDeltas(t *testing.T) {
+	emu := benchmarkEmulator(t)
+	var deltas []Delta
+	for i := 0; i < 2000; i++ {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		deltas = append(deltas, emu.LastDelta.Clone())
+	}
+	for i := len(deltas) - 1; i >= 0; i-- {
+		emu.Undo(&deltas[i])
+	}
+	if emu.RegisterFile != (RegisterFile{}) || emu.ProgramCounter != 0 {
+		t.Errorf("unexpected state after undo: pc=%d registers=%v", emu.ProgramCounter,
+			emu.RegisterFile)
+	}
+	for addr := uint32(0x100); addr < 0x104; addr++ {
+		if b := emu.Memory.Get(addr); b != 0 {
+			t.Errorf("unexpected byte at %d: %d", addr, b)
+		}
+	}
+}
+
+func sameInts(i1, i2 []int) bool {
+	if len(i1) != len(i2) {
+		return false
+	}
+	for i, x := range i1 {
+		if i2[i] != x {
+			return false
+		}
+	}
+	return true
+}
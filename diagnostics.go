@@ -0,0 +1,149 @@
+package mips32
+
+import (
+	"sort"
+	"strconv"
+)
+
+// A SourceError is an error caused by a specific line of an assembly program.
+type SourceError struct {
+	// Line is the 1-based line number of the offending line.
+	Line    int
+	Message string
+
+	// Column and EndColumn are the byte offsets of the offending text within the line, with
+	// EndColumn being exclusive.
+	// If EndColumn is 0, the error applies to the whole line.
+	Column    int
+	EndColumn int
+}
+
+func (s *SourceError) Error() string {
+	return "line " + strconv.Itoa(s.Line) + ": " + s.Message
+}
+
+// CheckSource tokenizes, parses, and encodes a source file, returning every error it finds
+// instead of stopping at the first one.
+// The errors are sorted by line number, and then by column.
+//
+// Errors which depend on the layout of the whole program (e.g. overlapping segments) are only
+// reported if every line can be parsed on its own.
+func CheckSource(source string) []*SourceError {
+	lineOffsets := []int{0}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			lineOffsets = append(lineOffsets, i+1)
+		}
+	}
+	// atSpan points an error at a span of the source, converted to columns of its line.
+	atSpan := func(lineNum int, span Span, msg string) *SourceError {
+		offset := lineOffsets[lineNum-1]
+		return &SourceError{
+			Line:      lineNum,
+			Message:   msg,
+			Column:    span.Start - offset,
+			EndColumn: span.End - offset,
+		}
+	}
+
+	var errs []*SourceError
+	lines, _ := tokenizeSource(source, func(lineNum int, err error) {
+		if spanErr, ok := err.(*spanError); ok {
+			errs = append(errs, atSpan(lineNum, spanErr.span, err.Error()))
+		} else {
+			errs = append(errs, &SourceError{Line: lineNum, Message: err.Error()})
+		}
+	})
+	for _, line := range lines {
+		if inst := line.Instruction; inst != nil {
+			// Symbols are not known yet, so this only catches errors which no symbol could fix.
+			if _, err := parseInstructionSymbols(inst, anySymbolValue); err != nil {
+				errs = append(errs, atSpan(line.LineNumber, inst.NameSpan, err.Error()))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Stable(sourceErrorList(errs))
+		return errs
+	}
+
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		if srcErr, ok := err.(*SourceError); ok {
+			return []*SourceError{srcErr}
+		}
+		return []*SourceError{{Message: err.Error()}}
+	}
+
+	for addr, lineNum := range exc.LineNumbers {
+		if _, err := exc.Get(addr).Encode(addr, exc.Symbols); err != nil {
+			srcErr := &SourceError{Line: lineNum, Message: err.Error()}
+			if line := lineAt(lines, lineNum, exc.lineWordIndex(addr)); line != nil &&
+				line.Instruction != nil && len(line.Instruction.ArgumentSpans) > 0 {
+				// Encoding only fails because of a code pointer, which is the last operand.
+				spans := line.Instruction.ArgumentSpans
+				srcErr = atSpan(lineNum, spans[len(spans)-1], err.Error())
+			}
+			errs = append(errs, srcErr)
+		}
+	}
+	sort.Sort(sourceErrorList(errs))
+	return errs
+}
+
+// lineWordCount returns the number of words which a tokenized line adds to an executable.
+func lineWordCount(line *TokenizedLine) int {
+	if line.Instruction != nil {
+		return 1
+	} else if dir := line.Directive; dir != nil &&
+		(isDataDirective(dir.Name) || isStringDirective(dir.Name)) {
+		return dataWordCount(dir.Name, len(dir.dataValues()))
+	}
+	return 0
+}
+
+// lineAt finds the tokenized line which produced a word, given the word's source line and the
+// index of the word among the words produced by that source line.
+func lineAt(lines []TokenizedLine, lineNum, wordIndex int) *TokenizedLine {
+	for i := range lines {
+		if lines[i].LineNumber != lineNum {
+			continue
+		}
+		words := lineWordCount(&lines[i])
+		if wordIndex < words {
+			return &lines[i]
+		}
+		wordIndex -= words
+	}
+	return nil
+}
+
+// lineWordIndex returns the number of words before an address which came from the same source
+// line.
+func (e *Executable) lineWordIndex(addr uint32) int {
+	var res int
+	lineNum := e.LineNumbers[addr]
+	for otherAddr, otherLine := range e.LineNumbers {
+		if otherLine == lineNum && otherAddr < addr {
+			res++
+		}
+	}
+	return res
+}
+
+type sourceErrorList []*SourceError
+
+func (s sourceErrorList) Len() int {
+	return len(s)
+}
+
+func (s sourceErrorList) Less(i, j int) bool {
+	if s[i].Line != s[j].Line {
+		return s[i].Line < s[j].Line
+	}
+	return s[i].Column < s[j].Column
+}
+
+func (s sourceErrorList) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
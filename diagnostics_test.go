@@ -0,0 +1,111 @@
+package mips32
+
+import "testing"
+
+func TestCheckSource(t *testing.T) {
+	code := "NOP\nADDIU $r1, $r2\nFOO BAR\nORI $r1, $r2, 3\nLUI $r9, 0x10000"
+	errs := CheckSource(code)
+	expectedLines := []int{2, 3, 5}
+	if len(errs) != len(expectedLines) {
+		t.Fatal("unexpected errors:", errs)
+	}
+	for i, err := range errs {
+		if err.Line != expectedLines[i] {
+			t.Error("error", i, "has line", err.Line)
+		}
+	}
+
+	code = "NOP\nJ FOO\nNOP\nBEQ $r1, $r2, BAR"
+	errs = CheckSource(code)
+	if len(errs) != 2 || errs[0].Line != 2 || errs[1].Line != 4 {
+		t.Error("unexpected errors:", errs)
+	}
+
+	code = "NOP\nFOO:\nNOP\nFOO:"
+	errs = CheckSource(code)
+	if len(errs) != 1 || errs[0].Line != 4 {
+		t.Error("unexpected errors:", errs)
+	}
+
+	if errs := CheckSource("FOO:\nJ FOO\nNOP"); len(errs) != 0 {
+		t.Error("unexpected errors:", errs)
+	}
+	if errs := CheckSource("ADDIU $t0, $0, FOO\nFOO:"); len(errs) != 0 {
+		t.Error("unexpected errors:", errs)
+	}
+}
+
+func TestCheckSourceColumns(t *testing.T) {
+	code := "ADDIU $r1 $r2, 3\nFOO $r1, $r2\n\tLW $r1, 4(bad)"
+	errs := CheckSource(code)
+	expected := [][2]int{{6, 9}, {0, 3}, {9, 15}}
+	if len(errs) != len(expected) {
+		t.Fatal("unexpected errors:", errs)
+	}
+	for i, err := range errs {
+		if err.Column != expected[i][0] || err.EndColumn != expected[i][1] {
+			t.Errorf("error %d: expected columns %v but got %d-%d", i, expected[i], err.Column,
+				err.EndColumn)
+		}
+	}
+
+	errs = CheckSource("NOP\nBEQ $r1, $r2, BAR # comment")
+	if len(errs) != 1 || errs[0].Column != 14 || errs[0].EndColumn != 17 {
+		t.Error("unexpected errors:", errs)
+	}
+
+	errs = CheckSource("NOP\nFOO:\nFOO:")
+	if len(errs) != 1 || errs[0].EndColumn != 0 {
+		t.Error("unexpected errors:", errs)
+	}
+}
+
+func TestCheckSourceBlockComments(t *testing.T) {
+	code := "NOP /* a\nFOO BAR\n*/ /* b */ FOO $r1\nNOP"
+	errs := CheckSource(code)
+	if len(errs) != 1 || errs[0].Line != 3 || errs[0].Column != 11 || errs[0].EndColumn != 14 {
+		t.Error("unexpected errors:", errs)
+	}
+
+	errs = CheckSource("NOP\n/* a\nNOP\n")
+	if len(errs) != 1 || errs[0].Line != 2 || errs[0].Message != "unterminated block comment" {
+		t.Error("unexpected errors:", errs)
+	}
+}
+
+func TestCheckSourceStatements(t *testing.T) {
+	errs := CheckSource("NOP; ADDIU $r1 $r2, 3; FOO $r1\n.word 1, 2; NOP; BAR")
+	expected := []struct {
+		line, column, endColumn int
+	}{
+		{1, 11, 14},
+		{1, 23, 26},
+		{2, 17, 20},
+	}
+	if len(errs) != len(expected) {
+		t.Fatal("unexpected errors:", errs)
+	}
+	for i, err := range errs {
+		x := expected[i]
+		if err.Line != x.line || err.Column != x.column || err.EndColumn != x.endColumn {
+			t.Errorf("error %d: expected %v but got %d:%d-%d", i, x, err.Line, err.Column,
+				err.EndColumn)
+		}
+	}
+
+	errs = CheckSource("NOP\n.word 1, 2; J FOO; BEQ $0, $0, BAR // c")
+	if len(errs) != 2 || errs[0].Column != 14 || errs[0].EndColumn != 17 ||
+		errs[1].Column != 31 || errs[1].EndColumn != 34 {
+		t.Error("unexpected errors:", errs)
+	}
+
+	// Strings may hold spaces and semicolons, which do not split fields or statements.
+	errs = CheckSource(".ascii \"a; b  c\"; FOO $r1\n.asciiz \" ; \"; J  BAR")
+	if len(errs) != 1 || errs[0].Line != 1 || errs[0].Column != 18 || errs[0].EndColumn != 21 {
+		t.Error("unexpected errors:", errs)
+	}
+	errs = CheckSource(".ascii \"a; b  c\"; J  BAR")
+	if len(errs) != 1 || errs[0].Column != 21 || errs[0].EndColumn != 24 {
+		t.Error("unexpected errors:", errs)
+	}
+}
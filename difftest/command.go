@@ -0,0 +1,73 @@
+package difftest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Command is a Reference which runs an external program, typically a script which wraps another
+// simulator.
+//
+// The command is run with the path of an assembly file as its last argument.
+// It should run the program in the file and then print the final state, one value per line.
+// Register lines look like "r8=0000002a" and memory lines look like "m10010000=0000002a", with
+// all numbers in hexadecimal.
+// Other lines are ignored, so the simulator's own output can be passed through.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// Run writes the program to a temporary file and runs the command on it.
+func (c *Command) Run(p *Program) (*State, error) {
+	f, err := ioutil.TempFile("", "difftest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(p.Source)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command(c.Name, append(append([]string{}, c.Args...), f.Name())...).Output()
+	if err != nil {
+		return nil, errors.New(c.Name + ": " + err.Error())
+	}
+	return ParseState(string(output))
+}
+
+// ParseState reads the output format described by Command.
+func ParseState(output string) (*State, error) {
+	res := &State{Registers: map[int]uint32{}, Memory: map[uint32]uint32{}}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		eqIdx := strings.IndexByte(line, '=')
+		if len(line) < 2 || (line[0] != 'r' && line[0] != 'm') || eqIdx < 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(line[eqIdx+1:], 16, 32)
+		if err != nil {
+			return nil, errors.New("invalid value: " + line)
+		}
+		if line[0] == 'r' {
+			reg, err := strconv.Atoi(line[1:eqIdx])
+			if err != nil || reg < 0 || reg > 31 {
+				continue
+			}
+			res.Registers[reg] = uint32(value)
+		} else {
+			addr, err := strconv.ParseUint(line[1:eqIdx], 16, 32)
+			if err != nil {
+				continue
+			}
+			res.Memory[uint32(addr)] = uint32(value)
+		}
+	}
+	return res, nil
+}
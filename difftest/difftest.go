@@ -0,0 +1,270 @@
+// Package difftest runs random programs through the emulator and a reference simulator, and
+// reports any differences in the resulting architectural state.
+//
+// A reference simulator is anything which implements Reference.
+// External simulators, such as SPIM or QEMU in user mode, can be used through a Command, which
+// runs a small adapter script for each program.
+package difftest
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+// Config controls the programs produced by Generate.
+type Config struct {
+	// Instructions is the number of random instructions in each program, not counting the
+	// instructions which set up the registers.
+	Instructions int
+
+	// MemoryBase and MemorySize describe the region of memory which programs may load from and
+	// store to.
+	// MemoryBase must be word-aligned and MemorySize must be a multiple of 4 no larger than
+	// 0x8000.
+	MemoryBase uint32
+	MemorySize uint32
+}
+
+// DefaultConfig uses the start of SPIM's data segment as scratch memory.
+var DefaultConfig = Config{
+	Instructions: 32,
+	MemoryBase:   0x10010000,
+	MemorySize:   64,
+}
+
+// baseRegister holds MemoryBase for the whole program.
+// It is never written by the random instructions.
+const baseRegister = 23
+
+// A Program is a randomly generated program.
+//
+// The program starts by loading a random value into every register from $t0 to $s6, and
+// MemoryBase into $s7.
+// It has no branches, jumps, or syscalls, so it behaves the same on simulators with and without
+// delay slots.
+type Program struct {
+	Config Config
+	Source string
+}
+
+// Generate creates a random program.
+func Generate(r *rand.Rand, c *Config) *Program {
+	var lines []string
+	for reg := 8; reg < baseRegister; reg++ {
+		value := r.Uint32()
+		lines = append(lines, "LUI "+regName(reg)+", "+hex(value>>16),
+			"ORI "+regName(reg)+", "+regName(reg)+", "+hex(value&0xffff))
+	}
+	lines = append(lines, "LUI "+regName(baseRegister)+", "+hex(c.MemoryBase>>16),
+		"ORI "+regName(baseRegister)+", "+regName(baseRegister)+", "+hex(c.MemoryBase&0xffff))
+
+	for i := 0; i < c.Instructions; i++ {
+		lines = append(lines, randomInstruction(r, c))
+	}
+	return &Program{Config: *c, Source: strings.Join(lines, "\n") + "\n"}
+}
+
+// State is the architectural state after a program runs.
+type State struct {
+	// Registers maps register indices to values.
+	Registers map[int]uint32
+
+	// Memory maps word-aligned addresses in the scratch region to values.
+	Memory map[uint32]uint32
+}
+
+// A Reference runs programs and reports the state they leave behind.
+//
+// A Reference need not report every register or memory word; only the values which both
+// references report are compared.
+type Reference interface {
+	Run(p *Program) (*State, error)
+}
+
+// Emulator is a Reference which uses this package's emulator.
+type Emulator struct {
+	LittleEndian bool
+}
+
+// Run assembles and runs a program.
+func (e *Emulator) Run(p *Program) (*State, error) {
+	exc, err := mips32.Assemble(p.Source, &mips32.AssembleOptions{LittleEndian: e.LittleEndian})
+	if err != nil {
+		return nil, err
+	}
+	emu := &mips32.Emulator{
+		Memory:       mips32.NewLazyMemory(),
+		Executable:   exc,
+		LittleEndian: e.LittleEndian,
+	}
+	if err := exc.LoadInto(emu.Memory, e.LittleEndian); err != nil {
+		return nil, err
+	}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			return nil, err
+		}
+	}
+	res := &State{Registers: map[int]uint32{}, Memory: map[uint32]uint32{}}
+	for i, value := range emu.RegisterFile {
+		res.Registers[i] = value
+	}
+	for addr := p.Config.MemoryBase; addr < p.Config.MemoryBase+p.Config.MemorySize; addr += 4 {
+		var word uint32
+		for i := uint32(0); i < 4; i++ {
+			shift := 8 * (3 - i)
+			if e.LittleEndian {
+				shift = 8 * i
+			}
+			word |= uint32(emu.Memory.Get(addr+i)) << shift
+		}
+		res.Memory[addr] = word
+	}
+	return res, nil
+}
+
+// A Divergence is a register or memory word whose value differs between two states.
+type Divergence struct {
+	// Location is a register (e.g. "$t0") or a memory address (e.g. "[0x10010004]").
+	Location string
+
+	Expected uint32
+	Actual   uint32
+}
+
+func (d Divergence) String() string {
+	return d.Location + ": expected " + hex(d.Expected) + " but got " + hex(d.Actual)
+}
+
+// Compare lists the values which differ between two states, with registers first and then
+// memory, each in increasing order.
+func Compare(expected, actual *State) []Divergence {
+	var res []Divergence
+	var regs []int
+	for reg := range expected.Registers {
+		regs = append(regs, reg)
+	}
+	sort.Ints(regs)
+	for _, reg := range regs {
+		a, ok := actual.Registers[reg]
+		if e := expected.Registers[reg]; ok && a != e {
+			res = append(res, Divergence{Location: regName(reg), Expected: e, Actual: a})
+		}
+	}
+	var addrs []int
+	for addr := range expected.Memory {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+	for _, addr := range addrs {
+		a, ok := actual.Memory[uint32(addr)]
+		if e := expected.Memory[uint32(addr)]; ok && a != e {
+			res = append(res, Divergence{
+				Location: "[" + hex(uint32(addr)) + "]",
+				Expected: e,
+				Actual:   a,
+			})
+		}
+	}
+	return res
+}
+
+// A Failure describes a program on which two references disagree.
+type Failure struct {
+	Program     *Program
+	Divergences []Divergence
+
+	// Err is set if either reference failed to run the program.
+	Err error
+}
+
+func (f *Failure) String() string {
+	var lines []string
+	if f.Err != nil {
+		lines = append(lines, "error: "+f.Err.Error())
+	}
+	for _, d := range f.Divergences {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n") + "\nprogram:\n" + f.Program.Source
+}
+
+// Run generates count random programs and runs each of them on two references.
+// It returns the first Failure, or nil if the references always agree.
+func Run(r *rand.Rand, c *Config, count int, expected, actual Reference) *Failure {
+	for i := 0; i < count; i++ {
+		program := Generate(r, c)
+		expectedState, err := expected.Run(program)
+		if err != nil {
+			return &Failure{Program: program, Err: errors.New("expected: " + err.Error())}
+		}
+		actualState, err := actual.Run(program)
+		if err != nil {
+			return &Failure{Program: program, Err: errors.New("actual: " + err.Error())}
+		}
+		if len(actualState.Registers) == 0 && len(actualState.Memory) == 0 {
+			return &Failure{Program: program, Err: errors.New("actual: no state reported")}
+		}
+		if divergences := Compare(expectedState, actualState); len(divergences) > 0 {
+			return &Failure{Program: program, Divergences: divergences}
+		}
+	}
+	return nil
+}
+
+func randomInstruction(r *rand.Rand, c *Config) string {
+	dest := regName(8 + r.Intn(baseRegister-8))
+	source := func() string {
+		if r.Intn(8) == 0 {
+			return "$0"
+		}
+		return regName(8 + r.Intn(baseRegister-7))
+	}
+	imm16 := func() string {
+		return strconv.Itoa(r.Intn(0x10000) - 0x8000)
+	}
+	switch r.Intn(6) {
+	case 0:
+		names := []string{"ADDU", "SUBU", "AND", "OR", "XOR", "NOR", "SLT", "SLTU", "MOVN",
+			"MOVZ", "SLLV", "SRLV", "SRAV"}
+		return names[r.Intn(len(names))] + " " + dest + ", " + source() + ", " + source()
+	case 1:
+		names := []string{"ADDIU", "SLTI", "SLTIU"}
+		return names[r.Intn(len(names))] + " " + dest + ", " + source() + ", " + imm16()
+	case 2:
+		names := []string{"ANDI", "ORI", "XORI"}
+		return names[r.Intn(len(names))] + " " + dest + ", " + source() + ", " +
+			hex(uint32(r.Intn(0x10000)))
+	case 3:
+		names := []string{"SLL", "SRL", "SRA"}
+		return names[r.Intn(len(names))] + " " + dest + ", " + source() + ", " +
+			strconv.Itoa(r.Intn(32))
+	case 4:
+		return "LUI " + dest + ", " + hex(uint32(r.Intn(0x10000)))
+	default:
+		names := []string{"LW", "SW", "LB", "LBU", "SB"}
+		name := names[r.Intn(len(names))]
+		offset := r.Intn(int(c.MemorySize))
+		if name == "LW" || name == "SW" {
+			offset &^= 3
+		}
+		reg := dest
+		if name[0] == 'S' {
+			reg = source()
+		}
+		return name + " " + reg + ", " + strconv.Itoa(offset) + "(" + regName(baseRegister) + ")"
+	}
+}
+
+func regName(reg int) string {
+	return "$" + mips32.ABIRegisterNames[reg]
+}
+
+func hex(n uint32) string {
+	return "0x" + strconv.FormatUint(uint64(n), 16)
+}
@@ -0,0 +1,76 @@
+package difftest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		program := Generate(r, &DefaultConfig)
+		if _, err := (&Emulator{}).Run(program); err != nil {
+			t.Fatalf("program %d: %s\n%s", i, err, program.Source)
+		}
+	}
+}
+
+func TestRunAgreement(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	if f := Run(r, &DefaultConfig, 50, &Emulator{}, &Emulator{}); f != nil {
+		t.Fatal("unexpected failure:", f)
+	}
+}
+
+// buggyReference behaves like the emulator, except that it computes SRA like SRL.
+type buggyReference struct{}
+
+func (b buggyReference) Run(p *Program) (*State, error) {
+	buggy := *p
+	buggy.Source = strings.Replace(p.Source, "SRA ", "SRL ", -1)
+	return (&Emulator{}).Run(&buggy)
+}
+
+func TestRunDivergence(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	f := Run(r, &DefaultConfig, 1000, &Emulator{}, buggyReference{})
+	if f == nil {
+		t.Fatal("expected a failure")
+	}
+	if f.Err != nil || len(f.Divergences) == 0 || !strings.Contains(f.Program.Source, "SRA ") {
+		t.Fatal("unexpected failure:", f)
+	}
+}
+
+func TestParseState(t *testing.T) {
+	state, err := ParseState("hello\nr8=0000002a\n  m10010004=ff\nr40=1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Registers) != 1 || state.Registers[8] != 0x2a {
+		t.Error("unexpected registers:", state.Registers)
+	}
+	if len(state.Memory) != 1 || state.Memory[0x10010004] != 0xff {
+		t.Error("unexpected memory:", state.Memory)
+	}
+	if _, err := ParseState("r8=xyz"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	expected := &State{
+		Registers: map[int]uint32{8: 1, 9: 2},
+		Memory:    map[uint32]uint32{0x100: 3},
+	}
+	actual := &State{
+		Registers: map[int]uint32{8: 1, 9: 5},
+		Memory:    map[uint32]uint32{0x100: 4},
+	}
+	divergences := Compare(expected, actual)
+	if len(divergences) != 2 || divergences[0].String() != "$t1: expected 0x2 but got 0x5" ||
+		divergences[1].String() != "[0x100]: expected 0x3 but got 0x4" {
+		t.Error("unexpected divergences:", divergences)
+	}
+}
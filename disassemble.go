@@ -0,0 +1,79 @@
+package mips32
+
+// A SymbolResolver names addresses for the disassembler.
+// It returns false for an address which has no name.
+type SymbolResolver func(addr uint32) (string, bool)
+
+// SymbolTableResolver creates a SymbolResolver which looks up addresses in a symbol table.
+// If several symbols have the same address, the alphabetically first one is used.
+func SymbolTableResolver(symbols map[string]uint32) SymbolResolver {
+	names := map[uint32]string{}
+	for name, addr := range symbols {
+		if old, ok := names[addr]; !ok || name < old {
+			names[addr] = name
+		}
+	}
+	return func(addr uint32) (string, bool) {
+		name, ok := names[addr]
+		return name, ok
+	}
+}
+
+// Disassemble decodes a word which is located at an address, and returns its canonical assembly
+// code, like "ADDIU $8, $9, -4".
+// If resolve is non-nil, branch and jump targets which it can name are written as symbols.
+func Disassemble(word, addr uint32, resolve SymbolResolver) string {
+	return DecodeInstruction(word).Symbolize(addr, resolve).String()
+}
+
+// String returns the canonical assembly code for the instruction, as rendered by Render.
+// If the instruction cannot be rendered, the result ends with a comment saying so.
+func (i *Instruction) String() string {
+	return i.Format(nil)
+}
+
+// Format is like String, but it renders operands according to some options.
+func (i *Instruction) Format(opts *RenderOptions) string {
+	line, err := i.Render()
+	if err != nil {
+		return opts.mnemonic(i.Name) + " # UNRECOGNIZED INSTRUCTION."
+	}
+	return line.Format(opts)
+}
+
+// Symbolize returns a version of the instruction, which is located at an address, with its
+// constant branch or jump target replaced by a symbol if resolve can name the target.
+// The result may be the receiver itself, which is never modified.
+// If resolve is nil, this has no effect.
+func (i *Instruction) Symbolize(addr uint32, resolve SymbolResolver) *Instruction {
+	if resolve == nil {
+		return i
+	}
+	target, ok := i.Target(addr)
+	if !ok {
+		return i
+	}
+	name, ok := resolve(target)
+	if !ok {
+		return i
+	}
+	res := *i
+	res.CodePointer = CodePointer{Absolute: i.CodePointer.Absolute, IsSymbol: true, Symbol: name}
+	return &res
+}
+
+// Target returns the constant branch or jump target of the instruction, which is located at an
+// address.
+// It returns false for other instructions, including ones whose target is a symbol.
+func (i *Instruction) Target(addr uint32) (uint32, bool) {
+	if i.CodePointer.IsSymbol {
+		return 0, false
+	}
+	switch i.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+		return addr + 4 + i.CodePointer.Constant, true
+	case "J", "JAL":
+		return ((addr + 4) & 0xf0000000) | (i.CodePointer.Constant & 0x0fffffff), true
+	}
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package mips32
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	resolve := SymbolTableResolver(map[string]uint32{
+		"loop":  0x1000,
+		"alias": 0x1000,
+		"end":   0x1020,
+	})
+	tests := []struct {
+		word     uint32
+		addr     uint32
+		resolve  SymbolResolver
+		expected string
+	}{
+		{0x2528fffc, 0x1000, resolve, "ADDIU $8, $9, -4"},
+		{0x00000000, 0x1000, resolve, "NOP"},
+		{0x1500ffff, 0x1000, resolve, "BNE $8, $0, alias"},
+		{0x1500ffff, 0x1000, nil, "BNE $8, $0, -4"},
+		{0x1500ffff, 0x1004, resolve, "BNE $8, $0, -4"},
+		{0x08000408, 0x1004, resolve, "J end"},
+		{0x08000408, 0x10001004, resolve, "J 4128"},
+		{0xffffffff, 0x1000, resolve, ".word 4294967295"},
+	}
+	for _, test := range tests {
+		actual := Disassemble(test.word, test.addr, test.resolve)
+		if actual != test.expected {
+			t.Errorf("%08x at %08x: expected %q but got %q", test.word, test.addr,
+				test.expected, actual)
+		}
+	}
+}
+
+func TestInstructionString(t *testing.T) {
+	inst := DecodeInstruction(0x8fbf0008)
+	if s := inst.String(); s != "LW $31, 8($29)" {
+		t.Errorf("unexpected string: %q", s)
+	}
+	opts := &RenderOptions{Registers: ABIRegisters, LowercaseMnemonics: true}
+	if s := inst.Format(opts); s != "lw $ra, 8($sp)" {
+		t.Errorf("unexpected formatted string: %q", s)
+	}
+	bad := &Instruction{Name: "ADDIU", Registers: []int{1}}
+	if s := bad.String(); s != "ADDIU # UNRECOGNIZED INSTRUCTION." {
+		t.Errorf("unexpected string for invalid instruction: %q", s)
+	}
+
+	// Symbolize must not modify the original instruction.
+	branch := DecodeInstruction(0x1000ffff)
+	symbolized := branch.Symbolize(0x1000, SymbolTableResolver(map[string]uint32{"x": 0x1000}))
+	if branch.CodePointer.IsSymbol || symbolized.String() != "BEQ $0, $0, x" {
+		t.Errorf("unexpected symbolized instruction: %s (original %s)", symbolized, branch)
+	}
+}
+
+func TestInstructionTarget(t *testing.T) {
+	tests := []struct {
+		word   uint32
+		addr   uint32
+		target uint32
+		ok     bool
+	}{
+		{0x1500ffff, 0x1000, 0x1000, true},
+		{0x08000408, 0x10001004, 0x10001020, true},
+		{0x2528fffc, 0x1000, 0, false},
+	}
+	for _, test := range tests {
+		target, ok := DecodeInstruction(test.word).Target(test.addr)
+		if target != test.target || ok != test.ok {
+			t.Errorf("%08x at %08x: expected (%08x, %v) but got (%08x, %v)", test.word,
+				test.addr, test.target, test.ok, target, ok)
+		}
+	}
+}
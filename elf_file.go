@@ -0,0 +1,307 @@
+package mips32
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+const (
+	elfHeaderSize        = 52
+	elfProgramHeaderSize = 32
+	elfSectionHeaderSize = 40
+	elfSymbolSize        = 16
+)
+
+// elfSectionNames is the section name string table of the files written by WriteELF.
+const elfSectionNames = "\x00.symtab\x00.strtab\x00.shstrtab\x00"
+
+// An ELFImage is the loadable contents of an ELF executable, along with its symbol table.
+type ELFImage struct {
+	// Chunks maps virtual addresses to the bytes which are loaded there.
+	Chunks map[uint32][]byte
+
+	Entry        uint32
+	LittleEndian bool
+
+	// Symbols maps symbol names to their addresses, and SymbolInfo describes them.
+	// Both may be nil, and symbols which are missing from SymbolInfo are local code symbols.
+	Symbols    map[string]uint32
+	SymbolInfo map[string]SymbolInfo
+}
+
+// ELFImage encodes an executable as an ELF image, including its symbols.
+// The entry point is the start of the first segment.
+func (e *Executable) ELFImage(littleEndian bool) (*ELFImage, error) {
+	chunks, err := e.EncodeBytes(littleEndian)
+	if err != nil {
+		return nil, err
+	}
+	img := &ELFImage{
+		Chunks:       chunks,
+		LittleEndian: littleEndian,
+		Symbols:      e.Symbols,
+		SymbolInfo:   e.SymbolInfo,
+	}
+	if segments := e.sortedSegmentAddresses(); len(segments) > 0 {
+		img.Entry = segments[0]
+	}
+	return img, nil
+}
+
+// Executable decodes every whole word of the image as an instruction, and copies the image's
+// symbols.
+// A chunk which does not start on a word boundary loses its leading partial word.
+func (img *ELFImage) Executable() *Executable {
+	var order binary.ByteOrder = binary.BigEndian
+	if img.LittleEndian {
+		order = binary.LittleEndian
+	}
+	res := &Executable{
+		Segments:   map[uint32][]Instruction{},
+		Symbols:    map[string]uint32{},
+		SymbolInfo: map[string]SymbolInfo{},
+	}
+	for name, addr := range img.Symbols {
+		res.Symbols[name] = addr
+	}
+	for name, info := range img.SymbolInfo {
+		res.SymbolInfo[name] = info
+	}
+	for start, data := range img.Chunks {
+		skip := (4 - start%4) % 4
+		var insts []Instruction
+		for i := skip; i+4 <= uint32(len(data)); i += 4 {
+			insts = append(insts, *DecodeInstruction(order.Uint32(data[i:])))
+		}
+		if len(insts) > 0 {
+			res.Segments[start+skip] = insts
+		}
+	}
+	res.joinContiguousSegments()
+	return res
+}
+
+// WriteELF writes a 32-bit MIPS ELF executable with one loadable segment per chunk.
+//
+// If the image has symbols, they are written to a symbol table, with sections for the symbol
+// table and its strings.
+// Since the file has no sections for the chunks, the symbols have absolute addresses.
+// Otherwise, the resulting file has no section headers.
+func WriteELF(w io.Writer, img *ELFImage) error {
+	var order binary.ByteOrder = binary.BigEndian
+	dataEncoding := elf.ELFDATA2MSB
+	if img.LittleEndian {
+		order = binary.LittleEndian
+		dataEncoding = elf.ELFDATA2LSB
+	}
+
+	starts := make(uint32List, 0, len(img.Chunks))
+	for start := range img.Chunks {
+		starts = append(starts, start)
+	}
+	sort.Sort(starts)
+
+	var dataSize uint32
+	for _, start := range starts {
+		dataSize += uint32(len(img.Chunks[start]))
+	}
+	dataOffset := uint32(elfHeaderSize + elfProgramHeaderSize*len(starts))
+	var symbolSections []byte
+	if len(img.Symbols) > 0 {
+		symbolSections = img.symbolSections(order, dataOffset+dataSize)
+	}
+
+	header := make([]byte, elfHeaderSize)
+	copy(header, elf.ELFMAG)
+	header[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	header[elf.EI_DATA] = byte(dataEncoding)
+	header[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	order.PutUint16(header[16:], uint16(elf.ET_EXEC))
+	order.PutUint16(header[18:], uint16(elf.EM_MIPS))
+	order.PutUint32(header[20:], uint32(elf.EV_CURRENT))
+	order.PutUint32(header[24:], img.Entry)
+	order.PutUint32(header[28:], elfHeaderSize)
+	order.PutUint16(header[40:], elfHeaderSize)
+	order.PutUint16(header[42:], elfProgramHeaderSize)
+	order.PutUint16(header[44:], uint16(len(starts)))
+	if symbolSections != nil {
+		headersSize := elfSectionHeaderSize * 4
+		order.PutUint32(header[32:], dataOffset+dataSize+uint32(len(symbolSections)-headersSize))
+		order.PutUint16(header[46:], elfSectionHeaderSize)
+		order.PutUint16(header[48:], 4)
+		order.PutUint16(header[50:], 3)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	offset := dataOffset
+	for _, start := range starts {
+		size := uint32(len(img.Chunks[start]))
+		progHeader := make([]byte, elfProgramHeaderSize)
+		order.PutUint32(progHeader[0:], uint32(elf.PT_LOAD))
+		order.PutUint32(progHeader[4:], offset)
+		order.PutUint32(progHeader[8:], start)
+		order.PutUint32(progHeader[12:], start)
+		order.PutUint32(progHeader[16:], size)
+		order.PutUint32(progHeader[20:], size)
+		order.PutUint32(progHeader[24:], uint32(elf.PF_R|elf.PF_W|elf.PF_X))
+		order.PutUint32(progHeader[28:], 4)
+		if _, err := w.Write(progHeader); err != nil {
+			return err
+		}
+		offset += size
+	}
+
+	for _, start := range starts {
+		if _, err := w.Write(img.Chunks[start]); err != nil {
+			return err
+		}
+	}
+	if symbolSections != nil {
+		if _, err := w.Write(symbolSections); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symbolSections encodes everything which WriteELF puts after the chunks when there are
+// symbols: the symbol table, its string table, the section name string table, and the section
+// headers (which are the last elfSectionHeaderSize*4 bytes).
+// The offset is where the result will start in the file.
+func (img *ELFImage) symbolSections(order binary.ByteOrder, offset uint32) []byte {
+	// Local symbols must come before global ones.
+	names := make([]string, 0, len(img.Symbols))
+	for name := range img.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		g1, g2 := img.SymbolInfo[names[i]].Global, img.SymbolInfo[names[j]].Global
+		if g1 != g2 {
+			return g2
+		}
+		a1, a2 := img.Symbols[names[i]], img.Symbols[names[j]]
+		if a1 != a2 {
+			return a1 < a2
+		}
+		return names[i] < names[j]
+	})
+
+	// The table is aligned to 4 bytes, and starts with a null symbol.
+	padding := (4 - offset%4) % 4
+	res := make([]byte, padding+elfSymbolSize*uint32(len(names)+1))
+	symtab := res[padding:]
+	strtab := []byte{0}
+	firstGlobal := len(names) + 1
+	for i, name := range names {
+		info := img.SymbolInfo[name]
+		symbolType, binding := elf.STT_FUNC, elf.STB_LOCAL
+		if info.Kind == DataSymbol {
+			symbolType = elf.STT_OBJECT
+		}
+		if info.Global {
+			binding = elf.STB_GLOBAL
+			if i+1 < firstGlobal {
+				firstGlobal = i + 1
+			}
+		}
+		entry := symtab[elfSymbolSize*(i+1):]
+		order.PutUint32(entry[0:], uint32(len(strtab)))
+		order.PutUint32(entry[4:], img.Symbols[name])
+		order.PutUint32(entry[8:], info.Size)
+		entry[12] = elf.ST_INFO(binding, symbolType)
+		order.PutUint16(entry[14:], uint16(elf.SHN_ABS))
+		strtab = append(append(strtab, name...), 0)
+	}
+
+	symtabOffset := offset + padding
+	strtabOffset := symtabOffset + uint32(len(symtab))
+	namesOffset := strtabOffset + uint32(len(strtab))
+	res = append(append(res, strtab...), elfSectionNames...)
+	for (offset+uint32(len(res)))%4 != 0 {
+		res = append(res, 0)
+	}
+
+	// The first section header is null.
+	headers := make([]byte, elfSectionHeaderSize*4)
+	putSection := func(index int, name uint32, sectionType elf.SectionType, offset, size, link,
+		info, align, entrySize uint32) {
+		header := headers[elfSectionHeaderSize*index:]
+		order.PutUint32(header[0:], name)
+		order.PutUint32(header[4:], uint32(sectionType))
+		order.PutUint32(header[16:], offset)
+		order.PutUint32(header[20:], size)
+		order.PutUint32(header[24:], link)
+		order.PutUint32(header[28:], info)
+		order.PutUint32(header[32:], align)
+		order.PutUint32(header[36:], entrySize)
+	}
+	putSection(1, 1, elf.SHT_SYMTAB, symtabOffset, uint32(len(symtab)), 2, uint32(firstGlobal),
+		4, elfSymbolSize)
+	putSection(2, 9, elf.SHT_STRTAB, strtabOffset, uint32(len(strtab)), 0, 0, 1, 0)
+	putSection(3, 17, elf.SHT_STRTAB, namesOffset, uint32(len(elfSectionNames)), 0, 0, 1, 0)
+	return append(res, headers...)
+}
+
+// ReadELF reads the loadable segments and the symbol table of a 32-bit MIPS ELF executable.
+// Zero-filled memory at the end of a segment (e.g. .bss) is included in its chunk.
+//
+// Symbols which label objects are data symbols, and every other symbol is a code symbol.
+// Section and file symbols are ignored.
+func ReadELF(r io.ReaderAt) (*ELFImage, error) {
+	file, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if file.Class != elf.ELFCLASS32 {
+		return nil, errors.New("not a 32-bit ELF file")
+	} else if file.Machine != elf.EM_MIPS {
+		return nil, errors.New("not a MIPS ELF file")
+	}
+	img := &ELFImage{
+		Chunks:       map[uint32][]byte{},
+		Entry:        uint32(file.Entry),
+		LittleEndian: file.Data == elf.ELFDATA2LSB,
+	}
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Memsz == 0 {
+			continue
+		}
+		if prog.Filesz > prog.Memsz {
+			return nil, errors.New("segment file size exceeds memory size")
+		}
+		data := make([]byte, prog.Memsz)
+		if _, err := prog.ReadAt(data[:prog.Filesz], 0); err != nil {
+			return nil, err
+		}
+		img.Chunks[uint32(prog.Vaddr)] = data
+	}
+
+	symbols, err := file.Symbols()
+	if err == elf.ErrNoSymbols {
+		return img, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, sym := range symbols {
+		symbolType := elf.ST_TYPE(sym.Info)
+		if sym.Name == "" || symbolType == elf.STT_SECTION || symbolType == elf.STT_FILE {
+			continue
+		}
+		if img.Symbols == nil {
+			img.Symbols = map[string]uint32{}
+			img.SymbolInfo = map[string]SymbolInfo{}
+		}
+		info := SymbolInfo{Size: uint32(sym.Size), Global: elf.ST_BIND(sym.Info) != elf.STB_LOCAL}
+		if symbolType == elf.STT_OBJECT {
+			info.Kind = DataSymbol
+		}
+		img.Symbols[sym.Name] = uint32(sym.Value)
+		img.SymbolInfo[sym.Name] = info
+	}
+	return img, nil
+}
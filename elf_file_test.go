@@ -0,0 +1,111 @@
+package mips32
+
+import (
+	"bytes"
+	"debug/elf"
+	"reflect"
+	"testing"
+)
+
+func TestELFRoundTrip(t *testing.T) {
+	for _, little := range []bool{false, true} {
+		img := &ELFImage{
+			Chunks: map[uint32][]byte{
+				0x400000:   []byte{0x3c, 0x01, 0xde, 0xad, 0, 0, 0, 0},
+				0x10000000: []byte{1, 2, 3},
+			},
+			Entry:        0x400000,
+			LittleEndian: little,
+		}
+		var buf bytes.Buffer
+		if err := WriteELF(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ReadELF(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, img) {
+			t.Errorf("expected %v but got %v", img, decoded)
+		}
+	}
+}
+
+func TestELFSymbols(t *testing.T) {
+	exc, err := Assemble(`.globl main
+main:
+	ADDIU $t0, $zero, 1
+loop:
+	BNE $t0, $zero, loop
+	NOP
+.text 0x1000
+table:
+	.half 1, 2, 3
+.size table, 6
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, little := range []bool{false, true} {
+		img, err := exc.ELFImage(little)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteELF(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+
+		// The standard library must be able to read the symbol table.
+		file, err := elf.NewFile(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		symbols, err := file.Symbols()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, sym := range symbols {
+			names = append(names, sym.Name)
+		}
+		if !reflect.DeepEqual(names, []string{"loop", "table", "main"}) {
+			t.Errorf("unexpected symbol order: %v", names)
+		}
+
+		decoded, err := ReadELF(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedInfo := map[string]SymbolInfo{
+			"main":  {Kind: CodeSymbol, Size: 4, Global: true},
+			"loop":  {Kind: CodeSymbol, Size: 8},
+			"table": {Kind: DataSymbol, Size: 6},
+		}
+		if decoded.Entry != 0 || !reflect.DeepEqual(decoded.Chunks, img.Chunks) {
+			t.Error("unexpected contents")
+		}
+		if !reflect.DeepEqual(decoded.Symbols, exc.Symbols) {
+			t.Errorf("unexpected symbols: %v", decoded.Symbols)
+		}
+		if !reflect.DeepEqual(decoded.SymbolInfo, expectedInfo) {
+			t.Errorf("unexpected symbol info: %v", decoded.SymbolInfo)
+		}
+
+		loaded := decoded.Executable()
+		if !reflect.DeepEqual(loaded.Symbols, exc.Symbols) ||
+			!reflect.DeepEqual(loaded.SymbolInfo, expectedInfo) {
+			t.Error("unexpected symbols in executable")
+		}
+		expectedWords, err := exc.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actualWords, err := loaded.Encode()
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(actualWords, expectedWords) {
+			t.Errorf("expected words %v but got %v", expectedWords, actualWords)
+		}
+	}
+}
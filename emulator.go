@@ -24,12 +24,20 @@ func (r RegisterFile) String() string {
 	return res
 }
 
+// An Emulator runs an Executable one instruction at a time.
+//
+// An Emulator is not safe for concurrent use.
+// To inspect a program from one goroutine while it runs on another, use a SyncEmulator.
 type Emulator struct {
 	RegisterFile   RegisterFile
 	Memory         Memory
-	Executable     *Executable
 	ProgramCounter uint32
 
+	// Executable holds the program's instructions.
+	// If it is nil, the emulator behaves as if the program had no instructions, which is useful
+	// for running instructions one at a time with Execute.
+	Executable *Executable
+
 	LittleEndian      bool
 	ForceMemAlignment bool
 
@@ -42,61 +50,102 @@ type Emulator struct {
 
 	// JumpTarget is the target location for the jump/branch referred to by JumpNext.
 	JumpTarget uint32
+
+	// Syscalls handles SYSCALL instructions.
+	// If it is nil, SYSCALL instructions fail.
+	Syscalls SyscallHandler
+
+	// Halted is set when the program asks to stop (e.g. with an exit syscall).
+	Halted bool
+
+	// AfterExecute, if non-nil, is called after each instruction runs successfully.
+	// It is passed the instruction (nil for a NOP past the end of the program) and the address
+	// it ran from.
+	AfterExecute func(inst *Instruction, addr uint32)
+
+	// LastDelta records what the most recent call to Execute or Step changed.
+	// Its slices are reused by the next instruction, so that Step does not allocate.
+	// To keep a Delta (e.g. for Undo) after running more instructions, keep a copy made with
+	// Delta.Clone.
+	//
+	// When AfterExecute is nil, StepN and Run do not record what each instruction changes, since
+	// that makes them several times faster.
+	// Afterwards, LastDelta records no changes.
+	LastDelta Delta
+
+	// instructionAddress is the address of the instruction being executed.
+	instructionAddress uint32
+
+	// code caches decoded instructions for StepN and Run.
+	code decodeCache
+
+	// skipDelta is set while StepN and Run are not recording LastDelta.
+	skipDelta bool
 }
 
-// Done returns true if the program has begun to execute NOPs past the executable code.
+// Done returns true if the program has halted or has begun to execute NOPs past the executable
+// code.
 func (e *Emulator) Done() bool {
-	if e.JumpNext {
+	if e.Halted {
+		return true
+	} else if e.JumpNext {
 		return false
 	}
-	return e.ProgramCounter >= e.Executable.End()
+	return e.ProgramCounter >= e.program().End()
 }
 
 // Step performs the next instruction on the CPU.
 // If the instruction fails, then this will return an error.
 // In the case of an error, the program counter may still be changed as usual.
 func (e *Emulator) Step() error {
-	inst := e.Executable.Get(e.ProgramCounter)
-	if e.JumpNext {
-		e.DelaySlot = true
-		e.JumpNext = false
-		e.ProgramCounter = e.JumpTarget
-	} else {
-		e.DelaySlot = false
-		e.ProgramCounter += 4
+	return e.Execute(e.program().Get(e.ProgramCounter))
+}
+
+// Execute performs an instruction as if it were located at the current program counter.
+// The program counter is updated just like it would be by Step.
+// A nil instruction is treated as a NOP.
+//
+// This makes it possible to run instructions which are not in the Executable, and even to run
+// instructions on an Emulator whose Executable is nil.
+// In the latter case, branches and jumps may not refer to symbols, and Step and StepN run the
+// delay slot of a jump as a NOP.
+func (e *Emulator) Execute(inst *Instruction) error {
+	addr := e.ProgramCounter
+	e.instructionAddress = addr
+	oldRegisters := e.RegisterFile
+	e.LastDelta.reset(e.controlState())
+	err := e.execute(inst)
+	e.LastDelta.setRegisters(oldRegisters, e.RegisterFile)
+	e.LastDelta.newControl = e.controlState()
+	if err != nil {
+		return err
 	}
+	if e.AfterExecute != nil {
+		e.AfterExecute(inst, addr)
+	}
+	return nil
+}
+
+func (e *Emulator) execute(inst *Instruction) error {
+	e.advance()
 
 	// If there is no instruction in the ROM, we assume it is a NOP.
 	if inst == nil {
 		return nil
 	}
+	return instructionHandlerFor(inst.Name)(e, inst)
+}
 
-	switch inst.Name {
-	case "NOP":
-	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
-		return e.executeBranch(inst)
-	case "J", "JR", "JAL", "JALR":
-		return e.executeJump(inst)
-	case "LB", "LBU", "LW", "SB", "SW":
-		return e.executeMemory(inst)
-	case "ADDU", "AND", "NOR", "OR", "SUBU", "XOR":
-		e.executeRegisterArithmetic(inst)
-	case "ADDIU", "ANDI", "ORI", "XORI":
-		e.executeImmediateArithmetic(inst)
-	case "LUI":
-		e.executeLoadUpperImmediate(inst)
-	case "SLT", "SLTI", "SLTIU", "SLTU":
-		e.executeSetLessThan(inst)
-	case "SLL", "SRL", "SRA":
-		e.executeConstantShift(inst)
-	case "SLLV", "SRLV", "SRAV":
-		e.executeRegisterShift(inst)
-	case "MOVN", "MOVZ":
-		e.executeConditionalMove(inst)
-	default:
-		return errors.New("unknown instruction: " + inst.Name)
+// advance moves the program counter past the instruction which is about to run.
+func (e *Emulator) advance() {
+	if e.JumpNext {
+		e.DelaySlot = true
+		e.JumpNext = false
+		e.ProgramCounter = e.JumpTarget
+	} else {
+		e.DelaySlot = false
+		e.ProgramCounter += 4
 	}
-	return nil
 }
 
 func (e *Emulator) executeBranch(inst *Instruction) error {
@@ -104,7 +153,7 @@ func (e *Emulator) executeBranch(inst *Instruction) error {
 		return errors.New("branch in delay slot yields unpredictable behavior")
 	}
 
-	offset, err := instructionBranchOffset(inst, e.ProgramCounter-4, e.Executable.Symbols)
+	offset, err := instructionBranchOffset(inst, e.ProgramCounter-4, e.symbols())
 	if err != nil {
 		return e.instructionError(err.Error())
 	}
@@ -134,7 +183,7 @@ func (e *Emulator) executeJump(inst *Instruction) error {
 	}
 
 	if inst.Name == "J" || inst.Name == "JAL" {
-		offset, err := instructionJumpBase(inst, e.ProgramCounter-4, e.Executable.Symbols)
+		offset, err := instructionJumpBase(inst, e.ProgramCounter-4, e.symbols())
 		if err != nil {
 			return e.instructionError(err.Error())
 		}
@@ -169,45 +218,87 @@ func (e *Emulator) executeMemory(inst *Instruction) error {
 	register := inst.Registers[0]
 	registerValue := e.RegisterFile[register]
 
+	req := MemoryRequest{
+		Address:      address,
+		Size:         1,
+		PC:           e.instructionAddress,
+		LittleEndian: e.LittleEndian,
+	}
 	switch inst.Name {
-	case "LB":
-		e.setReg(register, uint32(int8(e.Memory.Get(address))))
-	case "LBU":
-		e.setReg(register, uint32(e.Memory.Get(address)))
+	case "LB", "LBU":
+		value, err := e.load(req)
+		if err != nil {
+			return err
+		}
+		if inst.Name == "LB" {
+			value = uint32(int8(value))
+		}
+		e.setReg(register, value)
 	case "LW":
 		if e.ForceMemAlignment && (address&3) != 0 {
 			return e.instructionError("misaligned load word: 0x" +
 				strconv.FormatUint(uint64(address), 16))
 		}
-		if e.LittleEndian {
-			e.setReg(register, (uint32(e.Memory.Get(address+3))<<24)|
-				(uint32(e.Memory.Get(address+2))<<16)|(uint32(e.Memory.Get(address+1))<<8)|
-				uint32(e.Memory.Get(address)))
-		} else {
-			e.setReg(register, (uint32(e.Memory.Get(address))<<24)|
-				(uint32(e.Memory.Get(address+1))<<16)|(uint32(e.Memory.Get(address+2))<<8)|
-				uint32(e.Memory.Get(address+3)))
+		req.Size = 4
+		value, err := e.load(req)
+		if err != nil {
+			return err
 		}
+		e.setReg(register, value)
 	case "SB":
-		e.Memory.Set(address, byte(registerValue))
+		return e.store(req, registerValue)
 	case "SW":
 		if e.ForceMemAlignment && (address&3) != 0 {
 			return e.instructionError("misaligned store word: 0x" +
 				strconv.FormatUint(uint64(address), 16))
 		}
-		if e.LittleEndian {
-			e.Memory.Set(address+3, byte(registerValue>>24))
-			e.Memory.Set(address+2, byte(registerValue>>16))
-			e.Memory.Set(address+1, byte(registerValue>>8))
-			e.Memory.Set(address, byte(registerValue))
-		} else {
-			e.Memory.Set(address, byte(registerValue>>24))
-			e.Memory.Set(address+1, byte(registerValue>>16))
-			e.Memory.Set(address+2, byte(registerValue>>8))
-			e.Memory.Set(address+3, byte(registerValue))
+		req.Size = 4
+		return e.store(req, registerValue)
+	}
+
+	return nil
+}
+
+// load reads memory for a load instruction, using the MemoryBackend interface if possible.
+func (e *Emulator) load(r MemoryRequest) (uint32, error) {
+	if backend, ok := e.Memory.(MemoryBackend); ok {
+		value, err := backend.Load(r)
+		if err != nil {
+			return 0, e.instructionError(err.Error())
 		}
+		return value, nil
 	}
+	return LoadBytes(e.Memory, r), nil
+}
 
+// store writes memory for a store instruction, using the MemoryBackend interface if possible,
+// and records the write in LastDelta.
+func (e *Emulator) store(r MemoryRequest, value uint32) error {
+	backend, ok := e.Memory.(MemoryBackend)
+	if e.skipDelta {
+		if ok {
+			if err := backend.Store(r, value); err != nil {
+				return e.instructionError(err.Error())
+			}
+		} else {
+			StoreBytes(e.Memory, r, value)
+		}
+		return nil
+	} else if !ok {
+		StoreBytes(deltaMemory{e}, r, value)
+		return nil
+	}
+	var old [4]byte
+	for i := 0; i < r.Size; i++ {
+		old[i] = e.Memory.Get(r.Address + uint32(i))
+	}
+	if err := backend.Store(r, value); err != nil {
+		return e.instructionError(err.Error())
+	}
+	for i := 0; i < r.Size; i++ {
+		addr := r.Address + uint32(i)
+		e.LastDelta.addByte(addr, old[i], e.Memory.Get(addr))
+	}
 	return nil
 }
 
@@ -341,6 +432,45 @@ func (e *Emulator) instructionError(msg string) error {
 	return errors.New("error at " + pcStr + ": " + msg)
 }
 
+// noProgram stands in for a nil Executable.
+var noProgram = &Executable{}
+
+// program returns the Executable, or an empty one if it is nil.
+func (e *Emulator) program() *Executable {
+	if e.Executable == nil {
+		return noProgram
+	}
+	return e.Executable
+}
+
+func (e *Emulator) symbols() map[string]uint32 {
+	if e.Executable == nil {
+		return nil
+	}
+	return e.Executable.Symbols
+}
+
+// deltaMemory writes to an emulator's memory and records the writes in LastDelta.
+type deltaMemory struct {
+	e *Emulator
+}
+
+func (d deltaMemory) Get(addr uint32) byte {
+	return d.e.Memory.Get(addr)
+}
+
+func (d deltaMemory) Set(addr uint32, b byte) {
+	d.e.storeByte(addr, b)
+}
+
+// storeByte writes a byte to memory and records the write in LastDelta.
+func (e *Emulator) storeByte(addr uint32, b byte) {
+	if !e.skipDelta {
+		e.LastDelta.addByte(addr, e.Memory.Get(addr), b)
+	}
+	e.Memory.Set(addr, b)
+}
+
 func (e *Emulator) setReg(r int, val uint32) {
 	if r == 0 {
 		return
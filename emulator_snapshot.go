@@ -0,0 +1,307 @@
+package mips32
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+)
+
+// SnapshotMagic begins every emulator snapshot.
+const SnapshotMagic = "MIPSSNAP"
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+// ReadSnapshot accepts snapshots of this version or older ones, which it migrates as it reads
+// them.
+const SnapshotVersion = 1
+
+// SnapshotFeatures are flags in a snapshot's header which say what the snapshot contains beyond
+// the CPU state.
+type SnapshotFeatures uint32
+
+const (
+	// SnapshotMemory means the snapshot contains the emulator's memory.
+	SnapshotMemory SnapshotFeatures = 1 << iota
+
+	// SnapshotSyscallState means the snapshot contains the heap pointer and exit code of a
+	// SPIMSyscalls handler.
+	SnapshotSyscallState
+)
+
+const supportedSnapshotFeatures = SnapshotMemory | SnapshotSyscallState
+
+const (
+	snapshotHeaderSize = len(SnapshotMagic) + 12
+	snapshotPageSize   = 0x1000
+)
+
+// Bits of the control word in a snapshot's body.
+const (
+	snapshotLittleEndian = 1 << iota
+	snapshotForceAlignment
+	snapshotDelaySlot
+	snapshotJumpNext
+	snapshotHalted
+)
+
+// A SnapshotHeader describes an emulator snapshot.
+//
+// A snapshot is laid out as follows, with every number stored as a big-endian uint32:
+//
+//	magic     SnapshotMagic (8 bytes)
+//	version   the format version
+//	features  the SnapshotFeatures
+//	length    the number of bytes in the body
+//	body      the state, whose layout depends on the version
+//	checksum  the CRC-32 (IEEE) of everything before it
+//
+// In version 1, the body holds the program counter, the jump target, a control word (with bits
+// for LittleEndian, ForceMemAlignment, DelaySlot, JumpNext, and Halted, in that order), and the
+// 32 registers.
+// With SnapshotSyscallState, the heap pointer and exit code follow.
+// With SnapshotMemory, the number of memory pages follows, and then each page's address and
+// its 4096 bytes, in order of address.
+// Pages which are entirely zero are omitted.
+type SnapshotHeader struct {
+	Version  uint32
+	Features SnapshotFeatures
+}
+
+// ParseSnapshotHeader reads the header at the start of a snapshot.
+// It fails if the data is not a snapshot, but it does not check the version, features, or
+// checksum.
+func ParseSnapshotHeader(data []byte) (*SnapshotHeader, error) {
+	if len(data) < snapshotHeaderSize || string(data[:len(SnapshotMagic)]) != SnapshotMagic {
+		return nil, errors.New("not an emulator snapshot")
+	}
+	fields := data[len(SnapshotMagic):]
+	return &SnapshotHeader{
+		Version:  binary.BigEndian.Uint32(fields),
+		Features: SnapshotFeatures(binary.BigEndian.Uint32(fields[4:])),
+	}, nil
+}
+
+// WriteSnapshot saves the emulator's registers, control state, and memory.
+// If the Syscalls handler is a *SPIMSyscalls, its heap pointer and exit code are saved too.
+//
+// The memory must be a *LazyMemory, a *FlatMemory, or nil.
+// The executable is not saved, so it must be supplied again to resume the program.
+func (e *Emulator) WriteSnapshot(w io.Writer) error {
+	features := SnapshotFeatures(0)
+	var body []byte
+	body = appendUint32(body, e.ProgramCounter)
+	body = appendUint32(body, e.JumpTarget)
+	body = appendUint32(body, e.snapshotControlWord())
+	for _, reg := range e.RegisterFile {
+		body = appendUint32(body, reg)
+	}
+	if s, ok := e.Syscalls.(*SPIMSyscalls); ok {
+		features |= SnapshotSyscallState
+		body = appendUint32(body, s.HeapPointer)
+		body = appendUint32(body, uint32(int32(s.ExitCode)))
+	}
+	if e.Memory != nil {
+		pages, err := snapshotPages(e.Memory)
+		if err != nil {
+			return err
+		}
+		features |= SnapshotMemory
+		addrs := make(uint32List, 0, len(pages))
+		for addr := range pages {
+			addrs = append(addrs, addr)
+		}
+		sort.Sort(addrs)
+		body = appendUint32(body, uint32(len(addrs)))
+		for _, addr := range addrs {
+			body = appendUint32(body, addr)
+			body = append(body, pages[addr]...)
+		}
+	}
+
+	data := append([]byte(SnapshotMagic), make([]byte, 12)...)
+	binary.BigEndian.PutUint32(data[len(SnapshotMagic):], SnapshotVersion)
+	binary.BigEndian.PutUint32(data[len(SnapshotMagic)+4:], uint32(features))
+	binary.BigEndian.PutUint32(data[len(SnapshotMagic)+8:], uint32(len(body)))
+	data = append(data, body...)
+	data = appendUint32(data, crc32.ChecksumIEEE(data))
+	_, err := w.Write(data)
+	return err
+}
+
+// ReadSnapshot restores state saved by WriteSnapshot.
+//
+// It fails without modifying the emulator if the snapshot is corrupted, if it was written by a
+// newer version of the format, or if it uses features which this version does not know about.
+//
+// If the snapshot contains memory, the memory is replaced by a new LazyMemory, unless it is a
+// *FlatMemory, in which case its contents are overwritten and bytes outside of it are dropped.
+// Syscall state is only restored if the Syscalls handler is a *SPIMSyscalls.
+func (e *Emulator) ReadSnapshot(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	header, err := ParseSnapshotHeader(data)
+	if err != nil {
+		return err
+	}
+	if header.Version == 0 || header.Version > SnapshotVersion {
+		return errors.New("unsupported snapshot version: " +
+			strconv.FormatUint(uint64(header.Version), 10))
+	} else if extra := header.Features &^ supportedSnapshotFeatures; extra != 0 {
+		return errors.New("unsupported snapshot features: 0x" +
+			strconv.FormatUint(uint64(extra), 16))
+	}
+	bodyLength := binary.BigEndian.Uint32(data[len(SnapshotMagic)+8:])
+	if uint64(len(data)) != uint64(snapshotHeaderSize)+uint64(bodyLength)+4 {
+		return errors.New("snapshot has the wrong length")
+	}
+	checksumIndex := len(data) - 4
+	if crc32.ChecksumIEEE(data[:checksumIndex]) != binary.BigEndian.Uint32(data[checksumIndex:]) {
+		return errors.New("snapshot checksum mismatch")
+	}
+	body := &snapshotReader{data: data[snapshotHeaderSize:checksumIndex]}
+	return e.restoreSnapshot(header, body)
+}
+
+// restoreSnapshot decodes the body of a snapshot and applies it to the emulator.
+// Bodies from older versions of the format would be migrated here.
+func (e *Emulator) restoreSnapshot(header *SnapshotHeader, body *snapshotReader) error {
+	pc := body.next()
+	jumpTarget := body.next()
+	control := body.next()
+	var registers RegisterFile
+	for i := range registers {
+		registers[i] = body.next()
+	}
+	var heapPointer, exitCode uint32
+	if header.Features&SnapshotSyscallState != 0 {
+		heapPointer = body.next()
+		exitCode = body.next()
+	}
+	pages := map[uint32][]byte{}
+	if header.Features&SnapshotMemory != 0 {
+		count := body.next()
+		for i := uint32(0); i < count && body.err == nil; i++ {
+			addr := body.next()
+			if addr%snapshotPageSize != 0 {
+				return errors.New("snapshot page is misaligned")
+			}
+			pages[addr] = body.bytes(snapshotPageSize)
+		}
+	}
+	if body.err != nil {
+		return body.err
+	} else if len(body.data) != 0 {
+		return errors.New("snapshot has extra data")
+	}
+
+	if header.Features&SnapshotMemory != 0 {
+		switch memory := e.Memory.(type) {
+		case *FlatMemory:
+			for i := range memory.Data {
+				memory.Data[i] = 0
+			}
+		case *LazyMemory, nil:
+			e.Memory = NewLazyMemory()
+		default:
+			return errors.New("cannot restore memory of this type")
+		}
+		for addr, page := range pages {
+			for i, b := range page {
+				e.Memory.Set(addr+uint32(i), b)
+			}
+		}
+	}
+	if s, ok := e.Syscalls.(*SPIMSyscalls); ok && header.Features&SnapshotSyscallState != 0 {
+		s.HeapPointer = heapPointer
+		s.ExitCode = int(int32(exitCode))
+	}
+	e.ProgramCounter = pc
+	e.JumpTarget = jumpTarget
+	e.RegisterFile = registers
+	e.LittleEndian = control&snapshotLittleEndian != 0
+	e.ForceMemAlignment = control&snapshotForceAlignment != 0
+	e.DelaySlot = control&snapshotDelaySlot != 0
+	e.JumpNext = control&snapshotJumpNext != 0
+	e.Halted = control&snapshotHalted != 0
+	return nil
+}
+
+func (e *Emulator) snapshotControlWord() uint32 {
+	var res uint32
+	flags := []bool{e.LittleEndian, e.ForceMemAlignment, e.DelaySlot, e.JumpNext, e.Halted}
+	for i, flag := range flags {
+		if flag {
+			res |= 1 << uint(i)
+		}
+	}
+	return res
+}
+
+// snapshotPages finds the non-zero pages of a memory.
+func snapshotPages(mem Memory) (map[uint32][]byte, error) {
+	res := map[uint32][]byte{}
+	addPage := func(addr uint32, page []byte) {
+		for _, b := range page {
+			if b != 0 {
+				res[addr] = page
+				return
+			}
+		}
+	}
+	switch mem := mem.(type) {
+	case *LazyMemory:
+		for addr, page := range mem.pages {
+			addPage(addr, page)
+		}
+	case *FlatMemory:
+		if len(mem.Data) == 0 {
+			break
+		}
+		start := uint64(mem.Base) &^ (snapshotPageSize - 1)
+		end := uint64(mem.Base) + uint64(len(mem.Data))
+		for addr := start; addr < end; addr += snapshotPageSize {
+			page := make([]byte, snapshotPageSize)
+			for i := range page {
+				page[i] = mem.Get(uint32(addr) + uint32(i))
+			}
+			addPage(uint32(addr), page)
+		}
+	default:
+		return nil, errors.New("cannot snapshot memory of this type")
+	}
+	return res, nil
+}
+
+func appendUint32(data []byte, x uint32) []byte {
+	return append(data, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+// A snapshotReader decodes the body of a snapshot, recording the first error it encounters.
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (s *snapshotReader) next() uint32 {
+	if b := s.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (s *snapshotReader) bytes(n int) []byte {
+	if s.err != nil {
+		return nil
+	} else if len(s.data) < n {
+		s.err = errors.New("snapshot body is truncated")
+		return nil
+	}
+	res := s.data[:n]
+	s.data = s.data[n:]
+	return res
+}
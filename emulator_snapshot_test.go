@@ -0,0 +1,156 @@
+package mips32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestEmulatorSnapshot(t *testing.T) {
+	exc, err := Assemble(`ADDIU $t0, $0, 0x2000
+	ADDIU $t1, $0, 5
+LOOP:
+	SW $t1, 0($t0)
+	ADDIU $t1, $t1, -1
+	BNE $t1, $0, LOOP
+	ADDIU $t0, $t0, 4
+	ADDIU $v0, $0, 17
+	ADDIU $a0, $0, 3
+	SYSCALL`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emulator := &Emulator{
+		Memory:            NewLazyMemory(),
+		Executable:        exc,
+		LittleEndian:      true,
+		ForceMemAlignment: true,
+		Syscalls:          &SPIMSyscalls{HeapPointer: 0x12345678},
+	}
+	emulator.Memory.Set(0x80000, 7)
+	for i := 0; i < 5; i++ {
+		if err := emulator.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !emulator.JumpNext {
+		t.Fatal("expected to stop before a delay slot")
+	}
+
+	var buf bytes.Buffer
+	if err := emulator.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	header, err := ParseSnapshotHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if *header != (SnapshotHeader{SnapshotVersion, SnapshotMemory | SnapshotSyscallState}) {
+		t.Errorf("unexpected header: %+v", header)
+	}
+
+	restored := &Emulator{Executable: exc, Syscalls: &SPIMSyscalls{}}
+	if err := restored.ReadSnapshot(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if restored.RegisterFile != emulator.RegisterFile ||
+		restored.controlState() != emulator.controlState() || !restored.LittleEndian ||
+		!restored.ForceMemAlignment {
+		t.Fatal("restored state differs from the original")
+	}
+	if restored.Syscalls.(*SPIMSyscalls).HeapPointer != 0x12345678 {
+		t.Error("heap pointer was not restored")
+	}
+	for _, e := range []*Emulator{emulator, restored} {
+		for !e.Done() {
+			if err := e.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if restored.RegisterFile != emulator.RegisterFile ||
+		restored.Syscalls.(*SPIMSyscalls).ExitCode != 3 {
+		t.Error("restored emulator ran differently")
+	}
+	for _, addr := range []uint32{0x2000, 0x2004, 0x2010, 0x80000} {
+		if restored.Memory.Get(addr) != emulator.Memory.Get(addr) {
+			t.Errorf("memory differs at %08x", addr)
+		}
+	}
+
+	// Flat memories are overwritten in place.
+	flat := &Emulator{Memory: NewFlatMemory(0x1800, 0x1000)}
+	flat.Memory.Set(0x2400, 9)
+	if err := flat.ReadSnapshot(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if flat.Memory.Get(0x2000) != 5 || flat.Memory.Get(0x2400) != 0 {
+		t.Error("unexpected flat memory contents")
+	}
+	var flatBuf bytes.Buffer
+	if err := flat.WriteSnapshot(&flatBuf); err != nil {
+		t.Fatal(err)
+	}
+	header, _ = ParseSnapshotHeader(flatBuf.Bytes())
+	if header.Features != SnapshotMemory {
+		t.Errorf("unexpected features: %v", header.Features)
+	}
+}
+
+func TestEmulatorSnapshotErrors(t *testing.T) {
+	var buf bytes.Buffer
+	emulator := &Emulator{Memory: NewLazyMemory(), ProgramCounter: 8}
+	emulator.Memory.Set(0x1000, 1)
+	if err := emulator.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	// modified copies the snapshot, changes it, and fixes its checksum.
+	modified := func(f func(data []byte) []byte) []byte {
+		data := f(append([]byte{}, valid[:len(valid)-4]...))
+		return appendUint32(data, crc32.ChecksumIEEE(data))
+	}
+	cases := map[string][]byte{
+		"not an emulator snapshot":      []byte("MIPSSNA"),
+		"snapshot has the wrong length": valid[:len(valid)-1],
+		"unsupported snapshot version: 2": modified(func(d []byte) []byte {
+			d[11] = 2
+			return d
+		}),
+		"unsupported snapshot features: 0x80": modified(func(d []byte) []byte {
+			d[15] |= 0x80
+			return d
+		}),
+		"snapshot has extra data": modified(func(d []byte) []byte {
+			d[19]++
+			return append(d, 0)
+		}),
+		"snapshot body is truncated": modified(func(d []byte) []byte {
+			binary.BigEndian.PutUint32(d[16:], uint32(len(d)-snapshotHeaderSize-1))
+			return d[:len(d)-1]
+		}),
+		"snapshot page is misaligned": modified(func(d []byte) []byte {
+			d[len(d)-snapshotPageSize-1] = 4
+			return d
+		}),
+	}
+	corrupted := append([]byte{}, valid...)
+	corrupted[snapshotHeaderSize]++
+	cases["snapshot checksum mismatch"] = corrupted
+
+	for expected, data := range cases {
+		restored := &Emulator{}
+		err := restored.ReadSnapshot(bytes.NewReader(data))
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q but got %v", expected, err)
+		} else if restored.ProgramCounter != 0 || restored.Memory != nil {
+			t.Errorf("%s: emulator was modified", expected)
+		}
+	}
+
+	if err := (&Emulator{Memory: byteMemory{}}).WriteSnapshot(&buf); err == nil {
+		t.Error("expected an error for an unsupported memory")
+	}
+}
@@ -289,6 +289,75 @@ func TestEmulatorWriteZero(t *testing.T) {
 	}
 }
 
+func TestEmulatorExecute(t *testing.T) {
+	emulator := &Emulator{Memory: NewLazyMemory(), ProgramCounter: 0x100}
+	code := []string{"ORI $r1, $r0, 5", "BNE $r1, $r0, 0x20", "ADDIU $r2, $r1, 1", "SW $r2, 4($r0)"}
+	for _, line := range code {
+		tokenized, err := TokenizeSource(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst, err := ParseTokenizedInstruction(tokenized[0].Instruction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := emulator.Execute(inst); err != nil {
+			t.Fatal(line, "-", err)
+		}
+	}
+	if emulator.RegisterFile[1] != 5 || emulator.RegisterFile[2] != 6 {
+		t.Error("unexpected registers:", emulator.RegisterFile[1], emulator.RegisterFile[2])
+	}
+	if emulator.ProgramCounter != 0x12c {
+		t.Error("unexpected program counter:", emulator.ProgramCounter)
+	}
+	if emulator.Memory.Get(7) != 6 {
+		t.Error("unexpected memory value:", emulator.Memory.Get(7))
+	}
+}
+
+func TestEmulatorNilExecutable(t *testing.T) {
+	tokenized, err := TokenizeSource("BEQ $r0, $r0, 0x20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch, err := ParseTokenizedInstruction(tokenized[0].Instruction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blocks := range []bool{false, true} {
+		emulator := &Emulator{Memory: NewLazyMemory(), ProgramCounter: 0x100}
+		if !emulator.Done() {
+			t.Fatal("emulator without an executable should be done")
+		}
+		if err := emulator.Execute(branch); err != nil {
+			t.Fatal(err)
+		}
+		if emulator.Done() {
+			t.Fatal("emulator should not be done during a branch")
+		}
+		var steps int
+		if blocks {
+			steps, err = (&BlockEngine{Emulator: emulator}).StepN(10)
+		} else {
+			steps, err = emulator.StepN(10)
+		}
+		if err != nil {
+			t.Fatal(err)
+		} else if steps != 1 {
+			t.Errorf("expected 1 step but got %d", steps)
+		}
+		if emulator.ProgramCounter != 0x124 || !emulator.Done() {
+			t.Errorf("unexpected program counter: 0x%x", emulator.ProgramCounter)
+		}
+		if err := emulator.Step(); err != nil {
+			t.Fatal(err)
+		} else if emulator.ProgramCounter != 0x128 {
+			t.Errorf("unexpected program counter: 0x%x", emulator.ProgramCounter)
+		}
+	}
+}
+
 func runTestProgram(code string) (*Emulator, error) {
 	return runTestProgramEndianness(code, false)
 }
@@ -315,3 +384,57 @@ func runTestProgramEndianness(code string, little bool) (*Emulator, error) {
 	}
 	return emulator, nil
 }
+
+func TestEmulatorStepAllocations(t *testing.T) {
+	emu := benchmarkEmulator(t)
+	if n := testing.AllocsPerRun(100, func() { emu.StepN(100) }); n != 0 {
+		t.Errorf("StepN made %f allocations per call", n)
+	}
+	n := testing.AllocsPerRun(1000, func() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n != 0 {
+		t.Errorf("Step made %f allocations per call", n)
+	}
+}
+
+func BenchmarkEmulatorStep(b *testing.B) {
+	emu := benchmarkEmulator(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := emu.Step(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEmulatorStepN(b *testing.B) {
+	emu := benchmarkEmulator(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := emu.StepN(b.N); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func benchmarkEmulator(b testing.TB) *Emulator {
+	tokens, err := TokenizeSource(`LOOP:
+ADDIU $t0, $t0, 1
+SW $t0, 0x100($0)
+LW $t1, 0x100($0)
+XOR $t2, $t1, $t0
+SLL $t3, $t0, 2
+BNE $t0, $0, LOOP
+ADDU $t4, $t4, $t3`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &Emulator{Memory: NewLazyMemory(), Executable: exc}
+}
@@ -0,0 +1,166 @@
+package mips32
+
+// An Example is an annotated sample program for people who are learning MIPS.
+type Example struct {
+	Name        string
+	Description string
+	Source      string
+
+	// Input is console input which the program expects to read.
+	Input string
+
+	// Output is what the program prints when it is given Input.
+	Output string
+
+	// Registers maps register numbers to the values they hold once the program finishes.
+	Registers map[int]uint32
+}
+
+// Examples is the built-in library of example programs.
+// Every example runs to completion with SPIMSyscalls.
+var Examples = []*Example{
+	{
+		Name:        "loop",
+		Description: "Add up the numbers from 1 to 10 with a loop.",
+		Source: `# Add up the numbers from 1 to 10.
+# The sum ends up in $v0.
+ADDU $v0, $0, $0
+ADDIU $t0, $0, 1
+ADDIU $t1, $0, 11
+
+LOOP:
+ADDU $v0, $v0, $t0
+ADDIU $t0, $t0, 1
+BNE $t0, $t1, LOOP
+# This instruction is in the branch delay slot, so it runs whether or not we branch.
+NOP`,
+		Registers: map[int]uint32{2: 55, 8: 11},
+	},
+	{
+		Name:        "arrays",
+		Description: "Fill an array in memory with square numbers, then add them up.",
+		Source: `# Store the squares 0, 1, 4, ..., 49 in an array at 0x1000.
+# Each square is the previous one plus the next odd number.
+ADDIU $s0, $0, 0x1000
+ADDU $t0, $0, $0
+ADDU $t1, $0, $0
+ADDIU $t2, $0, 1
+ADDIU $t3, $0, 8
+
+FILL:
+# Compute the address of element $t0, which is 4*$t0 bytes into the array.
+SLL $t4, $t0, 2
+ADDU $t4, $s0, $t4
+SW $t1, 0($t4)
+ADDU $t1, $t1, $t2
+ADDIU $t2, $t2, 2
+ADDIU $t0, $t0, 1
+BNE $t0, $t3, FILL
+NOP
+
+# Add up the array by walking a pointer through it.
+ADDU $v0, $0, $0
+ADDU $t0, $0, $0
+
+SUM:
+LW $t1, 0($s0)
+ADDIU $s0, $s0, 4
+ADDIU $t0, $t0, 1
+BNE $t0, $t3, SUM
+# The delay slot does the addition, even on the last iteration.
+ADDU $v0, $v0, $t1`,
+		Registers: map[int]uint32{2: 140, 16: 0x1020},
+	},
+	{
+		Name:        "recursion",
+		Description: "Compute a Fibonacci number with a recursive function and a stack.",
+		Source: `# Compute the 10th Fibonacci number recursively.
+# The stack grows down from 0x4000.
+ADDIU $sp, $0, 0x4000
+ADDIU $a0, $0, 10
+JAL FIB
+NOP
+J END
+NOP
+
+# FIB returns fib($a0) in $v0.
+FIB:
+SLTI $t0, $a0, 2
+BEQ $t0, $0, RECURSE
+NOP
+# fib(0) = 0 and fib(1) = 1.
+JR $ra
+ADDU $v0, $a0, $0
+
+RECURSE:
+# Save the return address and argument, since the recursive calls overwrite them.
+ADDIU $sp, $sp, -12
+SW $ra, 0($sp)
+SW $a0, 4($sp)
+JAL FIB
+ADDIU $a0, $a0, -1
+# Save fib(n-1) while we compute fib(n-2).
+SW $v0, 8($sp)
+LW $a0, 4($sp)
+JAL FIB
+ADDIU $a0, $a0, -2
+LW $t0, 8($sp)
+LW $ra, 0($sp)
+ADDIU $sp, $sp, 12
+JR $ra
+ADDU $v0, $v0, $t0
+
+END:`,
+		Registers: map[int]uint32{2: 55, 29: 0x4000},
+	},
+	{
+		Name:        "console",
+		Description: "Read two numbers from the console and print their sum.",
+		Source: `# Read two numbers and print their sum.
+# Type each number into the console and press enter.
+# The syscall number goes in $v0, and arguments go in $a0.
+ADDIU $v0, $0, 5
+SYSCALL
+ADDU $s0, $v0, $0
+ADDIU $v0, $0, 5
+SYSCALL
+ADDU $s1, $v0, $0
+
+# Print "a+b=sum" followed by a newline.
+ADDIU $v0, $0, 1
+ADDU $a0, $s0, $0
+SYSCALL
+ADDIU $v0, $0, 11
+ADDIU $a0, $0, 0x2b
+SYSCALL
+ADDIU $v0, $0, 1
+ADDU $a0, $s1, $0
+SYSCALL
+ADDIU $v0, $0, 11
+ADDIU $a0, $0, 0x3d
+SYSCALL
+ADDIU $v0, $0, 1
+ADDU $a0, $s0, $s1
+SYSCALL
+ADDIU $v0, $0, 11
+ADDIU $a0, $0, 0x0a
+SYSCALL
+
+# Exit the program.
+ADDIU $v0, $0, 10
+SYSCALL`,
+		Input:     "3\n-10\n",
+		Output:    "3+-10=-7\n",
+		Registers: map[int]uint32{16: 3, 17: 0xfffffff6},
+	},
+}
+
+// ExampleNamed returns the example with the given name, or nil if there is none.
+func ExampleNamed(name string) *Example {
+	for _, example := range Examples {
+		if example.Name == name {
+			return example
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mips32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExamples(t *testing.T) {
+	for _, example := range Examples {
+		tokens, err := TokenizeSource(example.Source)
+		if err != nil {
+			t.Errorf("example %s: %s", example.Name, err)
+			continue
+		}
+		exc, err := ParseExecutable(tokens)
+		if err != nil {
+			t.Errorf("example %s: %s", example.Name, err)
+			continue
+		}
+		var output bytes.Buffer
+		emu := &Emulator{
+			Memory:     NewLazyMemory(),
+			Executable: exc,
+			Syscalls: &SPIMSyscalls{
+				Input:  strings.NewReader(example.Input),
+				Output: &output,
+			},
+		}
+		for steps := 0; !emu.Done(); steps++ {
+			if steps == 100000 {
+				t.Fatalf("example %s: too many steps", example.Name)
+			}
+			if err := emu.Step(); err != nil {
+				t.Fatalf("example %s: %s", example.Name, err)
+			}
+		}
+		if output.String() != example.Output {
+			t.Errorf("example %s: expected output %q but got %q", example.Name,
+				example.Output, output.String())
+		}
+		for reg, expected := range example.Registers {
+			if actual := emu.RegisterFile[reg]; actual != expected {
+				t.Errorf("example %s: register %d should be %d but got %d", example.Name, reg,
+					expected, actual)
+			}
+		}
+	}
+}
+
+func TestExampleNamed(t *testing.T) {
+	if ex := ExampleNamed("recursion"); ex == nil || ex.Name != "recursion" {
+		t.Error("failed to find example")
+	}
+	if ExampleNamed("nonexistent") != nil {
+		t.Error("unexpected example")
+	}
+}
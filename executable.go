@@ -4,6 +4,8 @@ import (
 	"errors"
 	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // An Executable stores chunks of instructions (called segments) and a symbol table.
@@ -13,64 +15,284 @@ type Executable struct {
 
 	// Symbols maps symbol names to their addresses.
 	Symbols map[string]uint32
+
+	// LineNumbers maps the address of each instruction to the source line it came from.
+	// It is nil for executables which were not produced by ParseExecutable.
+	LineNumbers map[uint32]int
+
+	// SymbolInfo records the kind, size, and visibility of each symbol.
+	// It may be nil (or missing some symbols) for executables which were not produced by
+	// ParseExecutable.
+	SymbolInfo map[string]SymbolInfo
+
+	// index caches the sorted segment addresses for InstructionAt.
+	index atomic.Value
 }
 
 // ParseExecutable turns a tokenized source file into an executable blob.
 //
 // If the executable cannot be parsed for any reason, this will fail.
-// Overlapping .text sections, invalid instructions, and repeated symbols will all cause errors.
+// Overlapping .text sections, invalid instructions, repeated symbols, and branches or jumps which
+// cannot reach their targets will all cause errors.
 func ParseExecutable(lines []TokenizedLine) (*Executable, error) {
-	var segmentStart uint32
-	var instructionAddr uint32
+	return ParseExecutableLayout(lines, nil)
+}
+
+// ParseExecutableLayout is like ParseExecutable, but it places ".section" directives according
+// to a Layout.
+// If the layout is nil, ".section" directives are not allowed.
+//
+// In addition to the errors reported by ParseExecutable, this fails if a section does not fit
+// in its region.
+func ParseExecutableLayout(lines []TokenizedLine, layout *Layout) (*Executable, error) {
+	res, err := parseExecutableLayout(lines, layout)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.checkControlRanges(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// parseExecutableLayout is like ParseExecutableLayout, but it does not check that branches and
+// jumps can reach their targets.
+//
+// It works in two passes.
+// The first pass finds the address of every instruction and symbol, and the second pass parses
+// the instructions.
+// Since every symbol is known by the second pass, instructions and ".word" directives can use
+// symbols which are declared later in the file, even for operands which need constants.
+func parseExecutableLayout(lines []TokenizedLine, layout *Layout) (*Executable, error) {
 	res := &Executable{
-		Segments: map[uint32][]Instruction{},
-		Symbols:  map[string]uint32{},
+		Segments:    map[uint32][]Instruction{},
+		Symbols:     map[string]uint32{},
+		LineNumbers: map[uint32]int{},
 	}
-	for _, line := range lines {
-		if line.Instruction != nil {
-			parsed, err := ParseTokenizedInstruction(line.Instruction)
+	placed, layoutErr := placeLines(lines, layout, res.Symbols)
+	symbolValue := func(name string) (uint32, bool) {
+		addr, ok := res.Symbols[name]
+		return addr, ok
+	}
+
+	// The sorted segment addresses make it fast to check if an address is in use.
+	var starts uint32List
+
+	// Errors from the first pass are reported after any errors on earlier lines, so that the
+	// first error in the file is always the one returned.
+	for _, p := range placed {
+		var nextInst *Instruction
+		if p.line.Instruction != nil {
+			parsed, err := parseInstructionSymbols(p.line.Instruction, symbolValue)
 			if err != nil {
-				return nil, errors.New("line " + strconv.Itoa(line.LineNumber) + ": " + err.Error())
+				// If the first pass stopped early, the symbol might be declared later.
+				_, anyErr := parseInstructionSymbols(p.line.Instruction, anySymbolValue)
+				if layoutErr != nil && anyErr == nil {
+					return nil, layoutErr
+				}
+				return nil, lineError(p.line.LineNumber, err.Error())
 			}
-			if res.addressInUse(instructionAddr) {
-				return nil, addressInUseError(line.LineNumber, instructionAddr)
+			nextInst = parsed
+		} else {
+			word, err := p.dataWord(symbolValue)
+			if err != nil {
+				_, anyErr := p.dataWord(anySymbolValue)
+				if layoutErr != nil && anyErr == nil {
+					return nil, layoutErr
+				}
+				return nil, lineError(p.line.LineNumber, err.Error())
 			}
-			res.Segments[segmentStart] = append(res.Segments[segmentStart], *parsed)
-			instructionAddr += 4
+			nextInst = DecodeInstruction(word)
+		}
+		if _, ok := res.segmentAt(starts, p.address); ok {
+			return nil, addressInUseError(p.line.LineNumber, p.address,
+				res.LineNumbers[p.address])
+		}
+		if _, ok := res.Segments[p.segmentStart]; !ok {
+			starts = starts.insert(p.segmentStart)
+		}
+		res.Segments[p.segmentStart] = append(res.Segments[p.segmentStart], *nextInst)
+		res.LineNumbers[p.address] = p.line.LineNumber
+	}
+	if layoutErr != nil {
+		return nil, layoutErr
+	}
+	res.joinContiguousSegments()
+	if err := res.computeSymbolInfo(lines, placed); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// A placedLine is a line which produces a word of the executable, along with where the word
+// goes.
+// A data directive may produce several words, and index is the word's index within them.
+// For data directives, values caches the directive's values.
+type placedLine struct {
+	line         *TokenizedLine
+	segmentStart uint32
+	address      uint32
+	index        int
+	values       []DirectiveValue
+}
+
+// dataWord packs the values of a data directive which make up a word of the executable.
+// Halves and bytes are packed in big endian order, and the last word is padded with zeroes.
+func (p *placedLine) dataWord(symbolValue func(string) (uint32, bool)) (uint32, error) {
+	dir := p.line.Directive
+	size := dataValueSize(dir.Name)
+	perWord := 4 / size
+	var word uint32
+	for i := p.index * perWord; i < (p.index+1)*perWord; i++ {
+		word <<= uint(size * 8)
+		if i >= len(p.values) {
+			continue
+		}
+		value := p.values[i]
+		if value.Symbol != "" {
+			addr, ok := symbolValue(value.Symbol)
+			if !ok {
+				return 0, unknownSymbolError(value.Symbol)
+			}
+			value.Constant += addr
+		}
+		if !dataValueFits(value.Constant, size) {
+			return 0, errors.New("value out of range for ." + dir.Name + ": " +
+				p.values[i].String())
+		}
+		word |= value.Constant & (0xffffffff >> uint(32-size*8))
+	}
+	return word, nil
+}
+
+// placeLines is the first pass of parseExecutableLayout.
+// It finds the address of each instruction and data directive, and adds each symbol to a
+// symbol table, without parsing any instructions.
+//
+// If it fails, it still returns the lines it placed before the error.
+func placeLines(lines []TokenizedLine, layout *Layout,
+	symbols map[string]uint32) ([]placedLine, error) {
+	var segmentStart uint32
+	var instructionAddr uint32
+	var section string
+	var sections *layoutState
+	if layout != nil {
+		if err := layout.validate(); err != nil {
+			return nil, err
+		}
+		sections = newLayoutState(layout)
+		if len(layout.Regions) > 0 {
+			section = layout.Regions[0].Name
+			segmentStart, _ = sections.enter(section)
+			instructionAddr = segmentStart
+		}
+	}
+
+	var res []placedLine
+	for i := range lines {
+		line := &lines[i]
+		var numWords int
+		var values []DirectiveValue
+		if line.Instruction != nil {
+			numWords = 1
 		} else if line.Directive != nil {
 			dir := line.Directive
-			if dir.Name == "word" {
-				if res.addressInUse(instructionAddr) {
-					return nil, addressInUseError(line.LineNumber, instructionAddr)
-				}
-				nextInst := DecodeInstruction(dir.Constant)
-				res.Segments[segmentStart] = append(res.Segments[segmentStart], *nextInst)
-				instructionAddr += 4
+			if isDataDirective(dir.Name) || isStringDirective(dir.Name) {
+				values = dir.dataValues()
+				numWords = dataWordCount(dir.Name, len(values))
 			} else if dir.Name == "text" {
 				if dir.Constant&3 != 0 {
-					return nil, errors.New("line " + strconv.Itoa(line.LineNumber) +
-						": misaligned segment")
+					return res, lineError(line.LineNumber, "misaligned segment")
 				}
 				segmentStart = dir.Constant
 				instructionAddr = dir.Constant
-			} else {
-				return nil, errors.New("line " + strconv.Itoa(line.LineNumber) +
-					": unknown directive: " + dir.Name)
+				section = ""
+			} else if dir.Name == "section" {
+				if sections == nil {
+					return res, lineError(line.LineNumber, "no layout for section: "+dir.Symbol)
+				}
+				addr, err := sections.enter(dir.Symbol)
+				if err != nil {
+					return res, lineError(line.LineNumber, err.Error())
+				}
+				segmentStart = addr
+				instructionAddr = addr
+				section = dir.Symbol
+			} else if !isSymbolDirective(dir.Name) {
+				return res, lineError(line.LineNumber, "unknown directive: "+dir.Name)
 			}
 		} else if line.SymbolMarker != nil {
 			sym := *line.SymbolMarker
-			if _, ok := res.Symbols[sym]; ok {
-				return nil, errors.New("line " + strconv.Itoa(line.LineNumber) +
-					": repeated symbol declaration: " + sym)
+			if _, ok := symbols[sym]; ok {
+				return res, lineError(line.LineNumber, "repeated symbol declaration: "+sym)
 			}
-			res.Symbols[sym] = instructionAddr
+			symbols[sym] = instructionAddr
+		}
+		for j := 0; j < numWords; j++ {
+			res = append(res, placedLine{
+				line:         line,
+				segmentStart: segmentStart,
+				address:      instructionAddr,
+				index:        j,
+				values:       values,
+			})
+			if section != "" {
+				err := sections.advance(section, uint64(instructionAddr)+4)
+				if err != nil {
+					return res, lineError(line.LineNumber, err.Error())
+				}
+			}
+			instructionAddr += 4
 		}
 	}
-	res.joinContiguousSegments()
-	// TODO: make sure no jump offsets are invalid.
 	return res, nil
 }
 
+// dataValueSize returns the number of bytes in each value of a data directive.
+func dataValueSize(name string) int {
+	switch name {
+	case "byte", "ascii", "asciiz":
+		return 1
+	case "half":
+		return 2
+	default:
+		return 4
+	}
+}
+
+// dataWordCount returns the number of words which a data directive with some number of values
+// produces.
+func dataWordCount(name string, numValues int) int {
+	perWord := 4 / dataValueSize(name)
+	return (numValues + perWord - 1) / perWord
+}
+
+// dataValues returns the values of a data directive, treating each byte of a string directive
+// as a value.
+func (t *TokenizedDirective) dataValues() []DirectiveValue {
+	if !isStringDirective(t.Name) {
+		return t.Values
+	}
+	res := make([]DirectiveValue, len(t.Text), len(t.Text)+1)
+	for i := 0; i < len(t.Text); i++ {
+		res[i].Constant = uint32(t.Text[i])
+	}
+	if t.Name == "asciiz" {
+		res = append(res, DirectiveValue{})
+	}
+	return res
+}
+
+// dataValueFits checks if a value fits in a number of bytes, either as a signed or as an
+// unsigned integer.
+func dataValueFits(value uint32, size int) bool {
+	if size == 4 {
+		return true
+	}
+	bits := uint(size * 8)
+	return value>>bits == 0 || value >= ^uint32(0)<<(bits-1)
+}
+
 // Render generates a tokenized source file that corresponds to the given executable.
 // If any the instructions are invalid, this will return an error.
 func (e *Executable) Render() (list []TokenizedLine, err error) {
@@ -144,6 +366,26 @@ func (e *Executable) Render() (list []TokenizedLine, err error) {
 	return
 }
 
+// A Segment is a chunk of instructions along with its address.
+type Segment struct {
+	Address      uint32
+	Instructions []Instruction
+}
+
+// OrderedSegments returns the segments of the executable sorted by address.
+//
+// Since Segments is a map, iterating over it directly visits the segments in a different order
+// each time, so anything which produces output should use this instead.
+// The Instructions slices are shared with the executable.
+func (e *Executable) OrderedSegments() []Segment {
+	starts := e.sortedSegmentAddresses()
+	res := make([]Segment, len(starts))
+	for i, start := range starts {
+		res[i] = Segment{Address: start, Instructions: e.Segments[start]}
+	}
+	return res
+}
+
 // End returns the pointer to the first byte that is completely past any instruction data.
 // Once a program starts executing instructions at or past End(), no more instructions will be seen.
 func (e *Executable) End() uint32 {
@@ -159,23 +401,81 @@ func (e *Executable) End() uint32 {
 
 // Get returns the instruction at a given pointer, or nil if no instruction exists at that pointer.
 func (e *Executable) Get(addr uint32) *Instruction {
-	for segStart, insts := range e.Segments {
-		end := segStart + uint32(len(insts)*4)
-		if segStart <= addr && end > addr {
-			return &insts[(addr-segStart)>>2]
+	inst, _ := e.InstructionAt(addr)
+	return inst
+}
+
+// InstructionAt returns the instruction at a given pointer, if there is one.
+//
+// The segment is found with a binary search over an index of the segment addresses.
+// The index is built when it is first needed, and rebuilt when a lookup finds that segments have
+// been added or removed since then.
+// Thus, lookups take logarithmic time in the number of segments, except for addresses outside of
+// every segment, which take linear time.
+func (e *Executable) InstructionAt(addr uint32) (*Instruction, bool) {
+	index, _ := e.index.Load().(uint32List)
+	start, ok := e.segmentAt(index, addr)
+	if !ok {
+		if e.indexCurrent(index) {
+			return nil, false
+		}
+		index = e.sortedSegmentAddresses()
+		e.index.Store(index)
+		if start, ok = e.segmentAt(index, addr); !ok {
+			return nil, false
 		}
 	}
-	return nil
+	return &e.Segments[start][(addr-start)>>2], true
 }
 
-// addressInUse reports of a word-aligned address is being used by one of the segments.
-func (e *Executable) addressInUse(addr uint32) bool {
-	for segment, insts := range e.Segments {
-		if segment <= addr && segment+uint32(len(insts)*4) > addr {
-			return true
+// ResolveLocation turns a number, a symbol, or a symbol plus or minus a number (e.g. "LOOP+8")
+// into an address.
+func (e *Executable) ResolveLocation(loc string) (uint32, error) {
+	if addr, ok := e.Symbols[loc]; ok {
+		return addr, nil
+	}
+	if idx := strings.IndexAny(loc, "+-"); idx > 0 {
+		if addr, ok := e.Symbols[loc[:idx]]; ok {
+			offset, err := strconv.ParseInt(loc[idx+1:], 0, 64)
+			if err != nil || offset > 0xffffffff {
+				return 0, errors.New("invalid offset: " + loc[idx+1:])
+			}
+			if loc[idx] == '-' {
+				offset = -offset
+			}
+			return addr + uint32(offset), nil
 		}
 	}
-	return false
+	num, err := strconv.ParseInt(loc, 0, 64)
+	if err != nil || num > 0xffffffff || num < -0x80000000 {
+		return 0, errors.New("invalid address or symbol: " + loc)
+	}
+	return uint32(num), nil
+}
+
+// segmentAt finds the segment containing an address, given the sorted segment addresses.
+func (e *Executable) segmentAt(starts uint32List, addr uint32) (uint32, bool) {
+	i := sort.Search(len(starts), func(i int) bool {
+		return starts[i] > addr
+	})
+	if i == 0 {
+		return 0, false
+	}
+	start := starts[i-1]
+	return start, addr-start < uint32(len(e.Segments[start])*4)
+}
+
+// indexCurrent checks if a list of segment addresses matches the segments.
+func (e *Executable) indexCurrent(starts uint32List) bool {
+	if len(starts) != len(e.Segments) {
+		return false
+	}
+	for _, start := range starts {
+		if _, ok := e.Segments[start]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 // joinContiguousSegments joins contiguous segments.
@@ -213,9 +513,16 @@ func (e *Executable) sortedSymbolAddrPairs() symbolAddrPairList {
 	return l
 }
 
-func addressInUseError(line int, addr uint32) error {
+// addressInUseError reports that a line tries to place an instruction at an address which is
+// already used by the instruction from another line.
+func addressInUseError(line int, addr uint32, otherLine int) error {
 	hexStr := "0x" + strconv.FormatUint(uint64(addr), 16)
-	return errors.New("line " + strconv.Itoa(line) + ": overwriting address " + hexStr)
+	return lineError(line, "overwriting address "+hexStr+", which is already used by line "+
+		strconv.Itoa(otherLine))
+}
+
+func lineError(line int, msg string) error {
+	return &SourceError{Line: line, Message: msg}
 }
 
 type uint32List []uint32
@@ -232,6 +539,17 @@ func (u uint32List) Swap(i, j int) {
 	u[i], u[j] = u[j], u[i]
 }
 
+// insert adds a number to a sorted list, keeping it sorted.
+func (u uint32List) insert(x uint32) uint32List {
+	i := sort.Search(len(u), func(i int) bool {
+		return u[i] >= x
+	})
+	u = append(u, 0)
+	copy(u[i+1:], u[i:])
+	u[i] = x
+	return u
+}
+
 type symbolAddrPair struct {
 	Symbol  string
 	Address uint32
@@ -244,6 +562,9 @@ func (s symbolAddrPairList) Len() int {
 }
 
 func (s symbolAddrPairList) Less(i, j int) bool {
+	if s[i].Address == s[j].Address {
+		return s[i].Symbol < s[j].Symbol
+	}
 	return s[i].Address < s[j].Address
 }
 
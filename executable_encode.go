@@ -0,0 +1,83 @@
+package mips32
+
+import "strconv"
+
+// An EncodeError describes an instruction which could not be encoded.
+type EncodeError struct {
+	Address uint32
+
+	// Line is the source line of the instruction, or 0 if it is unknown.
+	Line int
+
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	location := "instruction at " + eightDigitHex(e.Address)
+	if e.Line != 0 {
+		location = "line " + strconv.Itoa(e.Line) + " (" + eightDigitHex(e.Address) + ")"
+	}
+	return location + ": " + e.Err.Error()
+}
+
+// Encode turns every segment into machine words, keyed by the address of each segment.
+//
+// All symbols are resolved, and branch offsets and jump targets are checked against the ranges
+// of their fields.
+// If any instruction cannot be encoded, the error for the lowest such address is returned as an
+// *EncodeError.
+func (e *Executable) Encode() (map[uint32][]uint32, error) {
+	res := map[uint32][]uint32{}
+	for _, segment := range e.sortedSegmentAddresses() {
+		insts := e.Segments[segment]
+		words := make([]uint32, len(insts))
+		for i := range insts {
+			addr := segment + uint32(i*4)
+			word, err := insts[i].Encode(addr, e.Symbols)
+			if err != nil {
+				return nil, &EncodeError{Address: addr, Line: e.LineNumbers[addr], Err: err}
+			}
+			words[i] = word
+		}
+		res[segment] = words
+	}
+	return res, nil
+}
+
+// EncodeBytes is like Encode, but it turns each segment into bytes with the given byte order.
+func (e *Executable) EncodeBytes(littleEndian bool) (map[uint32][]byte, error) {
+	words, err := e.Encode()
+	if err != nil {
+		return nil, err
+	}
+	res := map[uint32][]byte{}
+	for segment, segmentWords := range words {
+		data := make([]byte, 0, len(segmentWords)*4)
+		for _, word := range segmentWords {
+			if littleEndian {
+				data = append(data, byte(word), byte(word>>8), byte(word>>16), byte(word>>24))
+			} else {
+				data = append(data, byte(word>>24), byte(word>>16), byte(word>>8), byte(word))
+			}
+		}
+		res[segment] = data
+	}
+	return res, nil
+}
+
+// LoadInto encodes every segment (see EncodeBytes) and writes it to memory, so that loads can
+// read the executable's data and instructions.
+//
+// The emulator fetches instructions from the Executable itself, so loading is only needed for
+// programs which read memory written by data directives (e.g. ".word" tables or ".asciiz"
+// strings).
+func (e *Executable) LoadInto(mem Memory, littleEndian bool) error {
+	segments, err := e.EncodeBytes(littleEndian)
+	if err != nil {
+		return err
+	}
+	for addr, data := range segments {
+		WriteBytes(mem, addr, data)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExecutableEncode(t *testing.T) {
+	lines, err := TokenizeSource(`ADDIU $t0, $0, 5
+J END
+.text 0x100
+END:
+BEQ $0, $0, END
+.word 0x12345678`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := exc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[uint32][]uint32{
+		0:     {0x24080005, 0x08000040},
+		0x100: {0x1000ffff, 0x12345678},
+	}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %x but got %x", expected, words)
+	}
+
+	data, err := exc.EncodeBytes(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data[0x100], []byte{0xff, 0xff, 0x00, 0x10, 0x78, 0x56, 0x34, 0x12}) {
+		t.Errorf("unexpected bytes: %x", data[0x100])
+	}
+}
+
+func TestExecutableEncodeError(t *testing.T) {
+	// ParseExecutable would reject this branch, so the executable is built by hand.
+	exc := &Executable{
+		Segments: map[uint32][]Instruction{
+			0: {
+				{Name: "NOP"},
+				{
+					Name:        "BEQ",
+					Registers:   []int{0, 0},
+					CodePointer: CodePointer{IsSymbol: true, Symbol: "FAR"},
+				},
+			},
+			0x80000: {{Name: "NOP"}},
+		},
+		Symbols:     map[string]uint32{"FAR": 0x80000},
+		LineNumbers: map[uint32]int{0: 1, 4: 2, 0x80000: 5},
+	}
+	_, err := exc.Encode()
+	if encErr, ok := err.(*EncodeError); !ok || encErr.Address != 4 || encErr.Line != 2 {
+		t.Fatal("unexpected error:", err)
+	}
+	if err.Error() != "line 2 (0x00000004): branch offset out of bounds" {
+		t.Error("unexpected message:", err.Error())
+	}
+}
+
+func TestExecutableLoadInto(t *testing.T) {
+	// The program loads a handler's address from a table and jumps to it.
+	exc, err := Assemble(`ORI $t0, $0, TABLE
+LW $t1, 0($t0)
+LW $t2, 4($t0)
+JR $t2
+NOP
+ADDIU $t3, $0, 1
+HANDLER:
+ADDIU $t4, $0, 2
+.text 0x100
+TABLE:
+.word 0x1234, HANDLER`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, littleEndian := range []bool{false, true} {
+		emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, LittleEndian: littleEndian}
+		if err := exc.LoadInto(emu.Memory, littleEndian); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 6; i++ {
+			if err := emu.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		regs := emu.RegisterFile
+		if regs[9] != 0x1234 || regs[10] != 0x18 || regs[11] != 0 || regs[12] != 2 {
+			t.Errorf("little endian %v: unexpected registers %x", littleEndian, regs[8:13])
+		}
+		if emu.Memory.Get(0) == 0 && emu.Memory.Get(3) == 0 {
+			t.Errorf("little endian %v: instructions were not loaded", littleEndian)
+		}
+	}
+}
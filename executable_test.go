@@ -2,6 +2,7 @@ package mips32
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -98,6 +99,255 @@ func TestParseExecutableFailure(t *testing.T) {
 	}
 }
 
+func TestParseExecutableOverlap(t *testing.T) {
+	sources := map[string]string{
+		".text 0x10\nNOP\nNOP\n.text 0x8\nNOP\nNOP\nNOP": "line 7: overwriting address 0x10, " +
+			"which is already used by line 2",
+		"NOP\nNOP\nNOP\n.text 0x4\nNOP": "line 5: overwriting address 0x4, which is already " +
+			"used by line 2",
+	}
+	for source, expected := range sources {
+		lines, err := TokenizeSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseExecutable(lines); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
+
+func TestParseExecutableForwardSymbols(t *testing.T) {
+	source := `ADDIU $t0, $0, DATA
+ORI $t1, $0, DATA
+LUI $t2, DATA
+SLL $t3, $t3, SHIFT
+J END
+NOP
+.text 0x10
+SHIFT:
+.text 0x1234
+DATA:
+END:
+NOP`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Instruction{
+		{Name: "ADDIU", Registers: []int{8, 0}, SignedConstant16: 0x1234},
+		{Name: "ORI", Registers: []int{9, 0}, UnsignedConstant16: 0x1234},
+		{Name: "LUI", Registers: []int{10}, UnsignedConstant16: 0x1234},
+		{Name: "SLL", Registers: []int{11, 11}, Constant5: 0x10},
+		{
+			Name:        "J",
+			CodePointer: CodePointer{Absolute: true, IsSymbol: true, Symbol: "END"},
+		},
+		{Name: "NOP"},
+	}
+	for i, inst := range expected {
+		actual := exc.Get(uint32(i * 4))
+		if actual == nil || !reflect.DeepEqual(*actual, inst) {
+			t.Errorf("instruction %d: expected %v but got %v", i, inst, actual)
+		}
+	}
+
+	errSources := map[string]string{
+		"ADDIU $t0, $0, DATA\n.text 0x10000\nDATA:": "line 1: bad instruction usage for ADDIU",
+		"ADDIU $t0, $0, MISSING":                    "line 1: bad instruction usage for ADDIU",
+		"ADDIU $t0, $0, DATA\n.text 3\nDATA:":       "line 2: misaligned segment",
+		"ADDIU $t0, $0, 5, 6\n.text 3\nDATA:":       "line 1: bad instruction usage for ADDIU",
+		"NOP\nADDIU $t0, $0, 1\n.text 3\nNOP\nX:":   "line 3: misaligned segment",
+	}
+	for source, expected := range errSources {
+		lines, err := TokenizeSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseExecutable(lines); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
+
+func TestParseExecutableExpressions(t *testing.T) {
+	source := `LUI $t0, %hi(DATA)
+ADDIU $t0, $t0, %lo(DATA)
+ORI $t1, $0, DATA+4 & 0xffff
+ADDIU $t2, $0, END - DATA
+SLL $t3, $t3, (END-DATA) / 2
+J END+8
+NOP
+.text 0x8000
+DATA:
+NOP
+NOP
+END:
+NOP`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Instruction{
+		{Name: "LUI", Registers: []int{8}, UnsignedConstant16: 1},
+		{Name: "ADDIU", Registers: []int{8, 8}, SignedConstant16: -0x8000},
+		{Name: "ORI", Registers: []int{9, 0}, UnsignedConstant16: 0x8004},
+		{Name: "ADDIU", Registers: []int{10, 0}, SignedConstant16: 8},
+		{Name: "SLL", Registers: []int{11, 11}, Constant5: 4},
+		{Name: "J", CodePointer: CodePointer{Absolute: true, Constant: 0x8010}},
+		{Name: "NOP"},
+	}
+	for i, inst := range expected {
+		actual := exc.Get(uint32(i * 4))
+		if actual == nil || !reflect.DeepEqual(*actual, inst) {
+			t.Errorf("instruction %d: expected %v but got %v", i, inst, actual)
+		}
+	}
+
+	memorySource := `LW $t0, VALUE($0)
+SW $t1, VALUE+4($t2)
+LB $t2, %lo(VALUE) ($t3)
+LBU $t3, (VALUE - 0x80) / 2($sp)
+.text 0x100
+VALUE:
+.word 1, VALUE+4`
+	lines, err = TokenizeSource(memorySource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err = ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Instruction{
+		{Name: "LW", Registers: []int{8}, MemoryReference: MemoryReference{0, 0x100}},
+		{Name: "SW", Registers: []int{9}, MemoryReference: MemoryReference{10, 0x104}},
+		{Name: "LB", Registers: []int{10}, MemoryReference: MemoryReference{11, 0x100}},
+		{Name: "LBU", Registers: []int{11}, MemoryReference: MemoryReference{29, 0x40}},
+	}
+	for i, inst := range expected {
+		actual := exc.Get(uint32(i * 4))
+		if actual == nil || !reflect.DeepEqual(*actual, inst) {
+			t.Errorf("memory instruction %d: expected %v but got %v", i, inst, actual)
+		}
+	}
+	if exc.Get(0x104).RawWord != 0x104 {
+		t.Error("unexpected data word:", exc.Get(0x104))
+	}
+
+	errSources := map[string]string{
+		"LW $t0, FAR($0)\n.text 0x8000\nFAR:":       "line 1: memory offset out of bounds: FAR",
+		"LW $t0, FAR-4($0)\n.text 0x8004\nFAR:":     "line 1: memory offset out of bounds: FAR-4",
+		"SW $t0, MISSING+4($t1)":                    "line 1: unknown symbol: MISSING",
+		"ADDIU $t0, $0, %lo(MISSING)":               "line 1: unknown symbol: MISSING",
+		"ORI $t0, $0, DATA+1\n.text 0x10000\nDATA:": "line 1: bad instruction usage for ORI",
+		"ADDIU $t0, $0, 1/(DATA-DATA)\nDATA:":       "line 1: division by zero",
+		"BEQ $t0, $0, DATA+4\nDATA:":                "line 1: bad instruction usage for BEQ",
+	}
+	for source, expected := range errSources {
+		lines, err := TokenizeSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseExecutable(lines); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
+
+func TestParseExecutableData(t *testing.T) {
+	source := `.text 0x100
+table:
+.word first
+.word second+4
+.word table-4
+.word later
+first:
+NOP
+second:
+NOP
+.text 0x2000
+later:`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []uint32{0x110, 0x118, 0xfc, 0x2000} {
+		addr := 0x100 + uint32(i*4)
+		if actual, err := exc.Get(addr).Encode(addr, nil); err != nil {
+			t.Error(err)
+		} else if actual != expected {
+			t.Errorf("word %d: expected %08x but got %08x", i, expected, actual)
+		}
+	}
+
+	lines, err = TokenizeSource(`.text 0x100
+.word 1, second, 3
+.half 0x1234, 0xffff, -1
+.byte 1, 2, 3, 4, -128
+second:`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err = ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedWords := []uint32{1, 0x11c, 3, 0x1234ffff, 0xffff0000, 0x01020304, 0x80000000}
+	for i, expected := range expectedWords {
+		addr := 0x100 + uint32(i*4)
+		if actual, _ := exc.Get(addr).Encode(addr, nil); actual != expected {
+			t.Errorf("data word %d: expected %08x but got %08x", i, expected, actual)
+		} else if line := exc.LineNumbers[addr]; line != []int{2, 2, 2, 3, 3, 4, 4}[i] {
+			t.Errorf("data word %d: unexpected line %d", i, line)
+		}
+	}
+
+	lines, err = TokenizeSource(".ascii \"héllo\"\n.asciiz \"\\x01\"\n.asciiz \"\"\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err = ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedWords = []uint32{0x68c3a96c, 0x6c6f0000, 0x01000000, 0, 0}
+	for i, expected := range expectedWords {
+		addr := uint32(i * 4)
+		if actual, _ := exc.Get(addr).Encode(addr, nil); actual != expected {
+			t.Errorf("string word %d: expected %08x but got %08x", i, expected, actual)
+		}
+	}
+
+	errSources := map[string]string{
+		"NOP\n.word missing":         "line 2: unknown symbol: missing",
+		".byte 256":                  "line 1: value out of range for .byte: 256",
+		".half -32769":               "line 1: value out of range for .half: 4294934527",
+		".text 0x10000\nX:\n.half X": "line 3: value out of range for .half: X",
+	}
+	for source, expected := range errSources {
+		lines, err := TokenizeSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseExecutable(lines); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
+
 func TestExecutableRender(t *testing.T) {
 	programs := []string{
 		`
@@ -154,3 +404,95 @@ func TestExecutableRender(t *testing.T) {
 		}
 	}
 }
+
+func TestExecutableResolveLocation(t *testing.T) {
+	exc := &Executable{Symbols: map[string]uint32{"LOOP": 0x10}}
+	for loc, expected := range map[string]uint32{
+		"LOOP":   0x10,
+		"LOOP+8": 0x18,
+		"LOOP-4": 0xc,
+		"0x20":   0x20,
+		"-1":     0xffffffff,
+	} {
+		addr, err := exc.ResolveLocation(loc)
+		if err != nil {
+			t.Error(loc, err)
+		} else if addr != expected {
+			t.Errorf("%s: expected %d but got %d", loc, expected, addr)
+		}
+	}
+	for _, loc := range []string{"FOO", "LOOP+X", "0x100000000"} {
+		if _, err := exc.ResolveLocation(loc); err == nil {
+			t.Error("expected error for", loc)
+		}
+	}
+}
+
+func TestExecutableInstructionAt(t *testing.T) {
+	// Segments are defined from the highest address down, so that each one is inserted at the
+	// front of the index.
+	var source string
+	for i := 999; i >= 0; i-- {
+		source += fmt.Sprintf(".text %d\nADDIU $t0, $0, %d\nNOP\n", i*12, i)
+	}
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		inst, ok := exc.InstructionAt(uint32(i * 12))
+		if !ok || inst.Name != "ADDIU" || inst.SignedConstant16 != int16(i) {
+			t.Fatalf("unexpected instruction at %d: %v", i*12, inst)
+		}
+		if _, ok := exc.InstructionAt(uint32(i*12 + 8)); ok {
+			t.Fatalf("unexpected instruction at %d", i*12+8)
+		}
+	}
+
+	// The index must notice segments which are added after it is built.
+	exc.Segments[0x100000] = []Instruction{{Name: "SYSCALL"}}
+	if inst, ok := exc.InstructionAt(0x100000); !ok || inst.Name != "SYSCALL" {
+		t.Error("new segment not found")
+	}
+	delete(exc.Segments, 0)
+	if exc.Get(0) != nil {
+		t.Error("deleted segment was found")
+	}
+}
+
+func TestExecutableOrderedSegments(t *testing.T) {
+	lines, err := TokenizeSource(".text 0x20\nB:\nA:\nC:\nNOP\n.text 0x10\nNOP\nNOP\n.text 0x40\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := exc.OrderedSegments()
+	if len(segments) != 3 || segments[0].Address != 0x10 || len(segments[0].Instructions) != 2 ||
+		segments[1].Address != 0x20 || segments[2].Address != 0x40 {
+		t.Fatal("unexpected segments:", segments)
+	}
+
+	// Symbols at the same address must always be rendered in the same order.
+	for i := 0; i < 10; i++ {
+		rendered, err := exc.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var symbols string
+		for _, line := range rendered {
+			if line.SymbolMarker != nil {
+				symbols += *line.SymbolMarker
+			}
+		}
+		if symbols != "ABC" {
+			t.Fatalf("expected symbols ABC but got %s", symbols)
+		}
+	}
+}
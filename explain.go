@@ -0,0 +1,203 @@
+package mips32
+
+import "strconv"
+
+// ExplainInstruction describes, in plain English, what an instruction will do if the emulator
+// runs it next, using the current values of its operands.
+// For example, a branch might be explained as "branch to 0x40 because $t0 (5) != $t1 (3)".
+//
+// The emulator is not modified.
+func ExplainInstruction(inst *Instruction, e *Emulator) string {
+	if inst == nil || inst.Name == "NOP" {
+		return "do nothing"
+	}
+
+	// Running the instruction on a copy of the emulator gives its results without
+	// duplicating the emulator's logic.
+	after := *e
+	after.Memory = &overlayMemory{base: e.Memory, writes: map[uint32]byte{}}
+	after.Syscalls = nil
+	after.AfterExecute = nil
+	var execErr error
+	if inst.Name != "SYSCALL" {
+		execErr = after.Execute(inst)
+	}
+
+	reg := func(r int) string {
+		return "$" + ABIRegisterNames[r]
+	}
+	operand := func(r int) string {
+		return reg(r) + " (" + explainValue(e.RegisterFile[r]) + ")"
+	}
+	result := func(r int) string {
+		return explainValue(after.RegisterFile[r])
+	}
+
+	switch inst.Name {
+	case "BEQ", "BNE", "BGEZ", "BGTZ", "BLEZ", "BLTZ":
+		if execErr != nil {
+			return "fail: " + execErr.Error()
+		}
+		var cond string
+		switch inst.Name {
+		case "BEQ", "BNE":
+			op := "!="
+			if e.RegisterFile[inst.Registers[0]] == e.RegisterFile[inst.Registers[1]] {
+				op = "=="
+			}
+			cond = operand(inst.Registers[0]) + " " + op + " " + operand(inst.Registers[1])
+		default:
+			ops := map[string][2]string{
+				"BGEZ": {">=", "<"},
+				"BGTZ": {">", "<="},
+				"BLEZ": {"<=", ">"},
+				"BLTZ": {"<", ">="},
+			}[inst.Name]
+			op := ops[1]
+			if after.JumpNext {
+				op = ops[0]
+			}
+			cond = operand(inst.Registers[0]) + " " + op + " 0"
+		}
+		target := explainAddress(after.JumpTarget, e.Executable)
+		if after.JumpNext {
+			return "branch to " + target + " because " + cond
+		}
+		return "do not branch to " + target + " because " + cond
+	case "J", "JAL", "JR", "JALR":
+		if execErr != nil {
+			return "fail: " + execErr.Error()
+		}
+		res := "jump to " + explainAddress(after.JumpTarget, e.Executable)
+		if inst.Name == "JR" || inst.Name == "JALR" {
+			res += " (from " + reg(inst.Registers[len(inst.Registers)-1]) + ")"
+		}
+		if inst.Name == "JAL" || inst.Name == "JALR" {
+			dest := 31
+			if inst.Name == "JALR" && len(inst.Registers) == 2 {
+				dest = inst.Registers[0]
+			}
+			res += " and set " + reg(dest) + " to the return address " + result(dest)
+		}
+		return res
+	case "LB", "LBU", "LW", "SB", "SW":
+		ref := inst.MemoryReference
+		addr := e.RegisterFile[ref.Register] + uint32(int32(ref.Offset))
+		location := explainAddress(addr, e.Executable) + " (" + operand(ref.Register) + " + " +
+			strconv.Itoa(int(ref.Offset)) + ")"
+		if execErr != nil {
+			return "fail: " + execErr.Error()
+		}
+		switch inst.Name {
+		case "LB":
+			return "load the byte at " + location + " into " + reg(inst.Registers[0]) +
+				", sign-extended to " + result(inst.Registers[0])
+		case "LBU":
+			return "load the byte at " + location + " into " + reg(inst.Registers[0]) +
+				", zero-extended to " + result(inst.Registers[0])
+		case "LW":
+			return "load the word at " + location + " into " + reg(inst.Registers[0]) +
+				", giving " + result(inst.Registers[0])
+		case "SB":
+			return "store the low byte of " + operand(inst.Registers[0]) + " at " + location
+		default:
+			return "store " + operand(inst.Registers[0]) + " at " + location
+		}
+	case "ADDU", "AND", "NOR", "OR", "SUBU", "XOR", "SLLV", "SRLV", "SRAV":
+		op := map[string]string{
+			"ADDU": "+", "AND": "&", "NOR": "NOR", "OR": "|", "SUBU": "-", "XOR": "^",
+			"SLLV": "<<", "SRLV": ">>", "SRAV": ">>",
+		}[inst.Name]
+		return "set " + reg(inst.Registers[0]) + " to " + result(inst.Registers[0]) + " = " +
+			operand(inst.Registers[1]) + " " + op + " " + operand(inst.Registers[2])
+	case "ADDIU", "ANDI", "ORI", "XORI":
+		op := map[string]string{"ADDIU": "+", "ANDI": "&", "ORI": "|", "XORI": "^"}[inst.Name]
+		constant := strconv.Itoa(int(inst.SignedConstant16))
+		if inst.Name != "ADDIU" {
+			constant = explainValue(uint32(inst.UnsignedConstant16))
+		}
+		return "set " + reg(inst.Registers[0]) + " to " + result(inst.Registers[0]) + " = " +
+			operand(inst.Registers[1]) + " " + op + " " + constant
+	case "LUI":
+		return "set " + reg(inst.Registers[0]) + " to " + result(inst.Registers[0]) + " = " +
+			explainValue(uint32(inst.UnsignedConstant16)) + " << 16"
+	case "SLL", "SRL", "SRA":
+		op := map[string]string{"SLL": "<<", "SRL": ">>", "SRA": ">>"}[inst.Name]
+		return "set " + reg(inst.Registers[0]) + " to " + result(inst.Registers[0]) + " = " +
+			operand(inst.Registers[1]) + " " + op + " " + strconv.Itoa(int(inst.Constant5))
+	case "SLT", "SLTU", "SLTI", "SLTIU":
+		rhs := strconv.Itoa(int(inst.SignedConstant16))
+		if len(inst.Registers) == 3 {
+			rhs = operand(inst.Registers[2])
+		}
+		op := ">="
+		if after.RegisterFile[inst.Registers[0]] == 1 {
+			op = "<"
+		}
+		if inst.Name == "SLTU" || inst.Name == "SLTIU" {
+			op += " (unsigned)"
+		}
+		return "set " + reg(inst.Registers[0]) + " to " + result(inst.Registers[0]) +
+			" because " + operand(inst.Registers[1]) + " " + op + " " + rhs
+	case "MOVN", "MOVZ":
+		cond := operand(inst.Registers[2])
+		moved := (e.RegisterFile[inst.Registers[2]] != 0) == (inst.Name == "MOVN")
+		if e.RegisterFile[inst.Registers[2]] != 0 {
+			cond += " != 0"
+		} else {
+			cond += " == 0"
+		}
+		if moved {
+			return "copy " + operand(inst.Registers[1]) + " into " + reg(inst.Registers[0]) +
+				" because " + cond
+		}
+		return "leave " + reg(inst.Registers[0]) + " unchanged because " + cond
+	case "SYSCALL":
+		return "perform syscall " + strconv.Itoa(int(e.RegisterFile[2])) + " (from $v0)"
+	case ".word":
+		return "fail, since " + explainValue(inst.RawWord) + " is not a valid instruction"
+	}
+	if execErr != nil {
+		return "fail: " + execErr.Error()
+	}
+	return "run " + inst.Name
+}
+
+// explainValue formats small numbers in decimal and large ones in hexadecimal.
+func explainValue(v uint32) string {
+	if n := int32(v); n > -10000 && n < 10000 {
+		return strconv.Itoa(int(n))
+	}
+	return "0x" + strconv.FormatUint(uint64(v), 16)
+}
+
+// explainAddress formats an address, along with the symbol at that address if there is one.
+// If several symbols share the address, the alphabetically first one is used.
+func explainAddress(addr uint32, exc *Executable) string {
+	res := "0x" + strconv.FormatUint(uint64(addr), 16)
+	var symbol string
+	if exc != nil {
+		symbol = symbolAt(exc, addr)
+	}
+	if symbol != "" {
+		res += " (" + symbol + ")"
+	}
+	return res
+}
+
+// overlayMemory records writes without changing the underlying memory.
+type overlayMemory struct {
+	base   Memory
+	writes map[uint32]byte
+}
+
+func (o *overlayMemory) Get(ptr uint32) byte {
+	if b, ok := o.writes[ptr]; ok {
+		return b
+	}
+	return o.base.Get(ptr)
+}
+
+func (o *overlayMemory) Set(ptr uint32, b byte) {
+	o.writes[ptr] = b
+}
@@ -0,0 +1,58 @@
+package mips32
+
+import "testing"
+
+func TestExplainInstruction(t *testing.T) {
+	lines, err := TokenizeSource(`
+BNE $t0, $t1, TARGET
+BEQ $t0, $t1, TARGET
+ADDU $t2, $t0, $t1
+SLT $t2, $t1, $t0
+LW $t2, 4($sp)
+SW $t0, 0($sp)
+JAL TARGET
+BLTZ $t3, TARGET
+TARGET:
+MOVZ $t2, $t0, $zero
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	emu.RegisterFile[8] = 5
+	emu.RegisterFile[9] = 3
+	emu.RegisterFile[11] = 0xffffffff
+	emu.RegisterFile[29] = 0x100
+	emu.Memory.Set(0x107, 42)
+
+	expected := []string{
+		"branch to 0x20 (TARGET) because $t0 (5) != $t1 (3)",
+		"do not branch to 0x20 (TARGET) because $t0 (5) != $t1 (3)",
+		"set $t2 to 8 = $t0 (5) + $t1 (3)",
+		"set $t2 to 1 because $t1 (3) < $t0 (5)",
+		"load the word at 0x104 ($sp (256) + 4) into $t2, giving 42",
+		"store $t0 (5) at 0x100 ($sp (256) + 0)",
+		"jump to 0x20 (TARGET) and set $ra to the return address 32",
+		"branch to 0x20 (TARGET) because $t3 (-1) < 0",
+		"copy $t0 (5) into $t2 because $zero (0) == 0",
+	}
+	for i, x := range expected {
+		emu.ProgramCounter = uint32(i * 4)
+		oldRegisters := emu.RegisterFile
+		actual := ExplainInstruction(exc.Get(emu.ProgramCounter), emu)
+		if actual != x {
+			t.Errorf("instruction %d: expected %q but got %q", i, x, actual)
+		}
+		if emu.RegisterFile != oldRegisters || emu.ProgramCounter != uint32(i*4) ||
+			emu.Memory.Get(0x100) != 0 {
+			t.Fatalf("instruction %d: emulator was modified", i)
+		}
+	}
+	if ExplainInstruction(nil, emu) != "do nothing" {
+		t.Error("unexpected explanation for nil instruction")
+	}
+}
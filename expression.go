@@ -0,0 +1,376 @@
+package mips32
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// An ExpressionEnv supplies the values which an expression may refer to.
+// If any field is nil, expressions which need it are rejected.
+type ExpressionEnv struct {
+	Symbols map[string]uint32
+
+	// Register looks up a register by its name without the "$", in lowercase (such as "t0",
+	// "5", or "pc").
+	Register func(name string) (uint32, bool)
+
+	// Memory reads the word at an address.
+	Memory func(addr uint32) uint32
+}
+
+// EvaluateExpression computes the value of an expression.
+//
+// Expressions may contain numbers (decimal or hexadecimal), registers (like $t0, $5, or $pc),
+// symbols, and memory dereferences, which are written as [address] and read a word.
+// These can be combined with parentheses and the operators + - * / % & | ^ ~ << and >>, which
+// have the same precedence as in C.
+// All arithmetic is done on unsigned 32-bit integers, and >> is a logical shift.
+//
+// As in GNU assemblers, %lo(x) is the low 16 bits of x, and %hi(x) is the upper 16 bits of x,
+// plus one if %lo(x) is negative as a signed 16-bit number.
+// This way, "LUI $t0, %hi(x)" followed by "ADDIU $t0, $t0, %lo(x)" loads x.
+//
+// The assembler accepts the same expressions as instruction operands, except that they cannot
+// use registers or memory.
+func EvaluateExpression(expr string, env *ExpressionEnv) (uint32, error) {
+	p := &exprParser{text: expr}
+	if env != nil {
+		if env.Symbols != nil {
+			p.symbol = func(name string) (uint32, bool) {
+				addr, ok := env.Symbols[name]
+				return addr, ok
+			}
+		}
+		p.register = env.Register
+		p.memory = env.Memory
+	}
+	return p.parse()
+}
+
+// Evaluate computes the value of an expression using the emulator's current state, as described
+// in EvaluateExpression.
+func (e *Emulator) Evaluate(expr string) (uint32, error) {
+	return EvaluateExpression(expr, e.ExpressionEnv())
+}
+
+// ExpressionEnv creates an environment for EvaluateExpression which refers to the emulator's
+// symbols, registers, and memory.
+// Registers and memory are read when an expression is evaluated, so the environment can be
+// reused as the emulator runs.
+func (e *Emulator) ExpressionEnv() *ExpressionEnv {
+	return &ExpressionEnv{
+		Symbols: e.symbols(),
+		Register: func(name string) (uint32, bool) {
+			if name == "pc" {
+				return e.ProgramCounter, true
+			}
+			reg, ok := lookupRegister(name)
+			return e.RegisterFile[reg], ok
+		},
+		Memory: e.loadWord,
+	}
+}
+
+// loadWord reads a word from memory using the emulator's byte order.
+func (e *Emulator) loadWord(addr uint32) uint32 {
+	var word [1]uint32
+	ReadWords(e.Memory, addr, word[:], e.LittleEndian)
+	return word[0]
+}
+
+// evaluateOperand evaluates an expression which is used as an instruction operand.
+// An operand of the form %lo(x) is sign extended, so that it can be used with ADDIU or as a
+// memory offset.
+func evaluateOperand(expr string, symbolValue func(name string) (uint32, bool)) (uint32,
+	error) {
+	p := &exprParser{text: expr, symbol: symbolValue}
+	val, err := p.parse()
+	if err != nil {
+		return 0, err
+	}
+	if isLowHalfExpression(expr) {
+		val = uint32(int16(val))
+	}
+	return val, nil
+}
+
+// checkOperandExpression checks the syntax of an operand expression without knowing the values
+// of its symbols.
+// It returns false for symbols if the expression does not refer to any.
+func checkOperandExpression(expr string) (symbols bool, err error) {
+	p := &exprParser{
+		text: expr,
+		symbol: func(name string) (uint32, bool) {
+			symbols = true
+			return 0, true
+		},
+		checkOnly: true,
+	}
+	_, err = p.parse()
+	return
+}
+
+// isLowHalfExpression checks if an expression is a single %lo(...).
+func isLowHalfExpression(expr string) bool {
+	if !strings.HasPrefix(expr, "%lo(") || !strings.HasSuffix(expr, ")") {
+		return false
+	}
+	var depth int
+	for i := 3; i < len(expr)-1; i++ {
+		if expr[i] == '(' {
+			depth++
+		} else if expr[i] == ')' {
+			depth--
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mapExpressionSymbols replaces every symbol in an expression with the result of f.
+func mapExpressionSymbols(expr string, f func(name string) string) string {
+	var res strings.Builder
+	for i := 0; i < len(expr); {
+		if !isWordByte(expr[i]) {
+			res.WriteByte(expr[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(expr) && isWordByte(expr[i]) {
+			i++
+		}
+		word := expr[start:i]
+		// Numbers, registers, and operators like %hi are not symbols.
+		if word[0] >= '0' && word[0] <= '9' || (start > 0 && (expr[start-1] == '$' ||
+			expr[start-1] == '%')) {
+			res.WriteString(word)
+		} else {
+			res.WriteString(f(word))
+		}
+	}
+	return res.String()
+}
+
+// exprParser is a recursive descent parser which evaluates expressions as it parses them.
+//
+// If any of the lookup functions is nil, expressions which need it are rejected.
+type exprParser struct {
+	text string
+	pos  int
+
+	symbol   func(name string) (uint32, bool)
+	register func(name string) (uint32, bool)
+	memory   func(addr uint32) uint32
+
+	// checkOnly skips errors which depend on the values of symbols, such as division by zero.
+	checkOnly bool
+}
+
+// exprPrecedence lists the binary operators from the loosest to the tightest binding.
+var exprPrecedence = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parse() (uint32, error) {
+	val, err := p.parseBinary(0)
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return 0, errors.New("unexpected " + strconv.Quote(p.text[p.pos:p.pos+1]) +
+			" at offset " + strconv.Itoa(p.pos))
+	}
+	return val, nil
+}
+
+func (p *exprParser) parseBinary(level int) (uint32, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+	val, err := p.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.nextOperator(exprPrecedence[level])
+		if op == "" {
+			return val, nil
+		}
+		rhs, err := p.parseBinary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "|":
+			val |= rhs
+		case "^":
+			val ^= rhs
+		case "&":
+			val &= rhs
+		case "<<":
+			val <<= rhs
+		case ">>":
+			val >>= rhs
+		case "+":
+			val += rhs
+		case "-":
+			val -= rhs
+		case "*":
+			val *= rhs
+		case "/", "%":
+			if rhs == 0 {
+				if p.checkOnly {
+					continue
+				}
+				return 0, errors.New("division by zero")
+			}
+			if op == "/" {
+				val /= rhs
+			} else {
+				val %= rhs
+			}
+		}
+	}
+}
+
+// nextOperator consumes and returns one of the given operators, or returns "" if none of them
+// comes next.
+func (p *exprParser) nextOperator(ops []string) string {
+	p.skipSpace()
+	for _, op := range ops {
+		if strings.HasPrefix(p.text[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parseUnary() (uint32, error) {
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case '-', '~', '+':
+			op := p.text[p.pos]
+			p.pos++
+			val, err := p.parseUnary()
+			if op == '-' {
+				val = -val
+			} else if op == '~' {
+				val = ^val
+			}
+			return val, err
+		}
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (uint32, error) {
+	p.skipSpace()
+	if p.pos == len(p.text) {
+		return 0, errors.New("unexpected end of expression")
+	}
+	switch c := p.text[p.pos]; {
+	case c == '(' || c == '[':
+		p.pos++
+		val, err := p.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+		closing := ")"
+		if c == '[' {
+			closing = "]"
+		}
+		if p.nextOperator([]string{closing}) == "" {
+			return 0, errors.New("missing " + closing)
+		}
+		if c == '[' {
+			if p.memory == nil {
+				return 0, errors.New("memory is not available")
+			}
+			val = p.memory(val)
+		}
+		return val, nil
+	case c == '%':
+		p.pos++
+		name := p.readWord()
+		if name != "hi" && name != "lo" {
+			return 0, errors.New("unknown operator: %" + name)
+		} else if p.nextOperator([]string{"("}) == "" {
+			return 0, errors.New("missing ( after %" + name)
+		}
+		val, err := p.parseBinary(0)
+		if err != nil {
+			return 0, err
+		} else if p.nextOperator([]string{")"}) == "" {
+			return 0, errors.New("missing )")
+		}
+		if name == "hi" {
+			return (val + 0x8000) >> 16, nil
+		}
+		return val & 0xffff, nil
+	case c == '$':
+		p.pos++
+		name := p.readWord()
+		if p.register == nil {
+			return 0, errors.New("registers are not available")
+		}
+		val, ok := p.register(strings.ToLower(name))
+		if !ok {
+			return 0, errors.New("unknown register: $" + name)
+		}
+		return val, nil
+	case c >= '0' && c <= '9':
+		word := p.readWord()
+		var num uint64
+		var err error
+		if strings.HasPrefix(word, "0x") || strings.HasPrefix(word, "0X") {
+			num, err = strconv.ParseUint(word[2:], 16, 32)
+		} else {
+			num, err = strconv.ParseUint(word, 10, 32)
+		}
+		if err != nil {
+			return 0, errors.New("invalid number: " + word)
+		}
+		return uint32(num), nil
+	case isWordByte(c):
+		name := p.readWord()
+		if p.symbol != nil {
+			if val, ok := p.symbol(name); ok {
+				return val, nil
+			}
+		}
+		return 0, errors.New("unknown symbol: " + name)
+	default:
+		return 0, errors.New("unexpected " + strconv.Quote(string(c)) + " at offset " +
+			strconv.Itoa(p.pos))
+	}
+}
+
+func (p *exprParser) readWord() string {
+	start := p.pos
+	for p.pos < len(p.text) && isWordByte(p.text[p.pos]) {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,84 @@
+package mips32
+
+import "testing"
+
+func TestEmulatorEvaluate(t *testing.T) {
+	emu := &Emulator{
+		Memory: NewLazyMemory(),
+		Executable: &Executable{
+			Segments: map[uint32][]Instruction{},
+			Symbols:  map[string]uint32{"ARRAY": 0x1000},
+		},
+		ProgramCounter: 0x40,
+	}
+	emu.RegisterFile[8] = 3
+	emu.RegisterFile[29] = 0x1004
+	emu.Memory.Set(0x1004, 0x12)
+	emu.Memory.Set(0x1007, 0x34)
+
+	tests := map[string]uint32{
+		"5":                 5,
+		"0x10 + 2 * 3":      22,
+		"(0x10 + 2) * 3":    54,
+		"$t0 << 4 | 1":      0x31,
+		"$8 - 4":            0xffffffff,
+		"-1 >> 28":          0xf,
+		"~0 ^ 0xff":         0xffffff00,
+		"$pc / 8 % 3":       2,
+		"ARRAY+4":           0x1004,
+		"[ARRAY + $t0 + 1]": 0x12000034,
+		"[$sp] & 0xff":      0x34,
+		"$SP":               0x1004,
+	}
+	for expr, expected := range tests {
+		actual, err := emu.Evaluate(expr)
+		if err != nil {
+			t.Errorf("%s: %s", expr, err)
+		} else if actual != expected {
+			t.Errorf("%s: expected %#x but got %#x", expr, expected, actual)
+		}
+	}
+
+	for _, expr := range []string{"", "1 +", "(1", "[1", "$foo", "FOO", "1 / 0", "1 2", "0x"} {
+		if _, err := emu.Evaluate(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	env := &ExpressionEnv{Symbols: map[string]uint32{"data": 0x1001fffc}}
+	tests := map[string]uint32{
+		"%hi(data)":         0x1002,
+		"%lo(data)":         0xfffc,
+		"%hi(0x12347fff)":   0x1234,
+		"%lo(data + 8) * 2": 0x8,
+		"(%hi(data) << 16) + (%lo(data) ^ 0x8000) - 0x8000": 0x1001fffc,
+		"7 % 4": 3,
+	}
+	for expr, expected := range tests {
+		actual, err := EvaluateExpression(expr, env)
+		if err != nil {
+			t.Errorf("%s: %s", expr, err)
+		} else if actual != expected {
+			t.Errorf("%s: expected %#x but got %#x", expr, expected, actual)
+		}
+	}
+
+	errors := map[string]string{
+		"%mid(1)":  "unknown operator: %mid",
+		"%hi 1":    "missing ( after %hi",
+		"%lo(1":    "missing )",
+		"$t0":      "registers are not available",
+		"[data]":   "memory is not available",
+		"data + x": "unknown symbol: x",
+	}
+	for expr, expected := range errors {
+		if _, err := EvaluateExpression(expr, env); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q but got %v", expr, expected, err)
+		}
+	}
+	if val, err := EvaluateExpression("1 + 2", nil); err != nil || val != 3 {
+		t.Errorf("unexpected result without an environment: %d, %v", val, err)
+	}
+}
@@ -0,0 +1,97 @@
+package mips32
+
+import "strings"
+
+const (
+	formatIndent        = "    "
+	formatMnemonicWidth = 6
+)
+
+// Format pretty-prints an assembly program.
+//
+// Labels are placed on their own lines, while instructions and directives are indented.
+// Operands are aligned into a column and written in their canonical form (e.g. "$8" rather than
+// "$t0"), unless FormatOptions is used to choose another form.
+// Trailing comments are aligned with each other within each block of lines, where blocks are
+// separated by blank lines.
+// Runs of blank lines are collapsed into a single blank line.
+// Statements which share a line (separated by semicolons) are put on lines of their own.
+//
+// This fails if the source cannot be tokenized or contains an unrecognized instruction.
+func Format(source string) (string, error) {
+	return FormatOptions(source, nil)
+}
+
+// FormatOptions is like Format, but it renders operands according to some options.
+func FormatOptions(source string, opts *RenderOptions) (string, error) {
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		return "", err
+	}
+
+	codes := make([]string, len(lines))
+	for i, line := range lines {
+		codes[i], err = formatLineCode(&line, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var res []string
+	blockStart := 0
+	for blockStart < len(lines) {
+		blockEnd := blockStart + 1
+		for blockEnd < len(lines) &&
+			lines[blockEnd].LineNumber <= lines[blockEnd-1].LineNumber+1 {
+			blockEnd++
+		}
+
+		commentColumn := 0
+		for i := blockStart; i < blockEnd; i++ {
+			if lines[i].Comment != nil && len(codes[i]) > commentColumn {
+				commentColumn = len(codes[i])
+			}
+		}
+
+		if blockStart > 0 {
+			res = append(res, "")
+		}
+		for i := blockStart; i < blockEnd; i++ {
+			code := codes[i]
+			if lines[i].Comment == nil {
+				res = append(res, code)
+			} else if code == "" {
+				res = append(res, formatIndent+"#"+*lines[i].Comment)
+			} else {
+				padding := strings.Repeat(" ", commentColumn-len(code)+1)
+				res = append(res, code+padding+"#"+*lines[i].Comment)
+			}
+		}
+		blockStart = blockEnd
+	}
+
+	if len(res) == 0 {
+		return "", nil
+	}
+	return strings.Join(res, "\n") + "\n", nil
+}
+
+func formatLineCode(line *TokenizedLine, opts *RenderOptions) (string, error) {
+	if line.SymbolMarker != nil {
+		return *line.SymbolMarker + ":", nil
+	} else if line.Directive != nil {
+		return formatIndent + line.Directive.Format(opts), nil
+	} else if line.Instruction == nil {
+		return "", nil
+	}
+	args, ok := line.Instruction.argumentStrings(opts)
+	if !ok {
+		return "", lineError(line.LineNumber, "unrecognized instruction: "+line.Instruction.Name)
+	}
+	code := formatIndent + opts.mnemonic(line.Instruction.Name)
+	if len(args) > 0 {
+		code += strings.Repeat(" ", formatMnemonicWidth-len(line.Instruction.Name)) +
+			strings.Join(args, ", ")
+	}
+	return code, nil
+}
@@ -0,0 +1,57 @@
+package mips32
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	source := `# Compute something.
+  START:
+  LUI $t0,   0xdead
+ORI $t0, $t0, 0x10 // low bits
+	J START     # loop forever
+NOP; NOP
+
+
+
+.text 0x100
+  addu $r1, $v0, $a0 # add
+  .word 0x5
+`
+	expected := `    # Compute something.
+START:
+    LUI   $8, 57005
+    ORI   $8, $8, 16 # low bits
+    J     START      # loop forever
+    NOP
+    NOP
+
+    .text 256
+    ADDU  $1, $2, $4 # add
+    .word 5
+`
+	actual, err := Format(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != expected {
+		t.Errorf("unexpected output:\n%s", actual)
+	}
+
+	if _, err := Format("FOO $r1"); err == nil {
+		t.Error("expected error for unknown instruction")
+	}
+
+	symbolic, err := Format("lui $t0, %hi(x)\naddiu $t0,$t0,%lo(x)\nlw $t1, x+4($sp)\nx:")
+	if err != nil {
+		t.Fatal(err)
+	} else if symbolic != "    LUI   $8, %hi(x)\n    ADDIU $8, $8, %lo(x)\n"+
+		"    LW    $9, x+4($29)\nx:\n" {
+		t.Errorf("unexpected output: %q", symbolic)
+	}
+
+	abi, err := FormatOptions("addu $8, $9, $sp", &RenderOptions{Registers: ABIRegisters})
+	if err != nil {
+		t.Fatal(err)
+	} else if abi != "    ADDU  $t0, $t1, $sp\n" {
+		t.Errorf("unexpected output: %q", abi)
+	}
+}
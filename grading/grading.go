@@ -0,0 +1,367 @@
+// Package grading checks programs against declarative test suites and scores the results, for
+// autograding assignments.
+//
+// A suite is usually loaded from JSON (see ParseSuite).
+// Each case sets up registers, memory, and console input, runs the program with a step budget,
+// and then checks registers, memory, console output, and how the program exited.
+package grading
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+// DefaultMaxSteps is the step budget of a case which does not set one.
+const DefaultMaxSteps = 100000
+
+// A Suite is a set of test cases for one program.
+type Suite struct {
+	// LittleEndian sets the byte order of the emulator's memory.
+	LittleEndian bool `json:"littleEndian"`
+
+	// AutoNOP inserts a NOP after every branch and jump (see mips32.DialectNoDelaySlots).
+	AutoNOP bool `json:"autoNop"`
+
+	Cases []Case `json:"cases"`
+}
+
+// A Case describes the initial state of the machine and what is expected after the program
+// finishes running.
+//
+// Registers are named like operands, but without the "$" (e.g. "t0", "r8", or "8").
+// Memory maps addresses (e.g. "0x1000") to lists of consecutive words, which are written after
+// the program itself is loaded into memory.
+type Case struct {
+	Name string `json:"name"`
+
+	// Points is the case's weight in the score.
+	// If it is 0, the case is worth one point.
+	Points int `json:"points"`
+
+	Registers map[string]uint32   `json:"registers"`
+	Memory    map[string][]uint32 `json:"memory"`
+
+	// Input is read by the syscalls which read from the console.
+	Input string `json:"input"`
+
+	// MaxSteps is the most instructions the program may run.
+	// If it is 0, DefaultMaxSteps is used.
+	MaxSteps int `json:"maxSteps"`
+
+	Expect Expectation `json:"expect"`
+}
+
+// An Expectation lists what a case checks after the program finishes.
+// Fields which are nil are not checked.
+type Expectation struct {
+	Registers map[string]uint32   `json:"registers"`
+	Memory    map[string][]uint32 `json:"memory"`
+
+	// Output is the exact text the program must print to the console.
+	Output *string `json:"output"`
+
+	// ExitCode is the code which the program must pass to the exit2 syscall.
+	ExitCode *int `json:"exitCode"`
+
+	// Exit requires that the program stops with an exit syscall (if true) or by running past
+	// the end of its code (if false).
+	Exit *bool `json:"exit"`
+}
+
+// A Report is the result of grading a program against a suite.
+type Report struct {
+	// Error is set if the program could not be assembled, in which case every case fails.
+	Error string `json:"error,omitempty"`
+
+	Score    int          `json:"score"`
+	MaxScore int          `json:"maxScore"`
+	Results  []CaseResult `json:"results"`
+}
+
+// Passed returns true if every case passed.
+func (r *Report) Passed() bool {
+	return r.Score == r.MaxScore && r.Error == ""
+}
+
+// A CaseResult is the result of one case.
+type CaseResult struct {
+	Name      string `json:"name"`
+	Passed    bool   `json:"passed"`
+	Points    int    `json:"points"`
+	MaxPoints int    `json:"maxPoints"`
+
+	// Failures describes each check which failed, or the error which stopped the program.
+	Failures []string `json:"failures,omitempty"`
+
+	Steps  int    `json:"steps"`
+	Output string `json:"output"`
+}
+
+// ParseSuite decodes a suite from JSON and checks that its register names and addresses are
+// valid.
+func ParseSuite(data []byte) (*Suite, error) {
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, err
+	}
+	if err := suite.Validate(); err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+// Validate checks that every register name and address in the suite is valid.
+func (s *Suite) Validate() error {
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		for _, regs := range []map[string]uint32{c.Registers, c.Expect.Registers} {
+			for name := range regs {
+				if _, err := parseRegister(name); err != nil {
+					return errors.New(c.name(i) + ": " + err.Error())
+				}
+			}
+		}
+		for _, mem := range []map[string][]uint32{c.Memory, c.Expect.Memory} {
+			for addr := range mem {
+				if _, err := parseAddress(addr); err != nil {
+					return errors.New(c.name(i) + ": " + err.Error())
+				}
+			}
+		}
+		if c.Points < 0 || c.MaxSteps < 0 {
+			return errors.New(c.name(i) + ": points and maxSteps must not be negative")
+		}
+	}
+	return nil
+}
+
+// Grade assembles a program and runs it against every case.
+// If the program cannot be assembled, every case fails and the report's Error is set.
+func (s *Suite) Grade(source string) *Report {
+	opts := &mips32.AssembleOptions{LittleEndian: s.LittleEndian}
+	if s.AutoNOP {
+		opts.Dialect = mips32.DialectNoDelaySlots
+	}
+	exc, err := mips32.Assemble(source, opts)
+	if err != nil {
+		res := &Report{Error: err.Error()}
+		for i := range s.Cases {
+			c := &s.Cases[i]
+			res.MaxScore += c.maxPoints()
+			res.Results = append(res.Results, CaseResult{
+				Name:      c.name(i),
+				MaxPoints: c.maxPoints(),
+				Failures:  []string{"assembly failed: " + err.Error()},
+			})
+		}
+		return res
+	}
+	return s.GradeExecutable(exc)
+}
+
+// GradeExecutable runs an assembled program against every case.
+// Each case runs on a fresh emulator, so cases cannot affect each other.
+func (s *Suite) GradeExecutable(e *mips32.Executable) *Report {
+	res := &Report{}
+	for i := range s.Cases {
+		result := s.Cases[i].run(e, s.LittleEndian)
+		result.Name = s.Cases[i].name(i)
+		res.Score += result.Points
+		res.MaxScore += result.MaxPoints
+		res.Results = append(res.Results, result)
+	}
+	return res
+}
+
+// String summarizes the report with one line per case, like "PASS name" or
+// "FAIL name: reason", followed by the number of cases which passed and the score.
+func (r *Report) String() string {
+	var lines []string
+	if r.Error != "" {
+		lines = append(lines, "ERROR "+r.Error)
+	}
+	passed := 0
+	for _, result := range r.Results {
+		if result.Passed {
+			passed++
+			lines = append(lines, "PASS "+result.Name)
+		} else {
+			lines = append(lines, "FAIL "+result.Name+": "+strings.Join(result.Failures, "; "))
+		}
+	}
+	lines = append(lines, strconv.Itoa(passed)+" of "+strconv.Itoa(len(r.Results))+
+		" tests passed (score "+strconv.Itoa(r.Score)+" of "+strconv.Itoa(r.MaxScore)+")")
+	return strings.Join(lines, "\n")
+}
+
+func (c *Case) name(index int) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return "case " + strconv.Itoa(index+1)
+}
+
+func (c *Case) maxPoints() int {
+	if c.Points == 0 {
+		return 1
+	}
+	return c.Points
+}
+
+func (c *Case) run(e *mips32.Executable, littleEndian bool) CaseResult {
+	res := CaseResult{MaxPoints: c.maxPoints()}
+	var output bytes.Buffer
+	syscalls := &mips32.SPIMSyscalls{Input: strings.NewReader(c.Input), Output: &output}
+	emu := &mips32.Emulator{
+		Memory:       mips32.NewLazyMemory(),
+		Executable:   e,
+		LittleEndian: littleEndian,
+		Syscalls:     syscalls,
+	}
+	fail := func(msg string) CaseResult {
+		res.Failures = append(res.Failures, msg)
+		res.Output = output.String()
+		return res
+	}
+
+	if err := e.LoadInto(emu.Memory, littleEndian); err != nil {
+		return fail(err.Error())
+	}
+
+	for name, value := range c.Registers {
+		reg, err := parseRegister(name)
+		if err != nil {
+			return fail(err.Error())
+		} else if reg != 0 {
+			emu.RegisterFile[reg] = value
+		}
+	}
+	for addrStr, words := range c.Memory {
+		addr, err := parseAddress(addrStr)
+		if err != nil {
+			return fail(err.Error())
+		}
+		mips32.WriteWords(emu.Memory, addr, words, emu.LittleEndian)
+	}
+
+	maxSteps := c.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	steps, err := emu.StepN(maxSteps)
+	res.Steps = steps
+	if err != nil {
+		return fail(err.Error())
+	} else if !emu.Done() {
+		return fail("exceeded " + strconv.Itoa(maxSteps) + " steps")
+	}
+	res.Output = output.String()
+
+	res.Failures = c.Expect.check(emu, syscalls, res.Output)
+	if len(res.Failures) == 0 {
+		res.Passed = true
+		res.Points = res.MaxPoints
+	}
+	return res
+}
+
+// check compares the state of a finished program against the expectation, and describes each
+// difference.
+func (x *Expectation) check(emu *mips32.Emulator, syscalls *mips32.SPIMSyscalls,
+	output string) []string {
+	var res []string
+
+	regNames := make([]string, 0, len(x.Registers))
+	for name := range x.Registers {
+		regNames = append(regNames, name)
+	}
+	sort.Strings(regNames)
+	for _, name := range regNames {
+		reg, err := parseRegister(name)
+		if err != nil {
+			res = append(res, err.Error())
+			continue
+		}
+		expected := x.Registers[name]
+		if actual := emu.RegisterFile[reg]; actual != expected {
+			res = append(res, "$"+name+" is "+hexString(actual)+" (expected "+
+				hexString(expected)+")")
+		}
+	}
+
+	addrStrs := make([]string, 0, len(x.Memory))
+	for addrStr := range x.Memory {
+		addrStrs = append(addrStrs, addrStr)
+	}
+	sort.Strings(addrStrs)
+	for _, addrStr := range addrStrs {
+		addr, err := parseAddress(addrStr)
+		if err != nil {
+			res = append(res, err.Error())
+			continue
+		}
+		expectedWords := x.Memory[addrStr]
+		actualWords := make([]uint32, len(expectedWords))
+		mips32.ReadWords(emu.Memory, addr, actualWords, emu.LittleEndian)
+		for i, expected := range expectedWords {
+			wordAddr := addr + uint32(i*4)
+			if actual := actualWords[i]; actual != expected {
+				res = append(res, "word at "+hexString(wordAddr)+" is "+hexString(actual)+
+					" (expected "+hexString(expected)+")")
+				break
+			}
+		}
+	}
+
+	if x.Output != nil && output != *x.Output {
+		res = append(res, "output is "+strconv.Quote(output)+" (expected "+
+			strconv.Quote(*x.Output)+")")
+	}
+	if x.Exit != nil && emu.Halted != *x.Exit {
+		if emu.Halted {
+			res = append(res, "program exited with a syscall (expected it to run off the end)")
+		} else {
+			res = append(res, "program ran off the end (expected an exit syscall)")
+		}
+	}
+	if x.ExitCode != nil && (!emu.Halted || syscalls.ExitCode != *x.ExitCode) {
+		if !emu.Halted {
+			res = append(res, "program did not exit with a syscall (expected exit code "+
+				strconv.Itoa(*x.ExitCode)+")")
+		} else {
+			res = append(res, "exit code is "+strconv.Itoa(syscalls.ExitCode)+" (expected "+
+				strconv.Itoa(*x.ExitCode)+")")
+		}
+	}
+	return res
+}
+
+func parseRegister(name string) (int, error) {
+	token, err := mips32.ParseArgToken("$" + name)
+	if err != nil {
+		return 0, errors.New("invalid register: " + name)
+	}
+	reg, ok := token.Register()
+	if !ok {
+		return 0, errors.New("invalid register: " + name)
+	}
+	return reg, nil
+}
+
+func parseAddress(s string) (uint32, error) {
+	num, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, errors.New("invalid address: " + s)
+	}
+	return uint32(num), nil
+}
+
+func hexString(n uint32) string {
+	return "0x" + strconv.FormatUint(uint64(n), 16)
+}
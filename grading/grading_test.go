@@ -0,0 +1,153 @@
+package grading
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sumProgram reads a count from the console and then that many words starting at $a0, prints
+// their sum, stores it after the words, and exits with the count as its exit code.
+const sumProgram = `	ADDIU $v0, $0, 5
+	SYSCALL
+	ADDU $t0, $v0, $0
+	ADDU $t1, $0, $0
+	ADDU $t2, $t0, $0
+LOOP:
+	BEQ $t2, $0, DONE
+	NOP
+	LW $t3, 0($a0)
+	ADDU $t1, $t1, $t3
+	ADDIU $a0, $a0, 4
+	J LOOP
+	ADDIU $t2, $t2, -1
+DONE:
+	SW $t1, 0($a0)
+	ADDU $a0, $t1, $0
+	ADDIU $v0, $0, 1
+	SYSCALL
+	ADDU $a0, $t0, $0
+	ADDIU $v0, $0, 17
+	SYSCALL
+`
+
+const sumSuite = `{
+  "cases": [
+    {
+      "name": "three words",
+      "points": 3,
+      "registers": {"a0": 256},
+      "memory": {"0x100": [1, 2, 3]},
+      "input": "3\n",
+      "expect": {
+        "registers": {"t1": 6},
+        "memory": {"0x10c": [6]},
+        "output": "6",
+        "exitCode": 3,
+        "exit": true
+      }
+    },
+    {
+      "registers": {"a0": 256},
+      "memory": {"0x100": [5]},
+      "input": "1\n",
+      "expect": {"output": "4", "exitCode": 2, "memory": {"0x100": [5, 4]}}
+    },
+    {
+      "name": "budget",
+      "input": "1000\n",
+      "maxSteps": 50
+    },
+    {
+      "name": "runs off the end",
+      "input": "0\n",
+      "expect": {"exit": false}
+    }
+  ]
+}`
+
+func TestGrade(t *testing.T) {
+	suite, err := ParseSuite([]byte(sumSuite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := suite.Grade(sumProgram)
+	if report.Score != 3 || report.MaxScore != 6 || report.Passed() || report.Error != "" {
+		t.Errorf("unexpected score: %d of %d", report.Score, report.MaxScore)
+	}
+
+	expected := []CaseResult{
+		{Name: "three words", Passed: true, Points: 3, MaxPoints: 3, Output: "6"},
+		{
+			Name:      "case 2",
+			MaxPoints: 1,
+			Failures: []string{
+				"word at 0x104 is 0x5 (expected 0x4)",
+				`output is "5" (expected "4")`,
+				"exit code is 1 (expected 2)",
+			},
+			Output: "5",
+		},
+		{
+			Name:      "budget",
+			MaxPoints: 1,
+			Failures:  []string{"exceeded 50 steps"},
+			Steps:     50,
+		},
+		{
+			Name:      "runs off the end",
+			MaxPoints: 1,
+			Failures:  []string{"program exited with a syscall (expected it to run off the end)"},
+			Output:    "0",
+		},
+	}
+	for i, result := range report.Results {
+		result.Steps = expected[i].Steps
+		if i != 2 && report.Results[i].Steps == 0 {
+			t.Errorf("case %d: expected steps to be counted", i)
+		}
+		if !reflect.DeepEqual(result, expected[i]) {
+			t.Errorf("case %d: expected %+v but got %+v", i, expected[i], result)
+		}
+	}
+	if report.Results[2].Steps != 50 {
+		t.Errorf("unexpected step count: %d", report.Results[2].Steps)
+	}
+
+	lines := strings.Split(report.String(), "\n")
+	if lines[0] != "PASS three words" || !strings.HasPrefix(lines[1], "FAIL case 2: word at") ||
+		lines[4] != "1 of 4 tests passed (score 3 of 6)" {
+		t.Errorf("unexpected report text:\n%s", report)
+	}
+}
+
+func TestGradeAssemblyError(t *testing.T) {
+	suite, err := ParseSuite([]byte(sumSuite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := suite.Grade("FOO $t0")
+	if report.Error == "" || report.Score != 0 || report.MaxScore != 6 || len(report.Results) != 4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if !strings.HasPrefix(report.String(), "ERROR ") {
+		t.Errorf("unexpected report text:\n%s", report)
+	}
+}
+
+func TestParseSuiteErrors(t *testing.T) {
+	cases := map[string]string{
+		`{"cases": [{"registers": {"q9": 1}}]}`:               "case 1: invalid register: q9",
+		`{"cases": [{}, {"expect": {"memory": {"x": [1]}}}]}`: "case 2: invalid address: x",
+		`{"cases": [{"name": "neg", "points": -1}]}`: "neg: points and maxSteps must not be " +
+			"negative",
+	}
+	for data, expected := range cases {
+		if _, err := ParseSuite([]byte(data)); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q but got %v", data, expected, err)
+		}
+	}
+	if _, err := ParseSuite([]byte("{")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
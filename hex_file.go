@@ -0,0 +1,194 @@
+package mips32
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const intelHexRecordSize = 16
+
+const (
+	intelHexData            = 0
+	intelHexEndOfFile       = 1
+	intelHexExtendedSegment = 2
+	intelHexExtendedAddress = 4
+)
+
+// WriteIntelHex writes chunks of memory as an Intel HEX file.
+// The chunks map start addresses to the bytes which should be stored there.
+func WriteIntelHex(w io.Writer, chunks map[uint32][]byte) error {
+	buf := bufio.NewWriter(w)
+
+	starts := make(uint32List, 0, len(chunks))
+	for start := range chunks {
+		starts = append(starts, start)
+	}
+	sort.Sort(starts)
+
+	upperAddress := uint32(0)
+	for _, start := range starts {
+		data := chunks[start]
+		for offset := 0; offset < len(data); {
+			addr := start + uint32(offset)
+			size := len(data) - offset
+			if size > intelHexRecordSize {
+				size = intelHexRecordSize
+			}
+			// Records may not cross a 64KB boundary.
+			if boundary := 0x10000 - int(addr&0xffff); size > boundary {
+				size = boundary
+			}
+			if addr>>16 != upperAddress {
+				upperAddress = addr >> 16
+				upper := []byte{byte(upperAddress >> 8), byte(upperAddress)}
+				writeIntelHexRecord(buf, 0, intelHexExtendedAddress, upper)
+			}
+			writeIntelHexRecord(buf, uint16(addr), intelHexData, data[offset:offset+size])
+			offset += size
+		}
+	}
+	writeIntelHexRecord(buf, 0, intelHexEndOfFile, nil)
+
+	return buf.Flush()
+}
+
+func writeIntelHexRecord(w *bufio.Writer, addr uint16, recordType byte, data []byte) {
+	record := []byte{byte(len(data)), byte(addr >> 8), byte(addr), recordType}
+	record = append(record, data...)
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	record = append(record, -sum)
+
+	w.WriteString(":")
+	for _, b := range record {
+		w.WriteString(twoDigitHex(b))
+	}
+	w.WriteString("\n")
+}
+
+func twoDigitHex(b byte) string {
+	s := strings.ToUpper(strconv.FormatUint(uint64(b), 16))
+	if len(s) < 2 {
+		s = "0" + s
+	}
+	return s
+}
+
+// ReadIntelHex reads an Intel HEX file and returns the chunks of memory it describes.
+// Records may appear in any order, and adjacent records are joined into a single chunk.
+// Records which overlap are an error.
+func ReadIntelHex(r io.Reader) (map[uint32][]byte, error) {
+	var records []dataRecord
+	var baseAddress uint32
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		record, err := parseIntelHexRecord(line)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(lineNum) + ": " + err.Error())
+		}
+		addr := (uint32(record[1]) << 8) | uint32(record[2])
+		data := record[4 : len(record)-1]
+		switch record[3] {
+		case intelHexData:
+			records = append(records, dataRecord{
+				addr: addr + baseAddress,
+				data: data,
+				line: lineNum,
+			})
+		case intelHexEndOfFile:
+			return joinDataRecords(records)
+		case intelHexExtendedSegment, intelHexExtendedAddress:
+			if len(data) != 2 {
+				return nil, errors.New("line " + strconv.Itoa(lineNum) +
+					": invalid extended address record")
+			}
+			baseAddress = (uint32(data[0]) << 8) | uint32(data[1])
+			if record[3] == intelHexExtendedSegment {
+				baseAddress <<= 4
+			} else {
+				baseAddress <<= 16
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("missing end of file record")
+}
+
+// A dataRecord is the data from one line of a HEX or S-record file.
+type dataRecord struct {
+	addr uint32
+	data []byte
+	line int
+}
+
+// joinDataRecords sorts records by address and joins adjacent ones into chunks.
+// It fails if two records overlap or if a record runs past the end of the address space.
+func joinDataRecords(records []dataRecord) (map[uint32][]byte, error) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].addr < records[j].addr
+	})
+	chunks := map[uint32][]byte{}
+	var chunkStart uint32
+	var chunkEnd uint64
+	var lastLine int
+	for _, record := range records {
+		if len(record.data) == 0 {
+			continue
+		}
+		end := uint64(record.addr) + uint64(len(record.data))
+		if end > 1<<32 {
+			return nil, errors.New("line " + strconv.Itoa(record.line) +
+				": data runs past the end of memory")
+		}
+		if lastLine != 0 && uint64(record.addr) < chunkEnd {
+			return nil, errors.New("line " + strconv.Itoa(record.line) +
+				": data overlaps line " + strconv.Itoa(lastLine))
+		}
+		if lastLine == 0 || uint64(record.addr) != chunkEnd {
+			chunkStart = record.addr
+		}
+		chunks[chunkStart] = append(chunks[chunkStart], record.data...)
+		chunkEnd = end
+		lastLine = record.line
+	}
+	return chunks, nil
+}
+
+// parseIntelHexRecord decodes and verifies a single record.
+// The result includes the length, address, type, data, and checksum bytes.
+func parseIntelHexRecord(line string) ([]byte, error) {
+	if !strings.HasPrefix(line, ":") {
+		return nil, errors.New("missing start code")
+	}
+	record, err := hex.DecodeString(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(record) < 5 || len(record) != int(record[0])+5 {
+		return nil, errors.New("invalid record length")
+	}
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, errors.New("bad checksum")
+	}
+	return record, nil
+}
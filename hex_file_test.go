@@ -0,0 +1,128 @@
+package mips32
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestWriteIntelHex(t *testing.T) {
+	chunks := map[uint32][]byte{
+		0x100:   []byte{0x3c, 0x01, 0xde, 0xad},
+		0x1fffe: []byte{1, 2, 3, 4},
+	}
+	var buf bytes.Buffer
+	if err := WriteIntelHex(&buf, chunks); err != nil {
+		t.Fatal(err)
+	}
+	expected := ":040100003C01DEAD33\n" +
+		":020000040001F9\n" +
+		":02FFFE000102FE\n" +
+		":020000040002F8\n" +
+		":020000000304F7\n" +
+		":00000001FF\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestReadIntelHex(t *testing.T) {
+	chunks := map[uint32][]byte{
+		0x100:   []byte{0x3c, 0x01, 0xde, 0xad},
+		0x1fff0: bytes.Repeat([]byte{0x55}, 40),
+		0x30000: []byte{1},
+	}
+	var buf bytes.Buffer
+	if err := WriteIntelHex(&buf, chunks); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadIntelHex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(chunks) {
+		t.Fatal("unexpected number of chunks:", len(decoded))
+	}
+	for start, data := range chunks {
+		if !bytes.Equal(decoded[start], data) {
+			t.Error("bad chunk at", start, "-", decoded[start])
+		}
+	}
+
+	failures := []string{
+		":040100003C01DEAD34\n:00000001FF\n",
+		"040100003C01DEAD33\n:00000001FF\n",
+		":040100003C01DEAD33\n",
+	}
+	for _, failure := range failures {
+		if _, err := ReadIntelHex(bytes.NewBufferString(failure)); err == nil {
+			t.Error("expected error for:", failure)
+		}
+	}
+}
+
+func TestReadIntelHexOrder(t *testing.T) {
+	type record struct {
+		addr uint16
+		kind byte
+		data []byte
+	}
+	encode := func(records []record) *bytes.Buffer {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		for _, r := range records {
+			writeIntelHexRecord(w, r.addr, r.kind, r.data)
+		}
+		writeIntelHexRecord(w, 0, intelHexEndOfFile, nil)
+		w.Flush()
+		return &buf
+	}
+
+	decoded, err := ReadIntelHex(encode([]record{
+		{0x104, intelHexData, []byte{5, 6, 7, 8}},
+		{0x200, intelHexData, []byte{9}},
+		{0x100, intelHexData, []byte{1, 2, 3, 4}},
+		{0x108, intelHexData, []byte{10}},
+		{0, intelHexExtendedAddress, []byte{0, 1}},
+		{0xfffe, intelHexData, []byte{11, 12}},
+		{0, intelHexExtendedAddress, []byte{0, 0}},
+		{0xfffc, intelHexData, []byte{13, 14}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[uint32][]byte{
+		0x100:   []byte{1, 2, 3, 4, 5, 6, 7, 8, 10},
+		0x200:   []byte{9},
+		0xfffc:  []byte{13, 14},
+		0x1fffe: []byte{11, 12},
+	}
+	if len(decoded) != len(expected) {
+		t.Fatal("unexpected chunks:", decoded)
+	}
+	for start, data := range expected {
+		if !bytes.Equal(decoded[start], data) {
+			t.Error("bad chunk at", start, "-", decoded[start])
+		}
+	}
+
+	failures := [][]record{
+		{
+			{0x100, intelHexData, []byte{1, 2, 3, 4}},
+			{0x100, intelHexData, []byte{1, 2, 3, 4}},
+		},
+		{
+			{0x104, intelHexData, []byte{1, 2, 3, 4}},
+			{0x100, intelHexData, []byte{1, 2, 3, 4, 5}},
+		},
+		{
+			{0, intelHexExtendedAddress, []byte{0xff, 0xff}},
+			{0xfffe, intelHexData, []byte{1, 2, 3}},
+		},
+	}
+	for i, records := range failures {
+		if _, err := ReadIntelHex(encode(records)); err == nil {
+			t.Error("expected error for case", i)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package mips32
+
+import "strings"
+
+// A TokenClass categorizes a piece of assembly code for syntax highlighting.
+type TokenClass int
+
+const (
+	MnemonicToken TokenClass = iota
+	RegisterToken
+	ConstantToken
+	LabelToken
+	DirectiveToken
+	CommentToken
+	StringToken
+)
+
+// String returns a lowercase name for the class, like "mnemonic" or "register".
+func (t TokenClass) String() string {
+	switch t {
+	case MnemonicToken:
+		return "mnemonic"
+	case RegisterToken:
+		return "register"
+	case ConstantToken:
+		return "constant"
+	case LabelToken:
+		return "label"
+	case DirectiveToken:
+		return "directive"
+	case CommentToken:
+		return "comment"
+	case StringToken:
+		return "string"
+	}
+	return "unknown"
+}
+
+// A SourceToken is a classified piece of a source file.
+// Start and End are byte offsets into the source, with End being exclusive.
+type SourceToken struct {
+	Class TokenClass
+	Start int
+	End   int
+}
+
+// ClassifyTokens finds the tokens in a source file and classifies each one.
+//
+// Unlike TokenizeSource, this never fails: lines that do not tokenize are classified as well as
+// possible, so that code can be highlighted while it is being typed.
+// Labels include both symbol declarations (with their trailing colon) and symbol references.
+// Punctuation and whitespace are not included in any token.
+func ClassifyTokens(source string) []SourceToken {
+	var res []SourceToken
+	lineStart := 0
+	var inComment bool
+	for _, line := range strings.SplitAfter(source, "\n") {
+		var comments []blockComment
+		comments, inComment = findBlockComments(line, inComment)
+		tokens := classifyLine(removeBlockComments(line, comments), lineStart)
+		for _, c := range comments {
+			end := c.start + len(strings.TrimRight(line[c.start:c.end], "\r\n"))
+			if end == c.start {
+				continue
+			}
+			comment := SourceToken{Class: CommentToken, Start: lineStart + c.start,
+				End: lineStart + end}
+			// Keep the tokens in the order they appear in the source.
+			idx := len(tokens)
+			for idx > 0 && tokens[idx-1].Start > comment.Start {
+				idx--
+			}
+			tokens = append(tokens[:idx], append([]SourceToken{comment}, tokens[idx:]...)...)
+		}
+		res = append(res, tokens...)
+		lineStart += len(line)
+	}
+	return res
+}
+
+func classifyLine(line string, offset int) []SourceToken {
+	var comment []SourceToken
+	code := line
+	if idx := commentIndex(line); idx >= 0 {
+		code = line[:idx]
+		text := strings.TrimRight(line[idx:], "\r\n")
+		comment = append(comment, SourceToken{Class: CommentToken, Start: offset + idx,
+			End: offset + idx + len(text)})
+	}
+
+	var res []SourceToken
+	for start := 0; ; {
+		end := statementEnd(code[start:])
+		if end < 0 {
+			res = append(res, classifyStatement(code[start:], offset+start)...)
+			break
+		}
+		res = append(res, classifyStatement(code[start:start+end], offset+start)...)
+		start += end + 1
+	}
+	return append(res, comment...)
+}
+
+// classifyStatement classifies the tokens of one statement, which has no comment.
+func classifyStatement(statement string, offset int) []SourceToken {
+	code := statement
+
+	// A string literal runs to the end of the statement, so it is one token even if it has
+	// spaces.
+	var str []SourceToken
+	if idx := strings.IndexByte(code, '"'); idx >= 0 {
+		end := len(strings.TrimRight(code, " \t\r\n"))
+		str = append(str, SourceToken{Class: StringToken, Start: offset + idx, End: offset + end})
+		code = code[:idx]
+	}
+
+	var words []SourceToken
+	start := -1
+	for i := 0; i <= len(code); i++ {
+		if i < len(code) && !isTokenSeparator(code[i]) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			words = append(words, SourceToken{Start: offset + start, End: offset + i})
+			start = -1
+		}
+	}
+	if len(words) == 0 {
+		return str
+	}
+
+	wordText := func(w SourceToken) string {
+		return statement[w.Start-offset : w.End-offset]
+	}
+	first := wordText(words[0])
+	if strings.HasPrefix(first, ".") {
+		words[0].Class = DirectiveToken
+		for i := 1; i < len(words); i++ {
+			if strings.EqualFold(first, ".section") {
+				words[i].Class = LabelToken
+			} else {
+				words[i].Class = classifyOperand(wordText(words[i]))
+			}
+		}
+	} else if len(words) == 1 && strings.HasSuffix(first, ":") {
+		words[0].Class = LabelToken
+	} else {
+		words[0].Class = MnemonicToken
+		for i := 1; i < len(words); i++ {
+			words[i].Class = classifyOperand(wordText(words[i]))
+		}
+	}
+
+	return append(words, str...)
+}
+
+// commentIndex returns the index of the start of a line's comment, or -1.
+// Comment markers inside string literals are ignored.
+func commentIndex(line string) int {
+	var inString bool
+	for i := 0; i < len(line); i++ {
+		if inString {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				inString = false
+			}
+		} else if line[i] == '"' {
+			inString = true
+		} else if line[i] == '#' || strings.HasPrefix(line[i:], "//") {
+			return i
+		}
+	}
+	return -1
+}
+
+func isTokenSeparator(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == ',' || b == '(' || b == ')'
+}
+
+func classifyOperand(operand string) TokenClass {
+	if strings.HasPrefix(operand, "$") {
+		return RegisterToken
+	} else if isConstantText(operand) {
+		return ConstantToken
+	}
+	return LabelToken
+}
@@ -0,0 +1,81 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyTokens(t *testing.T) {
+	source := "LOOP: # start\n  lw $t0, -4($sp)\nJ LOOP\n.section data\n.word 0x10 ; NOP // word\n"
+	expected := []struct {
+		Class TokenClass
+		Text  string
+	}{
+		{LabelToken, "LOOP:"},
+		{CommentToken, "# start"},
+		{MnemonicToken, "lw"},
+		{RegisterToken, "$t0"},
+		{ConstantToken, "-4"},
+		{RegisterToken, "$sp"},
+		{MnemonicToken, "J"},
+		{LabelToken, "LOOP"},
+		{DirectiveToken, ".section"},
+		{LabelToken, "data"},
+		{DirectiveToken, ".word"},
+		{ConstantToken, "0x10"},
+		{MnemonicToken, "NOP"},
+		{CommentToken, "// word"},
+	}
+	actual := ClassifyTokens(source)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d tokens but got %d", len(expected), len(actual))
+	}
+	for i, x := range expected {
+		a := actual[i]
+		if a.Class != x.Class || source[a.Start:a.End] != x.Text {
+			t.Errorf("token %d: expected %s %q but got %s %q", i, x.Class, x.Text, a.Class,
+				source[a.Start:a.End])
+		}
+	}
+}
+
+func TestClassifyTokensString(t *testing.T) {
+	source := `.ascii "a, b # c" # comment`
+	actual := ClassifyTokens(source)
+	expected := []SourceToken{
+		{Class: DirectiveToken, Start: 0, End: 6},
+		{Class: StringToken, Start: 7, End: 17},
+		{Class: CommentToken, Start: 18, End: 27},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected tokens: %v", actual)
+	}
+}
+
+func TestClassifyTokensBlockComment(t *testing.T) {
+	source := "NOP /* a\nb */ J /* c */ L\n"
+	actual := ClassifyTokens(source)
+	expected := []SourceToken{
+		{Class: MnemonicToken, Start: 0, End: 3},
+		{Class: CommentToken, Start: 4, End: 8},
+		{Class: CommentToken, Start: 9, End: 13},
+		{Class: MnemonicToken, Start: 14, End: 15},
+		{Class: CommentToken, Start: 16, End: 23},
+		{Class: LabelToken, Start: 24, End: 25},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected tokens: %v", actual)
+	}
+}
+
+func TestClassifyTokensInvalid(t *testing.T) {
+	// Half-typed code should still be classified.
+	source := "ADDIU $t0,\n// done"
+	actual := ClassifyTokens(source)
+	if len(actual) != 3 || actual[1].Class != RegisterToken || actual[2].Class != CommentToken {
+		t.Fatalf("unexpected tokens: %v", actual)
+	}
+	if source[actual[2].Start:actual[2].End] != "// done" {
+		t.Error("bad comment span")
+	}
+}
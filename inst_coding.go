@@ -57,9 +57,21 @@ var threeRegOperandFuncs = map[uint32]string{
 	0x26: "XOR",
 }
 
+// These map instruction names back to their numbers, for encoding.
+var (
+	twoOperandImmediateNumbers = instructionNumbers(twoOperandImmediateOpcodes)
+	branchNumbers              = instructionNumbers(branchOpcodes)
+	jTypeNumbers               = instructionNumbers(jTypeOpcodes)
+	memoryNumbers              = instructionNumbers(memoryOpcodes)
+	constantShiftNumbers       = instructionNumbers(constantShiftFuncs)
+	variableShiftNumbers       = instructionNumbers(variableShiftFuncs)
+	threeRegOperandNumbers     = instructionNumbers(threeRegOperandFuncs)
+)
+
 const luiOpcode = 0x0f
 const jrFunc = 0x08
 const jalrFunc = 0x09
+const syscallFunc = 0x0c
 
 // DecodeInstruction returns an Instruction for a 32-bit word.
 // This can never fail, since invalid instructions can be treated as ".word" directives.
@@ -77,40 +89,32 @@ func DecodeInstruction(word uint32) *Instruction {
 	immediate := word & 0xffff
 
 	if instName, ok := twoOperandImmediateOpcodes[opcode]; ok {
-		return &Instruction{
-			Name:               instName,
-			Registers:          []int{registerT, registerS},
-			UnsignedConstant16: uint16(immediate),
-			SignedConstant16:   int16(immediate),
-		}
+		res := newInstruction(instName, registerT, registerS)
+		res.UnsignedConstant16 = uint16(immediate)
+		res.SignedConstant16 = int16(immediate)
+		return res
 	}
 
 	if opcode == luiOpcode && registerS == 0 {
-		return &Instruction{
-			Name:               "LUI",
-			Registers:          []int{registerT},
-			UnsignedConstant16: uint16(immediate),
-		}
+		res := newInstruction("LUI", registerT)
+		res.UnsignedConstant16 = uint16(immediate)
+		return res
 	}
 
 	if instName, ok := branchOpcodes[opcode]; ok {
 		if instName == "BEQ" || instName == "BNE" {
-			return &Instruction{
-				Name:        instName,
-				Registers:   []int{registerS, registerT},
-				CodePointer: CodePointer{Constant: uint32(int16(immediate)) << 2},
-			}
+			res := newInstruction(instName, registerS, registerT)
+			res.CodePointer = CodePointer{Constant: uint32(int16(immediate)) << 2}
+			return res
 		}
 		if instName == "BLTZ" && registerT == 1 {
 			instName = "BGEZ"
 			registerT = 0
 		}
 		if registerT == 0 {
-			return &Instruction{
-				Name:        instName,
-				Registers:   []int{registerS},
-				CodePointer: CodePointer{Constant: uint32(int16(immediate)) << 2},
-			}
+			res := newInstruction(instName, registerS)
+			res.CodePointer = CodePointer{Constant: uint32(int16(immediate)) << 2}
+			return res
 		}
 	}
 
@@ -123,52 +127,44 @@ func DecodeInstruction(word uint32) *Instruction {
 	}
 
 	if instName, ok := memoryOpcodes[opcode]; ok {
-		return &Instruction{
-			Name:            instName,
-			Registers:       []int{registerT},
-			MemoryReference: MemoryReference{Register: registerS, Offset: int16(immediate)},
-		}
+		res := newInstruction(instName, registerT)
+		res.MemoryReference = MemoryReference{Register: registerS, Offset: int16(immediate)}
+		return res
 	}
 
 	if opcode == 0 {
 		if instName, ok := constantShiftFuncs[funcField]; ok && registerS == 0 {
-			return &Instruction{
-				Name:      instName,
-				Registers: []int{registerD, registerT},
-				Constant5: shiftAmount,
-			}
+			res := newInstruction(instName, registerD, registerT)
+			res.Constant5 = shiftAmount
+			return res
 		}
 
 		if instName, ok := variableShiftFuncs[funcField]; ok && shiftAmount == 0 {
-			return &Instruction{
-				Name:      instName,
-				Registers: []int{registerD, registerT, registerS},
-			}
+			return newInstruction(instName, registerD, registerT, registerS)
 		}
 
 		if instName, ok := threeRegOperandFuncs[funcField]; ok && shiftAmount == 0 {
-			return &Instruction{
-				Name:      instName,
-				Registers: []int{registerD, registerS, registerT},
-			}
+			return newInstruction(instName, registerD, registerS, registerT)
 		}
 
 		if opcode == 0 && registerT == 0 && registerD == 0 &&
 			shiftAmount == 0 && funcField == jrFunc {
-			return &Instruction{
-				Name:      "JR",
-				Registers: []int{registerS},
-			}
+			return newInstruction("JR", registerS)
+		}
+
+		if word == syscallFunc {
+			return &Instruction{Name: "SYSCALL"}
 		}
 
 		if opcode == 0 && registerT == 0 && shiftAmount == 0 && funcField == jalrFunc {
-			return &Instruction{
-				Name:      "JALR",
-				Registers: []int{registerD, registerS},
-			}
+			return newInstruction("JALR", registerD, registerS)
 		}
 	}
 
+	if inst, ok := decodeCustomInstruction(word); ok {
+		return inst
+	}
+
 	return &Instruction{
 		Name:    ".word",
 		RawWord: word,
@@ -188,9 +184,18 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 			return 0, registerCountError(inst.Name)
 		}
 		return 0, nil
+	} else if inst.Name == "SYSCALL" {
+		if len(inst.Registers) != 0 {
+			return 0, registerCountError(inst.Name)
+		}
+		return syscallFunc, nil
+	}
+
+	if c, ok := customInstructions[inst.Name]; ok {
+		return c.encode(inst)
 	}
 
-	if opcode, ok := numberForInstruction(twoOperandImmediateOpcodes, inst.Name); ok {
+	if opcode, ok := twoOperandImmediateNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 2 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -211,7 +216,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 	if branchName == "BGEZ" {
 		branchName = "BLTZ"
 	}
-	if opcode, ok := numberForInstruction(branchOpcodes, branchName); ok {
+	if opcode, ok := branchNumbers[branchName]; ok {
 		branchOffset, err := instructionBranchOffset(inst, instAddr, symbols)
 		if err != nil {
 			return 0, err
@@ -234,7 +239,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 			(regT << 16) | ((branchOffset >> 2) & 0xffff), nil
 	}
 
-	if opcode, ok := numberForInstruction(jTypeOpcodes, inst.Name); ok {
+	if opcode, ok := jTypeNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 0 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -245,7 +250,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 		return (opcode << 26) | (jumpAddr >> 2), nil
 	}
 
-	if opcode, ok := numberForInstruction(memoryOpcodes, inst.Name); ok {
+	if opcode, ok := memoryNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 1 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -253,7 +258,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 			(uint32(inst.Registers[0]) << 16) | uint32(uint16(inst.MemoryReference.Offset)), nil
 	}
 
-	if funcField, ok := numberForInstruction(constantShiftFuncs, inst.Name); ok {
+	if funcField, ok := constantShiftNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 2 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -261,7 +266,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 			(uint32(inst.Constant5) << 6) | funcField, nil
 	}
 
-	if funcField, ok := numberForInstruction(variableShiftFuncs, inst.Name); ok {
+	if funcField, ok := variableShiftNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 3 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -269,7 +274,7 @@ func (inst *Instruction) Encode(instAddr uint32, symbols map[string]uint32) (uin
 			(uint32(inst.Registers[0]) << 11) | funcField, nil
 	}
 
-	if funcField, ok := numberForInstruction(threeRegOperandFuncs, inst.Name); ok {
+	if funcField, ok := threeRegOperandNumbers[inst.Name]; ok {
 		if len(inst.Registers) != 3 {
 			return 0, registerCountError(inst.Name)
 		}
@@ -317,6 +322,10 @@ func instructionBranchOffset(inst *Instruction, instAddr uint32,
 		if inst.CodePointer.Constant&3 != 0 {
 			return 0, errors.New("misaligned address")
 		}
+		offset := int32(inst.CodePointer.Constant)
+		if offset < minBranchOffset || offset > maxBranchOffset {
+			return 0, errors.New("branch offset out of bounds")
+		}
 		return inst.CodePointer.Constant, nil
 	}
 }
@@ -345,13 +354,12 @@ func instructionJumpBase(inst *Instruction, instAddr uint32,
 	}
 }
 
-func numberForInstruction(m map[uint32]string, inst string) (uint32, bool) {
+func instructionNumbers(m map[uint32]string) map[string]uint32 {
+	res := make(map[string]uint32, len(m))
 	for number, name := range m {
-		if name == inst {
-			return number, true
-		}
+		res[name] = number
 	}
-	return 0, false
+	return res
 }
 
 func registerCountError(instName string) error {
@@ -51,6 +51,7 @@ func TestInstCodingProgram(t *testing.T) {
         LW $r1, ($r2)
         SB $r5, -0x8000($r31)
         SW $r31, 0x7fff($r5)
+        SYSCALL
 	`
 	words := []uint32{
 		0x00000000, 0x2485ECC9, 0x03ef3021, 0x00a1f824, 0x3051f0f0,
@@ -61,6 +62,7 @@ func TestInstCodingProgram(t *testing.T) {
 		0x06218000, 0x1cc07fff, 0x1a4000c8, 0x07e00000, 0x141f0001,
 		0x08014000, 0x0c014000, 0x00402809, 0x01e0f809, 0x03e00008,
 		0x80afffe2, 0x93d1001e, 0x8c410000, 0xa3e58000, 0xacbf7fff,
+		0x0000000c,
 	}
 	tokenizedLines, err := TokenizeSource(code)
 	if err != nil {
@@ -155,6 +157,14 @@ func TestInstCodingRawWords(t *testing.T) {
 	}
 }
 
+func BenchmarkDecodeInstruction(b *testing.B) {
+	words := []uint32{0x25080001, 0xad080100, 0x01095021, 0x00085880, 0x1500fffb, 0x0000000c}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		DecodeInstruction(words[i%len(words)])
+	}
+}
+
 func instructionsEquivalent(i1 *Instruction, i2 *Instruction) bool {
 	if i1.Name == "JALR" && i2.Name == "JALR" && len(i1.Registers) != len(i2.Registers) {
 		if len(i1.Registers) > len(i2.Registers) {
@@ -26,22 +26,47 @@ type Instruction struct {
 	RawWord uint32
 }
 
+// maxInstructionRegisters is the most registers any instruction takes.
+const maxInstructionRegisters = 3
+
+// An instructionAllocation stores an Instruction along with its registers, so that both can be
+// allocated at once.
+type instructionAllocation struct {
+	inst      Instruction
+	registers [maxInstructionRegisters]int
+}
+
+// newInstruction creates an Instruction with a list of registers using a single allocation.
+// If there are no registers, the Registers field is nil.
+func newInstruction(name string, registers ...int) *Instruction {
+	a := &instructionAllocation{inst: Instruction{Name: name}}
+	if len(registers) > maxInstructionRegisters {
+		a.inst.Registers = append([]int{}, registers...)
+	} else if len(registers) > 0 {
+		n := copy(a.registers[:], registers)
+		a.inst.Registers = a.registers[:n:n]
+	}
+	return &a.inst
+}
+
 // ParseTokenizedInstruction generates an Instruction which represents a TokenizedInstruction.
 // This may fail if the instruction is invalid, in which case an error is returned.
 func ParseTokenizedInstruction(t *TokenizedInstruction) (*Instruction, error) {
-	validName := false
-	for _, template := range Templates {
-		if template.Name == t.Name {
-			validName = true
-		}
+	templates := templatesNamed(t.Name)
+	for _, template := range templates {
 		if template.Match(t) {
-			res := &Instruction{Name: t.Name}
+			var regArray [maxInstructionRegisters]int
+			registers := regArray[:0]
+			for i, arg := range template.Arguments {
+				if arg == Register {
+					reg, _ := t.Arguments[i].Register()
+					registers = append(registers, reg)
+				}
+			}
+			res := newInstruction(t.Name, registers...)
 			for i, arg := range template.Arguments {
 				tokArg := t.Arguments[i]
 				switch arg {
-				case Register:
-					reg, _ := tokArg.Register()
-					res.Registers = append(res.Registers, reg)
 				case SignedConstant16:
 					res.SignedConstant16, _ = tokArg.SignedConstant16()
 				case UnsignedConstant16:
@@ -59,13 +84,74 @@ func ParseTokenizedInstruction(t *TokenizedInstruction) (*Instruction, error) {
 			return res, nil
 		}
 	}
-	if validName {
+	if len(templates) > 0 {
 		return nil, errors.New("bad instruction usage for " + t.Name)
 	} else {
 		return nil, errors.New("unknown instruction: " + t.Name)
 	}
 }
 
+// parseInstructionSymbols is like ParseTokenizedInstruction, but a symbol or an expression may
+// also be used as an operand which needs a constant, in which case its value is used.
+// An expression may also be used as an absolute jump target or as the offset of a memory
+// reference.
+// Symbols which symbolValue cannot find are left alone.
+func parseInstructionSymbols(t *TokenizedInstruction,
+	symbolValue func(name string) (uint32, bool)) (*Instruction, error) {
+	inst, err := ParseTokenizedInstruction(t)
+	if err == nil {
+		return inst, nil
+	}
+	for _, template := range templatesNamed(t.Name) {
+		if len(template.Arguments) != len(t.Arguments) {
+			continue
+		}
+		resolved := &TokenizedInstruction{
+			Name:      t.Name,
+			Arguments: append([]*ArgToken{}, t.Arguments...),
+		}
+		var changed bool
+		for i, arg := range template.Arguments {
+			token := t.Arguments[i]
+			if arg == RelativeCodePointer || (token.isSymbol && arg == AbsoluteCodePointer) {
+				continue
+			}
+			if token.isSymbol {
+				if value, ok := symbolValue(token.symbol); ok {
+					resolved.Arguments[i] = &ArgToken{isConstant: true, constant: value}
+					changed = true
+				}
+			} else if token.expression != "" {
+				value, exprErr := evaluateOperand(token.expression, symbolValue)
+				if exprErr != nil {
+					return nil, exprErr
+				}
+				if token.isMemory {
+					resolved.Arguments[i] = &ArgToken{}
+					exprErr = memoryArgToken(value, token.expression, token.memRegister,
+						resolved.Arguments[i])
+					if exprErr != nil {
+						return nil, exprErr
+					}
+				} else {
+					resolved.Arguments[i] = &ArgToken{isConstant: true, constant: value}
+				}
+				changed = true
+			}
+		}
+		if changed && template.Match(resolved) {
+			return ParseTokenizedInstruction(resolved)
+		}
+	}
+	return nil, err
+}
+
+// anySymbolValue is a symbol lookup for parseInstructionSymbols which pretends that every symbol
+// is defined, for checking instructions before the symbols' values are known.
+func anySymbolValue(name string) (uint32, bool) {
+	return 0, true
+}
+
 // Render generates a *TokenizedLine that represents this instruction.
 //
 // If this succeeds, the result will normally contain a TokenizedInstruction.
@@ -76,19 +162,15 @@ func (i *Instruction) Render() (*TokenizedLine, error) {
 	if i.Name == ".word" {
 		return &TokenizedLine{
 			Directive: &TokenizedDirective{
-				Name:     "word",
-				Constant: i.RawWord,
+				Name:   "word",
+				Values: []DirectiveValue{{Constant: i.RawWord}},
 			},
 		}, nil
 	}
-	found := false
+	templates := templatesNamed(i.Name)
 
 TemplateLoop:
-	for _, template := range Templates {
-		if template.Name != i.Name {
-			continue
-		}
-		found = true
+	for _, template := range templates {
 		if template.RegisterCount() != len(i.Registers) {
 			continue
 		}
@@ -145,7 +227,7 @@ TemplateLoop:
 		}
 		return &TokenizedLine{Instruction: res}, nil
 	}
-	if found {
+	if len(templates) > 0 {
 		return nil, errors.New("invalid arguments for " + i.Name)
 	} else {
 		return nil, errors.New("no such instruction: " + i.Name)
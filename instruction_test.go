@@ -97,7 +97,40 @@ func TestInstructionRender(t *testing.T) {
 	if rendered, err := inst.Render(); err != nil {
 		t.Error(err)
 	} else if rendered.Directive == nil || rendered.Directive.Name != "word" ||
-		rendered.Directive.Constant != 0xf2345678 {
+		len(rendered.Directive.Values) != 1 || rendered.Directive.Values[0].Constant != 0xf2345678 {
 		t.Error("bad result:", rendered.Directive)
 	}
 }
+
+func TestParseTokenizedInstructionNewTemplate(t *testing.T) {
+	inst := &TokenizedInstruction{
+		Name:      "TESTTEMPLATE",
+		Arguments: []*ArgToken{{isRegister: true, register: 3}},
+	}
+	if _, err := ParseTokenizedInstruction(inst); err == nil {
+		t.Fatal("expected error for unknown instruction")
+	}
+
+	oldTemplates := Templates
+	defer func() {
+		Templates = oldTemplates
+	}()
+	Templates = append(Templates[:len(Templates):len(Templates)],
+		Template{Name: "TESTTEMPLATE", Arguments: []ArgumentType{Register}})
+	if parsed, err := ParseTokenizedInstruction(inst); err != nil {
+		t.Fatal(err)
+	} else if parsed.Name != "TESTTEMPLATE" || parsed.Registers[0] != 3 {
+		t.Error("unexpected result", parsed)
+	}
+}
+
+func BenchmarkParseTokenizedInstruction(b *testing.B) {
+	lines, err := TokenizeSource("XORI $t0, $t1, 5")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseTokenizedInstruction(lines[0].Instruction)
+	}
+}
@@ -0,0 +1,125 @@
+package mips32
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// A Region describes a named part of the address space which code can be placed in.
+type Region struct {
+	Name string
+	Base uint32
+
+	// Size is the maximum number of bytes the region may hold.
+	// If it is 0, the region extends to the end of the address space.
+	Size uint32
+
+	// Alignment is the alignment for the start of each ".section" in the region.
+	// If it is 0, 4-byte alignment is used.
+	Alignment uint32
+}
+
+// A Layout describes where named sections land in the address space.
+//
+// When an executable is parsed with a Layout, the ".section NAME" directive moves the assembler
+// to the next free address in the region called NAME.
+// Code before the first directive is placed in the first region.
+type Layout struct {
+	Regions []Region
+}
+
+// Region returns the region with the given name, or nil if no such region exists.
+func (l *Layout) Region(name string) *Region {
+	for i := range l.Regions {
+		if l.Regions[i].Name == name {
+			return &l.Regions[i]
+		}
+	}
+	return nil
+}
+
+// validate makes sure that every region has a unique name, is properly aligned, fits in the
+// address space, and does not overlap any other region.
+func (l *Layout) validate() error {
+	names := map[string]bool{}
+	for _, region := range l.Regions {
+		if names[region.Name] {
+			return errors.New("duplicate region: " + region.Name)
+		}
+		names[region.Name] = true
+		if region.Base&3 != 0 {
+			return errors.New("misaligned region: " + region.Name)
+		}
+		if region.Alignment&3 != 0 {
+			return errors.New("invalid alignment for region: " + region.Name)
+		}
+		if region.end() > 1<<32 {
+			return errors.New("region extends past the end of memory: " + region.Name)
+		}
+	}
+	sorted := append([]Region{}, l.Regions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Base < sorted[j].Base
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].end() > uint64(sorted[i].Base) {
+			return errors.New("region " + sorted[i-1].Name + " overlaps region " +
+				sorted[i].Name)
+		}
+	}
+	return nil
+}
+
+// end returns the address just past the end of the region.
+func (r *Region) end() uint64 {
+	if r.Size == 0 {
+		return 1 << 32
+	}
+	return uint64(r.Base) + uint64(r.Size)
+}
+
+// layoutState tracks the next free address in each region while an executable is parsed.
+type layoutState struct {
+	layout *Layout
+	next   map[string]uint64
+}
+
+func newLayoutState(l *Layout) *layoutState {
+	res := &layoutState{layout: l, next: map[string]uint64{}}
+	for _, region := range l.Regions {
+		res.next[region.Name] = uint64(region.Base)
+	}
+	return res
+}
+
+// enter returns the address at which a new ".section" should start.
+func (l *layoutState) enter(name string) (uint32, error) {
+	region := l.layout.Region(name)
+	if region == nil {
+		return 0, errors.New("unknown section: " + name)
+	}
+	alignment := uint64(region.Alignment)
+	if alignment == 0 {
+		alignment = 4
+	}
+	addr := l.next[name]
+	if addr%alignment != 0 {
+		addr += alignment - addr%alignment
+	}
+	l.next[name] = addr
+	return uint32(addr), nil
+}
+
+// advance records that a section has grown to the given end address.
+// It fails if the section no longer fits in its region.
+func (l *layoutState) advance(name string, end uint64) error {
+	region := l.layout.Region(name)
+	l.next[name] = end
+	limit := region.end()
+	if end > limit {
+		return errors.New("section " + name + " overflows by " +
+			strconv.FormatUint(end-limit, 10) + " bytes")
+	}
+	return nil
+}
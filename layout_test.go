@@ -0,0 +1,130 @@
+package mips32
+
+import "testing"
+
+func TestParseExecutableLayout(t *testing.T) {
+	code := `
+		NOP
+		.section data
+		.word 0x1337
+		.section text
+		START:
+		LUI $r1, 5
+		.section data
+		TABLE:
+		.word 0x5
+	`
+	layout := &Layout{
+		Regions: []Region{
+			{Name: "text", Base: 0x1000, Size: 0x100},
+			{Name: "data", Base: 0x2000, Alignment: 0x10},
+		},
+	}
+	lines, err := TokenizeSource(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executable, err := ParseExecutableLayout(lines, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executable.Symbols["START"] != 0x1004 {
+		t.Error("bad START symbol:", executable.Symbols["START"])
+	}
+	if executable.Symbols["TABLE"] != 0x2010 {
+		t.Error("bad TABLE symbol:", executable.Symbols["TABLE"])
+	}
+	if inst := executable.Get(0x1000); inst == nil || inst.Name != "NOP" {
+		t.Error("bad instruction at 0x1000:", inst)
+	}
+	if inst := executable.Get(0x2000); inst == nil || inst.Name != ".word" {
+		t.Error("bad instruction at 0x2000:", inst)
+	}
+}
+
+func TestParseExecutableLayoutFailure(t *testing.T) {
+	layout := &Layout{
+		Regions: []Region{
+			{Name: "text", Base: 0x1000, Size: 8},
+			{Name: "data", Base: 0x1008},
+		},
+	}
+	failures := []string{
+		"NOP\nNOP\nNOP",
+		".section bss\nNOP",
+		".section data\nNOP\n.text 0x1008\nNOP",
+	}
+	for _, failure := range failures {
+		lines, err := TokenizeSource(failure)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if _, err := ParseExecutableLayout(lines, layout); err == nil {
+			t.Error("expected error for:", failure)
+		}
+	}
+
+	lines, err := TokenizeSource(".section text\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseExecutable(lines); err == nil {
+		t.Error("expected error for section without layout")
+	}
+}
+
+func TestParseExecutableLayoutInvalid(t *testing.T) {
+	lines, err := TokenizeSource(".section text\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layouts := [][]Region{
+		{
+			{Name: "text", Base: 0x1000, Size: 0x100},
+			{Name: "data", Base: 0x1080, Size: 0x100},
+		},
+		{
+			{Name: "text", Base: 0x2000, Size: 0x100},
+			{Name: "data", Base: 0x1000, Size: 0x1004},
+		},
+		{
+			{Name: "data", Base: 0x1000},
+			{Name: "text", Base: 0x2000, Size: 0x100},
+		},
+		{
+			{Name: "text", Base: 0xfffff000, Size: 0x1004},
+		},
+	}
+	for i, regions := range layouts {
+		if _, err := ParseExecutableLayout(lines, &Layout{Regions: regions}); err == nil {
+			t.Error("expected error for layout", i)
+		}
+	}
+
+	duplicate := &Layout{
+		Regions: []Region{
+			{Name: "text", Base: 0x2000, Size: 0x100},
+			{Name: "text", Base: 0x1000, Size: 0x100},
+		},
+	}
+	if _, err := ParseExecutableLayout(lines, duplicate); err == nil ||
+		err.Error() != "duplicate region: text" {
+		t.Error("unexpected error for duplicate regions:", err)
+	}
+
+	valid := &Layout{
+		Regions: []Region{
+			{Name: "data", Base: 0x2000, Size: 0x100},
+			{Name: "text", Base: 0x1000, Size: 0x1000},
+			{Name: "top", Base: 0xfffff000, Size: 0x1000},
+		},
+	}
+	exc, err := ParseExecutableLayout(lines, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := exc.Segments[0x1000]; !ok {
+		t.Error("unexpected segments:", exc.Segments)
+	}
+}
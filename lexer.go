@@ -2,17 +2,21 @@ package mips32
 
 import (
 	"errors"
-	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-var (
-	commentRegexp      = regexp.MustCompile("^(.*?)(#|//|;)(.*)$")
-	directiveRegexp    = regexp.MustCompile("^\\.(text|word)\\s+" + constantNumberPattern + "$")
-	symbolMarkerRegexp = regexp.MustCompile("^" + symbolNamePattern + ":$")
-	instNameRegexp     = regexp.MustCompile("^[A-Za-z]*$")
-)
+// tokenizeBatchSize is the number of values which TokenizeSource allocates at once for the
+// tokens, comments, and symbols it produces.
+const tokenizeBatchSize = 64
+
+// A Span is a range of bytes in a source file, from Start up to (but not including) End.
+type Span struct {
+	Start int
+	End   int
+}
 
 // A TokenizedLine represents one line of an assembly program, translated into syntactic tokens.
 // No more than one of Directive, SymbolDecl, and Instruction will be non-nil.
@@ -24,10 +28,18 @@ type TokenizedLine struct {
 	Directive    *TokenizedDirective
 	Instruction  *TokenizedInstruction
 	SymbolMarker *string
+
+	// Span covers the code of the line (for a symbol marker, the name and its colon), and
+	// CommentSpan covers the comment, including the "#", "//", or "/*" which starts it.
+	// Spans are byte offsets into the source which was tokenized, and are zero for lines which
+	// were not produced by the tokenizer or which have no code or no comment.
+	Span        Span
+	CommentSpan Span
 }
 
 // Equal returns true if this tokenized line is equivalent to another one.
-// This is a deep comparison, and all fields (including the comment and line number) are compared.
+// This is a deep comparison, and all fields (including the comment and line number) except for
+// the spans are compared.
 func (t *TokenizedLine) Equal(t1 *TokenizedLine) bool {
 	if t.LineNumber != t1.LineNumber {
 		return false
@@ -39,7 +51,7 @@ func (t *TokenizedLine) Equal(t1 *TokenizedLine) bool {
 	}
 	if (t.Directive == nil) != (t1.Directive == nil) {
 		return false
-	} else if t.Directive != nil && *t.Directive != *t1.Directive {
+	} else if t.Directive != nil && !t.Directive.Equal(t1.Directive) {
 		return false
 	}
 	if (t.Instruction == nil) != (t1.Instruction == nil) {
@@ -57,14 +69,19 @@ func (t *TokenizedLine) Equal(t1 *TokenizedLine) bool {
 
 // String returns a human-readable version of this line.
 func (l *TokenizedLine) String() string {
+	return l.Format(nil)
+}
+
+// Format is like String, but it renders operands according to some options.
+func (l *TokenizedLine) Format(opts *RenderOptions) string {
 	commentStr := ""
 	if l.Comment != nil {
 		commentStr = " #" + *l.Comment
 	}
 	if l.Directive != nil {
-		return l.Directive.String() + commentStr
+		return l.Directive.Format(opts) + commentStr
 	} else if l.Instruction != nil {
-		return l.Instruction.String() + commentStr
+		return l.Instruction.Format(opts) + commentStr
 	} else if l.SymbolMarker != nil {
 		return *l.SymbolMarker + ":" + commentStr
 	}
@@ -75,70 +92,187 @@ func (l *TokenizedLine) String() string {
 	}
 }
 
-// A TokenizedDirective represents a directive like ".text 0x5000" or ".data 0x0".
+// A TokenizedDirective represents a directive like ".text 0x5000" or ".section data".
+// Directives which take a name (such as ".section" and ".globl") store it in Symbol rather than
+// Constant, and ".size" stores its symbol in Symbol and its size in Constant.
+//
+// Data directives (".word", ".half", and ".byte") take a comma-separated list of values, which
+// are stored in Values instead.
+// String directives (".ascii" and ".asciiz") store their decoded string in Text.
+//
+// NameSpan covers the name (including the "."), and ArgumentSpans has one span for each value of
+// a data directive, or a single span for the argument of any other directive.
+// Like TokenizedLine's spans, they are only set by the tokenizer.
 type TokenizedDirective struct {
 	Name     string
 	Constant uint32
+	Symbol   string
+	Values   []DirectiveValue
+	Text     string
+
+	NameSpan      Span
+	ArgumentSpans []Span
+}
+
+// Equal returns true if this directive is equivalent to another one.
+func (t *TokenizedDirective) Equal(t1 *TokenizedDirective) bool {
+	if t.Name != t1.Name || t.Constant != t1.Constant || t.Symbol != t1.Symbol ||
+		t.Text != t1.Text || len(t.Values) != len(t1.Values) {
+		return false
+	}
+	for i, value := range t.Values {
+		if value != t1.Values[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func (t *TokenizedDirective) String() string {
-	return "." + t.Name + " " + unsignedConst32ToString(t.Constant)
+	return t.Format(nil)
+}
+
+// Format is like String, but it renders the constant according to some options.
+func (t *TokenizedDirective) Format(opts *RenderOptions) string {
+	if t.Name == "section" || t.Name == "globl" {
+		return "." + t.Name + " " + t.Symbol
+	} else if t.Name == "size" {
+		return ".size " + t.Symbol + ", " + opts.unsigned(t.Constant)
+	} else if isDataDirective(t.Name) {
+		valueStrings := make([]string, len(t.Values))
+		for i, value := range t.Values {
+			valueStrings[i] = value.Format(opts)
+		}
+		return "." + t.Name + " " + strings.Join(valueStrings, ", ")
+	} else if isStringDirective(t.Name) {
+		return "." + t.Name + " " + quoteStringLiteral(t.Text)
+	}
+	return "." + t.Name + " " + opts.unsigned(t.Constant)
+}
+
+// A DirectiveValue is one of the values of a data directive.
+//
+// A value may refer to a symbol, as in ".word table+8".
+// In this case, Symbol is the symbol and Constant is the offset from it (which may be negative,
+// in two's complement).
+type DirectiveValue struct {
+	Constant uint32
+	Symbol   string
+}
+
+func (d DirectiveValue) String() string {
+	return d.Format(nil)
+}
+
+// Format is like String, but it renders the constant according to some options.
+func (d DirectiveValue) Format(opts *RenderOptions) string {
+	if d.Symbol == "" {
+		return opts.unsigned(d.Constant)
+	} else if d.Constant == 0 {
+		return d.Symbol
+	} else if int32(d.Constant) < 0 {
+		return d.Symbol + "-" + opts.unsigned(-d.Constant)
+	}
+	return d.Symbol + "+" + opts.unsigned(d.Constant)
 }
 
 // A TokenizedInstruction represents an instruction call.
+//
+// NameSpan covers the instruction name, and ArgumentSpans has a span for each argument (without
+// its trailing comma).
+// Like TokenizedLine's spans, they are only set by the tokenizer.
 type TokenizedInstruction struct {
 	Name      string
 	Arguments []*ArgToken
+
+	NameSpan      Span
+	ArgumentSpans []Span
 }
 
 func (t *TokenizedInstruction) String() string {
-	for _, template := range Templates {
-		if !template.Match(t) {
+	return t.Format(nil)
+}
+
+// Format is like String, but it renders operands according to some options.
+func (t *TokenizedInstruction) Format(opts *RenderOptions) string {
+	argStrings, ok := t.argumentStrings(opts)
+	if !ok {
+		return opts.mnemonic(t.Name) + " # UNRECOGNIZED INSTRUCTION."
+	}
+	if len(argStrings) > 0 {
+		return opts.mnemonic(t.Name) + " " + strings.Join(argStrings, ", ")
+	} else {
+		return opts.mnemonic(t.Name)
+	}
+}
+
+// argumentStrings returns the representation of each argument according to some options, which
+// may be nil to get the canonical representation.
+// Symbols and expressions which stand for constants are written as they are.
+// If the instruction does not match any template, ok is false.
+func (t *TokenizedInstruction) argumentStrings(opts *RenderOptions) (argStrings []string,
+	ok bool) {
+	template := t.template()
+	if template == nil {
+		return nil, false
+	}
+	argStrings = make([]string, len(template.Arguments))
+	for i, arg := range template.Arguments {
+		tokArg := t.Arguments[i]
+		if tokArg.isSymbol {
+			argStrings[i] = tokArg.symbol
 			continue
-		}
-		argStrings := make([]string, len(template.Arguments))
-		for i, arg := range template.Arguments {
-			tokArg := t.Arguments[i]
-			switch arg {
-			case Register:
-				reg, _ := tokArg.Register()
-				argStrings[i] = registerToString(reg)
-			case SignedConstant16:
-				c, _ := tokArg.SignedConstant16()
-				argStrings[i] = signedConst16ToString(c)
-			case UnsignedConstant16:
-				c, _ := tokArg.UnsignedConstant16()
-				argStrings[i] = unsignedConst16ToString(c)
-			case Constant5:
-				c, _ := tokArg.Constant5()
-				argStrings[i] = strconv.Itoa(int(c))
-			case AbsoluteCodePointer:
-				ptr, _ := tokArg.AbsoluteCodePointer()
-				if ptr.IsSymbol {
-					argStrings[i] = ptr.Symbol
-				} else {
-					argStrings[i] = unsignedConst32ToString(ptr.Constant)
-				}
-			case RelativeCodePointer:
-				ptr, _ := tokArg.RelativeCodePointer()
-				if ptr.IsSymbol {
-					argStrings[i] = ptr.Symbol
-				} else {
-					argStrings[i] = signedConst32ToString(int32(ptr.Constant))
-				}
-			case MemoryAddress:
-				ref, _ := tokArg.MemoryReference()
-				argStrings[i] = signedConst16ToString(ref.Offset) + "(" +
-					registerToString(ref.Register) + ")"
+		} else if tokArg.expression != "" {
+			argStrings[i] = tokArg.expression
+			if tokArg.isMemory {
+				argStrings[i] += "(" + opts.register(tokArg.memRegister) + ")"
 			}
+			continue
 		}
-		if len(argStrings) > 0 {
-			return t.Name + " " + strings.Join(argStrings, ", ")
-		} else {
-			return t.Name
+		switch arg {
+		case Register:
+			reg, _ := tokArg.Register()
+			argStrings[i] = opts.register(reg)
+		case SignedConstant16:
+			c, _ := tokArg.SignedConstant16()
+			argStrings[i] = opts.signed(int32(c))
+		case UnsignedConstant16:
+			c, _ := tokArg.UnsignedConstant16()
+			argStrings[i] = opts.unsigned(uint32(c))
+		case Constant5:
+			c, _ := tokArg.Constant5()
+			argStrings[i] = strconv.Itoa(int(c))
+		case AbsoluteCodePointer:
+			ptr, _ := tokArg.AbsoluteCodePointer()
+			argStrings[i] = opts.unsigned(ptr.Constant)
+		case RelativeCodePointer:
+			ptr, _ := tokArg.RelativeCodePointer()
+			argStrings[i] = opts.signed(int32(ptr.Constant))
+		case MemoryAddress:
+			ref, _ := tokArg.MemoryReference()
+			argStrings[i] = opts.signed(int32(ref.Offset)) + "(" +
+				opts.register(ref.Register) + ")"
 		}
 	}
-	return t.Name + " # UNRECOGNIZED INSTRUCTION."
+	return argStrings, true
+}
+
+// template finds the template which an instruction matches, or which it will match once its
+// symbols and expressions are replaced with their values.
+// It returns nil if there is no such template.
+func (t *TokenizedInstruction) template() *Template {
+	templates := templatesNamed(t.Name)
+	for i := range templates {
+		if templates[i].Match(t) {
+			return &templates[i]
+		}
+	}
+	for i := range templates {
+		if templates[i].matchSymbolic(t) {
+			return &templates[i]
+		}
+	}
+	return nil
 }
 
 // Equal returns whether or not two TokenizedInstructions are syntactically equivalent.
@@ -163,82 +297,353 @@ func (t *TokenizedInstruction) Equal(t1 *TokenizedInstruction) bool {
 
 // TokenizeSource takes a source file and tokenizes each line.
 // It returns an array of tokenized lines, on an error if one occurred.
+//
+// A line may hold several statements separated by semicolons, as in "ADDIU $t0, $t0, 1; NOP".
+// Each statement becomes its own TokenizedLine, with the line number of the source line.
+// A comment at the end of the line goes with the last statement.
 func TokenizeSource(source string) ([]TokenizedLine, error) {
-	splitLines := strings.Split(source, "\n")
-	res := make([]TokenizedLine, 0, len(splitLines))
-	for lineNum, lineText := range splitLines {
-		line, err := tokenizeLine(lineText)
-		if err != nil {
-			linePreamble := "error on line " + strconv.Itoa(lineNum+1) + ": "
-			return nil, errors.New(linePreamble + err.Error())
-		} else if (line == TokenizedLine{}) {
-			continue
+	return tokenizeSource(source, nil)
+}
+
+// tokenizeSource implements TokenizeSource.
+//
+// If report is non-nil, each statement which cannot be tokenized is passed to it (along with
+// its line number) and left out of the result, and tokenizing continues.
+// In this case, the returned error is always nil.
+func tokenizeSource(source string, report func(lineNum int, err error)) ([]TokenizedLine,
+	error) {
+	numLines := strings.Count(source, "\n") + 1
+	res := make([]TokenizedLine, 0, numLines)
+	alloc := &lineAllocator{batchSize: tokenizeBatchSize}
+	if numLines < alloc.batchSize {
+		alloc.batchSize = numLines
+	}
+	var inComment bool
+	var commentLine, lineOffset int
+	for lineNum := 1; ; lineNum++ {
+		lineText := source
+		newline := strings.IndexByte(source, '\n')
+		if newline >= 0 {
+			lineText = source[:newline]
+			source = source[newline+1:]
+		}
+		if !inComment {
+			commentLine = lineNum
+		}
+		var comments []blockComment
+		comments, inComment = findBlockComments(lineText, inComment)
+		code := removeBlockComments(lineText, comments)
+		lineStart := len(res)
+		for column := 0; ; {
+			end := statementEnd(code[column:])
+			statement := code[column:]
+			if end >= 0 {
+				statement = statement[:end]
+			}
+			line, err := alloc.tokenizeLine(statement, lineOffset+column)
+			if err != nil {
+				if report == nil {
+					return nil, lineError(lineNum, err.Error())
+				}
+				report(lineNum, err)
+			} else if (line != TokenizedLine{}) {
+				line.LineNumber = lineNum
+				res = append(res, line)
+			}
+			if end < 0 {
+				break
+			}
+			column += end + 1
+		}
+		if len(comments) > 0 {
+			comment := alloc.newString(blockCommentText(comments))
+			span := blockCommentSpan(lineText, comments)
+			span.Start += lineOffset
+			span.End += lineOffset
+			if len(res) == lineStart {
+				res = append(res, TokenizedLine{LineNumber: lineNum, Comment: comment,
+					CommentSpan: span})
+			} else if last := &res[len(res)-1]; last.Comment == nil {
+				last.Comment = comment
+				last.CommentSpan = span
+			}
+		}
+		if newline < 0 {
+			if inComment {
+				if report == nil {
+					return nil, lineError(commentLine, "unterminated block comment")
+				}
+				report(commentLine, errors.New("unterminated block comment"))
+			}
+			return res, nil
+		}
+		lineOffset += newline + 1
+	}
+}
+
+// statementEnd returns the index of the semicolon which ends the first statement in a line, or
+// -1 if the line has only one statement.
+// Semicolons in string literals and comments do not end statements.
+func statementEnd(line string) int {
+	if strings.IndexByte(line, ';') < 0 {
+		return -1
+	}
+	var inString bool
+	for i := 0; i < len(line); i++ {
+		if inString {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				inString = false
+			}
+		} else if line[i] == '"' {
+			inString = true
+		} else if line[i] == ';' {
+			return i
+		} else if line[i] == '#' || strings.HasPrefix(line[i:], "//") {
+			return -1
 		}
-		line.LineNumber = lineNum + 1
-		res = append(res, line)
 	}
-	return res, nil
+	return -1
+}
+
+// A blockComment is the part of a line which is covered by a "/* ... */" comment.
+type blockComment struct {
+	// start and end are byte offsets into the line, including the delimiters.
+	start int
+	end   int
+
+	// text is the comment without its delimiters.
+	text string
+}
+
+// findBlockComments finds the block comments in a line, given whether the line starts inside a
+// block comment, and reports whether the line ends inside one.
+// Block comments may not start inside string literals or line comments.
+func findBlockComments(line string, inComment bool) ([]blockComment, bool) {
+	var res []blockComment
+	var start, textStart int
+	var inString bool
+	for i := 0; i < len(line); i++ {
+		if inComment {
+			if strings.HasPrefix(line[i:], "*/") {
+				res = append(res, blockComment{start: start, end: i + 2, text: line[textStart:i]})
+				inComment = false
+				i++
+			}
+		} else if inString {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				inString = false
+			}
+		} else if line[i] == '"' {
+			inString = true
+		} else if strings.HasPrefix(line[i:], "/*") {
+			inComment = true
+			start, textStart = i, i+2
+			i++
+		} else if line[i] == '#' || strings.HasPrefix(line[i:], "//") {
+			break
+		}
+	}
+	if inComment {
+		res = append(res, blockComment{start: start, end: len(line), text: line[textStart:]})
+	}
+	return res, inComment
+}
+
+// removeBlockComments replaces the block comments in a line with spaces, so that the rest of
+// the line keeps its columns.
+func removeBlockComments(line string, comments []blockComment) string {
+	if len(comments) == 0 {
+		return line
+	}
+	res := []byte(line)
+	for _, c := range comments {
+		for i := c.start; i < c.end; i++ {
+			res[i] = ' '
+		}
+	}
+	return string(res)
+}
+
+// blockCommentText joins the text of the block comments on a line.
+func blockCommentText(comments []blockComment) string {
+	if len(comments) == 1 {
+		return comments[0].text
+	}
+	texts := make([]string, len(comments))
+	for i, c := range comments {
+		texts[i] = c.text
+	}
+	return strings.Join(texts, " ")
+}
+
+// blockCommentSpan returns the span of a line which its block comments cover, not counting a
+// carriage return at the end of the line.
+func blockCommentSpan(line string, comments []blockComment) Span {
+	start, end := comments[0].start, comments[len(comments)-1].end
+	if end == len(line) {
+		end = start + len(strings.TrimRight(line[start:], "\r"))
+	}
+	return Span{Start: start, End: end}
 }
 
 // tokenizeLine tokenizes a single line of assembly code.
-func tokenizeLine(lineText string) (line TokenizedLine, err error) {
+// The spans of the result are byte offsets into the line.
+func tokenizeLine(lineText string) (TokenizedLine, error) {
+	alloc := &lineAllocator{batchSize: 1}
+	return alloc.tokenizeLine(lineText, 0)
+}
+
+// tokenizeLine tokenizes a line (or a statement) which starts at an offset in the source, which
+// is added to the spans of the result.
+func (a *lineAllocator) tokenizeLine(lineText string, offset int) (line TokenizedLine, err error) {
 	trimmed := strings.TrimSpace(lineText)
 	if len(trimmed) == 0 {
 		return
 	}
+	offset += len(lineText) - len(strings.TrimLeftFunc(lineText, unicode.IsSpace))
 
-	commentMatch := commentRegexp.FindStringSubmatch(trimmed)
-	if commentMatch != nil {
-		commentStr := commentMatch[3]
-		beforeComment := commentMatch[1]
-		line, err = tokenizeLine(beforeComment)
-		line.Comment = &commentStr
+	if idx := commentIndex(trimmed); idx >= 0 {
+		comment := trimmed[idx+1:]
+		if trimmed[idx] == '/' {
+			comment = comment[1:]
+		}
+		line, err = a.tokenizeLine(trimmed[:idx], offset)
+		line.Comment = a.newString(comment)
+		line.CommentSpan = Span{Start: offset + idx, End: offset + len(trimmed)}
 		return
 	}
+	line.Span = Span{Start: offset, End: offset + len(trimmed)}
 
-	directiveMatch := directiveRegexp.FindStringSubmatch(trimmed)
-	if directiveMatch != nil {
-		directiveConstant, err := parseConstant(directiveMatch[2])
-		if err != nil {
-			return line, err
+	if trimmed[0] == '.' {
+		// The argument of a directive runs to the end of the line.
+		argSpan := func(arg string) Span {
+			return Span{Start: line.Span.End - len(arg), End: line.Span.End}
+		}
+		nameSpan := func(name string) Span {
+			return Span{Start: offset, End: offset + len(name) + 1}
+		}
+		if arg, ok := directiveArgument(trimmed, "text"); ok && isConstantText(arg) {
+			directiveConstant, err := parseConstant(arg)
+			if err != nil {
+				return line, err
+			}
+			line.Directive = a.newDirective()
+			*line.Directive = TokenizedDirective{Name: "text", Constant: directiveConstant,
+				NameSpan: nameSpan("text"), ArgumentSpans: a.newSpans(1)}
+			line.Directive.ArgumentSpans[0] = argSpan(arg)
+			return line, nil
+		}
+		for _, name := range dataDirectives {
+			if arg, ok := directiveArgument(trimmed, name); ok {
+				values, err := parseDirectiveValues(arg)
+				if err != nil {
+					return line, errors.New("." + name + ": " + err.Error())
+				}
+				line.Directive = a.newDirective()
+				*line.Directive = TokenizedDirective{Name: name, Values: values,
+					NameSpan: nameSpan(name), ArgumentSpans: a.newSpans(len(values))}
+				directiveValueSpans(arg, argSpan(arg).Start, line.Directive.ArgumentSpans)
+				return line, nil
+			}
+		}
+		for _, name := range stringDirectives {
+			if arg, ok := directiveArgument(trimmed, name); ok {
+				text, err := parseStringLiteral(arg)
+				if err != nil {
+					return line, errors.New("." + name + ": " + err.Error())
+				}
+				line.Directive = a.newDirective()
+				*line.Directive = TokenizedDirective{Name: name, Text: text,
+					NameSpan: nameSpan(name), ArgumentSpans: a.newSpans(1)}
+				line.Directive.ArgumentSpans[0] = argSpan(arg)
+				return line, nil
+			}
+		}
+		if arg, ok := directiveArgument(trimmed, "section"); ok && isSymbolText(arg) {
+			line.Directive = a.newDirective()
+			*line.Directive = TokenizedDirective{Name: "section", Symbol: arg,
+				NameSpan: nameSpan("section"), ArgumentSpans: a.newSpans(1)}
+			line.Directive.ArgumentSpans[0] = argSpan(arg)
+			return line, nil
+		}
+		for _, name := range []string{"globl", "global"} {
+			if arg, ok := directiveArgument(trimmed, name); ok && isSymbolText(arg) {
+				line.Directive = a.newDirective()
+				*line.Directive = TokenizedDirective{Name: "globl", Symbol: arg,
+					NameSpan: nameSpan(name), ArgumentSpans: a.newSpans(1)}
+				line.Directive.ArgumentSpans[0] = argSpan(arg)
+				return line, nil
+			}
+		}
+		if arg, ok := directiveArgument(trimmed, "size"); ok {
+			symbol, size, err := parseSizeArgument(arg)
+			if err != nil {
+				return line, errors.New(".size: " + err.Error())
+			}
+			line.Directive = a.newDirective()
+			*line.Directive = TokenizedDirective{Name: "size", Symbol: symbol, Constant: size,
+				NameSpan: nameSpan("size"), ArgumentSpans: a.newSpans(2)}
+			directiveValueSpans(arg, argSpan(arg).Start, line.Directive.ArgumentSpans)
+			return line, nil
 		}
-		return TokenizedLine{
-			Directive: &TokenizedDirective{
-				Name:     directiveMatch[1],
-				Constant: directiveConstant,
-			},
-		}, nil
 	}
 
-	symbolMatch := symbolMarkerRegexp.FindStringSubmatch(trimmed)
-	if symbolMatch != nil {
-		return TokenizedLine{
-			SymbolMarker: &symbolMatch[1],
-		}, nil
+	if trimmed[len(trimmed)-1] == ':' && isSymbolText(trimmed[:len(trimmed)-1]) {
+		line.SymbolMarker = a.newString(trimmed[:len(trimmed)-1])
+		return
 	}
 
-	fields := strings.Fields(trimmed)
-	if len(fields) == 0 || !instNameRegexp.MatchString(fields[0]) {
-		err = errors.New("invalid/missing instruction name")
+	name, rest := nextField(trimmed)
+	if !isInstName(name) {
+		err = &spanError{
+			span:    Span{Start: offset, End: offset + len(name)},
+			message: "invalid/missing instruction name",
+		}
 		return
 	}
 
-	line.Instruction = &TokenizedInstruction{
-		Name:      strings.ToUpper(fields[0]),
-		Arguments: make([]*ArgToken, len(fields)-1),
+	line.Instruction = a.newInstruction()
+	line.Instruction.Name = strings.ToUpper(name)
+	var numOperands int
+	if spaceLength(rest) < len(rest) {
+		numOperands = strings.Count(rest, ",") + 1
 	}
+	line.Instruction.Arguments = a.newArguments(numOperands)
+	line.Instruction.NameSpan = Span{Start: offset, End: offset + len(name)}
+	line.Instruction.ArgumentSpans = a.newSpans(numOperands)
 
-	for i, field := range fields[1:] {
-		if i != len(fields)-2 {
-			if !strings.HasSuffix(field, ",") {
-				err = errors.New("missing comma after operand " + strconv.Itoa(i+1))
+	start := line.Span.End - len(rest)
+	for i := range line.Instruction.Arguments {
+		operand := rest
+		if idx := strings.IndexByte(rest, ','); idx >= 0 {
+			operand, rest = rest[:idx], rest[idx+1:]
+		}
+		field := strings.TrimRightFunc(operand[spaceLength(operand):], unicode.IsSpace)
+		fieldStart := start + spaceLength(operand)
+		start += len(operand) + 1
+		span := Span{Start: fieldStart, End: fieldStart + len(field)}
+		line.Instruction.ArgumentSpans[i] = span
+		if field == "" {
+			err = &spanError{span: span, message: "missing operand " + strconv.Itoa(i+1)}
+			return
+		}
+		if err = parseArgToken(field, line.Instruction.Arguments[i]); err != nil {
+			if first, _ := nextField(field); first != field {
+				// The operand is really two operands without a comma between them.
+				err = &spanError{
+					span:    Span{Start: fieldStart, End: fieldStart + len(first)},
+					message: "missing comma after operand " + strconv.Itoa(i+1),
+				}
 				return
 			}
-			field = field[:len(field)-1]
-		}
-		line.Instruction.Arguments[i], err = ParseArgToken(field)
-		if err != nil {
-			err = errors.New("operand " + strconv.Itoa(i+1) + ": " + err.Error())
+			err = &spanError{
+				span:    span,
+				message: "operand " + strconv.Itoa(i+1) + ": " + err.Error(),
+			}
 			return
 		}
 	}
@@ -246,6 +651,275 @@ func tokenizeLine(lineText string) (line TokenizedLine, err error) {
 	return
 }
 
+// directiveArgument checks if a trimmed line is a directive with the given name (in any case) and
+// exactly one argument, returning the argument if so.
+func directiveArgument(line, name string) (arg string, ok bool) {
+	if len(line) < len(name)+2 || line[0] != '.' || !strings.EqualFold(line[1:len(name)+1], name) {
+		return "", false
+	}
+	arg = line[len(name)+1:]
+	if !isDirectiveSpace(arg[0]) {
+		return "", false
+	}
+	for arg != "" && isDirectiveSpace(arg[0]) {
+		arg = arg[1:]
+	}
+	return arg, true
+}
+
+// dataDirectives lists the directives which take a list of values.
+var dataDirectives = []string{"word", "half", "byte"}
+
+// isDataDirective checks if a directive name is in dataDirectives.
+func isDataDirective(name string) bool {
+	for _, dataName := range dataDirectives {
+		if name == dataName {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDirectiveValues parses the comma-separated values of a data directive.
+func parseDirectiveValues(arg string) ([]DirectiveValue, error) {
+	res := make([]DirectiveValue, 0, strings.Count(arg, ",")+1)
+	for {
+		comma := strings.IndexByte(arg, ',')
+		valueStr := arg
+		if comma >= 0 {
+			valueStr = arg[:comma]
+		}
+		valueStr = strings.TrimSpace(valueStr)
+		if valueStr == "" {
+			return nil, errors.New("missing value " + strconv.Itoa(len(res)+1))
+		} else if isConstantText(valueStr) {
+			constant, err := parseConstant(valueStr)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, DirectiveValue{Constant: constant})
+		} else if symbol, offsetStr, negative, ok := splitSymbolOffset(valueStr); ok {
+			value := DirectiveValue{Symbol: symbol}
+			if offsetStr != "" {
+				offset, err := parseConstant(offsetStr)
+				if err != nil {
+					return nil, err
+				}
+				if negative {
+					offset = -offset
+				}
+				value.Constant = offset
+			}
+			res = append(res, value)
+		} else {
+			return nil, errors.New("invalid value: " + valueStr)
+		}
+		if comma < 0 {
+			return res, nil
+		}
+		arg = arg[comma+1:]
+	}
+}
+
+// parseSizeArgument parses the argument of a ".size" directive, like "table, 16".
+func parseSizeArgument(arg string) (symbol string, size uint32, err error) {
+	comma := strings.IndexByte(arg, ',')
+	if comma < 0 {
+		return "", 0, errors.New("expected a symbol and a size")
+	}
+	symbol = strings.TrimSpace(arg[:comma])
+	sizeStr := strings.TrimSpace(arg[comma+1:])
+	if !isSymbolText(symbol) || isConstantText(symbol) {
+		return "", 0, errors.New("invalid symbol: " + symbol)
+	} else if sizeStr == "" || !isConstantText(sizeStr) {
+		return "", 0, errors.New("invalid size: " + sizeStr)
+	}
+	size, err = parseConstant(sizeStr)
+	return symbol, size, err
+}
+
+// directiveValueSpans fills in the span of each comma-separated value in the argument of a data
+// directive, given the offset of the argument in the source.
+func directiveValueSpans(arg string, offset int, spans []Span) {
+	for i := range spans {
+		valueStr := arg
+		if comma := strings.IndexByte(arg, ','); comma >= 0 {
+			valueStr = arg[:comma]
+		}
+		start := offset + len(valueStr) - len(strings.TrimLeftFunc(valueStr, unicode.IsSpace))
+		spans[i] = Span{Start: start, End: start + len(strings.TrimSpace(valueStr))}
+		arg = arg[len(valueStr):]
+		offset += len(valueStr)
+		if arg != "" {
+			arg = arg[1:]
+			offset++
+		}
+	}
+}
+
+// splitSymbolOffset splits an operand like "table+8" into a symbol and the text of an unsigned
+// offset, which is empty if there is no offset.
+// If the operand does not have this form, ok is false.
+func splitSymbolOffset(s string) (symbol, offset string, negative, ok bool) {
+	symbol = s
+	if idx := strings.IndexAny(s, "+-"); idx >= 0 {
+		symbol, offset = s[:idx], s[idx+1:]
+		negative = s[idx] == '-'
+		if offset == "" || offset[0] == '-' || !isConstantText(offset) {
+			return "", "", false, false
+		}
+	}
+	if symbol == "" || !isSymbolText(symbol) || isConstantText(symbol) {
+		return "", "", false, false
+	}
+	return symbol, offset, negative, true
+}
+
+// isDirectiveSpace checks if a byte may separate a directive from its argument.
+func isDirectiveSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+// isInstName checks if a string is a non-empty sequence of ASCII letters.
+func isInstName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// nextField splits off the first whitespace-separated field of a string, in the same way as
+// strings.Fields.
+func nextField(s string) (field, rest string) {
+	s = s[spaceLength(s):]
+	for i := 0; i < len(s); {
+		if n := spaceLength(s[i:]); n > 0 {
+			return s[:i], s[i:]
+		} else if s[i] < utf8.RuneSelf {
+			i++
+		} else {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+	}
+	return s, ""
+}
+
+// spaceLength returns the number of bytes of whitespace at the start of a string.
+func spaceLength(s string) int {
+	var i int
+	for i < len(s) {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c != ' ' && (c < '\t' || c > '\r') {
+				break
+			}
+			i++
+		} else {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+	}
+	return i
+}
+
+// A lineAllocator allocates the values which tokenized lines point to in batches, so that
+// tokenizing a large file does not take several allocations per line.
+//
+// The slices it returns have no spare capacity, so appending to one of them never overwrites
+// another line's values.
+type lineAllocator struct {
+	batchSize int
+
+	strings      []string
+	directives   []TokenizedDirective
+	instructions []TokenizedInstruction
+	tokens       []ArgToken
+	tokenPtrs    []*ArgToken
+	spans        []Span
+}
+
+func (a *lineAllocator) newString(s string) *string {
+	if len(a.strings) == 0 {
+		a.strings = make([]string, a.batchSize)
+	}
+	res := &a.strings[0]
+	*res = s
+	a.strings = a.strings[1:]
+	return res
+}
+
+func (a *lineAllocator) newDirective() *TokenizedDirective {
+	if len(a.directives) == 0 {
+		a.directives = make([]TokenizedDirective, a.batchSize)
+	}
+	res := &a.directives[0]
+	a.directives = a.directives[1:]
+	return res
+}
+
+func (a *lineAllocator) newInstruction() *TokenizedInstruction {
+	if len(a.instructions) == 0 {
+		a.instructions = make([]TokenizedInstruction, a.batchSize)
+	}
+	res := &a.instructions[0]
+	a.instructions = a.instructions[1:]
+	return res
+}
+
+func (a *lineAllocator) newArguments(n int) []*ArgToken {
+	if n == 0 {
+		return []*ArgToken{}
+	}
+	if len(a.tokens) < n {
+		a.tokens = make([]ArgToken, a.batchLength(n))
+	}
+	if len(a.tokenPtrs) < n {
+		a.tokenPtrs = make([]*ArgToken, a.batchLength(n))
+	}
+	res := a.tokenPtrs[:n:n]
+	for i := range res {
+		res[i] = &a.tokens[i]
+	}
+	a.tokens = a.tokens[n:]
+	a.tokenPtrs = a.tokenPtrs[n:]
+	return res
+}
+
+func (a *lineAllocator) newSpans(n int) []Span {
+	if n == 0 {
+		return nil
+	}
+	if len(a.spans) < n {
+		a.spans = make([]Span, a.batchLength(n))
+	}
+	res := a.spans[:n:n]
+	a.spans = a.spans[n:]
+	return res
+}
+
+func (a *lineAllocator) batchLength(n int) int {
+	if n > a.batchSize {
+		return n
+	}
+	return a.batchSize
+}
+
+// A spanError is a tokenizer error caused by one part of a line, such as the instruction name
+// or an operand.
+type spanError struct {
+	span    Span
+	message string
+}
+
+func (s *spanError) Error() string {
+	return s.message
+}
+
 func unsignedConst32ToString(constant uint32) string {
 	return strconv.FormatUint(uint64(constant), 10)
 }
@@ -257,11 +931,3 @@ func signedConst32ToString(constant int32) string {
 func registerToString(regNum int) string {
 	return "$" + strconv.Itoa(regNum)
 }
-
-func signedConst16ToString(constant int16) string {
-	return strconv.FormatInt(int64(constant), 10)
-}
-
-func unsignedConst16ToString(constant uint16) string {
-	return strconv.FormatUint(uint64(constant), 10)
-}
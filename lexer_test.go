@@ -1,6 +1,11 @@
 package mips32
 
-import "testing"
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func TestTokenizeSource(t *testing.T) {
 	source := `.text 0x50000 # this says where our program's data is located.
@@ -24,7 +29,7 @@ func TestTokenizeSource(t *testing.T) {
 			{
 				LineNumber: 1,
 				Comment:    createStringPtr(" this says where our program's data is located."),
-				Directive:  &TokenizedDirective{"text", 0x50000},
+				Directive:  &TokenizedDirective{Name: "text", Constant: 0x50000},
 			},
 			{
 				LineNumber:   2,
@@ -68,7 +73,7 @@ func TestTokenizeSource(t *testing.T) {
 			},
 			{
 				LineNumber: 9,
-				Directive:  &TokenizedDirective{"word", 0},
+				Directive:  &TokenizedDirective{Name: "word", Values: []DirectiveValue{{}}},
 			},
 			{
 				LineNumber: 11,
@@ -107,6 +112,276 @@ func TestTokenizeSource(t *testing.T) {
 	}
 }
 
+func TestTokenizeDataDirectives(t *testing.T) {
+	cases := map[string]TokenizedDirective{
+		".word table": {Name: "word", Values: []DirectiveValue{{Symbol: "table"}}},
+		".word table+8": {Name: "word", Values: []DirectiveValue{{Symbol: "table",
+			Constant: 8}}},
+		".word table-16": {Name: "word", Values: []DirectiveValue{{Symbol: "table",
+			Constant: 0xfffffff0}}},
+		".word 1st": {Name: "word", Values: []DirectiveValue{{Symbol: "1st"}}},
+		".word 1, 2, 3, label": {Name: "word", Values: []DirectiveValue{{Constant: 1},
+			{Constant: 2}, {Constant: 3}, {Symbol: "label"}}},
+		".half 65535, 1": {Name: "half", Values: []DirectiveValue{{Constant: 0xffff},
+			{Constant: 1}}},
+		".byte 1": {Name: "byte", Values: []DirectiveValue{{Constant: 1}}},
+	}
+	for source, expected := range cases {
+		line, err := tokenizeLine(source)
+		if err != nil {
+			t.Errorf("%q: %v", source, err)
+		} else if line.Directive == nil || !line.Directive.Equal(&expected) {
+			t.Errorf("%q: unexpected line %v", source, line)
+		} else if line.String() != source {
+			t.Errorf("%q: rendered as %q", source, line.String())
+		}
+	}
+	if line, err := tokenizeLine(".byte 1 ,2,\t3"); err != nil {
+		t.Error(err)
+	} else if line.String() != ".byte 1, 2, 3" {
+		t.Errorf("unexpected line: %v", line)
+	}
+
+	errors := map[string]string{
+		".word table+":    ".word: invalid value: table+",
+		".word table+-4":  ".word: invalid value: table+-4",
+		".word +4":        ".word: invalid value: +4",
+		".word 4+4":       ".word: invalid value: 4+4",
+		".word table+foo": ".word: invalid value: table+foo",
+		".word $t0":       ".word: invalid value: $t0",
+		".byte 1,, 2":     ".byte: missing value 2",
+		".half 1, 2,":     ".half: missing value 3",
+	}
+	for source, expected := range errors {
+		if _, err := tokenizeLine(source); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+}
+
+func TestTokenizeStringDirectives(t *testing.T) {
+	line, err := tokenizeLine(`.asciiz "a # b; c // \"d\"\n" # comment`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TokenizedDirective{Name: "asciiz", Text: "a # b; c // \"d\"\n"}
+	if line.Directive == nil || !line.Directive.Equal(&expected) {
+		t.Errorf("unexpected directive: %v", line.Directive)
+	} else if line.Comment == nil || *line.Comment != " comment" {
+		t.Errorf("unexpected comment: %v", line.Comment)
+	} else if line.String() != `.asciiz "a # b; c // \"d\"\n" # comment` {
+		t.Errorf("unexpected rendering: %s", line.String())
+	}
+
+	if _, err := tokenizeLine(`.ascii "\z"`); err == nil ||
+		err.Error() != `.ascii: unknown escape sequence: \z` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenizeSymbolDirectives(t *testing.T) {
+	lines, err := TokenizeSource(".globl main\n.GLOBAL table\n.size table ,0x10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TokenizedDirective{
+		{Name: "globl", Symbol: "main"},
+		{Name: "globl", Symbol: "table"},
+		{Name: "size", Symbol: "table", Constant: 16},
+	}
+	texts := []string{".globl main", ".globl table", ".size table, 16"}
+	for i, line := range lines {
+		if !line.Directive.Equal(&expected[i]) {
+			t.Errorf("line %d: unexpected directive %v", i+1, line.Directive)
+		} else if line.String() != texts[i] {
+			t.Errorf("line %d: expected %q but got %q", i+1, texts[i], line.String())
+		}
+	}
+}
+
+func TestTokenizeBlockComments(t *testing.T) {
+	source := `/* header
+   comment */
+NOP /* a */ # b
+ADDIU $t0, /* c */ $t0, 1 /* d */ /* e
+f */ LABEL:
+.asciiz "/* not a comment */" /* g */`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"# header",
+		"#   comment ",
+		"NOP # b",
+		"ADDIU $8, $8, 1 # c   d   e",
+		"LABEL: #f ",
+		`.asciiz "/* not a comment */" # g `,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+	for i, line := range lines {
+		if line.LineNumber != i+1 || line.String() != expected[i] {
+			t.Errorf("line %d: expected %q but got %q (line %d)", i, expected[i], line.String(),
+				line.LineNumber)
+		}
+	}
+
+	_, err = TokenizeSource("NOP\n/* a */\n/* b\nNOP")
+	if err == nil || err.Error() != "line 3: unterminated block comment" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenizeStatements(t *testing.T) {
+	source := `loop: ; ADDIU $t0, $t0, 1 ; BNE $t0, $t1, loop # next; not a statement
+.ascii "a;b"; NOP;; /* c */
+NOP;`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		line int
+		text string
+	}{
+		{1, "loop:"},
+		{1, "ADDIU $8, $8, 1"},
+		{1, "BNE $8, $9, loop # next; not a statement"},
+		{2, `.ascii "a;b"`},
+		{2, "NOP # c "},
+		{3, "NOP"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+	for i, x := range expected {
+		if lines[i].LineNumber != x.line || lines[i].String() != x.text {
+			t.Errorf("statement %d: expected %q on line %d but got %q on line %d", i, x.text,
+				x.line, lines[i].String(), lines[i].LineNumber)
+		}
+	}
+
+	lines, err = TokenizeSource("ADDIU $t0,$t0,1 ; BNE $t0,$t1,loop\nADDIU $t0,$t0, 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := []string{"ADDIU $8, $8, 1", "BNE $8, $9, loop", "ADDIU $8, $8, 1"}
+	if len(lines) != len(compact) {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+	for i, x := range compact {
+		if lines[i].String() != x {
+			t.Errorf("statement %d: expected %q but got %q", i, x, lines[i].String())
+		}
+	}
+	spans := lines[1].Instruction.ArgumentSpans
+	if spans[0] != (Span{Start: 22, End: 25}) || spans[2] != (Span{Start: 30, End: 34}) {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+
+	_, err = TokenizeSource("NOP\nNOP; FOO $t0 BAR")
+	if err == nil || err.Error() != "line 2: missing comma after operand 1" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err = TokenizeSource("ADDIU $t0,,1")
+	if err == nil || err.Error() != "line 1: missing operand 2" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenizeMixedCase(t *testing.T) {
+	mixed, err := TokenizeSource(`.Text 0x100
+addiu $T0, $Sp, -4
+Lw $RA, 8($zero)
+.WORD 1, 2
+.AsciiZ "Hi"
+.SECTION Data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := TokenizeSource(`.text 0x100
+ADDIU $t0, $sp, -4
+LW $ra, 8($zero)
+.word 1, 2
+.asciiz "Hi"
+.section Data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range mixed {
+		if line.String() != canonical[i].String() {
+			t.Errorf("line %d: expected %q but got %q", i+1, canonical[i].String(), line.String())
+		}
+	}
+}
+
+func TestTokenizeSpans(t *testing.T) {
+	source := "start:  # entry\r\n" +
+		"  ADDIU $t0,  $sp, -4 ; lw $ra, 8($sp)\n" +
+		"\t.word 1 ,  end-4,x /* w */\n" +
+		".asciiz \"a b\" // s\n" +
+		".text 0x100\n" +
+		"nop /* a\r\nb */ NOP"
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(span Span) string {
+		return source[span.Start:span.End]
+	}
+	texts := func(spans []Span) []string {
+		var res []string
+		for _, span := range spans {
+			res = append(res, text(span))
+		}
+		return res
+	}
+	expected := []struct {
+		code     string
+		comment  string
+		name     string
+		argument []string
+	}{
+		{"start:", "# entry", "", nil},
+		{"ADDIU $t0,  $sp, -4", "", "ADDIU", []string{"$t0", "$sp", "-4"}},
+		{"lw $ra, 8($sp)", "", "lw", []string{"$ra", "8($sp)"}},
+		{".word 1 ,  end-4,x", "/* w */", ".word", []string{"1", "end-4", "x"}},
+		{`.asciiz "a b"`, "// s", ".asciiz", []string{`"a b"`}},
+		{".text 0x100", "", ".text", []string{"0x100"}},
+		{"nop", "/* a", "nop", nil},
+		{"NOP", "b */", "NOP", nil},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+	for i, x := range expected {
+		line := lines[i]
+		var name string
+		var arguments []string
+		if line.Instruction != nil {
+			name = text(line.Instruction.NameSpan)
+			arguments = texts(line.Instruction.ArgumentSpans)
+		} else if line.Directive != nil {
+			name = text(line.Directive.NameSpan)
+			arguments = texts(line.Directive.ArgumentSpans)
+		}
+		if text(line.Span) != x.code || text(line.CommentSpan) != x.comment ||
+			name != x.name || !reflect.DeepEqual(arguments, x.argument) {
+			t.Errorf("line %d: unexpected spans %q, %q, %q, %q", i, text(line.Span),
+				text(line.CommentSpan), name, arguments)
+		}
+	}
+
+	// Lines built without the tokenizer compare equal to tokenized ones.
+	built := &TokenizedLine{LineNumber: 5, Directive: &TokenizedDirective{Name: "text",
+		Constant: 0x100}}
+	if !lines[5].Equal(built) {
+		t.Error("spans should not affect Equal")
+	}
+}
+
 func BenchmarkTokenizeSource(b *testing.B) {
 	code := `
 		.text 0x50000 # this says where our program's data is located.
@@ -122,11 +397,42 @@ func BenchmarkTokenizeSource(b *testing.B) {
 		J FOOBAR
 		NOP
 	`
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		TokenizeSource(code)
 	}
 }
 
+func BenchmarkTokenizeSourceLarge(b *testing.B) {
+	source := largeBenchmarkSource()
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := TokenizeSource(source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// largeBenchmarkSource creates a program of about a megabyte.
+func largeBenchmarkSource() string {
+	var res strings.Builder
+	res.WriteString(".text 0x10000\n")
+	for i := 0; res.Len() < 1<<20; i++ {
+		label := "loop" + strconv.Itoa(i)
+		res.WriteString(label + ": # iteration " + strconv.Itoa(i) + "\n" +
+			"    ADDIU $t0, $t0, -1\n" +
+			"    LW $t1, 0x10($sp)\n" +
+			"    ADDU $t2, $t1, $t0 // sum\n" +
+			"    SW $t2, -4($sp)\n" +
+			"\n" +
+			"    BNE $t0, $zero, " + label + "\n" +
+			"    NOP\n")
+	}
+	return res.String()
+}
+
 func createStringPtr(s string) *string {
 	return &s
 }
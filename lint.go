@@ -0,0 +1,211 @@
+package mips32
+
+import (
+	"sort"
+	"strconv"
+)
+
+// A Warning describes something suspicious about a program which does not stop it from being
+// assembled or run.
+type Warning struct {
+	Address uint32
+
+	// Line is the source line which caused the warning, or 0 if it is unknown.
+	Line int
+
+	Message string
+}
+
+func (w *Warning) String() string {
+	if w.Line != 0 {
+		return "line " + strconv.Itoa(w.Line) + ": " + w.Message
+	}
+	hexStr := "0x" + strconv.FormatUint(uint64(w.Address), 16)
+	return hexStr + ": " + w.Message
+}
+
+// Lint looks for likely mistakes in an executable.
+// It reports code which can never run (see DeadCode), labels which are never used (unless they
+// are declared with ".globl"), writes to $zero, branches and jumps into delay slots, delay slots
+// which hold control instructions or change registers that their branch or jump uses, and
+// immediates which are probably being misinterpreted.
+//
+// The warnings are sorted by address.
+func Lint(e *Executable) []*Warning {
+	return lint(e, nil)
+}
+
+// lint is like Lint, but it also counts some extra symbols as used, such as symbols which the
+// source referred to from ".word" directives.
+func lint(e *Executable, extraUsed map[string]bool) []*Warning {
+	var warnings []*Warning
+	addWarning := func(addr uint32, msg string) {
+		warnings = append(warnings, &Warning{
+			Address: addr,
+			Line:    e.LineNumbers[addr],
+			Message: msg,
+		})
+	}
+
+	targets := map[uint32]bool{}
+	usedSymbols := map[string]bool{}
+	for _, segment := range e.sortedSegmentAddresses() {
+		for i := range e.Segments[segment] {
+			inst := &e.Segments[segment][i]
+			addr := segment + uint32(i*4)
+			if inst.CodePointer.IsSymbol {
+				usedSymbols[inst.CodePointer.Symbol] = true
+			}
+			if target, ok := e.controlTarget(inst, addr); ok {
+				targets[target] = true
+			}
+		}
+	}
+	for _, addr := range e.Symbols {
+		targets[addr] = true
+	}
+
+	unreachable := map[uint32]bool{}
+	for _, segment := range e.sortedSegmentAddresses() {
+		insts := e.Segments[segment]
+		for i := range insts {
+			inst := &insts[i]
+			addr := segment + uint32(i*4)
+
+			if i >= 2 && isUnconditionalJump(&insts[i-2]) && !targets[addr] {
+				addWarning(addr, "unreachable code")
+				unreachable[addr] = true
+			}
+			if reg, ok := destinationRegister(inst); ok && reg == 0 {
+				addWarning(addr, "write to $zero has no effect")
+			}
+			if target, ok := e.controlTarget(inst, addr); ok {
+				if prev := e.Get(target - 4); prev != nil && isControlInstruction(prev) {
+					addWarning(addr, "target is in the delay slot of another instruction")
+				}
+			}
+			if i >= 1 && isControlInstruction(&insts[i-1]) {
+				if isControlInstruction(inst) {
+					addWarning(addr, "branch or jump in delay slot")
+				} else if reg, ok := destinationRegister(inst); ok && reg != 0 {
+					for _, used := range controlRegisters(&insts[i-1]) {
+						if used == reg {
+							addWarning(addr, "delay slot changes $"+ABIRegisterNames[reg]+
+								", which the branch or jump before it uses")
+							break
+						}
+					}
+				}
+			}
+			if inst.Name == "SLTIU" && inst.SignedConstant16 < 0 {
+				hexStr := "0x" + strconv.FormatUint(uint64(uint32(inst.SignedConstant16)), 16)
+				addWarning(addr, "SLTIU sign-extends its immediate, so it compares against "+
+					hexStr)
+			}
+		}
+	}
+
+	for _, block := range deadCode(e, BuildCFG(e), extraUsed) {
+		if !unreachable[block.Start] {
+			addWarning(block.Start, deadCodeMessage)
+		}
+	}
+
+	for _, pair := range e.sortedSymbolAddrPairs() {
+		if !usedSymbols[pair.Symbol] && !extraUsed[pair.Symbol] &&
+			!e.SymbolInfo[pair.Symbol].Global {
+			addWarning(pair.Address, "unused label: "+pair.Symbol)
+		}
+	}
+
+	sort.Stable(warningList(warnings))
+	return warnings
+}
+
+// controlTarget returns the destination of a branch or a J/JAL instruction.
+// It returns false for other instructions, and for branches whose targets cannot be resolved.
+func (e *Executable) controlTarget(inst *Instruction, addr uint32) (uint32, bool) {
+	switch inst.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+		offset, err := instructionBranchOffset(inst, addr, e.Symbols)
+		if err != nil {
+			return 0, false
+		}
+		return addr + 4 + offset, true
+	case "J", "JAL":
+		base, err := instructionJumpBase(inst, addr, e.Symbols)
+		if err != nil {
+			return 0, false
+		}
+		return ((addr + 4) & 0xf0000000) | base, true
+	}
+	return 0, false
+}
+
+// isControlInstruction returns true for instructions which have delay slots.
+func isControlInstruction(inst *Instruction) bool {
+	switch inst.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE", "J", "JAL", "JALR", "JR":
+		return true
+	}
+	return false
+}
+
+// controlRegisters returns the registers which a control instruction reads or writes.
+func controlRegisters(inst *Instruction) []int {
+	switch inst.Name {
+	case "JAL":
+		return []int{31}
+	case "JALR":
+		if len(inst.Registers) == 1 {
+			return []int{31, inst.Registers[0]}
+		}
+	}
+	return inst.Registers
+}
+
+// isUnconditionalJump returns true for instructions after whose delay slots execution never
+// falls through.
+func isUnconditionalJump(inst *Instruction) bool {
+	switch inst.Name {
+	case "J", "JR":
+		return true
+	case "BEQ":
+		return inst.Registers[0] == inst.Registers[1]
+	case "BGEZ", "BLEZ":
+		return inst.Registers[0] == 0
+	}
+	return false
+}
+
+// destinationRegister returns the register an instruction writes to, if any.
+func destinationRegister(inst *Instruction) (int, bool) {
+	switch inst.Name {
+	case "NOP", "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE", "J", "JR", "SB", "SW", ".word":
+		return 0, false
+	case "JAL":
+		return 31, true
+	case "JALR":
+		if len(inst.Registers) == 1 {
+			return 31, true
+		}
+	}
+	if len(inst.Registers) == 0 {
+		return 0, false
+	}
+	return inst.Registers[0], true
+}
+
+type warningList []*Warning
+
+func (w warningList) Len() int {
+	return len(w)
+}
+
+func (w warningList) Less(i, j int) bool {
+	return w[i].Address < w[j].Address
+}
+
+func (w warningList) Swap(i, j int) {
+	w[i], w[j] = w[j], w[i]
+}
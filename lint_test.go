@@ -0,0 +1,93 @@
+package mips32
+
+import "testing"
+
+func TestLint(t *testing.T) {
+	code := `START:
+ADDIU $r1, $r0, 5
+LOOP:
+ADDIU $r1, $r1, -1
+BNE $r1, $r0, LOOP
+ADDU $r0, $r1, $r1
+J START
+NOP
+SLTIU $r2, $r1, -1
+UNUSED:
+NOP
+BEQ $r0, $r0, SLOT
+NOP
+J END
+SLOT:
+NOP
+END:
+NOP`
+	tokens, err := TokenizeSource(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := Lint(exc)
+	expected := []Warning{
+		{Address: 0xc, Line: 6, Message: "write to $zero has no effect"},
+		{Address: 0x18, Line: 9, Message: "unreachable code"},
+		{Address: 0x18, Line: 9,
+			Message: "SLTIU sign-extends its immediate, so it compares against 0xffffffff"},
+		{Address: 0x1c, Line: 11, Message: deadCodeMessage},
+		{Address: 0x1c, Line: 11, Message: "unused label: UNUSED"},
+		{Address: 0x20, Line: 12, Message: "target is in the delay slot of another instruction"},
+		{Address: 0x28, Line: 14, Message: "unreachable code"},
+		{Address: 0x30, Line: 18, Message: deadCodeMessage},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatal("unexpected warnings:", warnings)
+	}
+	for i, w := range warnings {
+		if *w != expected[i] {
+			t.Errorf("warning %d: expected %v but got %v", i, expected[i], *w)
+		}
+	}
+}
+
+func TestLintDelaySlots(t *testing.T) {
+	code := `BNE $t0, $t1, END
+ADDIU $t0, $t0, 1
+JAL END
+ADDIU $ra, $0, 4
+BEQ $t0, $0, END
+J END
+JR $ra
+ADDIU $t0, $0, 1
+END:
+NOP`
+	tokens, err := TokenizeSource(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, w := range Lint(exc) {
+		if w.Message != "unreachable code" {
+			messages = append(messages, w.String())
+		}
+	}
+	expected := []string{
+		"line 2: delay slot changes $t0, which the branch or jump before it uses",
+		"line 4: delay slot changes $ra, which the branch or jump before it uses",
+		"line 6: branch or jump in delay slot",
+		"line 7: branch or jump in delay slot",
+	}
+	if len(messages) != len(expected) {
+		t.Fatal("unexpected warnings:", messages)
+	}
+	for i, x := range expected {
+		if messages[i] != x {
+			t.Errorf("warning %d: expected %q but got %q", i, x, messages[i])
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package mips32
 
+import "encoding/binary"
+
 // Memory defines an interface for storing binary data.
 type Memory interface {
 	Get(ptr uint32) byte
@@ -11,6 +13,10 @@ type Memory interface {
 // of room inbetween sparse addresses.
 type LazyMemory struct {
 	pages map[uint32][]byte
+
+	// shared records the pages which may also be used by a clone, and must be copied before
+	// they are written.
+	shared map[uint32]bool
 }
 
 func NewLazyMemory() *LazyMemory {
@@ -27,11 +33,44 @@ func (l *LazyMemory) Get(ptr uint32) byte {
 }
 
 func (l *LazyMemory) Set(ptr uint32, b byte) {
+	l.writablePage(ptr)[ptr&0xfff] = b
+}
+
+// writablePage returns the page containing an address, allocating it or copying it from a
+// clone if necessary.
+func (l *LazyMemory) writablePage(ptr uint32) []byte {
 	page := ptr & 0xfffff000
-	if data := l.pages[page]; data != nil {
-		data[ptr&0xfff] = b
+	data := l.pages[page]
+	if data == nil {
+		data = make([]byte, 0x1000)
+		l.pages[page] = data
+	} else if l.shared[page] {
+		data = append([]byte{}, data...)
+		l.pages[page] = data
+		delete(l.shared, page)
+	}
+	return data
+}
+
+// loadWord reads the word at an aligned address.
+func (l *LazyMemory) loadWord(ptr uint32, littleEndian bool) uint32 {
+	data := l.pages[ptr&0xfffff000]
+	if data == nil {
+		return 0
+	}
+	word := data[ptr&0xffc : ptr&0xffc+4]
+	if littleEndian {
+		return binary.LittleEndian.Uint32(word)
+	}
+	return binary.BigEndian.Uint32(word)
+}
+
+// storeWord writes the word at an aligned address.
+func (l *LazyMemory) storeWord(ptr uint32, value uint32, littleEndian bool) {
+	word := l.writablePage(ptr)[ptr&0xffc : ptr&0xffc+4]
+	if littleEndian {
+		binary.LittleEndian.PutUint32(word, value)
 	} else {
-		l.pages[page] = make([]byte, 0x1000)
-		l.pages[page][ptr&0xfff] = b
+		binary.BigEndian.PutUint32(word, value)
 	}
 }
@@ -0,0 +1,199 @@
+package mips32
+
+import (
+	"errors"
+	"sort"
+)
+
+// A MemoryRequest describes a load or a store which an instruction asks a MemoryBackend to
+// perform.
+type MemoryRequest struct {
+	// Address is the first byte being accessed.
+	Address uint32
+
+	// Size is 1 for a byte or 4 for a word.
+	Size int
+
+	// PC is the address of the instruction making the access.
+	PC uint32
+
+	// LittleEndian is set if the emulator is in little endian mode, which determines the order
+	// of the bytes in a word.
+	LittleEndian bool
+}
+
+// A MemoryBackend is a Memory which handles whole loads and stores itself.
+//
+// When an Emulator's Memory is a MemoryBackend, load and store instructions use Load and Store
+// instead of Get and Set, so a backend can see the width and the origin of each access, and it
+// can make the instruction fail (e.g. for an unmapped address).
+// Get and Set are still used by debuggers and syscalls, so they should not have side effects
+// beyond reading or writing the byte.
+type MemoryBackend interface {
+	Memory
+
+	// Load reads a value.
+	// For a 1-byte access, the byte is returned in the low 8 bits.
+	Load(r MemoryRequest) (uint32, error)
+
+	// Store writes a value.
+	// For a 1-byte access, only the low 8 bits of the value are used.
+	Store(r MemoryRequest, value uint32) error
+}
+
+// LoadBytes implements a load using the Get method of a Memory.
+func LoadBytes(m Memory, r MemoryRequest) uint32 {
+	var res uint32
+	for i := 0; i < r.Size; i++ {
+		if r.LittleEndian {
+			res |= uint32(m.Get(r.Address+uint32(i))) << uint(8*i)
+		} else {
+			res = (res << 8) | uint32(m.Get(r.Address+uint32(i)))
+		}
+	}
+	return res
+}
+
+// StoreBytes implements a store using the Set method of a Memory.
+func StoreBytes(m Memory, r MemoryRequest, value uint32) {
+	for i := 0; i < r.Size; i++ {
+		shift := uint(8 * i)
+		if !r.LittleEndian {
+			shift = uint(8 * (r.Size - i - 1))
+		}
+		m.Set(r.Address+uint32(i), byte(value>>shift))
+	}
+}
+
+// FlatMemory is a MemoryBackend backed by a contiguous array.
+// Loads and stores outside of the array fail, while Get returns 0 and Set does nothing.
+type FlatMemory struct {
+	Base uint32
+	Data []byte
+}
+
+// NewFlatMemory creates a zeroed FlatMemory which covers size bytes, starting at base.
+func NewFlatMemory(base uint32, size int) *FlatMemory {
+	return &FlatMemory{Base: base, Data: make([]byte, size)}
+}
+
+func (f *FlatMemory) Get(ptr uint32) byte {
+	if idx := ptr - f.Base; idx < uint32(len(f.Data)) {
+		return f.Data[idx]
+	}
+	return 0
+}
+
+func (f *FlatMemory) Set(ptr uint32, b byte) {
+	if idx := ptr - f.Base; idx < uint32(len(f.Data)) {
+		f.Data[idx] = b
+	}
+}
+
+func (f *FlatMemory) Load(r MemoryRequest) (uint32, error) {
+	if !f.contains(r) {
+		return 0, unmappedAddressError(r.Address)
+	}
+	return LoadBytes(f, r), nil
+}
+
+func (f *FlatMemory) Store(r MemoryRequest, value uint32) error {
+	if !f.contains(r) {
+		return unmappedAddressError(r.Address)
+	}
+	StoreBytes(f, r, value)
+	return nil
+}
+
+func (f *FlatMemory) contains(r MemoryRequest) bool {
+	idx := uint64(r.Address) - uint64(f.Base)
+	return r.Address >= f.Base && idx+uint64(r.Size) <= uint64(len(f.Data))
+}
+
+// An MMIORouter is a MemoryBackend which sends accesses to different devices depending on the
+// address, e.g. to put memory-mapped peripherals next to RAM.
+//
+// Addresses which are not mapped to a device go to Default.
+// If Default is nil, accesses to them fail (and Get returns 0).
+type MMIORouter struct {
+	Default Memory
+
+	regions []mmioRegion
+}
+
+type mmioRegion struct {
+	start  uint32
+	size   uint32
+	device MemoryBackend
+}
+
+// Map assigns a range of addresses to a device.
+// The device receives accesses with their original addresses.
+// It is an error for the range to overlap with another mapped range.
+func (m *MMIORouter) Map(start, size uint32, device MemoryBackend) error {
+	if size == 0 || uint64(start)+uint64(size) > 1<<32 {
+		return errors.New("invalid MMIO range at " + eightDigitHex(start))
+	}
+	// The uint64 conversions deal with ranges which end at the top of memory.
+	end := uint64(start) + uint64(size)
+	for _, r := range m.regions {
+		if uint64(start) < uint64(r.start)+uint64(r.size) && uint64(r.start) < end {
+			return errors.New("MMIO range at " + eightDigitHex(start) + " overlaps range at " +
+				eightDigitHex(r.start))
+		}
+	}
+	m.regions = append(m.regions, mmioRegion{start: start, size: size, device: device})
+	sort.Slice(m.regions, func(i, j int) bool {
+		return m.regions[i].start < m.regions[j].start
+	})
+	return nil
+}
+
+func (m *MMIORouter) Get(ptr uint32) byte {
+	if dev := m.lookup(ptr); dev != nil {
+		return dev.Get(ptr)
+	}
+	return 0
+}
+
+func (m *MMIORouter) Set(ptr uint32, b byte) {
+	if dev := m.lookup(ptr); dev != nil {
+		dev.Set(ptr, b)
+	}
+}
+
+func (m *MMIORouter) Load(r MemoryRequest) (uint32, error) {
+	dev := m.lookup(r.Address)
+	if backend, ok := dev.(MemoryBackend); ok {
+		return backend.Load(r)
+	} else if dev == nil {
+		return 0, unmappedAddressError(r.Address)
+	}
+	return LoadBytes(dev, r), nil
+}
+
+func (m *MMIORouter) Store(r MemoryRequest, value uint32) error {
+	dev := m.lookup(r.Address)
+	if backend, ok := dev.(MemoryBackend); ok {
+		return backend.Store(r, value)
+	} else if dev == nil {
+		return unmappedAddressError(r.Address)
+	}
+	StoreBytes(dev, r, value)
+	return nil
+}
+
+// lookup finds the device for an address, or returns nil if there is none.
+func (m *MMIORouter) lookup(addr uint32) Memory {
+	idx := sort.Search(len(m.regions), func(i int) bool {
+		return m.regions[i].start+(m.regions[i].size-1) >= addr
+	})
+	if idx < len(m.regions) && m.regions[idx].start <= addr {
+		return m.regions[idx].device
+	}
+	return m.Default
+}
+
+func unmappedAddressError(addr uint32) error {
+	return errors.New("unmapped address: " + eightDigitHex(addr))
+}
@@ -0,0 +1,126 @@
+package mips32
+
+import (
+	"strings"
+	"testing"
+)
+
+type testDevice struct {
+	FlatMemory
+	requests []MemoryRequest
+}
+
+func (t *testDevice) Load(r MemoryRequest) (uint32, error) {
+	t.requests = append(t.requests, r)
+	return 0x12345678, nil
+}
+
+func (t *testDevice) Store(r MemoryRequest, value uint32) error {
+	t.requests = append(t.requests, r)
+	return t.FlatMemory.Store(r, value)
+}
+
+func TestFlatMemory(t *testing.T) {
+	m := NewFlatMemory(0x100, 8)
+	if err := m.Store(MemoryRequest{Address: 0x100, Size: 4}, 0x12345678); err != nil {
+		t.Fatal(err)
+	}
+	if m.Data[0] != 0x12 || m.Data[3] != 0x78 {
+		t.Errorf("unexpected big endian data: %x", m.Data)
+	}
+	req := MemoryRequest{Address: 0x104, Size: 4, LittleEndian: true}
+	if err := m.Store(req, 0x12345678); err != nil {
+		t.Fatal(err)
+	}
+	if m.Data[4] != 0x78 || m.Data[7] != 0x12 {
+		t.Errorf("unexpected little endian data: %x", m.Data)
+	}
+	if value, err := m.Load(req); err != nil || value != 0x12345678 {
+		t.Errorf("unexpected load: %x %v", value, err)
+	}
+	if value, err := m.Load(MemoryRequest{Address: 0x107, Size: 1}); err != nil || value != 0x12 {
+		t.Errorf("unexpected load: %x %v", value, err)
+	}
+	for _, addr := range []uint32{0xfc, 0x105, 0x108} {
+		if _, err := m.Load(MemoryRequest{Address: addr, Size: 4}); err == nil {
+			t.Errorf("expected error for address %x", addr)
+		}
+	}
+}
+
+func TestMMIORouter(t *testing.T) {
+	ram := NewFlatMemory(0, 0x100)
+	device := &testDevice{FlatMemory: *NewFlatMemory(0x1000, 4)}
+	router := &MMIORouter{Default: NewLazyMemory()}
+	if err := router.Map(0, 0x100, ram); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Map(0x1000, 4, device); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Map(0xfc, 8, device); err == nil {
+		t.Error("expected overlap error")
+	}
+
+	// Ranges at the top of memory must not wrap around.
+	top := &MMIORouter{}
+	if err := top.Map(0xfffff000, 0x1000, ram); err != nil {
+		t.Fatal(err)
+	}
+	if err := top.Map(0xfffff800, 0x10, device); err == nil {
+		t.Error("expected overlap error at the top of memory")
+	}
+	if err := top.Map(0xffffeff0, 0x10, device); err != nil {
+		t.Error(err)
+	}
+	if top.lookup(0xffffffff) != ram || top.lookup(0xffffeff0) != device {
+		t.Error("unexpected devices at the top of memory")
+	}
+
+	tokens, err := TokenizeSource(`LUI $t0, 0
+ORI $t0, $t0, 0x1000
+ADDIU $t1, $0, 7
+SW $t1, 0($t0)
+LW $t2, 0($t0)
+SB $t1, 0x10($0)
+SB $t1, 0x2000($0)
+LUI $t3, 0x10
+SW $t1, 0($t3)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: router, Executable: exc}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emu.RegisterFile[10] != 0x12345678 {
+		t.Errorf("unexpected load result: %x", emu.RegisterFile[10])
+	}
+	if len(device.requests) != 2 || device.requests[0].PC != 12 || device.requests[1].PC != 16 {
+		t.Errorf("unexpected device requests: %+v", device.requests)
+	}
+	if device.Data[3] != 7 || ram.Data[0x10] != 7 || router.Get(0x2000) != 7 ||
+		router.Get(0x100000) != 0 {
+		t.Error("unexpected memory contents")
+	}
+
+	// Without a default, unmapped accesses make instructions fail.
+	router.Default = nil
+	emu = &Emulator{Memory: router, Executable: exc}
+	var stepErr error
+	for !emu.Done() && stepErr == nil {
+		stepErr = emu.Step()
+	}
+	if stepErr == nil || !strings.Contains(stepErr.Error(), "unmapped address: 0x00002000") {
+		t.Error("unexpected error:", stepErr)
+	}
+	if len(emu.LastDelta.bytes) != 0 {
+		t.Error("failed store should not change memory")
+	}
+}
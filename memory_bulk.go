@@ -0,0 +1,219 @@
+package mips32
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// A BulkMemory is a Memory which can copy ranges of bytes faster than one byte at a time.
+// ReadBytes, WriteBytes, ReadWords, and WriteWords use these methods when they are available.
+//
+// The ranges passed to ReadRange and WriteRange never wrap around the end of the address space.
+// They behave exactly like calling Get or Set on each byte.
+type BulkMemory interface {
+	Memory
+	ReadRange(addr uint32, buf []byte)
+	WriteRange(addr uint32, data []byte)
+}
+
+// ReadBytes fills buf with the contents of memory, starting at an address.
+// Addresses wrap around at the end of the address space.
+func ReadBytes(m Memory, addr uint32, buf []byte) {
+	bulk, isBulk := m.(BulkMemory)
+	for len(buf) > 0 {
+		chunk := buf[:rangeLength(addr, len(buf))]
+		if isBulk {
+			bulk.ReadRange(addr, chunk)
+		} else {
+			for i := range chunk {
+				chunk[i] = m.Get(addr + uint32(i))
+			}
+		}
+		addr += uint32(len(chunk))
+		buf = buf[len(chunk):]
+	}
+}
+
+// WriteBytes copies data into memory, starting at an address.
+// Addresses wrap around at the end of the address space.
+func WriteBytes(m Memory, addr uint32, data []byte) {
+	bulk, isBulk := m.(BulkMemory)
+	for len(data) > 0 {
+		chunk := data[:rangeLength(addr, len(data))]
+		if isBulk {
+			bulk.WriteRange(addr, chunk)
+		} else {
+			for i, b := range chunk {
+				m.Set(addr+uint32(i), b)
+			}
+		}
+		addr += uint32(len(chunk))
+		data = data[len(chunk):]
+	}
+}
+
+// ReadWords fills words with consecutive words of memory, starting at an address.
+// The address need not be aligned.
+func ReadWords(m Memory, addr uint32, words []uint32, littleEndian bool) {
+	var buf [256]byte
+	for len(words) > 0 {
+		n := len(words)
+		if n > len(buf)/4 {
+			n = len(buf) / 4
+		}
+		ReadBytes(m, addr, buf[:4*n])
+		for i := range words[:n] {
+			if littleEndian {
+				words[i] = binary.LittleEndian.Uint32(buf[4*i:])
+			} else {
+				words[i] = binary.BigEndian.Uint32(buf[4*i:])
+			}
+		}
+		addr += uint32(4 * n)
+		words = words[n:]
+	}
+}
+
+// WriteWords stores consecutive words in memory, starting at an address.
+// The address need not be aligned.
+func WriteWords(m Memory, addr uint32, words []uint32, littleEndian bool) {
+	var buf [256]byte
+	for len(words) > 0 {
+		n := len(words)
+		if n > len(buf)/4 {
+			n = len(buf) / 4
+		}
+		for i, word := range words[:n] {
+			if littleEndian {
+				binary.LittleEndian.PutUint32(buf[4*i:], word)
+			} else {
+				binary.BigEndian.PutUint32(buf[4*i:], word)
+			}
+		}
+		WriteBytes(m, addr, buf[:4*n])
+		addr += uint32(4 * n)
+		words = words[n:]
+	}
+}
+
+// rangeLength returns how many of n bytes starting at an address come before the end of the
+// address space.
+func rangeLength(addr uint32, n int) int {
+	if remaining := (1 << 32) - uint64(addr); uint64(n) > remaining {
+		return int(remaining)
+	}
+	return n
+}
+
+func (l *LazyMemory) ReadRange(addr uint32, buf []byte) {
+	for len(buf) > 0 {
+		offset := addr & 0xfff
+		n := pageRemainder(offset, len(buf))
+		if data := l.pages[addr&0xfffff000]; data != nil {
+			copy(buf[:n], data[offset:])
+		} else {
+			for i := range buf[:n] {
+				buf[i] = 0
+			}
+		}
+		addr += uint32(n)
+		buf = buf[n:]
+	}
+}
+
+func (l *LazyMemory) WriteRange(addr uint32, data []byte) {
+	for len(data) > 0 {
+		offset := addr & 0xfff
+		n := pageRemainder(offset, len(data))
+		copy(l.writablePage(addr)[offset:], data[:n])
+		addr += uint32(n)
+		data = data[n:]
+	}
+}
+
+// pageRemainder returns how many of n bytes starting at an offset within a page are in the page.
+func pageRemainder(offset uint32, n int) int {
+	if remaining := int(0x1000 - offset); n > remaining {
+		return remaining
+	}
+	return n
+}
+
+func (f *FlatMemory) ReadRange(addr uint32, buf []byte) {
+	offset, data := f.overlap(addr, len(buf))
+	for i := range buf {
+		buf[i] = 0
+	}
+	copy(buf[offset:], data)
+}
+
+func (f *FlatMemory) WriteRange(addr uint32, data []byte) {
+	offset, dest := f.overlap(addr, len(data))
+	copy(dest, data[offset:])
+}
+
+// overlap finds the part of Data within a range of addresses, along with its offset from the
+// start of the range.
+func (f *FlatMemory) overlap(addr uint32, size int) (offset int, data []byte) {
+	start, end := uint64(addr), uint64(addr)+uint64(size)
+	base, top := uint64(f.Base), uint64(f.Base)+uint64(len(f.Data))
+	if start < base {
+		offset = int(base - start)
+		start = base
+	}
+	if end > top {
+		end = top
+	}
+	if start >= end {
+		return 0, nil
+	}
+	return offset, f.Data[start-base : end-base]
+}
+
+func (m *MMIORouter) ReadRange(addr uint32, buf []byte) {
+	m.splitRange(addr, len(buf), func(dev Memory, start uint32, offset, size int) {
+		if dev == nil {
+			for i := range buf[offset : offset+size] {
+				buf[offset+i] = 0
+			}
+		} else {
+			ReadBytes(dev, start, buf[offset:offset+size])
+		}
+	})
+}
+
+func (m *MMIORouter) WriteRange(addr uint32, data []byte) {
+	m.splitRange(addr, len(data), func(dev Memory, start uint32, offset, size int) {
+		if dev != nil {
+			WriteBytes(dev, start, data[offset:offset+size])
+		}
+	})
+}
+
+// splitRange divides a range of addresses into pieces which each go to one device, calling f
+// with each piece's device (which may be nil), its first address, and its offset and size
+// within the range.
+func (m *MMIORouter) splitRange(addr uint32, size int, f func(dev Memory, start uint32,
+	offset, size int)) {
+	cur, end := uint64(addr), uint64(addr)+uint64(size)
+	for cur < end {
+		idx := sort.Search(len(m.regions), func(i int) bool {
+			return uint64(m.regions[i].start)+uint64(m.regions[i].size) > cur
+		})
+		dev, pieceEnd := m.Default, end
+		if idx < len(m.regions) {
+			region := m.regions[idx]
+			if uint64(region.start) <= cur {
+				dev = region.device
+				pieceEnd = uint64(region.start) + uint64(region.size)
+			} else {
+				pieceEnd = uint64(region.start)
+			}
+			if pieceEnd > end {
+				pieceEnd = end
+			}
+		}
+		f(dev, uint32(cur), int(cur-uint64(addr)), int(pieceEnd-cur))
+		cur = pieceEnd
+	}
+}
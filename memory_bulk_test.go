@@ -0,0 +1,105 @@
+package mips32
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// byteMemory is a Memory without bulk methods.
+type byteMemory map[uint32]byte
+
+func (b byteMemory) Get(ptr uint32) byte {
+	return b[ptr]
+}
+
+func (b byteMemory) Set(ptr uint32, val byte) {
+	b[ptr] = val
+}
+
+func TestBulkMemory(t *testing.T) {
+	newRouter := func() Memory {
+		router := &MMIORouter{Default: NewLazyMemory()}
+		if err := router.Map(0x2000, 0x10, NewFlatMemory(0x2000, 0x10)); err != nil {
+			t.Fatal(err)
+		}
+		if err := router.Map(0x2010, 0x20, NewFlatMemory(0x2010, 0x20)); err != nil {
+			t.Fatal(err)
+		}
+		return router
+	}
+	memories := map[string]func() Memory{
+		"lazy":   func() Memory { return NewLazyMemory() },
+		"flat":   func() Memory { return NewFlatMemory(0x1ff8, 0x40) },
+		"router": newRouter,
+		"bytes":  func() Memory { return byteMemory{} },
+	}
+	ranges := []struct {
+		addr uint32
+		size int
+	}{
+		{0x1ff0, 0x80},
+		{0x2008, 0x20},
+		{0xfffffffc, 8},
+		{0x0ffe, 0x1004},
+	}
+	for name, newMemory := range memories {
+		for _, r := range ranges {
+			data := make([]byte, r.size)
+			rand.Read(data)
+			bulk, single := newMemory(), newMemory()
+			WriteBytes(bulk, r.addr, data)
+			for i, b := range data {
+				single.Set(r.addr+uint32(i), b)
+			}
+
+			// Read a slightly larger range to check the bytes around the edges.
+			expected := make([]byte, r.size+8)
+			for i := range expected {
+				expected[i] = single.Get(r.addr - 4 + uint32(i))
+			}
+			actual := make([]byte, len(expected))
+			ReadBytes(bulk, r.addr-4, actual)
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("%s: unexpected bytes at %08x", name, r.addr)
+			}
+		}
+	}
+}
+
+func TestBulkMemoryWords(t *testing.T) {
+	mem := NewLazyMemory()
+	WriteWords(mem, 0xffe, []uint32{0x12345678, 0x9abcdef0}, false)
+	expected := []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+	actual := make([]byte, len(expected))
+	ReadBytes(mem, 0xffe, actual)
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("unexpected bytes: %v", actual)
+	}
+
+	words := make([]uint32, 2)
+	ReadWords(mem, 0xffe, words, true)
+	if words[0] != 0x78563412 || words[1] != 0xf0debc9a {
+		t.Errorf("unexpected words: %08x", words)
+	}
+
+	// Writes to a clone must not show up in the original.
+	clone := mem.Clone()
+	WriteWords(clone, 0xffe, []uint32{0}, false)
+	ReadWords(mem, 0xffe, words, false)
+	if words[0] != 0x12345678 {
+		t.Errorf("clone changed the original: %08x", words[0])
+	}
+}
+
+func BenchmarkReadBytes(b *testing.B) {
+	mem := NewLazyMemory()
+	buf := make([]byte, 0x10000)
+	WriteBytes(mem, 0x10000, buf)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ReadBytes(mem, 0x10000, buf)
+	}
+}
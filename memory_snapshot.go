@@ -0,0 +1,79 @@
+package mips32
+
+import "sort"
+
+// A MemorySnapshot is a copy of a LazyMemory's contents at one point in time.
+// Later writes to the memory do not affect the snapshot.
+type MemorySnapshot struct {
+	pages map[uint32][]byte
+}
+
+// Snapshot copies the memory's contents.
+func (l *LazyMemory) Snapshot() *MemorySnapshot {
+	res := &MemorySnapshot{pages: map[uint32][]byte{}}
+	for addr, data := range l.pages {
+		res.pages[addr] = append([]byte{}, data...)
+	}
+	return res
+}
+
+// Get returns the byte at an address when the snapshot was taken.
+func (m *MemorySnapshot) Get(ptr uint32) byte {
+	if data := m.pages[ptr&0xfffff000]; data != nil {
+		return data[ptr&0xfff]
+	}
+	return 0
+}
+
+// A MemoryChange records a word which differs between two snapshots.
+type MemoryChange struct {
+	// Address is the word-aligned address of the word.
+	Address uint32
+
+	Old uint32
+	New uint32
+}
+
+// DiffSnapshots finds the words whose values differ between two snapshots.
+// The changes are sorted by address.
+//
+// The littleEndian argument determines how bytes are combined into words.
+func DiffSnapshots(old, new *MemorySnapshot, littleEndian bool) []MemoryChange {
+	pageSet := map[uint32]bool{}
+	for addr := range old.pages {
+		pageSet[addr] = true
+	}
+	for addr := range new.pages {
+		pageSet[addr] = true
+	}
+	pages := make(uint32List, 0, len(pageSet))
+	for addr := range pageSet {
+		pages = append(pages, addr)
+	}
+	sort.Sort(pages)
+
+	var res []MemoryChange
+	for _, page := range pages {
+		for addr := page; addr < page+0x1000; addr += 4 {
+			oldWord := snapshotWord(old, addr, littleEndian)
+			newWord := snapshotWord(new, addr, littleEndian)
+			if oldWord != newWord {
+				res = append(res, MemoryChange{Address: addr, Old: oldWord, New: newWord})
+			}
+		}
+	}
+	return res
+}
+
+func snapshotWord(m *MemorySnapshot, addr uint32, littleEndian bool) uint32 {
+	var res uint32
+	for i := uint32(0); i < 4; i++ {
+		b := uint32(m.Get(addr + i))
+		if littleEndian {
+			res |= b << (8 * i)
+		} else {
+			res |= b << (8 * (3 - i))
+		}
+	}
+	return res
+}
@@ -0,0 +1,41 @@
+package mips32
+
+import "testing"
+
+func TestDiffSnapshots(t *testing.T) {
+	mem := NewLazyMemory()
+	mem.Set(0x1000, 1)
+	mem.Set(0x5003, 2)
+	before := mem.Snapshot()
+
+	mem.Set(0x1000, 3)
+	mem.Set(0x1001, 4)
+	mem.Set(0x5003, 2)
+	mem.Set(0x9006, 5)
+	mem.Set(0x200, 6)
+	after := mem.Snapshot()
+
+	if before.Get(0x1000) != 1 || after.Get(0x1000) != 3 || before.Get(0x9006) != 0 {
+		t.Fatal("snapshot changed after it was taken")
+	}
+
+	expected := []MemoryChange{
+		{Address: 0x200, Old: 0, New: 6},
+		{Address: 0x1000, Old: 1, New: 0x0403},
+		{Address: 0x9004, Old: 0, New: 0x050000},
+	}
+	actual := DiffSnapshots(before, after, true)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+	for i, x := range expected {
+		if actual[i] != x {
+			t.Errorf("change %d: expected %v but got %v", i, x, actual[i])
+		}
+	}
+
+	bigEndian := DiffSnapshots(before, after, false)
+	if len(bigEndian) != 3 || bigEndian[1].Old != 0x01000000 || bigEndian[1].New != 0x03040000 {
+		t.Errorf("unexpected big-endian changes: %v", bigEndian)
+	}
+}
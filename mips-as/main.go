@@ -1,72 +1,390 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/unixpickle/mips32"
 )
 
 func main() {
 	var littleEndian bool
-	flag.BoolVar(&littleEndian, "little", false, "encode instructions as little endian")
+	flag.BoolVar(&littleEndian, "little", false, "encode instructions and data as little endian")
+
+	var format string
+	flag.StringVar(&format, "format", "bin",
+		"output format (bin, hex, elf, listing, dot, cfg, or calls)")
+
+	var baseAddress uint64
+	flag.Uint64Var(&baseAddress, "base", 0,
+		"address of the first byte of binary output (default: the -start address)")
+
+	var startAddress uint64
+	flag.Uint64Var(&startAddress, "start", 0, "address of the code before the first .text directive")
+
+	var sections sectionFlag
+	flag.Var(&sections, "section", "region for .section directives (name=base[,size[,align]])")
+
+	var autoNOP bool
+	flag.BoolVar(&autoNOP, "autonop", false, "same as -dialect nodelay")
+
+	var dialect string
+	flag.StringVar(&dialect, "dialect", "standard",
+		"assembly dialect (standard, or nodelay to insert a NOP after every branch and jump)")
+
+	var defines defineFlag
+	flag.Var(&defines, "D", "define a constant operand (name=value, or name for 1)")
+
+	var optimize bool
+	flag.BoolVar(&optimize, "optimize", false, "run the peephole optimizer and report its changes")
+
+	var relax bool
+	flag.BoolVar(&relax, "relax", false, "rewrite branches and jumps which cannot reach targets")
+
+	var strip bool
+	flag.BoolVar(&strip, "strip", false, "remove code which can never run (see mips32.DeadCode)")
+
+	var checksum string
+	flag.StringVar(&checksum, "checksum", "",
+		"print a checksum (crc32 or fletcher32) of each segment, or add them to a listing")
 
 	flag.Parse()
-	if len(flag.Args()) != 2 {
+	if len(flag.Args()) < 2 {
 		dieUsage()
 	}
 
-	inFile := flag.Args()[0]
-	outFile := flag.Args()[1]
-
-	source, err := ioutil.ReadFile(inFile)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if startAddress > math.MaxUint32 || baseAddress > math.MaxUint32 {
+		fmt.Fprintln(os.Stderr, "address out of range")
 		os.Exit(1)
 	}
+	baseSet := false
+	flag.Visit(func(f *flag.Flag) {
+		baseSet = baseSet || f.Name == "base"
+	})
+	if !baseSet {
+		baseAddress = startAddress
+	}
 
-	tokenized, err := mips32.TokenizeSource(string(source))
+	inFiles := flag.Args()[:len(flag.Args())-1]
+	outFile := flag.Args()[len(flag.Args())-1]
+
+	source, files, err := readSources(inFiles)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	executable, err := mips32.ParseExecutable(tokenized)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	opts := &mips32.AssembleOptions{
+		LittleEndian: littleEndian,
+		Constants:    defines,
+		Optimize:     optimize,
+		OnOptimize: func(opt *mips32.Optimization) {
+			name, line := files.find(opt.Line)
+			local := mips32.Optimization{Line: line, Message: opt.Message}
+			fmt.Fprintln(os.Stderr, name+": "+local.String())
+		},
+		Relax:       relax,
+		BaseAddress: uint32(startAddress),
+	}
+	switch dialect {
+	case "standard":
+	case "nodelay":
+		opts.Dialect = mips32.DialectNoDelaySlots
+	default:
+		fmt.Fprintln(os.Stderr, "unknown dialect: "+dialect)
 		os.Exit(1)
 	}
+	if autoNOP {
+		opts.Dialect = mips32.DialectNoDelaySlots
+	}
+	if len(sections) > 0 {
+		opts.Layout = &mips32.Layout{Regions: sections}
+	}
 
-	output, err := os.Create(outFile)
+	executable, err := mips32.Assemble(source, opts)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, files.locate(err))
 		os.Exit(1)
 	}
-	defer output.Close()
+	if strip {
+		executable = mips32.StripDeadCode(executable)
+	}
 
-	for addr := uint32(0); addr < executable.End(); addr += 4 {
-		inst := executable.Get(addr)
-		if inst == nil {
-			output.Write([]byte{0, 0, 0, 0})
-			continue
+	var sums []mips32.SegmentChecksum
+	if checksum != "" {
+		alg, err := mips32.ParseChecksumAlgorithm(checksum)
+		if err == nil {
+			sums, err = executable.Checksums(littleEndian, alg)
 		}
-		enc, err := inst.Encode(addr, executable.Symbols)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		if littleEndian {
-			output.Write([]byte{byte(enc), byte(enc >> 8), byte(enc >> 16), byte(enc >> 24)})
-		} else {
-			output.Write([]byte{byte(enc >> 24), byte(enc >> 16), byte(enc >> 8), byte(enc)})
+		if format != "listing" {
+			for _, sum := range sums {
+				fmt.Println(sum)
+			}
 		}
 	}
+
+	output, err := os.Create(outFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer output.Close()
+
+	switch format {
+	case "bin":
+		err = writeBinary(output, executable, uint32(baseAddress), littleEndian)
+	case "hex":
+		err = writeHex(output, executable, littleEndian)
+	case "elf":
+		err = writeELF(output, executable, littleEndian)
+	case "listing":
+		err = writeListing(output, executable, checksum, sums)
+	case "dot":
+		_, err = io.WriteString(output, mips32.BuildCFG(executable).DOT(executable))
+	case "cfg":
+		err = json.NewEncoder(output).Encode(mips32.BuildCFG(executable))
+	case "calls":
+		_, err = io.WriteString(output, mips32.BuildCallGraph(executable).DOT(executable))
+	default:
+		err = errors.New("unknown format: " + format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func dieUsage() {
-	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <in.s> <out.bin>")
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <in.s> [in2.s ...] <out.bin>")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
+
+func writeBinary(w io.Writer, e *mips32.Executable, base uint32, little bool) error {
+	chunks, err := e.EncodeBytes(little)
+	if err != nil {
+		return err
+	}
+	starts := make([]uint32, 0, len(chunks))
+	for start := range chunks {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+	addr := base
+	for _, start := range starts {
+		if start < base {
+			return errors.New("segment at " + hexString(start) + " is below base address")
+		}
+		if _, err := w.Write(make([]byte, start-addr)); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunks[start]); err != nil {
+			return err
+		}
+		addr = start + uint32(len(chunks[start]))
+	}
+	return nil
+}
+
+func writeHex(w io.Writer, e *mips32.Executable, little bool) error {
+	chunks, err := e.EncodeBytes(little)
+	if err != nil {
+		return err
+	}
+	return mips32.WriteIntelHex(w, chunks)
+}
+
+func writeELF(w io.Writer, e *mips32.Executable, little bool) error {
+	image, err := e.ELFImage(little)
+	if err != nil {
+		return err
+	}
+	return mips32.WriteELF(w, image)
+}
+
+// writeListing writes the address, encoding, and assembly code of each instruction.
+// If sums is non-empty, each segment ends with a comment giving its checksum.
+func writeListing(w io.Writer, e *mips32.Executable, alg string,
+	sums []mips32.SegmentChecksum) error {
+	sumsByAddress := map[uint32]mips32.SegmentChecksum{}
+	for _, sum := range sums {
+		sumsByAddress[sum.Address] = sum
+	}
+
+	symbolsByAddress := map[uint32][]string{}
+	for sym, addr := range e.Symbols {
+		symbolsByAddress[addr] = append(symbolsByAddress[addr], sym)
+	}
+
+	for _, segment := range e.OrderedSegments() {
+		for i, inst := range segment.Instructions {
+			addr := segment.Address + uint32(i*4)
+			syms := symbolsByAddress[addr]
+			sort.Strings(syms)
+			for _, sym := range syms {
+				fmt.Fprintln(w, sym+":")
+			}
+			enc, err := inst.Encode(addr, e.Symbols)
+			if err != nil {
+				return errors.New("failed to encode instruction at " + hexString(addr) + ": " +
+					err.Error())
+			}
+			rendered, err := inst.Render()
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, hexString(addr)+"  "+hexString(enc)+"  "+rendered.String())
+			if err != nil {
+				return err
+			}
+		}
+		if sum, ok := sumsByAddress[segment.Address]; ok {
+			if _, err := fmt.Fprintln(w, "# "+alg+" "+sum.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
+
+// sectionFlag collects the regions passed with -section flags.
+type sectionFlag []mips32.Region
+
+func (s *sectionFlag) String() string {
+	var parts []string
+	for _, region := range *s {
+		parts = append(parts, region.Name+"="+strconv.FormatUint(uint64(region.Base), 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *sectionFlag) Set(value string) error {
+	nameAndArgs := strings.SplitN(value, "=", 2)
+	if len(nameAndArgs) != 2 {
+		return errors.New("expected name=base[,size[,align]]")
+	}
+	args := strings.Split(nameAndArgs[1], ",")
+	if len(args) > 3 {
+		return errors.New("expected name=base[,size[,align]]")
+	}
+	var nums [3]uint32
+	for i, arg := range args {
+		num, err := strconv.ParseUint(arg, 0, 32)
+		if err != nil {
+			return err
+		}
+		nums[i] = uint32(num)
+	}
+	*s = append(*s, mips32.Region{
+		Name:      nameAndArgs[0],
+		Base:      nums[0],
+		Size:      nums[1],
+		Alignment: nums[2],
+	})
+	return nil
+}
+
+// readSources joins several source files into one program, as if they were concatenated.
+func readSources(paths []string) (string, sourceFiles, error) {
+	var parts []string
+	var files sourceFiles
+	line := 1
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		files = append(files, sourceFile{Name: path, FirstLine: line})
+		line += strings.Count(text, "\n") + 1
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n"), files, nil
+}
+
+// A sourceFile records where an input file starts in the joined program.
+type sourceFile struct {
+	Name      string
+	FirstLine int
+}
+
+type sourceFiles []sourceFile
+
+// find converts a line number of the joined program into a file name and a line number within
+// that file.
+func (s sourceFiles) find(line int) (string, int) {
+	for i := len(s) - 1; i > 0; i-- {
+		if line >= s[i].FirstLine {
+			return s[i].Name, line - s[i].FirstLine + 1
+		}
+	}
+	return s[0].Name, line
+}
+
+// locate rewrites an error about a line of the joined program to name the input file and the
+// line within it.
+func (s sourceFiles) locate(err error) error {
+	srcErr, ok := err.(*mips32.SourceError)
+	if !ok {
+		return err
+	}
+	local := *srcErr
+	var name string
+	name, local.Line = s.find(srcErr.Line)
+	return errors.New(name + ": " + local.Error())
+}
+
+// defineFlag collects the constants passed with -D flags.
+type defineFlag map[string]uint32
+
+func (d *defineFlag) String() string {
+	var parts []string
+	for name, value := range *d {
+		parts = append(parts, name+"="+strconv.FormatUint(uint64(value), 10))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func (d *defineFlag) Set(value string) error {
+	nameAndValue := strings.SplitN(value, "=", 2)
+	if nameAndValue[0] == "" {
+		return errors.New("expected name=value")
+	}
+	var num uint32 = 1
+	if len(nameAndValue) == 2 {
+		parsed, err := strconv.ParseInt(nameAndValue[1], 0, 64)
+		if err != nil || parsed < -(1<<31) || parsed >= 1<<32 {
+			return errors.New("invalid value: " + nameAndValue[1])
+		}
+		num = uint32(parsed)
+	}
+	if *d == nil {
+		*d = defineFlag{}
+	}
+	(*d)[nameAndValue[0]] = num
+	return nil
+}
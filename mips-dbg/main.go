@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+const (
+	listingLines      = 9
+	memoryDumpColumns = 16
+
+	// maxCallSteps limits how long "next", "finish", and "until" run, in case the program never
+	// gets where it is going.
+	maxCallSteps = 1000000
+)
+
+type debugger struct {
+	executable   *mips32.Executable
+	source       []string
+	littleEndian bool
+	alignment    bool
+
+	// out receives the output of commands and of the program.
+	out io.Writer
+
+	// screen is the full-screen interface, or nil when using the plain command prompt.
+	screen *screen
+
+	// stdin is shared by the command prompt and the program's syscalls, so that neither reads
+	// ahead into the other's input.
+	stdin *bufio.Reader
+
+	emulator    *mips32.Emulator
+	breakpoints mips32.Breakpoints
+	callStack   mips32.CallStack
+	steps       int
+}
+
+func main() {
+	var littleEndian bool
+	flag.BoolVar(&littleEndian, "little", false, "use little endian memory")
+
+	var relaxAlignment bool
+	flag.BoolVar(&relaxAlignment, "misaligned", false, "allow misaligned memory access")
+
+	var plain bool
+	flag.BoolVar(&plain, "plain", false, "use a line-oriented prompt, not the full-screen view")
+
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		dieUsage()
+	}
+
+	contents, err := ioutil.ReadFile(flag.Args()[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := &mips32.AssembleOptions{LittleEndian: littleEndian}
+	exc, err := mips32.Assemble(string(contents), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	d := &debugger{
+		executable:   exc,
+		source:       strings.Split(string(contents), "\n"),
+		littleEndian: littleEndian,
+		alignment:    !relaxAlignment,
+		out:          os.Stdout,
+		stdin:        bufio.NewReader(os.Stdin),
+	}
+	if !plain && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		d.screen = newScreen()
+		d.out = d.screen
+	}
+	if err := d.reset(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if d.screen != nil {
+		d.runScreen()
+	} else {
+		d.runPrompt()
+	}
+}
+
+func dieUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s>")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// runPrompt reads commands from a plain, line-oriented prompt.
+func (d *debugger) runPrompt() {
+	d.printListing(d.emulator.ProgramCounter)
+	for {
+		fmt.Print("(mips-dbg) ")
+		line, err := d.stdin.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println()
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "q" || fields[0] == "quit" {
+			return
+		}
+		if err := d.runCommand(fields[0], fields[1:]); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+}
+
+func (d *debugger) runCommand(name string, args []string) error {
+	switch name {
+	case "h", "help":
+		printHelp(d.out)
+	case "s", "step":
+		count := 1
+		if len(args) > 0 {
+			var err error
+			if count, err = strconv.Atoi(args[0]); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < count && !d.emulator.Done(); i++ {
+			if err := d.step(); err != nil {
+				return err
+			}
+		}
+		d.printListing(d.emulator.ProgramCounter)
+	case "n", "next", "finish", "u", "until":
+		var steps int
+		var err error
+		if name == "finish" {
+			steps, err = d.emulator.StepOut(maxCallSteps, &d.breakpoints)
+		} else if name == "u" || name == "until" {
+			if len(args) != 1 {
+				return errors.New("expected an address or symbol")
+			}
+			addr, parseErr := d.parseAddress(args[0])
+			if parseErr != nil {
+				return parseErr
+			}
+			steps, err = d.emulator.RunUntil(addr, maxCallSteps, &d.breakpoints)
+		} else {
+			steps, err = d.emulator.StepOver(maxCallSteps, &d.breakpoints)
+		}
+		d.steps += steps
+		if err != nil {
+			return err
+		}
+		d.printListing(d.emulator.ProgramCounter)
+	case "c", "continue":
+		if !d.emulator.Done() {
+			if err := d.step(); err != nil {
+				return err
+			}
+		}
+		for !d.emulator.Done() && !d.breakpoints.Has(d.emulator.ProgramCounter) {
+			if err := d.step(); err != nil {
+				return err
+			}
+		}
+		if d.emulator.Done() {
+			fmt.Fprintln(d.out, "program finished after", d.steps, "steps")
+		}
+		d.printListing(d.emulator.ProgramCounter)
+	case "b", "break", "d", "delete":
+		if len(args) != 1 {
+			return errors.New("expected an address or symbol")
+		}
+		addr, err := d.parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		if name == "b" || name == "break" {
+			d.breakpoints.Set(addr)
+		} else {
+			d.breakpoints.Clear(addr)
+		}
+	case "bt", "backtrace":
+		d.printBacktrace()
+	case "i", "info":
+		d.printBreakpoints()
+	case "r", "regs":
+		fmt.Fprintln(d.out, d.emulator.RegisterFile.String())
+		fmt.Fprintln(d.out, "pc  = "+hexString(d.emulator.ProgramCounter))
+	case "l", "list":
+		addr := d.emulator.ProgramCounter
+		if len(args) > 0 {
+			var err error
+			if addr, err = d.parseAddress(args[0]); err != nil {
+				return err
+			}
+		}
+		d.printListing(addr)
+	case "m", "mem":
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("expected an address and an optional size")
+		}
+		addr, err := d.parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		size := uint64(64)
+		if len(args) == 2 {
+			if size, err = strconv.ParseUint(args[1], 0, 32); err != nil {
+				return err
+			}
+		}
+		d.dumpMemory(addr, uint32(size))
+	case "p", "print":
+		if len(args) == 0 {
+			return errors.New("expected an expression")
+		}
+		val, err := d.emulator.Evaluate(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(d.out, hexString(val), "=", val, "=", int32(val))
+	case "set":
+		return d.setValue(args)
+	case "reset":
+		if err := d.reset(); err != nil {
+			return err
+		}
+		d.printListing(d.emulator.ProgramCounter)
+	case "save", "load":
+		if len(args) != 1 {
+			return errors.New("expected a file name")
+		}
+		if name == "save" {
+			return d.saveSnapshot(args[0])
+		}
+		if err := d.loadSnapshot(args[0]); err != nil {
+			return err
+		}
+		d.printListing(d.emulator.ProgramCounter)
+	default:
+		return errors.New("unknown command: " + name + " (try \"help\")")
+	}
+	return nil
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, `Commands:
+  step [n]          run n instructions (default 1)
+  next              run one instruction, stepping over function calls
+  finish            run until the current function returns
+  until ADDR        run until the program reaches an address
+  continue          run until a breakpoint or the end of the program
+  break ADDR        set a breakpoint at an address, symbol, or symbol+offset
+  delete ADDR       remove a breakpoint
+  backtrace         list the active function calls
+  info              list breakpoints
+  regs              print the register file
+  list [ADDR]       show the code around an address (default: the PC)
+  mem ADDR [SIZE]   dump memory (or show it in the memory pane)
+  print EXPR        evaluate an expression like $sp+4 or [ARRAY+8]
+  set $REG VALUE    change a register
+  set ADDR VALUE    change a byte of memory
+  reset             restart the program
+  save FILE         save the registers and memory to a snapshot file
+  load FILE         restore the registers and memory from a snapshot file
+  quit              exit the debugger`)
+}
+
+func (d *debugger) reset() error {
+	memory := mips32.NewLazyMemory()
+	if err := d.executable.LoadInto(memory, d.littleEndian); err != nil {
+		return err
+	}
+	d.emulator = &mips32.Emulator{
+		Memory:            memory,
+		Executable:        d.executable,
+		LittleEndian:      d.littleEndian,
+		ForceMemAlignment: d.alignment,
+	}
+	syscalls := &mips32.SPIMSyscalls{Input: d.stdin, Output: d.out}
+	if d.screen != nil {
+		d.emulator.Syscalls = &screenSyscalls{debugger: d, syscalls: syscalls}
+	} else {
+		d.emulator.Syscalls = syscalls
+	}
+	d.steps = 0
+	d.callStack.Reset()
+	emulator := d.emulator
+	emulator.AfterExecute = func(inst *mips32.Instruction, addr uint32) {
+		d.callStack.Observe(emulator, inst, addr)
+	}
+	return nil
+}
+
+func (d *debugger) saveSnapshot(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := d.emulator.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadSnapshot restores a snapshot.
+// The call stack is forgotten, since snapshots do not record it.
+func (d *debugger) loadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := d.emulator.ReadSnapshot(f); err != nil {
+		return err
+	}
+	d.callStack.Reset()
+	return nil
+}
+
+func (d *debugger) step() error {
+	d.steps++
+	return d.emulator.Step()
+}
+
+func (d *debugger) setValue(args []string) error {
+	if len(args) != 2 {
+		return errors.New("expected a destination and a value")
+	}
+	value, err := d.parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(args[0], "$") {
+		token, err := mips32.ParseArgToken(args[0])
+		if err != nil {
+			return err
+		}
+		reg, ok := token.Register()
+		if !ok {
+			return errors.New("invalid register: " + args[0])
+		} else if reg != 0 {
+			d.emulator.RegisterFile[reg] = value
+		}
+		return nil
+	}
+	addr, err := d.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	d.emulator.Memory.Set(addr, byte(value))
+	return nil
+}
+
+// parseAddress parses a number, a symbol name, or a symbol plus an offset.
+func (d *debugger) parseAddress(s string) (uint32, error) {
+	return d.executable.ResolveLocation(s)
+}
+
+// printListing disassembles the instructions around an address.
+// With the full-screen view, it moves the code pane to the address instead.
+func (d *debugger) printListing(center uint32) {
+	if d.screen != nil {
+		d.screen.codeCenter = center
+		return
+	}
+	for _, line := range d.listing(center, listingLines) {
+		fmt.Fprintln(d.out, line)
+	}
+}
+
+// listing disassembles a number of instructions around an address, along with the labels
+// before them.
+func (d *debugger) listing(center uint32, count int) []string {
+	var lines []string
+	start := center &^ 3
+	if start/4 > uint32(count/2) {
+		start -= uint32(count/2) * 4
+	} else {
+		start = 0
+	}
+	symbols := map[uint32][]string{}
+	for name, addr := range d.executable.Symbols {
+		symbols[addr] = append(symbols[addr], name)
+	}
+	for i := 0; i < count; i++ {
+		addr := start + uint32(i*4)
+		names := symbols[addr]
+		sort.Strings(names)
+		for _, name := range names {
+			lines = append(lines, "            "+name+":")
+		}
+		marker := "  "
+		if addr == d.emulator.ProgramCounter {
+			marker = "=>"
+		}
+		bpMarker := " "
+		if d.breakpoints.Has(addr) {
+			bpMarker = "*"
+		}
+		lines = append(lines, bpMarker+marker+" "+hexString(addr)+"  "+d.renderAt(addr))
+	}
+	return lines
+}
+
+func (d *debugger) renderAt(addr uint32) string {
+	inst := d.executable.Get(addr)
+	if inst == nil {
+		return "NOP"
+	}
+	rendering, err := inst.Render()
+	if err != nil {
+		return "(unknown)"
+	}
+	return rendering.String()
+}
+
+func (d *debugger) printBreakpoints() {
+	addrs := d.breakpoints.List()
+	if len(addrs) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+		return
+	}
+	for _, addr := range addrs {
+		fmt.Fprintln(d.out, hexString(addr)+"  "+d.renderAt(addr))
+	}
+}
+
+func (d *debugger) printBacktrace() {
+	frames := d.callStack.Frames
+	if len(frames) == 0 {
+		fmt.Fprintln(d.out, "no active calls")
+		return
+	}
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		exc := d.executable
+		fmt.Fprintln(d.out, "#"+strconv.Itoa(len(frames)-1-i)+"  "+
+			exc.SymbolicAddress(frame.Function)+
+			" (returns to "+exc.SymbolicAddress(frame.ReturnAddress)+")")
+	}
+}
+
+// dumpMemory prints the contents of memory.
+// With the full-screen view, it moves the memory pane to the address instead.
+func (d *debugger) dumpMemory(start, size uint32) {
+	if d.screen != nil {
+		d.screen.memoryAddr = start
+		return
+	}
+	mem := d.emulator.Memory
+	// The uint64 conversions deal with the case when start+size would overflow.
+	for row := uint64(start); row < uint64(start)+uint64(size); row += memoryDumpColumns {
+		line := hexString(uint32(row)) + " "
+		rowSize := uint64(start) + uint64(size) - row
+		if rowSize > memoryDumpColumns {
+			rowSize = memoryDumpColumns
+		}
+		data := make([]byte, rowSize)
+		mips32.ReadBytes(mem, uint32(row), data)
+		for _, value := range data {
+			b := strconv.FormatUint(uint64(value), 16)
+			if len(b) < 2 {
+				b = "0" + b
+			}
+			line += " " + b
+		}
+		fmt.Fprintln(d.out, line)
+	}
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+const (
+	defaultScreenWidth  = 80
+	defaultScreenHeight = 24
+
+	// maxLogLines is the number of lines of output which the screen remembers.
+	maxLogLines = 200
+
+	registerColumns = 4
+	registerWidth   = 18
+)
+
+// ANSI escape sequences for drawing the screen.
+const (
+	enterAltScreen = "\x1b[?1049h"
+	exitAltScreen  = "\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+	reverseVideo   = "\x1b[7m"
+	normalVideo    = "\x1b[0m"
+)
+
+// A screen is a full-screen view of the debugger, with panes for the code, the registers,
+// memory, and the output of commands and of the program.
+//
+// It is redrawn with ANSI escape sequences before each command, and commands are typed at a
+// prompt on the bottom of the screen.
+type screen struct {
+	width  int
+	height int
+
+	// codeCenter is the address which the code pane shows.
+	codeCenter uint32
+
+	// memoryAddr is the first address which the memory pane shows.
+	memoryAddr uint32
+
+	// log holds the recent output, one line per entry.
+	// The last entry is the line which is still being written.
+	log []string
+}
+
+func newScreen() *screen {
+	s := &screen{width: defaultScreenWidth, height: defaultScreenHeight, log: []string{""}}
+	s.readSize()
+	return s
+}
+
+// Write adds output to the output pane.
+func (s *screen) Write(data []byte) (int, error) {
+	for _, ch := range strings.Replace(string(data), "\r\n", "\n", -1) {
+		if ch == '\n' {
+			s.log = append(s.log, "")
+		} else {
+			s.log[len(s.log)-1] += string(ch)
+		}
+	}
+	if len(s.log) > maxLogLines {
+		s.log = append([]string{}, s.log[len(s.log)-maxLogLines:]...)
+	}
+	return len(data), nil
+}
+
+// endLine starts a new line in the output pane, unless the current one is empty.
+func (s *screen) endLine() {
+	if s.log[len(s.log)-1] != "" {
+		s.log = append(s.log, "")
+	}
+}
+
+// readSize asks the terminal for its size, keeping the defaults if it cannot be found.
+func (s *screen) readSize() {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return
+	}
+	height, err1 := strconv.Atoi(fields[0])
+	width, err2 := strconv.Atoi(fields[1])
+	if err1 == nil && err2 == nil && height >= defaultScreenHeight && width > 0 {
+		s.width = width
+		s.height = height
+	}
+}
+
+// runScreen reads commands at the bottom of the full-screen view until the user quits.
+func (d *debugger) runScreen() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		fmt.Print(exitAltScreen)
+		os.Exit(1)
+	}()
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(exitAltScreen)
+
+	fmt.Fprintln(d.out, "Type \"help\" for a list of commands.")
+	d.screen.codeCenter = d.emulator.ProgramCounter
+	for {
+		d.draw("(mips-dbg) ")
+		line, err := d.stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		d.screen.endLine()
+		fmt.Fprintln(d.out, "(mips-dbg) "+strings.Join(fields, " "))
+		if fields[0] == "q" || fields[0] == "quit" {
+			return
+		}
+		if err := d.runCommand(fields[0], fields[1:]); err != nil {
+			fmt.Fprintln(d.out, "error:", err)
+		}
+		if fields[0] != "l" && fields[0] != "list" {
+			// The code pane follows the PC, unless it was just moved with "list".
+			d.screen.codeCenter = d.emulator.ProgramCounter
+		}
+	}
+}
+
+// draw redraws every pane and leaves the cursor after a prompt on the bottom line.
+func (d *debugger) draw(prompt string) {
+	s := d.screen
+
+	// The bottom line is kept free, since pressing enter at the prompt moves the cursor down.
+	registerRows := (len(mips32.RegisterFile{}) + registerColumns - 1) / registerColumns
+	free := s.height - 1 - (registerRows + 1) - 3 - 2
+	memoryRows := free / 4
+	if memoryRows > 4 {
+		memoryRows = 4
+	}
+	logRows := free / 4
+	codeRows := free - memoryRows - logRows
+
+	var lines []string
+	lines = append(lines, d.titleLine())
+	lines = append(lines, d.paneTitle("Registers  pc="+hexString(d.emulator.ProgramCounter)))
+	lines = append(lines, d.registerLines(registerRows)...)
+	codeTitle, code := d.codeLines(codeRows)
+	lines = append(lines, d.paneTitle(codeTitle))
+	lines = append(lines, code...)
+	lines = append(lines, d.paneTitle("Memory"))
+	lines = append(lines, d.memoryLines(memoryRows)...)
+	lines = append(lines, d.paneTitle("Output"))
+	lines = append(lines, s.logLines(logRows)...)
+
+	var out strings.Builder
+	out.WriteString(clearScreen)
+	for _, line := range lines {
+		out.WriteString(line + "\n")
+	}
+	out.WriteString(prompt)
+	fmt.Print(out.String())
+}
+
+func (d *debugger) titleLine() string {
+	status := "running"
+	if d.emulator.Done() {
+		status = "finished"
+	}
+	title := " mips-dbg  " + d.emulator.Executable.SymbolicAddress(d.emulator.ProgramCounter) +
+		"  " + strconv.Itoa(d.steps) + " steps  " + status
+	return reverseVideo + d.screen.fit(title, true) + normalVideo
+}
+
+func (d *debugger) paneTitle(title string) string {
+	return reverseVideo + d.screen.fit(" "+title, true) + normalVideo
+}
+
+// registerLines lists the registers in columns, highlighting the ones which the last
+// instruction changed.
+func (d *debugger) registerLines(rows int) []string {
+	changed := map[int]bool{}
+	for _, reg := range d.emulator.LastDelta.Registers {
+		changed[reg] = true
+	}
+	lines := make([]string, rows)
+	for reg, value := range d.emulator.RegisterFile {
+		cell := "$" + mips32.ABIRegisterNames[reg]
+		for len(cell) < 6 {
+			cell += " "
+		}
+		cell += hexString(value)
+		padding := strings.Repeat(" ", registerWidth-len(cell))
+		if changed[reg] {
+			cell = reverseVideo + cell + normalVideo
+		}
+		lines[reg%rows] += cell + padding
+	}
+	return lines
+}
+
+// codeLines shows the source lines around the code pane's address, or disassembles the code
+// if the address has no source line.
+func (d *debugger) codeLines(rows int) (title string, lines []string) {
+	s := d.screen
+	current, ok := d.executable.LineNumbers[s.codeCenter]
+	if !ok {
+		for _, line := range d.listing(s.codeCenter, rows) {
+			lines = append(lines, s.fit(line, false))
+		}
+		for len(lines) < rows {
+			lines = append(lines, "")
+		}
+		return "Disassembly", lines[:rows]
+	}
+
+	breakpointLines := map[int]bool{}
+	for _, addr := range d.breakpoints.List() {
+		if line, ok := d.executable.LineNumbers[addr]; ok {
+			breakpointLines[line] = true
+		}
+	}
+	pcLine, pcOK := d.executable.LineNumbers[d.emulator.ProgramCounter]
+
+	start := current - rows/2
+	if start+rows > len(d.source)+1 {
+		start = len(d.source) + 1 - rows
+	}
+	if start < 1 {
+		start = 1
+	}
+	for line := start; line < start+rows; line++ {
+		if line > len(d.source) {
+			lines = append(lines, "")
+			continue
+		}
+		marker := " "
+		if breakpointLines[line] {
+			marker = "*"
+		}
+		if pcOK && line == pcLine {
+			marker += "=>"
+		} else {
+			marker += "  "
+		}
+		num := strconv.Itoa(line)
+		for len(num) < 5 {
+			num = " " + num
+		}
+		lines = append(lines, s.fit(marker+num+"  "+d.source[line-1], false))
+	}
+	return "Source", lines
+}
+
+// memoryLines dumps memory starting at the memory pane's address.
+func (d *debugger) memoryLines(rows int) []string {
+	var lines []string
+	for row := 0; row < rows; row++ {
+		addr := d.screen.memoryAddr + uint32(row*memoryDumpColumns)
+		data := make([]byte, memoryDumpColumns)
+		mips32.ReadBytes(d.emulator.Memory, addr, data)
+		line := hexString(addr) + " "
+		var text string
+		for _, value := range data {
+			b := strconv.FormatUint(uint64(value), 16)
+			if len(b) < 2 {
+				b = "0" + b
+			}
+			line += " " + b
+			if value >= 0x20 && value < 0x7f {
+				text += string(rune(value))
+			} else {
+				text += "."
+			}
+		}
+		lines = append(lines, d.screen.fit(line+"  "+text, false))
+	}
+	return lines
+}
+
+// logLines returns the last lines of output, padded to a number of rows.
+func (s *screen) logLines(rows int) []string {
+	log := s.log
+	if log[len(log)-1] == "" {
+		log = log[:len(log)-1]
+	}
+	if len(log) > rows {
+		log = log[len(log)-rows:]
+	}
+	var lines []string
+	for _, line := range log {
+		lines = append(lines, s.fit(line, false))
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// fit expands the tabs in a line and cuts it to the width of the screen.
+// If pad is true, the line is padded with spaces to the full width.
+func (s *screen) fit(line string, pad bool) string {
+	var res []rune
+	for _, ch := range line {
+		if ch == '\t' {
+			res = append(res, ' ')
+			for len(res)%8 != 0 {
+				res = append(res, ' ')
+			}
+		} else if ch >= ' ' {
+			res = append(res, ch)
+		}
+	}
+	if len(res) > s.width {
+		res = res[:s.width]
+	}
+	for pad && len(res) < s.width {
+		res = append(res, ' ')
+	}
+	return string(res)
+}
+
+// screenSyscalls runs syscalls for the full-screen view, prompting on the screen before the
+// program reads input.
+type screenSyscalls struct {
+	debugger *debugger
+	syscalls *mips32.SPIMSyscalls
+}
+
+func (s *screenSyscalls) Syscall(e *mips32.Emulator) error {
+	switch e.RegisterFile[2] {
+	case 5, 8, 12:
+		if s.debugger.stdin.Buffered() == 0 {
+			s.debugger.draw("(program input) ")
+		}
+	}
+	return s.syscalls.Syscall(e)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/unixpickle/mips32/difftest"
+)
+
+func main() {
+	var count int
+	flag.IntVar(&count, "n", 100, "number of random programs to run")
+
+	var seed int64
+	flag.Int64Var(&seed, "seed", 0, "random seed (0 to use the time)")
+
+	var littleEndian bool
+	flag.BoolVar(&littleEndian, "little", false, "use little endian memory")
+
+	config := difftest.DefaultConfig
+	flag.IntVar(&config.Instructions, "length", config.Instructions,
+		"number of random instructions per program")
+
+	flag.Parse()
+	if len(flag.Args()) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <adapter> [adapter args...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "The adapter is run with the path of a program's assembly file, "+
+			"and should print")
+		fmt.Fprintln(os.Stderr, "the final state as lines like r8=0000002a and "+
+			"m10010000=0000002a.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	reference := &difftest.Command{Name: flag.Args()[0], Args: flag.Args()[1:]}
+	r := rand.New(rand.NewSource(seed))
+	emulator := &difftest.Emulator{LittleEndian: littleEndian}
+	if failure := difftest.Run(r, &config, count, emulator, reference); failure != nil {
+		fmt.Println("Seed:", seed)
+		fmt.Println(failure)
+		os.Exit(1)
+	}
+	fmt.Println("All", count, "programs matched.")
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/unixpickle/mips32"
 )
@@ -13,57 +19,252 @@ func main() {
 	var littleEndian bool
 	flag.BoolVar(&littleEndian, "little", false, "decode instructions as little endian")
 
+	var format string
+	flag.StringVar(&format, "format", "bin", "input format (bin, hex, or elf)")
+
+	var baseAddress uint64
+	flag.Uint64Var(&baseAddress, "base", 0, "load address for raw binary input")
+
+	var symbolFile string
+	flag.StringVar(&symbolFile, "symbols", "", "file of \"address name\" lines for labels")
+
+	var style string
+	flag.StringVar(&style, "style", "plain", "output style (plain or listing)")
+
+	var registers string
+	flag.StringVar(&registers, "registers", "numeric",
+		"register spelling (numeric, abi, or prefixed)")
+
+	var hexImmediates bool
+	flag.BoolVar(&hexImmediates, "hex", false, "write constants in hexadecimal")
+
+	var lowercase bool
+	flag.BoolVar(&lowercase, "lower", false, "write instruction names in lowercase")
+
 	flag.Parse()
 	if len(flag.Args()) != 2 {
 		dieUsage()
 	}
 
+	registerStyle, err := mips32.ParseRegisterStyle(registers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := &mips32.RenderOptions{
+		Registers:          registerStyle,
+		HexImmediates:      hexImmediates,
+		LowercaseMnemonics: lowercase,
+	}
+
 	inFile := flag.Args()[0]
 	outFile := flag.Args()[1]
 
-	binary, err := ioutil.ReadFile(inFile)
+	input, err := ioutil.ReadFile(inFile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
-	} else if len(binary)&3 != 0 {
-		fmt.Fprintln(os.Stderr, "file is invalid length (must be multiple of 4)")
-		os.Exit(1)
 	}
 
-	instructions := []*mips32.Instruction{}
-	for i := 0; i < len(binary); i += 4 {
-		var instNum uint32
-		if littleEndian {
-			instNum = uint32(binary[i]) | (uint32(binary[i+1]) << 8) | (uint32(binary[i+2]) << 16) |
-				(uint32(binary[i+3]) << 24)
-		} else {
-			instNum = (uint32(binary[i]) << 24) | (uint32(binary[i+1]) << 16) |
-				(uint32(binary[i+2]) << 8) | uint32(binary[i+3])
+	var executable *mips32.Executable
+	switch format {
+	case "bin":
+		chunks := map[uint32][]byte{uint32(baseAddress): input}
+		executable, err = decodeChunks(chunks, littleEndian)
+	case "hex":
+		var chunks map[uint32][]byte
+		chunks, err = mips32.ReadIntelHex(strings.NewReader(string(input)))
+		if err == nil {
+			executable, err = decodeChunks(chunks, littleEndian)
 		}
-		inst := mips32.DecodeInstruction(instNum)
-		instructions = append(instructions, inst)
+	case "elf":
+		executable, err = readELF(input)
+	default:
+		err = errors.New("unknown format: " + format)
 	}
-
-	output, err := os.Create(outFile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	defer output.Close()
 
-	for _, inst := range instructions {
-		rendering, err := inst.Render()
+	if symbolFile != "" {
+		symbols, err := readSymbols(symbolFile)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		output.WriteString(rendering.String())
-		output.WriteString("\n")
+		for name, addr := range symbols {
+			executable.Symbols[name] = addr
+		}
+	}
+	if len(executable.Symbols) > 0 {
+		symbolize(executable)
+	}
+
+	output, err := os.Create(outFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer output.Close()
+
+	switch style {
+	case "plain":
+		err = writePlain(output, executable, opts)
+	case "listing":
+		err = writeListing(output, executable, opts)
+	default:
+		err = errors.New("unknown style: " + style)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
 func dieUsage() {
-	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <in.bin> <out.s>")
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <in.bin | in.hex | in.elf> <out.s>")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
+
+func decodeChunks(chunks map[uint32][]byte, little bool) (*mips32.Executable, error) {
+	res := &mips32.Executable{
+		Segments: map[uint32][]mips32.Instruction{},
+		Symbols:  map[string]uint32{},
+	}
+	for start, binary := range chunks {
+		if start&3 != 0 {
+			return nil, errors.New("chunk at 0x" + strconv.FormatUint(uint64(start), 16) +
+				" is misaligned")
+		} else if len(binary)&3 != 0 {
+			return nil, errors.New("file is invalid length (must be multiple of 4)")
+		}
+		instructions := make([]mips32.Instruction, 0, len(binary)/4)
+		for i := 0; i < len(binary); i += 4 {
+			var instNum uint32
+			if little {
+				instNum = uint32(binary[i]) | (uint32(binary[i+1]) << 8) |
+					(uint32(binary[i+2]) << 16) | (uint32(binary[i+3]) << 24)
+			} else {
+				instNum = (uint32(binary[i]) << 24) | (uint32(binary[i+1]) << 16) |
+					(uint32(binary[i+2]) << 8) | uint32(binary[i+3])
+			}
+			instructions = append(instructions, *mips32.DecodeInstruction(instNum))
+		}
+		res.Segments[start] = instructions
+	}
+	return res, nil
+}
+
+// readELF decodes the segments of an ELF file, in the file's byte order, and takes the labels
+// from its symbol table.
+// The entry point is labeled _start unless a symbol already marks it.
+func readELF(data []byte) (*mips32.Executable, error) {
+	img, err := mips32.ReadELF(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	res := img.Executable()
+	for _, addr := range res.Symbols {
+		if addr == img.Entry {
+			return res, nil
+		}
+	}
+	if _, ok := res.Symbols["_start"]; !ok && res.Get(img.Entry) != nil {
+		res.Symbols["_start"] = img.Entry
+	}
+	return res, nil
+}
+
+func readSymbols(path string) (map[string]uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := map[string]uint32{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 2 {
+			return nil, errors.New("invalid symbol line: " + scanner.Text())
+		}
+		addr, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "0x"), 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		res[fields[1]] = uint32(addr)
+	}
+	return res, scanner.Err()
+}
+
+// symbolize replaces constant branch and jump targets with symbols wherever possible.
+func symbolize(e *mips32.Executable) {
+	resolve := mips32.SymbolTableResolver(e.Symbols)
+	for start, insts := range e.Segments {
+		for i := range insts {
+			insts[i] = *insts[i].Symbolize(start+uint32(i*4), resolve)
+		}
+	}
+}
+
+func writePlain(w io.Writer, e *mips32.Executable, opts *mips32.RenderOptions) error {
+	lines, err := e.Render()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line.Format(opts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeListing(w io.Writer, e *mips32.Executable, opts *mips32.RenderOptions) error {
+	lines, err := e.Render()
+	if err != nil {
+		return err
+	}
+	symbols := e.SymbolIndex()
+	var addr uint32
+	for _, line := range lines {
+		if line.Directive != nil && line.Directive.Name == "text" {
+			addr = line.Directive.Constant
+			continue
+		} else if line.SymbolMarker != nil {
+			if _, err := fmt.Fprintln(w, line.Format(opts)); err != nil {
+				return err
+			}
+			continue
+		}
+		enc, err := e.Get(addr).Encode(addr, e.Symbols)
+		if err != nil {
+			return err
+		}
+		text := hexString(addr) + "  " + hexString(enc) + "  " + line.Format(opts)
+		if target, ok := e.Get(addr).Target(addr); ok {
+			if _, _, ok := symbols.NearestSymbol(target); ok {
+				text += " # " + symbols.SymbolicAddress(target)
+			}
+		}
+		_, err = fmt.Fprintln(w, text)
+		if err != nil {
+			return err
+		}
+		addr += 4
+	}
+	return nil
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
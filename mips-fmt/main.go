@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/mips32"
+)
+
+func main() {
+	var overwrite bool
+	flag.BoolVar(&overwrite, "w", false, "write the result back to the source files")
+
+	var registers string
+	flag.StringVar(&registers, "registers", "numeric",
+		"register spelling (numeric, abi, or prefixed)")
+
+	var hexImmediates bool
+	flag.BoolVar(&hexImmediates, "hex", false, "write constants in hexadecimal")
+
+	var lowercase bool
+	flag.BoolVar(&lowercase, "lower", false, "write instruction names in lowercase")
+
+	flag.Parse()
+	if len(flag.Args()) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s> [file.s ...]")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	style, err := mips32.ParseRegisterStyle(registers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := &mips32.RenderOptions{
+		Registers:          style,
+		HexImmediates:      hexImmediates,
+		LowercaseMnemonics: lowercase,
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if err := formatFile(path, opts, overwrite); err != nil {
+			fmt.Fprintln(os.Stderr, path+":", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(path string, opts *mips32.RenderOptions, overwrite bool) error {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	formatted, err := mips32.FormatOptions(string(source), opts)
+	if err != nil {
+		return err
+	}
+	if !overwrite {
+		_, err := os.Stdout.WriteString(formatted)
+		return err
+	}
+	if formatted == string(source) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(formatted), info.Mode())
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+var symbolNameRegexp = regexp.MustCompile("^[a-zA-Z0-9_]+$")
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+type diagnostic struct {
+	Range    textRange `json:"range"`
+	Severity int       `json:"severity"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+
+type textEdit struct {
+	Range   textRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+	NewName  string   `json:"newName"`
+}
+
+type server struct {
+	output    io.Writer
+	documents map[string]string
+}
+
+func newServer(output io.Writer) *server {
+	return &server{output: output, documents: map[string]string{}}
+}
+
+func (s *server) handle(msg *message) {
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"definitionProvider": true,
+				"hoverProvider":      true,
+				"renameProvider":     true,
+			},
+		})
+	case "shutdown":
+		s.reply(msg, nil)
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.documents[params.TextDocument.URI] = params.TextDocument.Text
+			s.publishDiagnostics(params.TextDocument.URI)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			changes := params.ContentChanges
+			s.documents[params.TextDocument.URI] = changes[len(changes)-1].Text
+			s.publishDiagnostics(params.TextDocument.URI)
+		}
+	case "textDocument/didClose":
+		var params textDocumentPositionParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+				"uri":         params.TextDocument.URI,
+				"diagnostics": []diagnostic{},
+			})
+		}
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			s.reply(msg, nil)
+			return
+		}
+		s.reply(msg, s.definition(&params))
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			s.reply(msg, nil)
+			return
+		}
+		s.reply(msg, s.hover(&params))
+	case "textDocument/rename":
+		var params textDocumentPositionParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			s.reply(msg, nil)
+			return
+		}
+		s.reply(msg, s.rename(&params))
+	default:
+		if msg.ID != nil {
+			writeMessage(s.output, &message{
+				ID: msg.ID,
+				Error: &responseError{
+					Code:    methodNotFound,
+					Message: "unknown method: " + msg.Method,
+				},
+			})
+		}
+	}
+}
+
+func (s *server) reply(request *message, result interface{}) {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	writeMessage(s.output, &message{ID: request.ID, Result: result})
+}
+
+func (s *server) notify(method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	writeMessage(s.output, &message{Method: method, Params: data})
+}
+
+func (s *server) publishDiagnostics(uri string) {
+	source := s.documents[uri]
+	lines := strings.Split(source, "\n")
+	diagnostics := []diagnostic{}
+	addDiagnostic := func(line, startCol, endCol, severity int, msg string) {
+		lineIdx := line - 1
+		if lineIdx < 0 {
+			lineIdx = 0
+		}
+		if endCol == 0 {
+			startCol = 0
+			if lineIdx < len(lines) {
+				endCol = len(lines[lineIdx])
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range: textRange{
+				Start: position{Line: lineIdx, Character: startCol},
+				End:   position{Line: lineIdx, Character: endCol},
+			},
+			Severity: severity,
+			Source:   "mips32",
+			Message:  msg,
+		})
+	}
+
+	errs := mips32.CheckSource(source)
+	for _, err := range errs {
+		addDiagnostic(err.Line, err.Column, err.EndColumn, severityError, err.Message)
+	}
+	if len(errs) == 0 {
+		if exc, warnings, err := mips32.AssembleWithWarnings(source, nil); err == nil {
+			for _, warning := range append(warnings, mips32.Lint(exc)...) {
+				addDiagnostic(warning.Line, 0, 0, severityWarning, warning.Message)
+			}
+		}
+	}
+
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+func (s *server) definition(p *textDocumentPositionParams) interface{} {
+	lines := strings.Split(s.documents[p.TextDocument.URI], "\n")
+	word := wordAt(lines, p.Position)
+	if word == "" {
+		return nil
+	}
+	for i, line := range lines {
+		code := stripComment(line)
+		if strings.TrimSpace(code) == word+":" {
+			start := strings.Index(code, word)
+			return location{
+				URI: p.TextDocument.URI,
+				Range: textRange{
+					Start: position{Line: i, Character: start},
+					End:   position{Line: i, Character: start + len(word)},
+				},
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) hover(p *textDocumentPositionParams) interface{} {
+	source := s.documents[p.TextDocument.URI]
+	tokens, err := mips32.TokenizeSource(source)
+	if err != nil {
+		return nil
+	}
+	exc, err := mips32.ParseExecutable(tokens)
+	if err != nil {
+		return nil
+	}
+	for addr, lineNum := range exc.LineNumbers {
+		if lineNum != p.Position.Line+1 {
+			continue
+		}
+		inst := exc.Get(addr)
+		text := "address `" + hexString(addr) + "`"
+		if word, err := inst.Encode(addr, exc.Symbols); err == nil {
+			binary := strconv.FormatUint(uint64(word), 2)
+			for len(binary) < 32 {
+				binary = "0" + binary
+			}
+			text += ", encoding `" + hexString(word) + "`\n\n`" + binary + "`"
+		}
+		return map[string]interface{}{
+			"contents": map[string]string{"kind": "markdown", "value": text},
+		}
+	}
+	return nil
+}
+
+func (s *server) rename(p *textDocumentPositionParams) interface{} {
+	if !symbolNameRegexp.MatchString(p.NewName) {
+		return nil
+	}
+	lines := strings.Split(s.documents[p.TextDocument.URI], "\n")
+	word := wordAt(lines, p.Position)
+	if word == "" {
+		return nil
+	}
+	edits := []textEdit{}
+	for i, line := range lines {
+		code := stripComment(line)
+		for _, start := range wordOccurrences(code, word) {
+			edits = append(edits, textEdit{
+				Range: textRange{
+					Start: position{Line: i, Character: start},
+					End:   position{Line: i, Character: start + len(word)},
+				},
+				NewText: p.NewName,
+			})
+		}
+	}
+	return map[string]interface{}{
+		"changes": map[string][]textEdit{p.TextDocument.URI: edits},
+	}
+}
+
+// wordAt finds the symbol name under the cursor.
+// Register names (which follow a "$") are not considered symbols.
+func wordAt(lines []string, pos position) string {
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	if pos.Character < 0 || pos.Character > len(line) {
+		return ""
+	}
+	start := pos.Character
+	for start > 0 && isWordByte(line[start-1]) {
+		start--
+	}
+	end := pos.Character
+	for end < len(line) && isWordByte(line[end]) {
+		end++
+	}
+	if start == end || (start > 0 && line[start-1] == '$') {
+		return ""
+	}
+	return line[start:end]
+}
+
+func wordOccurrences(code, word string) []int {
+	var res []int
+	for offset := 0; offset < len(code); {
+		idx := strings.Index(code[offset:], word)
+		if idx < 0 {
+			break
+		}
+		start := offset + idx
+		end := start + len(word)
+		if (start == 0 || (!isWordByte(code[start-1]) && code[start-1] != '$')) &&
+			(end == len(code) || !isWordByte(code[end])) {
+			res = append(res, start)
+		}
+		offset = end
+	}
+	return res
+}
+
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' || strings.HasPrefix(line[i:], "//") {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return "0x" + s
+}
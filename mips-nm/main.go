@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+func main() {
+	var sortBy string
+	flag.StringVar(&sortBy, "sort", "addr", "sort order (addr or name)")
+
+	var printSize bool
+	flag.BoolVar(&printSize, "size", false, "print the size of each symbol")
+	flag.Parse()
+	if len(flag.Args()) != 1 || (sortBy != "addr" && sortBy != "name") {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s | file.elf>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	contents, err := ioutil.ReadFile(flag.Args()[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	exc, err := readExecutable(contents)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(exc.Symbols))
+	for name := range exc.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if sortBy == "addr" {
+			addr1, addr2 := exc.Symbols[names[i]], exc.Symbols[names[j]]
+			if addr1 != addr2 {
+				return addr1 < addr2
+			}
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		info := exc.SymbolInfo[name]
+		line := hexString(exc.Symbols[name]) + " "
+		if printSize {
+			line += hexString(info.Size) + " "
+		}
+		fmt.Println(line + typeLetter(info) + " " + name)
+	}
+}
+
+// typeLetter returns the letter which nm uses for a symbol's type: "T" for code and "D" for
+// data, in lowercase for local symbols.
+func typeLetter(info mips32.SymbolInfo) string {
+	letter := "t"
+	if info.Kind == mips32.DataSymbol {
+		letter = "d"
+	}
+	if info.Global {
+		letter = strings.ToUpper(letter)
+	}
+	return letter
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
+
+// readExecutable loads an ELF file, or assembles the data if it is not an ELF file.
+func readExecutable(data []byte) (*mips32.Executable, error) {
+	if bytes.HasPrefix(data, []byte("\x7fELF")) {
+		img, err := mips32.ReadELF(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return img.Executable(), nil
+	}
+	tokens, err := mips32.TokenizeSource(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return mips32.ParseExecutable(tokens)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+// A jsonChunk is the JSON representation of a contiguous piece of memory.
+type jsonChunk struct {
+	Address uint32 `json:"address"`
+	Data    string `json:"data"`
+}
+
+func main() {
+	var inFormat, outFormat string
+	flag.StringVar(&inFormat, "from", "", "input format (hex, srec, elf, bin, or json)")
+	flag.StringVar(&outFormat, "to", "", "output format (hex, srec, elf, bin, or json)")
+
+	var baseAddress uint64
+	flag.Uint64Var(&baseAddress, "base", 0, "load address of raw binary input")
+
+	var offset int64
+	flag.Int64Var(&offset, "offset", 0, "amount to add to every address")
+
+	var gapFill int
+	flag.IntVar(&gapFill, "gap-fill", -1, "fill gaps between chunks with this byte")
+
+	var littleEndian bool
+	flag.BoolVar(&littleEndian, "little", false, "mark ELF output as little endian")
+
+	var entry string
+	flag.StringVar(&entry, "entry", "", "entry point for ELF output (default: lowest address)")
+
+	var checksum string
+	flag.StringVar(&checksum, "checksum", "",
+		"print a checksum (crc32 or fletcher32) of each output chunk")
+
+	flag.Parse()
+	if len(flag.Args()) != 2 {
+		dieUsage()
+	}
+	inFile := flag.Args()[0]
+	outFile := flag.Args()[1]
+
+	if inFormat == "" {
+		inFormat = formatForPath(inFile)
+	}
+	if outFormat == "" {
+		outFormat = formatForPath(outFile)
+	}
+	if gapFill > 0xff {
+		fmt.Fprintln(os.Stderr, "gap-fill value must be a byte")
+		os.Exit(1)
+	}
+	var alg mips32.ChecksumAlgorithm
+	if checksum != "" {
+		var err error
+		if alg, err = mips32.ParseChecksumAlgorithm(checksum); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	input, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	image, err := readImage(input, inFormat, uint32(baseAddress))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	image.LittleEndian = image.LittleEndian || littleEndian
+	if entry != "" {
+		num, err := strconv.ParseUint(entry, 0, 32)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid entry point:", entry)
+			os.Exit(1)
+		}
+		image.Entry = uint32(num)
+	}
+
+	if offset != 0 {
+		rebased := map[uint32][]byte{}
+		for start, data := range image.Chunks {
+			rebased[uint32(int64(start)+offset)] = data
+		}
+		image.Chunks = rebased
+		image.Entry = uint32(int64(image.Entry) + offset)
+	}
+	if gapFill >= 0 || outFormat == "bin" {
+		image.Chunks = fillGaps(image.Chunks, byte(gapFill))
+	}
+
+	output, err := os.Create(outFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer output.Close()
+	if err := writeImage(output, image, outFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if checksum != "" {
+		for _, sum := range mips32.ImageChecksums(image.Chunks, alg) {
+			fmt.Println(sum)
+		}
+	}
+}
+
+func dieUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <input> <output>")
+	fmt.Fprintln(os.Stderr, "Formats are inferred from file extensions unless -from or -to is set.")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func formatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex":
+		return "hex"
+	case ".srec", ".s19", ".s28", ".s37", ".mot":
+		return "srec"
+	case ".elf", "":
+		return "elf"
+	case ".json":
+		return "json"
+	default:
+		return "bin"
+	}
+}
+
+func readImage(data []byte, format string, base uint32) (*mips32.ELFImage, error) {
+	image := &mips32.ELFImage{}
+	var err error
+	switch format {
+	case "hex":
+		image.Chunks, err = mips32.ReadIntelHex(bytes.NewReader(data))
+	case "srec":
+		image.Chunks, err = mips32.ReadSRecord(bytes.NewReader(data))
+	case "elf":
+		return mips32.ReadELF(bytes.NewReader(data))
+	case "bin":
+		image.Chunks = map[uint32][]byte{base: data}
+	case "json":
+		image.Chunks, err = readJSON(data)
+	default:
+		return nil, errors.New("unknown input format: " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	image.Entry = lowestAddress(image.Chunks)
+	return image, nil
+}
+
+func writeImage(w io.Writer, image *mips32.ELFImage, format string) error {
+	switch format {
+	case "hex":
+		return mips32.WriteIntelHex(w, image.Chunks)
+	case "srec":
+		return mips32.WriteSRecord(w, image.Chunks)
+	case "elf":
+		return mips32.WriteELF(w, image)
+	case "bin":
+		// fillGaps has already merged everything into one chunk.
+		for _, data := range image.Chunks {
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		return writeJSON(w, image.Chunks)
+	default:
+		return errors.New("unknown output format: " + format)
+	}
+}
+
+func readJSON(data []byte) (map[uint32][]byte, error) {
+	var list []jsonChunk
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	chunks := map[uint32][]byte{}
+	for _, chunk := range list {
+		data, err := hex.DecodeString(chunk.Data)
+		if err != nil {
+			return nil, err
+		}
+		chunks[chunk.Address] = data
+	}
+	return chunks, nil
+}
+
+func writeJSON(w io.Writer, chunks map[uint32][]byte) error {
+	list := []jsonChunk{}
+	for _, start := range sortedStarts(chunks) {
+		list = append(list, jsonChunk{Address: start, Data: hex.EncodeToString(chunks[start])})
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// fillGaps joins all of the chunks into one, filling the space between them with a byte.
+func fillGaps(chunks map[uint32][]byte, fill byte) map[uint32][]byte {
+	starts := sortedStarts(chunks)
+	if len(starts) == 0 {
+		return chunks
+	}
+	var joined []byte
+	for _, start := range starts {
+		offset := int(start - starts[0])
+		for len(joined) < offset {
+			joined = append(joined, fill)
+		}
+		data := chunks[start]
+		if offset < len(joined) {
+			// Overlapping chunks overwrite each other in address order.
+			overlap := len(joined) - offset
+			if overlap > len(data) {
+				overlap = len(data)
+			}
+			copy(joined[offset:], data[:overlap])
+			data = data[overlap:]
+		}
+		joined = append(joined, data...)
+	}
+	return map[uint32][]byte{starts[0]: joined}
+}
+
+func lowestAddress(chunks map[uint32][]byte) uint32 {
+	if starts := sortedStarts(chunks); len(starts) > 0 {
+		return starts[0]
+	}
+	return 0
+}
+
+func sortedStarts(chunks map[uint32][]byte) []uint32 {
+	starts := make([]uint32, 0, len(chunks))
+	for start := range chunks {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+	return starts
+}
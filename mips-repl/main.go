@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+func main() {
+	var littleEndian bool
+	flag.BoolVar(&littleEndian, "little", false, "use little endian memory")
+
+	flag.Parse()
+	if len(flag.Args()) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags]")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	emu := newEmulator(littleEndian)
+
+	fmt.Println("Type instructions to run them. Type :regs to see every register, " +
+		":reset to start over, or :quit to exit.")
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print(hexString(emu.ProgramCounter) + "> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case ":quit", ":q":
+			return
+		case ":regs":
+			fmt.Println(emu.RegisterFile.String())
+			continue
+		case ":reset":
+			emu = newEmulator(littleEndian)
+			continue
+		}
+
+		tokenized, err := mips32.TokenizeSource(line)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		for _, tokens := range tokenized {
+			if tokens.Instruction == nil {
+				fmt.Println("expected an instruction")
+				continue
+			}
+			inst, err := mips32.ParseTokenizedInstruction(tokens.Instruction)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			oldRegs := emu.RegisterFile
+			if err := emu.Execute(inst); err != nil {
+				fmt.Println(err)
+			}
+			printChanges(oldRegs, emu.RegisterFile)
+			if emu.JumpNext {
+				fmt.Println("(delay slot; next jump to " + hexString(emu.JumpTarget) + ")")
+			}
+		}
+	}
+}
+
+func newEmulator(littleEndian bool) *mips32.Emulator {
+	return &mips32.Emulator{
+		Memory:       mips32.NewLazyMemory(),
+		LittleEndian: littleEndian,
+	}
+}
+
+func printChanges(oldRegs, newRegs mips32.RegisterFile) {
+	for i := range oldRegs {
+		if oldRegs[i] != newRegs[i] {
+			fmt.Println("$r"+strconv.Itoa(i), "=", "0x"+hexString(newRegs[i]),
+				"("+strconv.FormatInt(int64(int32(newRegs[i])), 10)+")")
+		}
+	}
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
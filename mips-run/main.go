@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/unixpickle/mips32"
 )
@@ -25,6 +29,41 @@ func main() {
 	var memoryDumpStart uint64
 	flag.Uint64Var(&memoryDumpStart, "dumpstart", 0, "base address for memory dump")
 
+	var maxSteps uint64
+	flag.Uint64Var(&maxSteps, "maxsteps", 0, "maximum number of instructions to run (0 for no limit)")
+
+	var trace bool
+	flag.BoolVar(&trace, "trace", false, "print each instruction to stderr as it runs")
+
+	var binaryInput bool
+	flag.BoolVar(&binaryInput, "binary", false, "treat the input as a raw binary (see mips-as)")
+
+	var binaryBase uint64
+	flag.Uint64Var(&binaryBase, "base", 0, "load address for -binary input")
+
+	var autoNOP bool
+	flag.BoolVar(&autoNOP, "autonop", false, "insert a NOP after every branch and jump")
+
+	var datapathFile string
+	flag.StringVar(&datapathFile, "datapath", "",
+		"write the single-cycle datapath signals of each instruction to a file, as JSON lines")
+
+	var registers string
+	flag.StringVar(&registers, "regs", "",
+		"initial registers: a preset name (e.g. SPIM) or assignments like '$a0=1, $sp=0x1000'")
+
+	var coverageFile string
+	flag.StringVar(&coverageFile, "coverage", "",
+		"write a coverage report to a file (lcov format if the name ends in .info, else JSON)")
+
+	var traceFile string
+	flag.StringVar(&traceFile, "tracefile", "",
+		"write a trace of register writes and memory accesses to a file (see mips32.TraceRecord)")
+
+	var checkTraceFile string
+	flag.StringVar(&checkTraceFile, "checktrace", "",
+		"check a trace file (e.g. from a CPU's testbench) against the program's trace")
+
 	flag.Parse()
 	if len(flag.Args()) != 1 {
 		dieUsage()
@@ -37,29 +76,139 @@ func main() {
 		os.Exit(1)
 	}
 
-	tokens, err := mips32.TokenizeSource(string(contents))
+	var exc *mips32.Executable
+	var image *mips32.ELFImage
+	if bytes.HasPrefix(contents, []byte("\x7fELF")) {
+		image, err = mips32.ReadELF(bytes.NewReader(contents))
+		if err == nil {
+			exc = image.Executable()
+			littleEndian = image.LittleEndian
+		}
+	} else if binaryInput {
+		exc, err = loadBinary(contents, uint32(binaryBase), littleEndian)
+	} else {
+		exc, err = assemble(string(contents), autoNOP, littleEndian)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	exc, err := mips32.ParseExecutable(tokens)
-	if err != nil {
+	memory := mips32.NewLazyMemory()
+	if image != nil {
+		for start, data := range image.Chunks {
+			mips32.WriteBytes(memory, start, data)
+		}
+	} else if err := exc.LoadInto(memory, littleEndian); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-
+	syscalls := &mips32.SPIMSyscalls{Input: os.Stdin, Output: os.Stdout}
 	emu := &mips32.Emulator{
-		Memory:            mips32.NewLazyMemory(),
+		Memory:            memory,
 		Executable:        exc,
 		LittleEndian:      littleEndian,
 		ForceMemAlignment: !relaxAlignment,
+		Syscalls:          syscalls,
+	}
+	if image != nil {
+		emu.ProgramCounter = image.Entry
+	}
+	if registers != "" {
+		preset, err := parsePreset(registers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		preset.Apply(emu)
+	}
+	var datapath *json.Encoder
+	if datapathFile != "" {
+		f, err := os.Create(datapathFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		datapath = json.NewEncoder(f)
+	}
+	var observers []func(e *mips32.Emulator, inst *mips32.Instruction, addr uint32)
+	var coverage *mips32.Coverage
+	if coverageFile != "" {
+		coverage = mips32.NewCoverage(exc)
+		observers = append(observers, coverage.Observe)
+	}
+	var traceWriter *mips32.TraceWriter
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		traceWriter = mips32.NewTraceWriter(f)
+		observers = append(observers, traceWriter.Observe)
+	}
+	var referenceTrace []*mips32.TraceRecord
+	if checkTraceFile != "" {
+		observers = append(observers, func(e *mips32.Emulator, inst *mips32.Instruction,
+			addr uint32) {
+			referenceTrace = append(referenceTrace, mips32.NewTraceRecord(e, inst, addr))
+		})
+	}
+	if len(observers) > 0 {
+		emu.AfterExecute = func(inst *mips32.Instruction, addr uint32) {
+			for _, observer := range observers {
+				observer(emu, inst, addr)
+			}
+		}
+	}
+	exit := func(code int) {
+		if coverage != nil {
+			if err := writeCoverage(coverageFile, file, coverage); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+		}
+		if traceWriter != nil && traceWriter.Err() != nil {
+			fmt.Fprintln(os.Stderr, traceWriter.Err())
+			code = 1
+		}
+		if checkTraceFile != "" {
+			if err := checkTrace(checkTraceFile, referenceTrace); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+		}
+		if code != 0 {
+			os.Exit(code)
+		}
 	}
+	var steps uint64
+	symbols := emu.Executable.SymbolIndex()
 	for !emu.Done() {
+		if maxSteps != 0 && steps == maxSteps {
+			fmt.Fprintln(os.Stderr, "instruction limit reached")
+			exit(2)
+		}
+		if trace {
+			traceInstruction(emu, symbols)
+		}
+		if datapath != nil {
+			signals, err := mips32.Datapath(emu.Executable.Get(emu.ProgramCounter), emu)
+			if err == nil {
+				err = datapath.Encode(signals)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
 		if err := emu.Step(); err != nil {
 			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			exit(1)
 		}
+		steps++
 	}
 
 	fmt.Println("Register file:")
@@ -68,21 +217,111 @@ func main() {
 	if memoryDumpSize > 0 {
 		dumpMemory(emu.Memory, uint32(memoryDumpStart), uint32(memoryDumpSize))
 	}
+
+	exit(syscalls.ExitCode)
 }
 
 func dieUsage() {
-	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s>")
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s | file.bin | file.elf>")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
 
+func writeCoverage(path, sourceFile string, coverage *mips32.Coverage) error {
+	if strings.HasSuffix(path, ".info") {
+		return ioutil.WriteFile(path, []byte(coverage.LCOV(sourceFile)), 0644)
+	}
+	data, err := json.Marshal(coverage.Report())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// checkTrace compares the trace in a file against the emulator's trace.
+func checkTrace(path string, reference []*mips32.TraceRecord) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	actual, err := mips32.ReadTrace(f)
+	if err != nil {
+		return errors.New(path + ": " + err.Error())
+	}
+	if mismatch := mips32.CompareTraces(reference, actual); mismatch != nil {
+		return errors.New("trace mismatch at " + mismatch.String())
+	}
+	return nil
+}
+
+func parsePreset(text string) (*mips32.RegisterPreset, error) {
+	if preset := mips32.PresetNamed(text); preset != nil {
+		return preset, nil
+	}
+	return mips32.ParseRegisterPreset("custom", text)
+}
+
+func assemble(source string, autoNOP, little bool) (*mips32.Executable, error) {
+	opts := &mips32.AssembleOptions{LittleEndian: little}
+	if autoNOP {
+		opts.Dialect = mips32.DialectNoDelaySlots
+	}
+	return mips32.Assemble(source, opts)
+}
+
+func loadBinary(data []byte, base uint32, little bool) (*mips32.Executable, error) {
+	if len(data)&3 != 0 {
+		return nil, errors.New("file is invalid length (must be multiple of 4)")
+	}
+	insts := make([]mips32.Instruction, len(data)/4)
+	for i := range insts {
+		word := data[i*4 : i*4+4]
+		var num uint32
+		if little {
+			num = uint32(word[0]) | (uint32(word[1]) << 8) | (uint32(word[2]) << 16) |
+				(uint32(word[3]) << 24)
+		} else {
+			num = (uint32(word[0]) << 24) | (uint32(word[1]) << 16) | (uint32(word[2]) << 8) |
+				uint32(word[3])
+		}
+		insts[i] = *mips32.DecodeInstruction(num)
+	}
+	return &mips32.Executable{
+		Segments: map[uint32][]mips32.Instruction{base: insts},
+		Symbols:  map[string]uint32{},
+	}, nil
+}
+
+func traceInstruction(emu *mips32.Emulator, symbols *mips32.SymbolIndex) {
+	text := "NOP"
+	if inst := emu.Executable.Get(emu.ProgramCounter); inst != nil {
+		if rendering, err := inst.Render(); err == nil {
+			text = rendering.String()
+		}
+	}
+	hexAddr := strconv.FormatUint(uint64(emu.ProgramCounter), 16)
+	for len(hexAddr) < 8 {
+		hexAddr = "0" + hexAddr
+	}
+	if _, _, ok := symbols.NearestSymbol(emu.ProgramCounter); ok {
+		text = "<" + symbols.SymbolicAddress(emu.ProgramCounter) + ">  " + text
+	}
+	fmt.Fprintln(os.Stderr, hexAddr+"  "+text)
+}
+
 func dumpMemory(mem mips32.Memory, start, size uint32) {
 	fmt.Println("Memory dump:")
 
 	// The uint64 conversions deal with the case when start+size would overflow.
 	column := 0
+	var chunk [4096]byte
 	for i := uint64(start); i < uint64(start)+uint64(size); i++ {
-		b := mem.Get(uint32(i))
+		offset := (i - uint64(start)) % uint64(len(chunk))
+		if offset == 0 {
+			mips32.ReadBytes(mem, uint32(i), chunk[:])
+		}
+		b := chunk[offset]
 		hexValue := strconv.FormatUint(uint64(b), 16)
 		for len(hexValue) < 2 {
 			hexValue = "0" + hexValue
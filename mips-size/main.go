@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/mips32"
+)
+
+func main() {
+	var stack bool
+	flag.BoolVar(&stack, "stack", false, "estimate the stack usage of each function instead")
+
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <file.s | file.elf>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	contents, err := ioutil.ReadFile(flag.Args()[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	exc, err := readExecutable(contents)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if stack {
+		printStackUsage(exc)
+		return
+	}
+
+	segments := exc.OrderedSegments()
+
+	fmt.Println("start     end       size")
+	var total uint64
+	for _, segment := range segments {
+		size := uint64(len(segment.Instructions) * 4)
+		end := uint32(uint64(segment.Address) + size)
+		fmt.Println(hexString(segment.Address) + "  " + hexString(end) + "  " +
+			strconv.FormatUint(size, 10))
+		total += size
+	}
+	fmt.Println("total", total, "bytes in", len(segments), "segments")
+}
+
+// printStackUsage lists each function's own frame size and worst-case stack usage, along with
+// the chain of calls which reaches that usage.
+// Usages which are only lower bounds are marked with the reasons.
+func printStackUsage(exc *mips32.Executable) {
+	fmt.Println("function  frame  total  path")
+	for _, usage := range mips32.AnalyzeStackUsage(exc) {
+		var path []string
+		for _, function := range usage.Path {
+			path = append(path, exc.SymbolicAddress(function))
+		}
+		line := hexString(usage.Function) + "  " + pad(usage.Frame, 5) + "  " +
+			pad(usage.Total, 5) + "  " + strings.Join(path, " -> ")
+		if usage.Recursive {
+			line += " (recursive)"
+		}
+		if usage.IndirectCalls {
+			line += " (indirect calls)"
+		}
+		if usage.DynamicStack {
+			line += " (dynamic stack)"
+		}
+		fmt.Println(line)
+	}
+}
+
+// pad right-aligns a number in a column.
+func pad(n uint32, width int) string {
+	s := strconv.FormatUint(uint64(n), 10)
+	for len(s) < width {
+		s = " " + s
+	}
+	return s
+}
+
+func hexString(n uint32) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < 8 {
+		s = "0" + s
+	}
+	return s
+}
+
+// readExecutable loads an ELF file, or assembles the data if it is not an ELF file.
+func readExecutable(data []byte) (*mips32.Executable, error) {
+	if bytes.HasPrefix(data, []byte("\x7fELF")) {
+		img, err := mips32.ReadELF(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return img.Executable(), nil
+	}
+	tokens, err := mips32.TokenizeSource(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return mips32.ParseExecutable(tokens)
+}
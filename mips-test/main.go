@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/mips32/grading"
+)
+
+func main() {
+	var jsonReport bool
+	flag.BoolVar(&jsonReport, "json", false, "print the report as JSON (see grading.Report)")
+
+	flag.Parse()
+	if len(flag.Args()) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] <tests.json> <program.s>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	suiteData, err := ioutil.ReadFile(flag.Args()[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	suite, err := grading.ParseSuite(suiteData)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	source, err := ioutil.ReadFile(flag.Args()[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	report := suite.Grade(string(source))
+	if jsonReport {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println(report)
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,291 @@
+package mips32
+
+import "strconv"
+
+// An Optimization describes one change made by Optimize.
+type Optimization struct {
+	// Line is the source line of the instruction which was changed.
+	Line int
+
+	Message string
+}
+
+func (o *Optimization) String() string {
+	return "line " + strconv.Itoa(o.Line) + ": " + o.Message
+}
+
+// Optimize runs a peephole optimizer over a tokenized program.
+//
+// It removes instructions which do nothing (such as "ADDU $t0, $t0, $0"), folds LUI/ORI pairs
+// which load small constants into a single instruction, deletes branches and jumps to the
+// instruction after their delay slots, and moves instructions into the NOP delay slots of the
+// branches and jumps they precede, when doing so cannot change what the program does.
+// Instructions in delay slots are never removed, and nothing is moved across a label or a
+// directive.
+//
+// The optimized program is returned along with a list of the changes, in the order they were
+// made.
+// Comments on removed instructions are kept on their own lines.
+func Optimize(lines []TokenizedLine) ([]TokenizedLine, []*Optimization, error) {
+	o := &optimizer{
+		lines: append([]TokenizedLine{}, lines...),
+		insts: make([]*Instruction, len(lines)),
+	}
+	for i, line := range lines {
+		if line.Instruction != nil {
+			inst, err := ParseTokenizedInstruction(line.Instruction)
+			if err != nil {
+				return nil, nil, lineError(line.LineNumber, err.Error())
+			}
+			o.insts[i] = inst
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i, inst := range o.insts {
+			if inst == nil || o.inDelaySlot(i) {
+				continue
+			}
+			if o.removeRedundant(i) || o.foldConstant(i) || o.removeJumpToNext(i) ||
+				o.fillDelaySlot(i) {
+				changed = true
+			}
+		}
+	}
+
+	var res []TokenizedLine
+	for _, line := range o.lines {
+		if line.Comment != nil || line.Directive != nil || line.Instruction != nil ||
+			line.SymbolMarker != nil {
+			res = append(res, line)
+		}
+	}
+	return res, o.report, nil
+}
+
+type optimizer struct {
+	lines  []TokenizedLine
+	insts  []*Instruction
+	report []*Optimization
+}
+
+func (o *optimizer) removeRedundant(i int) bool {
+	if !isRedundant(o.insts[i]) {
+		return false
+	}
+	o.addReport(i, "removed "+o.lines[i].Instruction.String()+", which does nothing")
+	o.remove(i)
+	return true
+}
+
+func (o *optimizer) foldConstant(i int) bool {
+	lui := o.insts[i]
+	if lui.Name != "LUI" {
+		return false
+	}
+	j, labeled := o.next(i)
+	if j < 0 || labeled {
+		return false
+	}
+	ori := o.insts[j]
+	reg := lui.Registers[0]
+	if ori.Name != "ORI" || ori.Registers[0] != reg || ori.Registers[1] != reg {
+		return false
+	}
+	regArg := &ArgToken{isRegister: true, register: reg}
+	zeroArg := &ArgToken{isRegister: true, register: 0}
+	folded := &TokenizedInstruction{Name: "ORI"}
+	if lui.UnsignedConstant16 == 0 {
+		folded.Arguments = []*ArgToken{regArg, zeroArg,
+			{isConstant: true, constant: uint32(ori.UnsignedConstant16)}}
+	} else if lui.UnsignedConstant16 == 0xffff && ori.UnsignedConstant16&0x8000 != 0 {
+		folded.Name = "ADDIU"
+		folded.Arguments = []*ArgToken{regArg, zeroArg,
+			{isConstant: true, constant: 0xffff0000 | uint32(ori.UnsignedConstant16)}}
+	} else {
+		return false
+	}
+	inst, err := ParseTokenizedInstruction(folded)
+	if err != nil {
+		return false
+	}
+	o.addReport(i, "folded "+o.lines[i].Instruction.String()+" and "+
+		o.lines[j].Instruction.String()+" into "+folded.String())
+	o.lines[i].Instruction = folded
+	o.insts[i] = inst
+	o.remove(j)
+	return true
+}
+
+func (o *optimizer) removeJumpToNext(i int) bool {
+	inst := o.insts[i]
+	switch inst.Name {
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE", "J":
+	default:
+		return false
+	}
+	if !inst.CodePointer.IsSymbol {
+		return false
+	}
+	slot, slotLabeled := o.next(i)
+	if slot < 0 || isControlInstruction(o.insts[slot]) ||
+		!o.labelFollows(slot, inst.CodePointer.Symbol) {
+		return false
+	}
+	o.addReport(i, "removed "+o.lines[i].Instruction.String()+
+		", which jumps to the instruction after its delay slot")
+	o.remove(i)
+	if o.insts[slot].Name == "NOP" && !slotLabeled && o.lines[slot].Comment == nil {
+		o.remove(slot)
+	}
+	return true
+}
+
+func (o *optimizer) fillDelaySlot(i int) bool {
+	control := o.insts[i]
+	if !isControlInstruction(control) {
+		return false
+	}
+	slot, slotLabeled := o.next(i)
+	if slot < 0 || slotLabeled || o.insts[slot].Name != "NOP" ||
+		o.lines[slot].Comment != nil {
+		return false
+	}
+	prev, prevLabeled := o.prev(i)
+	if prev < 0 || prevLabeled || o.inDelaySlot(prev) ||
+		!canFillDelaySlot(o.insts[prev], control) {
+		return false
+	}
+	o.addReport(prev, "moved "+o.lines[prev].Instruction.String()+" into the delay slot of "+
+		o.lines[i].Instruction.String())
+	o.lines[slot] = o.lines[prev]
+	o.insts[slot] = o.insts[prev]
+	o.lines[prev] = TokenizedLine{}
+	o.insts[prev] = nil
+	return true
+}
+
+// next finds the first instruction after a line, returning -1 if there is a directive (or
+// nothing) before it.
+// It also reports whether there is a label before the instruction.
+func (o *optimizer) next(i int) (index int, labeled bool) {
+	for j := i + 1; j < len(o.lines); j++ {
+		if o.lines[j].Directive != nil {
+			return -1, labeled
+		} else if o.lines[j].SymbolMarker != nil {
+			labeled = true
+		} else if o.insts[j] != nil {
+			return j, labeled
+		}
+	}
+	return -1, labeled
+}
+
+// prev is like next, but it finds the instruction before a line.
+func (o *optimizer) prev(i int) (index int, labeled bool) {
+	for j := i - 1; j >= 0; j-- {
+		if o.lines[j].Directive != nil {
+			return -1, labeled
+		} else if o.lines[j].SymbolMarker != nil {
+			labeled = true
+		} else if o.insts[j] != nil {
+			return j, labeled
+		}
+	}
+	return -1, labeled
+}
+
+func (o *optimizer) inDelaySlot(i int) bool {
+	prev, _ := o.prev(i)
+	return prev >= 0 && isControlInstruction(o.insts[prev])
+}
+
+// labelFollows checks if a symbol is declared between an instruction and the next instruction.
+func (o *optimizer) labelFollows(i int, symbol string) bool {
+	for j := i + 1; j < len(o.lines) && o.insts[j] == nil && o.lines[j].Directive == nil; j++ {
+		if o.lines[j].SymbolMarker != nil && *o.lines[j].SymbolMarker == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// remove deletes an instruction, leaving its comment behind.
+func (o *optimizer) remove(i int) {
+	o.lines[i] = TokenizedLine{LineNumber: o.lines[i].LineNumber, Comment: o.lines[i].Comment}
+	o.insts[i] = nil
+}
+
+func (o *optimizer) addReport(i int, msg string) {
+	o.report = append(o.report, &Optimization{Line: o.lines[i].LineNumber, Message: msg})
+}
+
+// isRedundant returns true for instructions which never have any effect.
+func isRedundant(inst *Instruction) bool {
+	switch inst.Name {
+	case "ADDIU", "ADDU", "AND", "ANDI", "LUI", "MOVN", "MOVZ", "NOR", "OR", "ORI", "SLL",
+		"SLLV", "SLT", "SLTI", "SLTIU", "SLTU", "SRA", "SRAV", "SRL", "SRLV", "SUBU", "XOR",
+		"XORI":
+	default:
+		return false
+	}
+	regs := inst.Registers
+	if regs[0] == 0 {
+		return true
+	}
+	switch inst.Name {
+	case "ADDU", "OR", "XOR":
+		return (regs[1] == regs[0] && regs[2] == 0) || (regs[2] == regs[0] && regs[1] == 0)
+	case "SUBU", "SLLV", "SRAV", "SRLV":
+		return regs[1] == regs[0] && regs[2] == 0
+	case "AND":
+		return regs[1] == regs[0] && regs[2] == regs[0]
+	case "MOVN", "MOVZ":
+		return regs[1] == regs[0]
+	case "ADDIU":
+		return regs[1] == regs[0] && inst.SignedConstant16 == 0
+	case "ORI", "XORI":
+		return regs[1] == regs[0] && inst.UnsignedConstant16 == 0
+	case "SLL", "SRA", "SRL":
+		return regs[1] == regs[0] && inst.Constant5 == 0
+	}
+	return false
+}
+
+// canFillDelaySlot checks if an instruction right before a branch or jump can be moved into its
+// delay slot.
+func canFillDelaySlot(inst, control *Instruction) bool {
+	if isControlInstruction(inst) {
+		return false
+	}
+	switch inst.Name {
+	case "NOP", "SYSCALL", ".word":
+		return false
+	}
+	used := controlRegisters(control)
+	if dest, ok := destinationRegister(inst); ok {
+		for _, reg := range used {
+			if reg == dest {
+				return false
+			}
+		}
+	}
+
+	// The link register is written before the delay slot runs, so the instruction must not
+	// read it either.
+	if control.Name == "JAL" || control.Name == "JALR" {
+		link, _ := destinationRegister(control)
+		reads := append([]int{}, inst.Registers...)
+		switch inst.Name {
+		case "LB", "LBU", "LW", "SB", "SW":
+			reads = append(reads, inst.MemoryReference.Register)
+		}
+		for _, reg := range reads {
+			if reg == link {
+				return false
+			}
+		}
+	}
+	return true
+}
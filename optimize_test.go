@@ -0,0 +1,102 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	source := `LUI $t0, 0
+ORI $t0, $t0, 5
+ADDU $t0, $t0, $0 # does nothing
+LUI $t1, 0xffff
+ORI $t1, $t1, 0xfffe
+J NEXT
+ADDIU $t2, $0, 1
+NEXT:
+ADDIU $t3, $t3, 1
+BNE $t3, $t0, NEXT
+NOP
+ADDIU $t4, $0, 7
+JAL FUNC
+NOP
+J END
+ADDU $t5, $t5, $0
+FUNC:
+ADDIU $ra, $ra, 0
+ADDIU $t6, $t4, 1
+JR $ra
+NOP
+END:`
+	lines, err := TokenizeSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	optimized, report, err := Optimize(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, line := range optimized {
+		actual = append(actual, line.String())
+	}
+	expected := []string{
+		"ORI $8, $0, 5",
+		"# does nothing",
+		"ADDIU $9, $0, -2",
+		"ADDIU $10, $0, 1",
+		"NEXT:",
+		"ADDIU $11, $11, 1",
+		"BNE $11, $8, NEXT",
+		"NOP",
+		"JAL FUNC",
+		"ADDIU $12, $0, 7",
+		"J END",
+		"ADDU $13, $13, $0",
+		"FUNC:",
+		"JR $31",
+		"ADDIU $14, $12, 1",
+		"END:",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q but got %q", expected, actual)
+	}
+
+	var messages []string
+	for _, opt := range report {
+		messages = append(messages, opt.String())
+	}
+	expectedMessages := []string{
+		"line 1: folded LUI $8, 0 and ORI $8, $8, 5 into ORI $8, $0, 5",
+		"line 3: removed ADDU $8, $8, $0, which does nothing",
+		"line 4: folded LUI $9, 65535 and ORI $9, $9, 65534 into ADDIU $9, $0, -2",
+		"line 6: removed J NEXT, which jumps to the instruction after its delay slot",
+		"line 12: moved ADDIU $12, $0, 7 into the delay slot of JAL FUNC",
+		"line 18: removed ADDIU $31, $31, 0, which does nothing",
+		"line 19: moved ADDIU $14, $12, 1 into the delay slot of JR $31",
+	}
+	if !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("expected %q but got %q", expectedMessages, messages)
+	}
+
+	// The optimized program must do exactly what the original does.
+	var results [2]RegisterFile
+	for i, program := range [][]TokenizedLine{lines, optimized} {
+		exc, err := ParseExecutable(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+		for !emu.Done() {
+			if err := emu.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		results[i] = emu.RegisterFile
+	}
+	results[0][31], results[1][31] = 0, 0
+	if results[0] != results[1] {
+		t.Errorf("registers differ:\n%s\n\n%s", results[0], results[1])
+	}
+}
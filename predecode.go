@@ -0,0 +1,192 @@
+package mips32
+
+import "errors"
+
+// An instructionHandler runs an instruction after the program counter has been advanced.
+type instructionHandler func(e *Emulator, inst *Instruction) error
+
+// instructionHandlerFor finds the handler for an instruction name.
+func instructionHandlerFor(name string) instructionHandler {
+	switch name {
+	case "NOP":
+		return executeNOP
+	case "BEQ", "BGEZ", "BGTZ", "BLEZ", "BLTZ", "BNE":
+		return (*Emulator).executeBranch
+	case "J", "JR", "JAL", "JALR":
+		return (*Emulator).executeJump
+	case "LB", "LBU", "LW", "SB", "SW":
+		return (*Emulator).executeMemory
+	case "ADDU", "AND", "NOR", "OR", "SUBU", "XOR":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeRegisterArithmetic(inst)
+			return nil
+		}
+	case "ADDIU", "ANDI", "ORI", "XORI":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeImmediateArithmetic(inst)
+			return nil
+		}
+	case "LUI":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeLoadUpperImmediate(inst)
+			return nil
+		}
+	case "SLT", "SLTI", "SLTIU", "SLTU":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeSetLessThan(inst)
+			return nil
+		}
+	case "SLL", "SRL", "SRA":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeConstantShift(inst)
+			return nil
+		}
+	case "SLLV", "SRLV", "SRAV":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeRegisterShift(inst)
+			return nil
+		}
+	case "MOVN", "MOVZ":
+		return func(e *Emulator, inst *Instruction) error {
+			e.executeConditionalMove(inst)
+			return nil
+		}
+	case "SYSCALL":
+		return (*Emulator).executeSyscall
+	}
+	return (*Emulator).executeOther
+}
+
+func executeNOP(e *Emulator, inst *Instruction) error {
+	return nil
+}
+
+func (e *Emulator) executeSyscall(inst *Instruction) error {
+	if e.Syscalls == nil {
+		return e.instructionError("no syscall handler")
+	}
+	return e.Syscalls.Syscall(e)
+}
+
+// executeOther runs a custom instruction, or fails if the instruction is unknown.
+func (e *Emulator) executeOther(inst *Instruction) error {
+	if ok, err := e.executeCustomInstruction(inst); ok {
+		return err
+	}
+	return errors.New("unknown instruction: " + inst.Name)
+}
+
+// A decodeCache remembers the handler for each instruction in an executable, so that the
+// emulator does not have to dispatch on instruction names every time an instruction runs.
+type decodeCache struct {
+	executable *Executable
+	starts     uint32List
+	segments   map[uint32]*decodedSegment
+	end        uint32
+
+	// current is the segment which was used most recently.
+	current *decodedSegment
+}
+
+type decodedSegment struct {
+	start        uint32
+	instructions []Instruction
+	handlers     []instructionHandler
+
+	// names records the instruction names the handlers were chosen for, so that instructions
+	// which are modified in place get new handlers.
+	names []string
+}
+
+// sync makes sure the cache matches an executable.
+// Segments which were added, removed, or replaced since the last call are decoded again.
+// It returns true if anything changed.
+func (d *decodeCache) sync(e *Executable) bool {
+	if d.executable != e {
+		*d = decodeCache{executable: e, segments: map[uint32]*decodedSegment{}}
+	}
+	d.current = nil
+	if len(d.segments) == len(e.Segments) && len(e.Segments) > 0 {
+		unchanged := true
+		for start, seg := range d.segments {
+			insts := e.Segments[start]
+			if len(insts) != len(seg.instructions) ||
+				(len(insts) > 0 && &insts[0] != &seg.instructions[0]) {
+				unchanged = false
+				break
+			}
+		}
+		if unchanged {
+			return false
+		}
+	}
+	d.starts = e.sortedSegmentAddresses()
+	d.end = e.End()
+	old := d.segments
+	d.segments = make(map[uint32]*decodedSegment, len(e.Segments))
+	for start, insts := range e.Segments {
+		if seg := old[start]; seg != nil && len(seg.instructions) == len(insts) &&
+			(len(insts) == 0 || &insts[0] == &seg.instructions[0]) {
+			d.segments[start] = seg
+			continue
+		}
+		seg := &decodedSegment{
+			start:        start,
+			instructions: insts,
+			handlers:     make([]instructionHandler, len(insts)),
+			names:        make([]string, len(insts)),
+		}
+		for i, inst := range insts {
+			seg.handlers[i] = instructionHandlerFor(inst.Name)
+			seg.names[i] = inst.Name
+		}
+		d.segments[start] = seg
+	}
+	return true
+}
+
+// fetch finds the instruction at an address and its handler.
+// The instruction is nil if there is no instruction at the address.
+func (d *decodeCache) fetch(addr uint32) (*Instruction, instructionHandler) {
+	seg := d.current
+	if seg == nil || addr-seg.start >= uint32(len(seg.instructions))*4 {
+		start, ok := d.executable.segmentAt(d.starts, addr)
+		if !ok {
+			return nil, nil
+		}
+		seg = d.segments[start]
+		d.current = seg
+	}
+	idx := (addr - seg.start) >> 2
+	inst := &seg.instructions[idx]
+	if inst.Name != seg.names[idx] {
+		seg.handlers[idx] = instructionHandlerFor(inst.Name)
+		seg.names[idx] = inst.Name
+	}
+	return inst, seg.handlers[idx]
+}
+
+// stepDecoded implements StepN using the decode cache, without recording LastDelta.
+func (e *Emulator) stepDecoded(n int) (int, error) {
+	e.code.sync(e.program())
+	e.skipDelta = true
+	defer func() {
+		e.skipDelta = false
+		state := e.controlState()
+		e.LastDelta.reset(state)
+	}()
+	for i := 0; i < n; i++ {
+		if e.Halted || (!e.JumpNext && e.ProgramCounter >= e.code.end) {
+			return i, nil
+		}
+		inst, handler := e.code.fetch(e.ProgramCounter)
+		e.instructionAddress = e.ProgramCounter
+		e.advance()
+		if inst != nil {
+			if err := handler(e, inst); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+	return n, nil
+}
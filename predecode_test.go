@@ -0,0 +1,65 @@
+package mips32
+
+import "testing"
+
+func TestStepNMatchesStep(t *testing.T) {
+	slow := steppingTestEmulator(t)
+	fast := steppingTestEmulator(t)
+	for !slow.Done() {
+		if err := slow.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fast.StepN(1); err != nil {
+			t.Fatal(err)
+		}
+		if slow.RegisterFile != fast.RegisterFile || slow.ProgramCounter != fast.ProgramCounter ||
+			slow.JumpNext != fast.JumpNext {
+			t.Fatalf("state differs at pc=%d", slow.ProgramCounter)
+		}
+	}
+	if !fast.Done() {
+		t.Error("fast emulator should be done")
+	}
+
+	// LastDelta should not undo anything after StepN.
+	state := fast.RegisterFile
+	fast.Undo(&fast.LastDelta)
+	if fast.RegisterFile != state || !fast.Done() {
+		t.Error("LastDelta changed the state")
+	}
+}
+
+func TestStepNModifiedExecutable(t *testing.T) {
+	tokens, err := TokenizeSource("ADDIU $t0, $t0, 1\nADDIU $t1, $t1, 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	if _, err := emu.StepN(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying an instruction in place.
+	exc.Segments[0][1] = Instruction{Name: "ORI", Registers: []int{9, 0}, UnsignedConstant16: 7}
+	if _, err := emu.StepN(1); err != nil {
+		t.Fatal(err)
+	} else if emu.RegisterFile[9] != 7 {
+		t.Error("expected modified instruction to run, but t1 is", emu.RegisterFile[9])
+	}
+
+	// Replacing and extending a segment.
+	exc.Segments[0] = append(exc.Segments[0][:2:2], Instruction{
+		Name:             "ADDIU",
+		Registers:        []int{10, 10},
+		SignedConstant16: 5,
+	})
+	if steps, err := emu.StepN(10); err != nil || steps != 1 {
+		t.Fatal("unexpected result", steps, err)
+	} else if emu.RegisterFile[10] != 5 {
+		t.Error("expected added instruction to run, but t2 is", emu.RegisterFile[10])
+	}
+}
@@ -0,0 +1,87 @@
+package mips32
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// A RegisterPreset is a named set of initial register values, which can be applied to an
+// emulator before a program runs.
+type RegisterPreset struct {
+	Name      string
+	Registers map[int]uint32
+}
+
+// DefaultPresets are the presets offered by the tools and the web debugger.
+// The SPIM preset sets $gp and $sp to the values that SPIM uses.
+var DefaultPresets = []*RegisterPreset{
+	{
+		Name:      "SPIM",
+		Registers: map[int]uint32{28: 0x10008000, 29: 0x7fffeffc},
+	},
+}
+
+// PresetNamed finds a preset in DefaultPresets, or returns nil if there is no such preset.
+func PresetNamed(name string) *RegisterPreset {
+	for _, p := range DefaultPresets {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ParseRegisterPreset parses a comma-separated list of assignments, such as
+// "$a0=0x10010000, $sp=0x7fffeffc".
+//
+// Each value may be any constant expression that Evaluate accepts, e.g. "0x80000000-4".
+func ParseRegisterPreset(name, assignments string) (*RegisterPreset, error) {
+	res := &RegisterPreset{Name: name, Registers: map[int]uint32{}}
+	for _, assignment := range strings.Split(assignments, ",") {
+		assignment = strings.TrimSpace(assignment)
+		if assignment == "" {
+			continue
+		}
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("expected register=value: " + assignment)
+		}
+		reg, err := parseRegister(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		if reg == 0 {
+			return nil, errors.New("cannot set $zero")
+		}
+		p := &exprParser{text: strings.TrimSpace(parts[1])}
+		value, err := p.parse()
+		if err != nil {
+			return nil, errors.New("invalid value for " + parts[0] + ": " + err.Error())
+		}
+		res.Registers[reg] = value
+	}
+	return res, nil
+}
+
+// Apply sets the registers in an emulator's register file.
+func (r *RegisterPreset) Apply(e *Emulator) {
+	for reg, value := range r.Registers {
+		e.RegisterFile[reg] = value
+	}
+}
+
+// String formats the preset's registers in the format accepted by ParseRegisterPreset.
+// Registers are listed in numerical order and use their ABI names.
+func (r *RegisterPreset) String() string {
+	regs := make([]int, 0, len(r.Registers))
+	for reg := range r.Registers {
+		regs = append(regs, reg)
+	}
+	sort.Ints(regs)
+	parts := make([]string, len(regs))
+	for i, reg := range regs {
+		parts[i] = "$" + ABIRegisterNames[reg] + "=" + eightDigitHex(r.Registers[reg])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,41 @@
+package mips32
+
+import "testing"
+
+func TestParseRegisterPreset(t *testing.T) {
+	preset, err := ParseRegisterPreset("test", "$sp=0x7fffeffc, $4 = 16*4,$t0=-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]uint32{29: 0x7fffeffc, 4: 64, 8: 0xffffffff}
+	if len(preset.Registers) != len(expected) {
+		t.Fatalf("unexpected registers: %v", preset.Registers)
+	}
+	for reg, value := range expected {
+		if preset.Registers[reg] != value {
+			t.Errorf("register %d: expected %d but got %d", reg, value, preset.Registers[reg])
+		}
+	}
+	if s := preset.String(); s != "$a0=0x00000040, $t0=0xffffffff, $sp=0x7fffeffc" {
+		t.Errorf("unexpected string: %s", s)
+	}
+
+	for _, bad := range []string{"$sp", "$foo=1", "$zero=1", "$sp=[4]", "$sp=0x"} {
+		if _, err := ParseRegisterPreset("bad", bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestRegisterPresetApply(t *testing.T) {
+	emu := &Emulator{}
+	emu.RegisterFile[5] = 3
+	PresetNamed("SPIM").Apply(emu)
+	if emu.RegisterFile[29] != 0x7fffeffc || emu.RegisterFile[28] != 0x10008000 ||
+		emu.RegisterFile[5] != 3 {
+		t.Errorf("unexpected registers: %v", emu.RegisterFile)
+	}
+	if PresetNamed("nonexistent") != nil {
+		t.Error("unexpected preset")
+	}
+}
@@ -0,0 +1,33 @@
+package mips32
+
+import "strconv"
+
+// ABIRegisterNames maps register indices to their names in the MIPS calling convention.
+// The names do not include a leading "$".
+var ABIRegisterNames = [32]string{
+	"zero", "at", "v0", "v1", "a0", "a1", "a2", "a3",
+	"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7",
+	"s0", "s1", "s2", "s3", "s4", "s5", "s6", "s7",
+	"t8", "t9", "k0", "k1", "gp", "sp", "fp", "ra",
+}
+
+// A ValueFormat specifies how to display the contents of a register.
+type ValueFormat int
+
+const (
+	HexFormat ValueFormat = iota
+	SignedFormat
+	UnsignedFormat
+)
+
+// Format renders a value in the format.
+func (v ValueFormat) Format(value uint32) string {
+	switch v {
+	case SignedFormat:
+		return strconv.Itoa(int(int32(value)))
+	case UnsignedFormat:
+		return strconv.FormatUint(uint64(value), 10)
+	default:
+		return eightDigitHex(value)
+	}
+}
@@ -0,0 +1,29 @@
+package mips32
+
+import "testing"
+
+func TestValueFormat(t *testing.T) {
+	tests := []struct {
+		format   ValueFormat
+		value    uint32
+		expected string
+	}{
+		{HexFormat, 0x1337, "0x00001337"},
+		{SignedFormat, 0xffffffff, "-1"},
+		{SignedFormat, 5, "5"},
+		{UnsignedFormat, 0xffffffff, "4294967295"},
+	}
+	for _, test := range tests {
+		if actual := test.format.Format(test.value); actual != test.expected {
+			t.Errorf("expected %s but got %s", test.expected, actual)
+		}
+	}
+}
+
+func TestABIRegisterNames(t *testing.T) {
+	for i, name := range ABIRegisterNames {
+		if registerNames[name] != i {
+			t.Errorf("name %s does not match register %d", name, i)
+		}
+	}
+}
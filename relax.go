@@ -0,0 +1,190 @@
+package mips32
+
+import "strconv"
+
+// Relaxation levels record how much a line has been rewritten.
+// A branch at relaxNear branches around a J, and a branch or jump at relaxFar jumps through $at.
+const (
+	relaxNear = 1
+	relaxFar  = 2
+)
+
+// RelaxBranches rewrites the branches and jumps in a tokenized program which cannot reach their
+// targets, so that the program can be parsed by ParseExecutableLayout.
+//
+// A branch which is out of range becomes the opposite branch around a J.
+// For example, "BEQ $t0, $t1, FAR" becomes:
+//
+//	BNE $t0, $t1, skip
+//	NOP
+//	J FAR
+//	skip:
+//
+// The original delay slot becomes the delay slot of the J, so it still runs whether or not the
+// branch is taken.
+// A J or JAL whose target is outside of its 256 MB region loads the target into $at with LUI
+// and ORI, and uses JR or JALR instead.
+// This clobbers $at, which is reserved for the assembler anyway.
+//
+// Rewriting an instruction moves the code after it, which may push other branches out of range,
+// so this repeats until nothing else needs to be rewritten.
+// The new lines are given the line numbers of the lines they replace.
+func RelaxBranches(lines []TokenizedLine, layout *Layout) ([]TokenizedLine, error) {
+	r := &relaxer{
+		lines:       lines,
+		levels:      map[int]int{},
+		skipLabels:  map[int]string{},
+		usedSymbols: map[string]bool{},
+	}
+	for _, line := range lines {
+		if line.SymbolMarker != nil {
+			r.usedSymbols[*line.SymbolMarker] = true
+		}
+	}
+
+	var symbols map[string]uint32
+	var settled bool
+	for {
+		relaxed, indices := r.relaxedLines(symbols)
+
+		// Number each line by the index of the line it came from, so that instructions can be
+		// traced back to the lines which produced them.
+		for i := range relaxed {
+			relaxed[i].LineNumber = indices[i] + 1
+		}
+		exc, err := parseExecutableLayout(relaxed, layout)
+		for i := range relaxed {
+			relaxed[i].LineNumber = lines[indices[i]].LineNumber
+		}
+		if err != nil {
+			if srcErr, ok := err.(*SourceError); ok && srcErr.Line > 0 {
+				srcErr.Line = lines[srcErr.Line-1].LineNumber
+			}
+			return nil, err
+		}
+
+		// Once the levels stop changing, the layout is final, but the addresses loaded into $at
+		// came from the previous layout, so they are filled in one last time.
+		if settled {
+			return relaxed, nil
+		}
+		settled = !r.update(exc)
+		symbols = exc.Symbols
+	}
+}
+
+type relaxer struct {
+	lines       []TokenizedLine
+	levels      map[int]int
+	skipLabels  map[int]string
+	usedSymbols map[string]bool
+}
+
+// update increases the level of every line which produced an instruction that cannot reach its
+// target, and returns whether any level changed.
+func (r *relaxer) update(exc *Executable) bool {
+	var changed bool
+	for _, segment := range exc.sortedSegmentAddresses() {
+		for i := range exc.Segments[segment] {
+			addr := segment + uint32(i*4)
+			if controlRangeProblem(&exc.Segments[segment][i], addr, exc.Symbols) == "" {
+				continue
+			}
+			index := exc.LineNumbers[addr] - 1
+			if r.levels[index] == relaxFar {
+				continue
+			}
+			if _, ok := invertedBranches[r.lines[index].Instruction.Name]; ok {
+				r.levels[index]++
+			} else {
+				// Jumps have nothing to branch around, so they go straight through $at.
+				r.levels[index] = relaxFar
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// relaxedLines rewrites the program according to the current levels, using the symbol table to
+// compute the addresses loaded into $at.
+// It also returns the index of the original line for each new line.
+func (r *relaxer) relaxedLines(symbols map[string]uint32) ([]TokenizedLine, []int) {
+	var res []TokenizedLine
+	var indices []int
+	for i, line := range r.lines {
+		level := r.levels[i]
+		if level == 0 {
+			res = append(res, line)
+			indices = append(indices, i)
+			continue
+		}
+		inst := line.Instruction
+		var replacement []TokenizedLine
+		if inverted, ok := invertedBranches[inst.Name]; ok {
+			skip := r.skipLabel(i)
+			args := append([]*ArgToken{}, inst.Arguments[:len(inst.Arguments)-1]...)
+			args = append(args, &ArgToken{isSymbol: true, symbol: skip})
+			nop := &TokenizedInstruction{Name: "NOP", Arguments: []*ArgToken{}}
+			replacement = append(replacement,
+				TokenizedLine{Instruction: &TokenizedInstruction{Name: inverted, Arguments: args}},
+				TokenizedLine{Instruction: nop})
+			target := inst.Arguments[len(inst.Arguments)-1]
+			replacement = append(replacement, relaxedJump("J", target, level == relaxFar,
+				symbols)...)
+			replacement = append(replacement, TokenizedLine{SymbolMarker: &skip})
+		} else {
+			replacement = relaxedJump(inst.Name, inst.Arguments[0], true, symbols)
+		}
+		replacement[0].Comment = line.Comment
+		for _, newLine := range replacement {
+			res = append(res, newLine)
+			indices = append(indices, i)
+		}
+	}
+	return res, indices
+}
+
+// skipLabel returns a unique symbol for the end of a relaxed branch.
+func (r *relaxer) skipLabel(index int) string {
+	if label, ok := r.skipLabels[index]; ok {
+		return label
+	}
+	label := "relax_skip_" + strconv.Itoa(index)
+	for r.usedSymbols[label] {
+		label += "_"
+	}
+	r.usedSymbols[label] = true
+	r.skipLabels[index] = label
+	return label
+}
+
+// relaxedJump generates a J or JAL to a target, going through $at if far is set.
+func relaxedJump(name string, target *ArgToken, far bool,
+	symbols map[string]uint32) []TokenizedLine {
+	if !far {
+		return []TokenizedLine{
+			{Instruction: &TokenizedInstruction{Name: name, Arguments: []*ArgToken{target}}},
+		}
+	}
+	addr := target.constant
+	if target.isSymbol {
+		addr = symbols[target.symbol]
+	}
+	at := &ArgToken{isRegister: true, register: 1}
+	register := "JR"
+	if name == "JAL" {
+		register = "JALR"
+	}
+	return []TokenizedLine{
+		{Instruction: &TokenizedInstruction{
+			Name:      "LUI",
+			Arguments: []*ArgToken{at, {isConstant: true, constant: addr >> 16}},
+		}},
+		{Instruction: &TokenizedInstruction{
+			Name:      "ORI",
+			Arguments: []*ArgToken{at, at, {isConstant: true, constant: addr & 0xffff}},
+		}},
+		{Instruction: &TokenizedInstruction{Name: register, Arguments: []*ArgToken{at}}},
+	}
+}
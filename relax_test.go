@@ -0,0 +1,89 @@
+package mips32
+
+import "testing"
+
+func TestRelaxBranches(t *testing.T) {
+	lines, err := TokenizeSource(`.text 0x10000000
+BNE $0, $0, SUB
+ADDIU $t1, $t1, 1
+BEQ $0, $0, SUB
+ADDIU $t1, $t1, 2
+ADDIU $t2, $0, 99
+BACK:
+JAL LOW
+ADDIU $t4, $t4, 1
+J END
+NOP
+.text 0x10040000
+SUB:
+J BACK
+ADDIU $t3, $t3, 1
+END:
+.text 0
+LOW:
+JR $ra
+ADDIU $t5, $0, 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseExecutable(lines); err == nil {
+		t.Fatal("expected range error before relaxing")
+	}
+	relaxed, err := RelaxBranches(lines, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(relaxed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc.LineNumbers[0x10000008] != 2 || exc.LineNumbers[0x10000018] != 4 {
+		t.Error("unexpected line numbers:", exc.LineNumbers)
+	}
+
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, ProgramCounter: 0x10000000}
+	for i := 0; !emu.Done(); i++ {
+		if i > 100 {
+			t.Fatal("program did not finish")
+		}
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[int]uint32{1: 0, 9: 3, 10: 0, 11: 1, 12: 1, 13: 3}
+	for reg, value := range expected {
+		if emu.RegisterFile[reg] != value {
+			t.Errorf("expected $%d to be %d but got %d", reg, value, emu.RegisterFile[reg])
+		}
+	}
+}
+
+func TestRelaxBranchesInRange(t *testing.T) {
+	lines, err := TokenizeSource("LOOP:\nBNE $t0, $0, LOOP\nNOP\nJ LOOP\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relaxed, err := RelaxBranches(lines, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relaxed) != len(lines) {
+		t.Fatal("expected no changes")
+	}
+	for i := range lines {
+		if !relaxed[i].Equal(&lines[i]) {
+			t.Errorf("line %d: expected %s but got %s", i, lines[i].String(), relaxed[i].String())
+		}
+	}
+}
+
+func TestRelaxBranchesError(t *testing.T) {
+	lines, err := TokenizeSource("NOP\n\nBEQ $0, $0, FAR\nNOP\nNOP\nFAR:\nFAR:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RelaxBranches(lines, nil); err == nil || err.Error() !=
+		"line 7: repeated symbol declaration: FAR" {
+		t.Error("unexpected error:", err)
+	}
+}
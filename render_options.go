@@ -0,0 +1,96 @@
+package mips32
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A RegisterStyle determines how registers are spelled in rendered assembly.
+// Any of these spellings is accepted as input, regardless of the style used for output.
+type RegisterStyle int
+
+const (
+	// NumericRegisters spells registers by number, as in "$8".
+	NumericRegisters RegisterStyle = iota
+
+	// ABIRegisters spells registers by their calling convention names, as in "$t0".
+	ABIRegisters
+
+	// PrefixedRegisters spells registers by number with an "r" prefix, as in "$r8".
+	PrefixedRegisters
+)
+
+// ParseRegisterStyle parses the name of a register style: "numeric", "abi", or "prefixed".
+func ParseRegisterStyle(name string) (RegisterStyle, error) {
+	switch name {
+	case "numeric":
+		return NumericRegisters, nil
+	case "abi":
+		return ABIRegisters, nil
+	case "prefixed":
+		return PrefixedRegisters, nil
+	}
+	return 0, errors.New("unknown register style: " + name)
+}
+
+// RenderOptions controls how tokenized lines are turned into text.
+// A nil *RenderOptions, like the zero value, produces the same text as String.
+type RenderOptions struct {
+	Registers RegisterStyle
+
+	// HexImmediates writes constants in hexadecimal rather than decimal.
+	// Shift amounts are always written in decimal.
+	HexImmediates bool
+
+	// UppercaseHex uses "0xFFFF" rather than "0xffff" for hexadecimal constants.
+	UppercaseHex bool
+
+	// NegativeHex writes negative signed constants (such as branch offsets and memory offsets)
+	// as their 32-bit two's complement, e.g. "0xfffffffc" instead of "-0x4".
+	// It only applies when HexImmediates is set.
+	NegativeHex bool
+
+	// LowercaseMnemonics writes instruction names as "addi" rather than "ADDI".
+	// Instruction names, registers, and directives are accepted in any case as input.
+	LowercaseMnemonics bool
+}
+
+func (r *RenderOptions) mnemonic(name string) string {
+	if r != nil && r.LowercaseMnemonics {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (r *RenderOptions) register(reg int) string {
+	if r != nil {
+		switch r.Registers {
+		case ABIRegisters:
+			return "$" + ABIRegisterNames[reg]
+		case PrefixedRegisters:
+			return "$r" + strconv.Itoa(reg)
+		}
+	}
+	return registerToString(reg)
+}
+
+func (r *RenderOptions) unsigned(c uint32) string {
+	if r == nil || !r.HexImmediates {
+		return unsignedConst32ToString(c)
+	}
+	digits := strconv.FormatUint(uint64(c), 16)
+	if r.UppercaseHex {
+		digits = strings.ToUpper(digits)
+	}
+	return "0x" + digits
+}
+
+func (r *RenderOptions) signed(c int32) string {
+	if r == nil || !r.HexImmediates {
+		return signedConst32ToString(c)
+	} else if c < 0 && !r.NegativeHex {
+		return "-" + r.unsigned(uint32(-int64(c)))
+	}
+	return r.unsigned(uint32(c))
+}
@@ -0,0 +1,113 @@
+package mips32
+
+import "testing"
+
+func TestRenderOptionsRegisters(t *testing.T) {
+	lines, err := TokenizeSource("ADDU $t0, $r9, $10\nLW $ra, -4($sp)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[RegisterStyle][]string{
+		NumericRegisters:  {"ADDU $8, $9, $10", "LW $31, -4($29)"},
+		ABIRegisters:      {"ADDU $t0, $t1, $t2", "LW $ra, -4($sp)"},
+		PrefixedRegisters: {"ADDU $r8, $r9, $r10", "LW $r31, -4($r29)"},
+	}
+	for style, strs := range expected {
+		opts := &RenderOptions{Registers: style}
+		for i, line := range lines {
+			if actual := line.Format(opts); actual != strs[i] {
+				t.Errorf("style %d: expected %q but got %q", style, strs[i], actual)
+			}
+		}
+	}
+	if lines[0].Format(nil) != lines[0].String() {
+		t.Error("nil options should match String")
+	}
+
+	// Every style must be accepted as input.
+	for _, style := range []RegisterStyle{NumericRegisters, ABIRegisters, PrefixedRegisters} {
+		opts := &RenderOptions{Registers: style}
+		reparsed, err := TokenizeSource(lines[1].Format(opts))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reparsed[0].Instruction.Equal(lines[1].Instruction) {
+			t.Errorf("style %d did not round trip", style)
+		}
+	}
+}
+
+func TestParseRegisterStyle(t *testing.T) {
+	if style, err := ParseRegisterStyle("abi"); err != nil || style != ABIRegisters {
+		t.Error("unexpected result", style, err)
+	}
+	if _, err := ParseRegisterStyle("ABI"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestRenderOptionsMnemonics(t *testing.T) {
+	lines, err := TokenizeSource("AddIU $t0, $t0, 1\nnop\nfoo $t0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &RenderOptions{LowercaseMnemonics: true}
+	expected := []string{"addiu $8, $8, 1", "nop", "foo # UNRECOGNIZED INSTRUCTION."}
+	for i, line := range lines {
+		if actual := line.Format(opts); actual != expected[i] {
+			t.Errorf("line %d: expected %q but got %q", i+1, expected[i], actual)
+		}
+	}
+	if actual := lines[0].Format(nil); actual != "ADDIU $8, $8, 1" {
+		t.Errorf("unexpected default rendering: %q", actual)
+	}
+
+	formatted, err := FormatOptions("NOP\nADDIU $t0, $t0, 1\n", opts)
+	if err != nil {
+		t.Fatal(err)
+	} else if formatted != "    nop\n    addiu $8, $8, 1\n" {
+		t.Errorf("unexpected formatted code: %q", formatted)
+	}
+}
+
+func TestRenderOptionsImmediates(t *testing.T) {
+	lines, err := TokenizeSource(`.text 0x1000
+ADDIU $t0, $t0, -4
+ORI $t0, $t0, 0xabcd
+SLL $t0, $t0, 12
+SW $t0, -8($sp)
+BNE $t0, $0, -12
+J 0x1000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := []*RenderOptions{
+		{HexImmediates: true},
+		{HexImmediates: true, UppercaseHex: true, NegativeHex: true},
+	}
+	expected := [][]string{
+		{
+			".text 0x1000", "ADDIU $8, $8, -0x4", "ORI $8, $8, 0xabcd", "SLL $8, $8, 12",
+			"SW $8, -0x8($29)", "BNE $8, $0, -0xc", "J 0x1000",
+		},
+		{
+			".text 0x1000", "ADDIU $8, $8, 0xFFFFFFFC", "ORI $8, $8, 0xABCD", "SLL $8, $8, 12",
+			"SW $8, 0xFFFFFFF8($29)", "BNE $8, $0, 0xFFFFFFF4", "J 0x1000",
+		},
+	}
+	for i, opts := range options {
+		for j, line := range lines {
+			actual := line.Format(opts)
+			if actual != expected[i][j] {
+				t.Errorf("options %d: expected %q but got %q", i, expected[i][j], actual)
+				continue
+			}
+			reparsed, err := TokenizeSource(actual)
+			if err != nil {
+				t.Errorf("options %d: %s", i, err)
+			} else if line.Instruction != nil &&
+				!reparsed[0].Instruction.Equal(line.Instruction) {
+				t.Errorf("options %d: %q did not round trip", i, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package mips32
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A RoundTripError describes a step at which an instruction failed to survive a round trip
+// through decoding, rendering, tokenizing, parsing, and encoding.
+type RoundTripError struct {
+	// Word is the encoding the round trip started from, or 0 if the instruction could not be
+	// encoded in the first place.
+	Word uint32
+
+	// Step is "encode", "render", "tokenize", "parse", or "compare".
+	Step string
+
+	// Text is the rendered assembly, if the round trip got that far.
+	Text string
+
+	Err error
+}
+
+func (r *RoundTripError) Error() string {
+	res := "round trip of " + eightDigitHex(r.Word) + " failed at " + r.Step
+	if r.Text != "" {
+		res += " (" + strconv.Quote(r.Text) + ")"
+	}
+	return res + ": " + r.Err.Error()
+}
+
+// CheckRoundTrip checks that a word survives being decoded, rendered as assembly, tokenized,
+// parsed, and encoded again, and that decoding and encoding it directly agrees with that.
+//
+// If DecodeInstruction ignores some of a word's bits, the word decodes to the same instruction
+// as a canonical encoding, so it cannot be reproduced exactly.
+// In that case, canonical is false and the round trip is checked against the canonical encoding
+// instead.
+// (DecodeInstruction currently turns such words into ".word" directives, so this only happens
+// with a more lenient decoder.)
+//
+// Any failure is returned as a *RoundTripError.
+func CheckRoundTrip(word uint32) (canonical bool, err error) {
+	decoded := DecodeInstruction(word)
+	encoded, err := decoded.Encode(0, nil)
+	if err != nil {
+		return false, &RoundTripError{Word: word, Step: "encode", Err: err}
+	}
+	if err := checkInstructionRoundTrip(decoded, encoded, 0, nil); err != nil {
+		err.Word = word
+		return false, err
+	}
+	if redecoded, err := DecodeInstruction(encoded).Encode(0, nil); err != nil ||
+		redecoded != encoded {
+		return false, &RoundTripError{
+			Word: word,
+			Step: "compare",
+			Err:  errors.New("canonical encoding " + eightDigitHex(encoded) + " is not canonical"),
+		}
+	}
+	return encoded == word, nil
+}
+
+// CheckInstructionRoundTrip is like CheckRoundTrip, but starts from an instruction at an address
+// instead of a word.
+// The symbols are used to resolve code pointers which refer to symbols.
+//
+// The instruction is encoded, and then that word is decoded and encoded again.
+// Separately, the instruction is rendered, tokenized, parsed, and encoded.
+// Both results must match the first encoding.
+func CheckInstructionRoundTrip(inst *Instruction, addr uint32,
+	symbols map[string]uint32) error {
+	word, err := inst.Encode(addr, symbols)
+	if err != nil {
+		return &RoundTripError{Step: "encode", Err: err}
+	}
+	if err := checkInstructionRoundTrip(inst, word, addr, symbols); err != nil {
+		return err
+	}
+	if redecoded, err := DecodeInstruction(word).Encode(addr, symbols); err != nil ||
+		redecoded != word {
+		return &RoundTripError{
+			Word: word,
+			Step: "compare",
+			Err:  errors.New("decoding and encoding gives " + eightDigitHex(redecoded)),
+		}
+	}
+	return nil
+}
+
+// checkInstructionRoundTrip renders, tokenizes, parses, and encodes an instruction, and checks
+// that the result is the expected word.
+func checkInstructionRoundTrip(inst *Instruction, word, addr uint32,
+	symbols map[string]uint32) *RoundTripError {
+	fail := func(step, text string, err error) *RoundTripError {
+		return &RoundTripError{Word: word, Step: step, Text: text, Err: err}
+	}
+
+	rendered, err := inst.Render()
+	if err != nil {
+		return fail("render", "", err)
+	}
+	text := rendered.String()
+	lines, err := TokenizeSource(text)
+	if err != nil {
+		return fail("tokenize", text, err)
+	}
+	if len(lines) != 1 {
+		return fail("tokenize", text, errors.New("expected 1 line but got "+
+			strconv.Itoa(len(lines))))
+	}
+
+	var parsed *Instruction
+	if dir := lines[0].Directive; dir != nil && dir.Name == "word" && len(dir.Values) == 1 {
+		parsed = DecodeInstruction(dir.Values[0].Constant)
+	} else if lines[0].Instruction != nil {
+		parsed, err = ParseTokenizedInstruction(lines[0].Instruction)
+		if err != nil {
+			return fail("parse", text, err)
+		}
+	} else {
+		return fail("tokenize", text, errors.New("not an instruction"))
+	}
+
+	reencoded, err := parsed.Encode(addr, symbols)
+	if err != nil {
+		return fail("encode", text, err)
+	}
+	if reencoded != word {
+		return fail("compare", text, errors.New("expected "+eightDigitHex(word)+" but got "+
+			eightDigitHex(reencoded)))
+	}
+	return nil
+}
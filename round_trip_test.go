@@ -0,0 +1,53 @@
+package mips32
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCheckRoundTrip(t *testing.T) {
+	words := []uint32{0, 0x24080005, 0x0128500a, 0x0c000009, 0x1509fffe, 0x03e00008, 0x0000000c,
+		0xffffffff}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		words = append(words, r.Uint32())
+	}
+	for _, word := range words {
+		canonical, err := CheckRoundTrip(word)
+		if err != nil {
+			t.Fatal(err)
+		} else if !canonical {
+			t.Errorf("word %08x should be canonical", word)
+		}
+	}
+}
+
+func TestCheckInstructionRoundTrip(t *testing.T) {
+	lines, err := TokenizeSource(`
+BNE $t0, $t1, END
+JAL END
+LW $t0, -4($sp)
+END:
+.word 0xffffffff
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range exc.Segments[0] {
+		addr := uint32(i * 4)
+		if err := CheckInstructionRoundTrip(&exc.Segments[0][i], addr, exc.Symbols); err != nil {
+			t.Error(err)
+		}
+	}
+
+	bad := &Instruction{Name: "ADDIU", Registers: []int{8}}
+	if err := CheckInstructionRoundTrip(bad, 0, nil); err == nil {
+		t.Error("expected an error")
+	} else if rtErr, ok := err.(*RoundTripError); !ok || rtErr.Step != "encode" {
+		t.Error("unexpected error:", err)
+	}
+}
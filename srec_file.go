@@ -0,0 +1,131 @@
+package mips32
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const srecRecordSize = 16
+
+// WriteSRecord writes chunks of memory as a Motorola S-record file.
+// Data is written with S3 records, which use 32-bit addresses.
+func WriteSRecord(w io.Writer, chunks map[uint32][]byte) error {
+	buf := bufio.NewWriter(w)
+
+	starts := make(uint32List, 0, len(chunks))
+	for start := range chunks {
+		starts = append(starts, start)
+	}
+	sort.Sort(starts)
+
+	writeSRecord(buf, '0', []byte{0, 0}, nil)
+	for _, start := range starts {
+		data := chunks[start]
+		for offset := 0; offset < len(data); offset += srecRecordSize {
+			end := offset + srecRecordSize
+			if end > len(data) {
+				end = len(data)
+			}
+			addr := start + uint32(offset)
+			addrBytes := []byte{byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}
+			writeSRecord(buf, '3', addrBytes, data[offset:end])
+		}
+	}
+	writeSRecord(buf, '7', []byte{0, 0, 0, 0}, nil)
+
+	return buf.Flush()
+}
+
+func writeSRecord(w *bufio.Writer, recordType byte, addr, data []byte) {
+	record := []byte{byte(len(addr) + len(data) + 1)}
+	record = append(record, addr...)
+	record = append(record, data...)
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	record = append(record, ^sum)
+
+	w.WriteString("S")
+	w.WriteByte(recordType)
+	for _, b := range record {
+		w.WriteString(twoDigitHex(b))
+	}
+	w.WriteString("\n")
+}
+
+// ReadSRecord reads a Motorola S-record file and returns the chunks of memory it describes.
+// Records may appear in any order, and adjacent records are joined into a single chunk.
+// Records which overlap are an error.
+func ReadSRecord(r io.Reader) (map[uint32][]byte, error) {
+	var records []dataRecord
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		recordType, record, err := parseSRecord(line)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(lineNum) + ": " + err.Error())
+		}
+		var addrSize int
+		switch recordType {
+		case '1':
+			addrSize = 2
+		case '2':
+			addrSize = 3
+		case '3':
+			addrSize = 4
+		default:
+			continue
+		}
+		if len(record) < addrSize+2 {
+			return nil, errors.New("line " + strconv.Itoa(lineNum) + ": record too short")
+		}
+		var addr uint32
+		for _, b := range record[1 : 1+addrSize] {
+			addr = (addr << 8) | uint32(b)
+		}
+		records = append(records, dataRecord{
+			addr: addr,
+			data: record[1+addrSize : len(record)-1],
+			line: lineNum,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return joinDataRecords(records)
+}
+
+// parseSRecord decodes and verifies a single record.
+// The result includes the count, address, data, and checksum bytes.
+func parseSRecord(line string) (recordType byte, record []byte, err error) {
+	if len(line) < 2 || line[0] != 'S' || line[1] < '0' || line[1] > '9' {
+		return 0, nil, errors.New("invalid record type")
+	}
+	record, err = hex.DecodeString(line[2:])
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(record) < 2 || len(record) != int(record[0])+1 {
+		return 0, nil, errors.New("invalid record length")
+	}
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	if sum != 0xff {
+		return 0, nil, errors.New("bad checksum")
+	}
+	return line[1], record, nil
+}
@@ -0,0 +1,69 @@
+package mips32
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteSRecord(t *testing.T) {
+	chunks := map[uint32][]byte{
+		0x100: []byte{0x3c, 0x01, 0xde, 0xad},
+	}
+	var buf bytes.Buffer
+	if err := WriteSRecord(&buf, chunks); err != nil {
+		t.Fatal(err)
+	}
+	expected := "S0030000FC\n" +
+		"S30900000100" + "3C01DEAD" + "2D\n" +
+		"S70500000000FA\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestReadSRecord(t *testing.T) {
+	chunks := map[uint32][]byte{
+		0x100:      []byte{0x3c, 0x01, 0xde, 0xad},
+		0x1fff0:    bytes.Repeat([]byte{0x55}, 40),
+		0xfffffffe: []byte{1, 2},
+	}
+	var buf bytes.Buffer
+	if err := WriteSRecord(&buf, chunks); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadSRecord(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, chunks) {
+		t.Errorf("expected %v but got %v", chunks, decoded)
+	}
+
+	decoded, err = ReadSRecord(strings.NewReader("S1070010AABBCCDDDA\nS9030000FC\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[uint32][]byte{0x10: []byte{0xaa, 0xbb, 0xcc, 0xdd}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %v but got %v", expected, decoded)
+	}
+
+	decoded, err = ReadSRecord(strings.NewReader("S1050014EEFFF9\nS1070010AABBCCDDDA\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[uint32][]byte{0x10: []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %v but got %v", expected, decoded)
+	}
+
+	if _, err := ReadSRecord(strings.NewReader("S1070010AABBCCDDDB\n")); err == nil {
+		t.Error("expected checksum error")
+	}
+	overlap := "S1070010AABBCCDDDA\nS1060012010203E1\n"
+	if _, err := ReadSRecord(strings.NewReader(overlap)); err == nil {
+		t.Error("expected overlap error")
+	}
+}
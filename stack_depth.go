@@ -0,0 +1,211 @@
+package mips32
+
+// A StackUsage estimates how much stack memory a function needs.
+type StackUsage struct {
+	// Function is the function's entry point (see CallGraph).
+	Function uint32 `json:"function"`
+
+	// Frame is the most stack space which the function allocates itself, in bytes.
+	Frame uint32 `json:"frame"`
+
+	// Total is the most stack space which the function and the functions it calls may use at
+	// once, in bytes.
+	// If Bounded returns false, this is a lower bound.
+	Total uint32 `json:"total"`
+
+	// Path is the chain of calls which uses Total bytes, starting with Function.
+	Path []uint32 `json:"path"`
+
+	// Recursive is set if the function, or a function it calls, may call itself.
+	Recursive bool `json:"recursive,omitempty"`
+
+	// IndirectCalls is set if the function, or a function it calls, makes a call whose target
+	// is unknown (see CallSite).
+	IndirectCalls bool `json:"indirectCalls,omitempty"`
+
+	// DynamicStack is set if the function, or a function it calls, changes $sp by something
+	// other than a constant, or may allocate more stack each time around a loop.
+	DynamicStack bool `json:"dynamicStack,omitempty"`
+}
+
+// Bounded returns true if Total is a true upper bound, i.e. if the analysis found no recursion,
+// indirect calls, or dynamic stack allocation.
+func (s *StackUsage) Bounded() bool {
+	return !s.Recursive && !s.IndirectCalls && !s.DynamicStack
+}
+
+// AnalyzeStackUsage estimates the worst-case stack usage of every function in the call graph.
+//
+// Stack space is allocated and freed by "ADDIU $sp, $sp, N" instructions, which are followed
+// along each path through a function.
+// A jump from one function into another (a tail call) counts as a call.
+// The results are sorted by entry point.
+func AnalyzeStackUsage(e *Executable) []StackUsage {
+	graph := BuildCallGraph(e)
+	a := &stackAnalysis{
+		executable: e,
+		cfg:        BuildCFG(e),
+		sites:      map[uint32]CallSite{},
+		entries:    map[uint32]bool{},
+		frames:     map[uint32]*stackFrame{},
+		results:    map[uint32]*StackUsage{},
+	}
+	for _, site := range graph.Sites {
+		a.sites[site.Address] = site
+	}
+	for _, function := range graph.Functions {
+		a.entries[function] = true
+	}
+	for _, function := range graph.Functions {
+		a.frames[function] = a.frame(function)
+	}
+
+	res := make([]StackUsage, 0, len(graph.Functions))
+	for _, function := range graph.Functions {
+		usage := *a.usage(function)
+		for callee := range a.reachable(function) {
+			frame := a.frames[callee]
+			usage.IndirectCalls = usage.IndirectCalls || frame.indirect
+			usage.DynamicStack = usage.DynamicStack || frame.dynamic
+			if !usage.Recursive {
+				for _, call := range frame.calls {
+					if a.reachable(call.callee)[callee] {
+						usage.Recursive = true
+						break
+					}
+				}
+			}
+		}
+		res = append(res, usage)
+	}
+	return res
+}
+
+type stackAnalysis struct {
+	executable *Executable
+	cfg        *ControlFlowGraph
+	sites      map[uint32]CallSite
+	entries    map[uint32]bool
+	frames     map[uint32]*stackFrame
+	results    map[uint32]*StackUsage
+	reach      map[uint32]map[uint32]bool
+}
+
+// A stackFrame summarizes the stack usage of a function, not counting the functions it calls.
+type stackFrame struct {
+	size     int64
+	calls    []stackCall
+	indirect bool
+	dynamic  bool
+}
+
+// A stackCall is a call (or tail call) made when a function has allocated depth bytes.
+type stackCall struct {
+	callee uint32
+	depth  int64
+}
+
+// frame walks every path through a function, tracking the stack space it has allocated.
+func (a *stackAnalysis) frame(function uint32) *stackFrame {
+	res := &stackFrame{}
+	depths := map[uint32]int64{}
+	queue := []stackCall{{callee: function}}
+	for len(queue) > 0 {
+		start, depth := queue[0].callee, queue[0].depth
+		queue = queue[1:]
+		block := a.cfg.Block(start)
+		if block == nil {
+			continue
+		} else if old, ok := depths[start]; ok {
+			if depth > old {
+				res.dynamic = true
+			}
+			continue
+		}
+		depths[start] = depth
+		for addr := start; addr < block.End; addr += 4 {
+			inst := a.executable.Get(addr)
+			if site, ok := a.sites[addr]; ok {
+				if site.Resolved {
+					res.calls = append(res.calls, stackCall{callee: site.Callee, depth: depth})
+				} else {
+					res.indirect = true
+				}
+			}
+			if reg, ok := destinationRegister(inst); ok && reg == 29 {
+				if inst.Name == "ADDIU" && inst.Registers[1] == 29 {
+					depth -= int64(inst.SignedConstant16)
+				} else {
+					res.dynamic = true
+				}
+			}
+			if depth > res.size {
+				res.size = depth
+			}
+		}
+		for _, successor := range block.Successors {
+			if a.entries[successor] && successor != function {
+				res.calls = append(res.calls, stackCall{callee: successor, depth: depth})
+			} else {
+				queue = append(queue, stackCall{callee: successor, depth: depth})
+			}
+		}
+	}
+	return res
+}
+
+// usage computes the deepest chain of calls from a function.
+// Calls which lead back to a function whose usage is still being computed are skipped, so the
+// results for recursive functions are lower bounds.
+func (a *stackAnalysis) usage(function uint32) *StackUsage {
+	if res, ok := a.results[function]; ok {
+		return res
+	}
+	frame := a.frames[function]
+	res := &StackUsage{
+		Function: function,
+		Frame:    uint32(frame.size),
+		Total:    uint32(frame.size),
+		Path:     []uint32{function},
+	}
+	a.results[function] = res
+	for _, call := range frame.calls {
+		callee := a.usage(call.callee)
+		depth := call.depth
+		if depth < 0 {
+			depth = 0
+		}
+		if total := depth + int64(callee.Total); total > int64(res.Total) {
+			res.Total = uint32(total)
+			res.Path = append([]uint32{function}, callee.Path...)
+		}
+	}
+	return res
+}
+
+// reachable finds the functions which a function may call, directly or indirectly, including
+// tail calls.
+// The result includes the function itself.
+func (a *stackAnalysis) reachable(function uint32) map[uint32]bool {
+	if a.reach == nil {
+		a.reach = map[uint32]map[uint32]bool{}
+	}
+	if res, ok := a.reach[function]; ok {
+		return res
+	}
+	res := map[uint32]bool{}
+	queue := []uint32{function}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if res[f] {
+			continue
+		}
+		res[f] = true
+		for _, call := range a.frames[f].calls {
+			queue = append(queue, call.callee)
+		}
+	}
+	a.reach[function] = res
+	return res
+}
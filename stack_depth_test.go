@@ -0,0 +1,118 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeStackUsage(t *testing.T) {
+	exc, err := Assemble(`main:
+	ADDIU $sp, $sp, -8
+	SW $ra, 4($sp)
+	JAL leaf
+	NOP
+	JAL mid
+	NOP
+	LW $ra, 4($sp)
+	ADDIU $sp, $sp, 8
+	JR $ra
+	NOP
+leaf:
+	ADDIU $sp, $sp, -16
+	ADDIU $sp, $sp, 16
+	JR $ra
+	NOP
+mid:
+	ADDIU $sp, $sp, -4
+	JAL leaf
+	NOP
+	ADDIU $sp, $sp, 4
+	J leaf
+	NOP
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, leaf, mid := exc.Symbols["main"], exc.Symbols["leaf"], exc.Symbols["mid"]
+	expected := []StackUsage{
+		{Function: main, Frame: 8, Total: 28, Path: []uint32{main, mid, leaf}},
+		{Function: leaf, Frame: 16, Total: 16, Path: []uint32{leaf}},
+		{Function: mid, Frame: 4, Total: 20, Path: []uint32{mid, leaf}},
+	}
+	actual := AnalyzeStackUsage(exc)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v but got %+v", expected, actual)
+	}
+	for _, usage := range actual {
+		if !usage.Bounded() {
+			t.Errorf("%08x: expected a bounded usage", usage.Function)
+		}
+	}
+}
+
+func TestAnalyzeStackUsageFlags(t *testing.T) {
+	exc, err := Assemble(`main:
+	JAL fact
+	NOP
+	JAL indirect
+	NOP
+	JAL dynamic
+	NOP
+	JAL loop
+	NOP
+	JR $ra
+	NOP
+fact:
+	ADDIU $sp, $sp, -8
+	BEQ $a0, $0, done
+	ADDIU $a0, $a0, -1
+	JAL fact
+	NOP
+done:
+	ADDIU $sp, $sp, 8
+	JR $ra
+	NOP
+indirect:
+	JALR $t1
+	NOP
+	JR $ra
+	NOP
+dynamic:
+	SUBU $sp, $sp, $a0
+	JR $ra
+	NOP
+loop:
+	ADDIU $sp, $sp, -4
+	BNE $a0, $0, loop
+	NOP
+	JR $ra
+	NOP
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := map[string][3]bool{
+		"main":     {true, true, true},
+		"fact":     {true, false, false},
+		"indirect": {false, true, false},
+		"dynamic":  {false, false, true},
+		"loop":     {false, false, true},
+	}
+	usages := map[uint32]StackUsage{}
+	for _, usage := range AnalyzeStackUsage(exc) {
+		usages[usage.Function] = usage
+	}
+	for name, expected := range flags {
+		usage := usages[exc.Symbols[name]]
+		actual := [3]bool{usage.Recursive, usage.IndirectCalls, usage.DynamicStack}
+		if actual != expected {
+			t.Errorf("%s: expected flags %v but got %v", name, expected, actual)
+		}
+		if usage.Bounded() {
+			t.Errorf("%s: expected an unbounded usage", name)
+		}
+	}
+	if fact := usages[exc.Symbols["fact"]]; fact.Frame != 8 || fact.Total != 16 {
+		t.Errorf("unexpected usage for fact: %+v", fact)
+	}
+}
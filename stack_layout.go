@@ -0,0 +1,90 @@
+package mips32
+
+// maxPrologueLength is the number of instructions at the start of a function which are searched
+// for register saves.
+const maxPrologueLength = 32
+
+// A StackWord is a word of stack memory, annotated with the call that it belongs to.
+type StackWord struct {
+	Address uint32
+	Value   uint32
+
+	// Frame is the index in CallStack.Frames of the call whose stack frame contains the word.
+	// It is -1 for words above the outermost call's frame.
+	Frame int
+
+	// SavedRegister is the register which the frame's function saved in the word, or -1.
+	SavedRegister int
+}
+
+// StackWords reads count words of memory starting at $sp, and works out which call each word
+// belongs to.
+//
+// A call's frame extends from the value of $sp when it was made up to the value of $sp when the
+// next call was made (or the current $sp, for the innermost call).
+// Saved registers are found by looking for "ADDIU $sp, $sp, -N" followed by "SW $r, offset($sp)"
+// near the start of each called function.
+func (c *CallStack) StackWords(e *Emulator, count int) []StackWord {
+	sp := e.RegisterFile[29] &^ 3
+	var saves []map[uint32]int
+	for _, frame := range c.Frames {
+		saves = append(saves, prologueSaves(e.Executable, frame))
+	}
+
+	res := make([]StackWord, count)
+	for i := range res {
+		addr := sp + uint32(i*4)
+		word := StackWord{
+			Address:       addr,
+			Value:         e.loadWord(addr),
+			Frame:         -1,
+			SavedRegister: -1,
+		}
+		for j := len(c.Frames) - 1; j >= 0; j-- {
+			if int32(addr) < int32(c.Frames[j].StackPointer) {
+				word.Frame = j
+				if reg, ok := saves[j][addr]; ok {
+					word.SavedRegister = reg
+				}
+				break
+			}
+		}
+		res[i] = word
+	}
+	return res
+}
+
+// prologueSaves finds the stack addresses where a called function saves registers.
+//
+// The search stops when the function frees its stack space, so that loads and stores in
+// the epilogue are not mistaken for saves.
+func prologueSaves(exc *Executable, frame Frame) map[uint32]int {
+	res := map[uint32]int{}
+	if exc == nil {
+		return res
+	}
+	sp := frame.StackPointer
+	for i := uint32(0); i < maxPrologueLength; i++ {
+		inst := exc.Get(frame.Function + i*4)
+		if inst == nil {
+			break
+		}
+		switch inst.Name {
+		case "ADDIU", "ADDI":
+			if inst.Registers[0] == 29 && inst.Registers[1] == 29 {
+				if inst.SignedConstant16 > 0 {
+					return res
+				}
+				sp += uint32(int32(inst.SignedConstant16))
+			}
+		case "SW":
+			if inst.MemoryReference.Register == 29 {
+				addr := sp + uint32(int32(inst.MemoryReference.Offset))
+				if _, ok := res[addr]; !ok {
+					res[addr] = inst.Registers[0]
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,33 @@
+package mips32
+
+import "testing"
+
+func TestStackWords(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	var stack CallStack
+	emu.AfterExecute = func(inst *Instruction, addr uint32) {
+		stack.Observe(emu, inst, addr)
+	}
+	for emu.ProgramCounter != emu.Executable.Symbols["DONE"] || len(stack.Frames) != 3 {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The innermost call has freed its stack space, and each of the other two calls has saved
+	// $ra and $a0.
+	returnAddr := emu.Executable.Symbols["RECURSE"] + 20
+	expected := []StackWord{
+		{Address: 0xff0, Value: returnAddr, Frame: 1, SavedRegister: 31},
+		{Address: 0xff4, Value: 2, Frame: 1, SavedRegister: 4},
+		{Address: 0xff8, Value: 0x10, Frame: 0, SavedRegister: 31},
+		{Address: 0xffc, Value: 3, Frame: 0, SavedRegister: 4},
+		{Address: 0x1000, Value: 0, Frame: -1, SavedRegister: -1},
+	}
+	actual := stack.StackWords(emu, len(expected))
+	for i, x := range expected {
+		if actual[i] != x {
+			t.Errorf("word %d: expected %v but got %v", i, x, actual[i])
+		}
+	}
+}
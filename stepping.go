@@ -0,0 +1,137 @@
+package mips32
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStepLimit is returned when an Emulator runs for too many steps without reaching its goal.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// runCheckInterval is the number of instructions Run executes between checks of its context.
+const runCheckInterval = 1024
+
+// Run runs the program until it finishes, an instruction fails, or the context is done.
+//
+// If the context is cancelled or its deadline passes, Run stops and returns the context's error.
+// The emulator is left in a consistent state, so it can be inspected or resumed afterwards.
+// The context is only checked every so often, so a few more instructions may run after it is
+// done.
+func (e *Emulator) Run(ctx context.Context) error {
+	for !e.Done() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := e.StepN(runCheckInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StepN runs up to n instructions, stopping early if the program finishes or an instruction
+// fails.
+// It returns the number of instructions which were executed, including one that failed.
+//
+// If AfterExecute is nil, StepN uses a faster loop which does not record LastDelta and which
+// only looks up the handler for each instruction once.
+func (e *Emulator) StepN(n int) (int, error) {
+	if e.AfterExecute == nil {
+		return e.stepDecoded(n)
+	}
+	for i := 0; i < n; i++ {
+		if e.Done() {
+			return i, nil
+		}
+		if err := e.Step(); err != nil {
+			return i + 1, err
+		}
+	}
+	return n, nil
+}
+
+// StepOver runs the next instruction.
+// If that instruction is a JAL or JALR, StepOver keeps running until the called function returns.
+//
+// Calls are matched with returns by counting JAL/JALR instructions and "JR $ra" instructions,
+// so recursive calls are handled correctly.
+//
+// Execution stops early if the program finishes or if it reaches one of the breakpoints, which
+// may be nil.
+// If maxSteps is positive and that many instructions run without stopping, ErrStepLimit is
+// returned.
+// The number of executed instructions is returned in all cases.
+func (e *Emulator) StepOver(maxSteps int, b *Breakpoints) (int, error) {
+	inst := e.program().Get(e.ProgramCounter)
+	if e.JumpNext || inst == nil || (inst.Name != "JAL" && inst.Name != "JALR") {
+		return 1, e.Step()
+	}
+	if err := e.Step(); err != nil {
+		return 1, err
+	}
+	return e.runUntilReturn(1, maxSteps, b)
+}
+
+// StepOut runs until the current function returns to its caller.
+// The return is considered complete once the delay slot of the "JR $ra" has run.
+//
+// See StepOver for details on breakpoints and maxSteps.
+func (e *Emulator) StepOut(maxSteps int, b *Breakpoints) (int, error) {
+	return e.runUntilReturn(0, maxSteps, b)
+}
+
+// RunUntil runs until the program counter reaches an address.
+// At least one instruction is executed, so RunUntil can be used to get back to the current
+// address, e.g. in a loop.
+// If the address is in a delay slot, RunUntil stops before the delay slot runs, with JumpNext
+// still set.
+//
+// See StepOver for details on breakpoints and maxSteps.
+func (e *Emulator) RunUntil(addr uint32, maxSteps int, b *Breakpoints) (int, error) {
+	for steps := 0; ; steps++ {
+		if maxSteps > 0 && steps == maxSteps {
+			return steps, ErrStepLimit
+		}
+		if err := e.Step(); err != nil {
+			return steps + 1, err
+		}
+		if e.ProgramCounter == addr {
+			return steps + 1, nil
+		}
+		if e.JumpNext {
+			continue
+		}
+		if e.Done() || (b != nil && b.Has(e.ProgramCounter)) {
+			return steps + 1, nil
+		}
+	}
+}
+
+// runUntilReturn runs until the current function returns.
+// The steps argument is the number of instructions which have already been run.
+func (e *Emulator) runUntilReturn(steps, maxSteps int, b *Breakpoints) (int, error) {
+	var depth int
+	var returning bool
+	for ; ; steps++ {
+		if maxSteps > 0 && steps == maxSteps {
+			return steps, ErrStepLimit
+		}
+		if inst := e.program().Get(e.ProgramCounter); inst != nil && !e.JumpNext {
+			if inst.Name == "JAL" || inst.Name == "JALR" {
+				depth++
+			} else if inst.Name == "JR" && inst.Registers[0] == 31 {
+				depth--
+				returning = depth < 0
+			}
+		}
+		if err := e.Step(); err != nil {
+			return steps + 1, err
+		}
+		if e.JumpNext {
+			continue
+		}
+		if returning || e.Done() || (b != nil && b.Has(e.ProgramCounter)) {
+			return steps + 1, nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package mips32
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const steppingTestCode = `
+ADDIU $sp, $0, 0x1000
+ADDIU $a0, $0, 3
+JAL FACT
+NOP
+ADDIU $s0, $v0, 0
+J END
+NOP
+
+# FACT computes a0! recursively.
+FACT:
+BNE $a0, $0, RECURSE
+NOP
+JR $ra
+ADDIU $v0, $0, 1
+RECURSE:
+ADDIU $sp, $sp, -8
+SW $ra, 0($sp)
+SW $a0, 4($sp)
+JAL FACT
+ADDIU $a0, $a0, -1
+LW $a0, 4($sp)
+LW $ra, 0($sp)
+ADDIU $sp, $sp, 8
+ADDU $t0, $0, $0
+ADDU $t1, $0, $0
+MULTIPLY:
+BEQ $t1, $a0, DONE
+NOP
+ADDU $t0, $t0, $v0
+J MULTIPLY
+ADDIU $t1, $t1, 1
+DONE:
+JR $ra
+ADDU $v0, $t0, $0
+END:
+`
+
+func steppingTestEmulator(t *testing.T) *Emulator {
+	tokens, err := TokenizeSource(steppingTestCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Emulator{Memory: NewLazyMemory(), Executable: exc}
+}
+
+func TestEmulatorStepOver(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	for i := 0; i < 2; i++ {
+		if steps, err := emu.StepOver(0, nil); err != nil || steps != 1 {
+			t.Fatal("unexpected result", steps, err)
+		}
+	}
+	if _, err := emu.StepOver(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if emu.ProgramCounter != 0x10 || emu.RegisterFile[2] != 6 {
+		t.Errorf("unexpected state: pc=%d v0=%d", emu.ProgramCounter, emu.RegisterFile[2])
+	}
+
+	emu = steppingTestEmulator(t)
+	emu.Step()
+	emu.Step()
+	if _, err := emu.StepOver(10, nil); err != ErrStepLimit {
+		t.Error("expected step limit but got", err)
+	}
+
+	emu = steppingTestEmulator(t)
+	emu.Step()
+	emu.Step()
+	var b Breakpoints
+	b.Set(emu.Executable.Symbols["DONE"])
+	if _, err := emu.StepOver(0, &b); err != nil {
+		t.Fatal(err)
+	} else if emu.ProgramCounter != b.List()[0] {
+		t.Error("did not stop at breakpoint:", emu.ProgramCounter)
+	}
+}
+
+func TestEmulatorStepOut(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	for emu.ProgramCounter != emu.Executable.Symbols["RECURSE"] {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := emu.StepOut(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if emu.ProgramCounter != 0x10 || emu.RegisterFile[2] != 6 {
+		t.Errorf("unexpected state: pc=%d v0=%d", emu.ProgramCounter, emu.RegisterFile[2])
+	}
+}
+
+func TestEmulatorRunUntil(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	done := emu.Executable.Symbols["DONE"]
+	if _, err := emu.RunUntil(done, 0, nil); err != nil {
+		t.Fatal(err)
+	} else if emu.ProgramCounter != done {
+		t.Fatal("unexpected PC:", emu.ProgramCounter)
+	}
+	if a0 := emu.RegisterFile[4]; a0 != 1 {
+		t.Error("expected to stop in the innermost call, but a0 is", a0)
+	}
+	if _, err := emu.RunUntil(done, 0, nil); err != nil || emu.RegisterFile[4] != 2 {
+		t.Error("unexpected result", err, emu.RegisterFile[4])
+	}
+	if _, err := emu.RunUntil(0x1234, 5, nil); err != ErrStepLimit {
+		t.Error("expected step limit but got", err)
+	}
+	if _, err := emu.RunUntil(0x1234, 0, nil); err != nil || !emu.Done() {
+		t.Error("expected program to finish", err)
+	}
+
+	// The instruction after DONE is only ever run in the delay slot of JR.
+	emu = steppingTestEmulator(t)
+	if _, err := emu.RunUntil(done+4, 0, nil); err != nil {
+		t.Fatal(err)
+	} else if emu.ProgramCounter != done+4 || !emu.JumpNext {
+		t.Error("unexpected state", emu.ProgramCounter, emu.JumpNext)
+	}
+	if a0 := emu.RegisterFile[4]; a0 != 1 {
+		t.Error("expected to stop in the innermost call, but a0 is", a0)
+	}
+}
+
+func TestEmulatorStepN(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	if steps, err := emu.StepN(3); err != nil || steps != 3 || emu.ProgramCounter != 12 {
+		t.Fatal("unexpected result", steps, err, emu.ProgramCounter)
+	}
+	steps, err := emu.StepN(1000)
+	if err != nil || !emu.Done() || steps >= 1000 {
+		t.Fatal("unexpected result", steps, err)
+	}
+	if emu.RegisterFile[16] != 6 {
+		t.Error("unexpected s0:", emu.RegisterFile[16])
+	}
+	if steps, err := emu.StepN(5); err != nil || steps != 0 {
+		t.Error("unexpected result", steps, err)
+	}
+}
+
+func TestEmulatorRun(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	if err := emu.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !emu.Done() || emu.RegisterFile[16] != 6 {
+		t.Error("unexpected state", emu.Done(), emu.RegisterFile[16])
+	}
+
+	tokens, err := TokenizeSource("LOOP:\nJ LOOP\nNOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu = &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := emu.Run(ctx); err != context.DeadlineExceeded {
+		t.Error("expected deadline but got", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := emu.Run(ctx); err != context.Canceled {
+		t.Error("expected cancellation but got", err)
+	}
+}
@@ -0,0 +1,102 @@
+package mips32
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringDirectives lists the directives which take a string literal.
+// A ".asciiz" string is followed by a zero byte, but a ".ascii" string is not.
+var stringDirectives = []string{"ascii", "asciiz"}
+
+// isStringDirective checks if a directive name is in stringDirectives.
+func isStringDirective(name string) bool {
+	return name == "ascii" || name == "asciiz"
+}
+
+// parseStringLiteral decodes a double-quoted string literal.
+//
+// The supported escape sequences are \n, \t, \0, \\, \", and \x followed by two hexadecimal
+// digits.
+// Every other byte, including each byte of a multi-byte UTF-8 character, stands for itself.
+func parseStringLiteral(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("expected a double-quoted string")
+	}
+	s = s[1 : len(s)-1]
+	if !strings.ContainsAny(s, "\\\"") {
+		return s, nil
+	}
+	var res strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", errors.New("unexpected quote in string")
+		} else if c != '\\' {
+			res.WriteByte(c)
+			continue
+		}
+		if i+1 == len(s) {
+			return "", errors.New("missing escape sequence after backslash")
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			res.WriteByte('\n')
+		case 't':
+			res.WriteByte('\t')
+		case '0':
+			res.WriteByte(0)
+		case '\\', '"':
+			res.WriteByte(s[i])
+		case 'x':
+			var value uint64
+			var err error
+			if i+2 < len(s) {
+				value, err = strconv.ParseUint(s[i+1:i+3], 16, 8)
+			}
+			if i+2 >= len(s) || err != nil {
+				return "", errors.New(`\x must be followed by two hexadecimal digits`)
+			}
+			res.WriteByte(byte(value))
+			i += 2
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			return "", errors.New("unknown escape sequence: \\" + s[i:i+size])
+		}
+	}
+	return res.String(), nil
+}
+
+// quoteStringLiteral is the inverse of parseStringLiteral.
+// Printable UTF-8 characters are kept as they are, and other bytes are escaped.
+func quoteStringLiteral(s string) string {
+	var res strings.Builder
+	res.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\n':
+			res.WriteString(`\n`)
+		case r == '\t':
+			res.WriteString(`\t`)
+		case r == 0:
+			res.WriteString(`\0`)
+		case r == '\\' || r == '"':
+			res.WriteByte('\\')
+			res.WriteByte(s[i])
+		case r == utf8.RuneError && size == 1, !unicode.IsPrint(r):
+			for _, b := range []byte(s[i : i+size]) {
+				res.WriteString(`\x` + twoDigitHex(b))
+			}
+		default:
+			res.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	res.WriteByte('"')
+	return res.String()
+}
@@ -0,0 +1,100 @@
+package mips32
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseStringLiteral(t *testing.T) {
+	cases := map[string]string{
+		`""`:                 "",
+		`"hello, world"`:     "hello, world",
+		`"a\nb\tc\0d"`:       "a\nb\tc\x00d",
+		`"\\ and \""`:        `\ and "`,
+		`"\x41\x7a\xff\xFE"`: "Az\xff\xfe",
+		`"héllo, 世界"`:        "héllo, 世界",
+		`"\xe4\xb8\x96\xe7"`: "\xe4\xb8\x96\xe7",
+	}
+	for literal, expected := range cases {
+		actual, err := parseStringLiteral(literal)
+		if err != nil {
+			t.Errorf("%s: %v", literal, err)
+		} else if actual != expected {
+			t.Errorf("%s: expected %q but got %q", literal, expected, actual)
+		}
+	}
+
+	errors := map[string]string{
+		`hello`:       "expected a double-quoted string",
+		`"`:           "expected a double-quoted string",
+		`"a"b"`:       "unexpected quote in string",
+		`"\q"`:        `unknown escape sequence: \q`,
+		`"\é"`:        `unknown escape sequence: \é`,
+		`"\x4"`:       `\x must be followed by two hexadecimal digits`,
+		`"\x+f"`:      `\x must be followed by two hexadecimal digits`,
+		`"trailing\"`: "missing escape sequence after backslash",
+	}
+	for literal, expected := range errors {
+		if _, err := parseStringLiteral(literal); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q but got %v", literal, expected, err)
+		}
+	}
+}
+
+func TestQuoteStringLiteral(t *testing.T) {
+	cases := map[string]string{
+		"hello":            `"hello"`,
+		"a\nb\tc\x00\"\\":  `"a\nb\tc\0\"\\"`,
+		"héllo, 世界":        `"héllo, 世界"`,
+		"\x01\xff\xe4\xb8": `"\x01\xFF\xE4\xB8"`,
+		"\u200b":           `"\xE2\x80\x8B"`,
+	}
+	for s, expected := range cases {
+		actual := quoteStringLiteral(s)
+		if actual != expected {
+			t.Errorf("%q: expected %s but got %s", s, expected, actual)
+		}
+		if parsed, err := parseStringLiteral(actual); err != nil || parsed != s {
+			t.Errorf("%q: quoted string parsed as %q (error %v)", s, parsed, err)
+		}
+	}
+}
+
+func TestStringLiteralPrintString(t *testing.T) {
+	source := `ORI $a0, $0, GREETING
+ADDIU $v0, $0, 4
+SYSCALL
+ORI $a0, $0, NAME
+SYSCALL
+ADDIU $v0, $0, 10
+SYSCALL
+GREETING:
+.ascii "hello, "
+NAME:
+.asciiz "w\x6frld\n"`
+	for _, littleEndian := range []bool{false, true} {
+		exc, err := Assemble(source, &AssembleOptions{LittleEndian: littleEndian})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var output bytes.Buffer
+		emu := &Emulator{
+			Memory:       NewLazyMemory(),
+			Executable:   exc,
+			LittleEndian: littleEndian,
+			Syscalls:     &SPIMSyscalls{Output: &output},
+		}
+		if err := exc.LoadInto(emu.Memory, littleEndian); err != nil {
+			t.Fatal(err)
+		}
+		for !emu.Done() {
+			if err := emu.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The first string is padded to a word with zeroes, which end it.
+		if output.String() != "hello, world\n" {
+			t.Errorf("little endian %v: unexpected output %q", littleEndian, output.String())
+		}
+	}
+}
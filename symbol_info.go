@@ -0,0 +1,179 @@
+package mips32
+
+import (
+	"sort"
+	"strconv"
+)
+
+// A SymbolKind says whether a symbol labels code or data.
+type SymbolKind int
+
+const (
+	CodeSymbol SymbolKind = iota
+	DataSymbol
+)
+
+// String returns "code" or "data".
+func (s SymbolKind) String() string {
+	if s == DataSymbol {
+		return "data"
+	}
+	return "code"
+}
+
+// SymbolInfo describes a symbol beyond its address.
+type SymbolInfo struct {
+	// Kind is DataSymbol if the symbol labels a data directive (such as ".word"), and
+	// CodeSymbol otherwise.
+	Kind SymbolKind
+
+	// Size is the number of bytes which the symbol labels.
+	// Unless a ".size" directive sets it, it runs up to the next symbol or the end of the
+	// symbol's segment, whichever comes first.
+	Size uint32
+
+	// Global is true if the symbol was declared with ".globl" (or ".global").
+	Global bool
+}
+
+// isSymbolDirective checks if a directive takes the name of a symbol declared elsewhere.
+func isSymbolDirective(name string) bool {
+	return name == "globl" || name == "size"
+}
+
+// computeSymbolInfo fills in the SymbolInfo of an executable whose segments and symbols have
+// been laid out, given the lines it was parsed from and the words which they produced.
+func (e *Executable) computeSymbolInfo(lines []TokenizedLine, placed []placedLine) error {
+	symbolAddrs := make(uint32List, 0, len(e.Symbols))
+	isSymbolAddr := map[uint32]bool{}
+	for _, addr := range e.Symbols {
+		if !isSymbolAddr[addr] {
+			isSymbolAddr[addr] = true
+			symbolAddrs = append(symbolAddrs, addr)
+		}
+	}
+	sort.Sort(symbolAddrs)
+
+	dataAddrs := map[uint32]bool{}
+	for _, p := range placed {
+		if p.line.Directive != nil && isSymbolAddr[p.address] {
+			dataAddrs[p.address] = true
+		}
+	}
+
+	segments := e.sortedSegmentAddresses()
+	e.SymbolInfo = make(map[string]SymbolInfo, len(e.Symbols))
+	for name, addr := range e.Symbols {
+		info := SymbolInfo{}
+		if dataAddrs[addr] {
+			info.Kind = DataSymbol
+		}
+		idx := sort.Search(len(segments), func(i int) bool {
+			return segments[i] > addr
+		})
+		if idx > 0 {
+			start := segments[idx-1]
+			end := uint64(start) + 4*uint64(len(e.Segments[start]))
+			next := sort.Search(len(symbolAddrs), func(i int) bool {
+				return symbolAddrs[i] > addr
+			})
+			if next < len(symbolAddrs) && uint64(symbolAddrs[next]) < end {
+				end = uint64(symbolAddrs[next])
+			}
+			if end > uint64(addr) {
+				info.Size = uint32(end - uint64(addr))
+			}
+		}
+		e.SymbolInfo[name] = info
+	}
+
+	for _, line := range lines {
+		dir := line.Directive
+		if dir == nil || !isSymbolDirective(dir.Name) {
+			continue
+		}
+		info, ok := e.SymbolInfo[dir.Symbol]
+		if !ok {
+			return lineError(line.LineNumber, unknownSymbolError(dir.Symbol).Error())
+		}
+		if dir.Name == "globl" {
+			info.Global = true
+		} else {
+			info.Size = dir.Constant
+		}
+		e.SymbolInfo[dir.Symbol] = info
+	}
+	return nil
+}
+
+// NearestSymbol finds the closest symbol at or before an address, and returns its name along with
+// the address's offset from it.
+// If several symbols share that address, the alphabetically first one is used.
+// If no symbol is at or before the address, ok is false.
+//
+// This scans the whole symbol table, so it always sees the current symbols.
+// To look up many addresses, build a SymbolIndex instead.
+func (e *Executable) NearestSymbol(addr uint32) (name string, offset uint32, ok bool) {
+	var bestAddr uint32
+	for sym, symAddr := range e.Symbols {
+		if symAddr > addr {
+			continue
+		}
+		if !ok || symAddr > bestAddr || (symAddr == bestAddr && sym < name) {
+			name, bestAddr, ok = sym, symAddr, true
+		}
+	}
+	if !ok {
+		return "", 0, false
+	}
+	return name, addr - bestAddr, true
+}
+
+// SymbolicAddress formats an address relative to the closest symbol at or before it, like
+// "main+0x14".
+// Addresses with no such symbol are formatted as hexadecimal numbers, like "0x00001000".
+func (e *Executable) SymbolicAddress(addr uint32) string {
+	return symbolicAddress(addr, e.NearestSymbol)
+}
+
+// A SymbolIndex finds the symbols near addresses with a binary search.
+//
+// An index is a sorted copy of a symbol table, and it does not see later changes to the table.
+// After changing an executable's symbols, build a new index with Executable.SymbolIndex.
+type SymbolIndex struct {
+	pairs symbolAddrPairList
+}
+
+// SymbolIndex builds an index of the executable's current symbols.
+func (e *Executable) SymbolIndex() *SymbolIndex {
+	return &SymbolIndex{pairs: e.sortedSymbolAddrPairs()}
+}
+
+// NearestSymbol is like Executable.NearestSymbol, but it uses the symbols in the index.
+func (s *SymbolIndex) NearestSymbol(addr uint32) (name string, offset uint32, ok bool) {
+	i := sort.Search(len(s.pairs), func(i int) bool {
+		return s.pairs[i].Address > addr
+	}) - 1
+	if i < 0 {
+		return "", 0, false
+	}
+	for i > 0 && s.pairs[i-1].Address == s.pairs[i].Address {
+		i--
+	}
+	return s.pairs[i].Symbol, addr - s.pairs[i].Address, true
+}
+
+// SymbolicAddress is like Executable.SymbolicAddress, but it uses the symbols in the index.
+func (s *SymbolIndex) SymbolicAddress(addr uint32) string {
+	return symbolicAddress(addr, s.NearestSymbol)
+}
+
+func symbolicAddress(addr uint32, nearest func(uint32) (string, uint32, bool)) string {
+	name, offset, ok := nearest(addr)
+	if !ok {
+		return eightDigitHex(addr)
+	} else if offset == 0 {
+		return name
+	}
+	return name + "+0x" + strconv.FormatUint(uint64(offset), 16)
+}
@@ -0,0 +1,140 @@
+package mips32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSymbolInfo(t *testing.T) {
+	exc, err := Assemble(`.globl main
+main:
+start:
+	ADDIU $t0, $zero, 1
+	NOP
+end:
+.text 0x1000
+.global table
+table:
+	.word 1, 2, 3
+message:
+	.asciiz "hi"
+.size table, 8
+.text 0x2000
+	NOP
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]SymbolInfo{
+		"main":    {Kind: CodeSymbol, Size: 8, Global: true},
+		"start":   {Kind: CodeSymbol, Size: 8},
+		"end":     {Kind: CodeSymbol, Size: 0},
+		"table":   {Kind: DataSymbol, Size: 8, Global: true},
+		"message": {Kind: DataSymbol, Size: 4},
+	}
+	if !reflect.DeepEqual(exc.SymbolInfo, expected) {
+		t.Errorf("unexpected symbol info: %v", exc.SymbolInfo)
+	}
+	if !reflect.DeepEqual(exc.Clone().SymbolInfo, expected) {
+		t.Error("clone has different symbol info")
+	}
+	if DataSymbol.String() != "data" || CodeSymbol.String() != "code" {
+		t.Error("unexpected kind names")
+	}
+
+	// Global labels are not reported as unused.
+	for _, warning := range Lint(exc) {
+		if warning.Message == "unused label: main" || warning.Message == "unused label: table" {
+			t.Errorf("unexpected warning: %s", warning)
+		}
+	}
+
+	errors := map[string]string{
+		".globl foo\nNOP":    "line 1: unknown symbol: foo",
+		"NOP\n.size foo, 4":  "line 2: unknown symbol: foo",
+		".size foo":          "line 1: .size: expected a symbol and a size",
+		".size 3, 4":         "line 1: .size: invalid symbol: 3",
+		".size foo, bar":     "line 1: .size: invalid size: bar",
+		"foo:\n.size FOO, 4": "line 2: unknown symbol: FOO",
+	}
+	for source, expected := range errors {
+		if _, err := Assemble(source, nil); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected error %q but got %v", source, expected, err)
+		}
+	}
+
+	// Symbol directives follow case folding.
+	exc, err = Assemble("Foo:\nNOP\n.globl FOO\n.size foo, 2",
+		&AssembleOptions{CaseInsensitiveSymbols: true})
+	if err != nil {
+		t.Fatal(err)
+	} else if info := exc.SymbolInfo["Foo"]; !info.Global || info.Size != 2 {
+		t.Errorf("unexpected info: %v", info)
+	}
+}
+
+func TestNearestSymbol(t *testing.T) {
+	exc, err := Assemble(".text 0x1000\nmain:\nloop:\nNOP\nNOP\nexit:\nNOP\n.text 0x2000\nNOP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr     uint32
+		name     string
+		offset   uint32
+		ok       bool
+		symbolic string
+	}{
+		{0xffc, "", 0, false, "0x00000ffc"},
+		{0x1000, "loop", 0, true, "loop"},
+		{0x1004, "loop", 4, true, "loop+0x4"},
+		{0x1008, "exit", 0, true, "exit"},
+		{0x2014, "exit", 0x100c, true, "exit+0x100c"},
+	}
+	index := exc.SymbolIndex()
+	for _, test := range tests {
+		name, offset, ok := exc.NearestSymbol(test.addr)
+		if name != test.name || offset != test.offset || ok != test.ok {
+			t.Errorf("%08x: expected (%s, %d, %v) but got (%s, %d, %v)", test.addr,
+				test.name, test.offset, test.ok, name, offset, ok)
+		}
+		if s := exc.SymbolicAddress(test.addr); s != test.symbolic {
+			t.Errorf("%08x: expected %q but got %q", test.addr, test.symbolic, s)
+		}
+		name, offset, ok = index.NearestSymbol(test.addr)
+		if name != test.name || offset != test.offset || ok != test.ok {
+			t.Errorf("%08x: index expected (%s, %d, %v) but got (%s, %d, %v)", test.addr,
+				test.name, test.offset, test.ok, name, offset, ok)
+		}
+		if s := index.SymbolicAddress(test.addr); s != test.symbolic {
+			t.Errorf("%08x: index expected %q but got %q", test.addr, test.symbolic, s)
+		}
+	}
+
+	// Lookups follow changes to the symbol table.
+	exc.Symbols["middle"] = 0x1004
+	if name, offset, _ := exc.NearestSymbol(0x1006); name != "middle" || offset != 2 {
+		t.Errorf("unexpected location after adding a symbol: %s+%d", name, offset)
+	}
+	delete(exc.Symbols, "exit")
+	exc.Symbols["zzz"] = 0x3000
+	if name, _, _ := exc.NearestSymbol(0x2000); name != "middle" {
+		t.Errorf("unexpected symbol after replacing a symbol: %s", name)
+	}
+	exc.Symbols["middle"] = 0x1ffc
+	if name, offset, _ := exc.NearestSymbol(0x2000); name != "middle" || offset != 4 {
+		t.Errorf("unexpected location after moving a symbol: %s+%d", name, offset)
+	}
+	exc.Symbols["middle"] = 0x1010
+	if name, offset, _ := exc.NearestSymbol(0x2000); name != "middle" || offset != 0xff0 {
+		t.Errorf("unexpected location after moving a symbol back: %s+%d", name, offset)
+	}
+
+	// An index keeps the symbols it was built from until it is rebuilt.
+	if name, _, _ := index.NearestSymbol(0x2000); name != "exit" {
+		t.Errorf("unexpected symbol from old index: %s", name)
+	}
+	if name, _, _ := exc.SymbolIndex().NearestSymbol(0x2000); name != "middle" {
+		t.Errorf("unexpected symbol from new index: %s", name)
+	}
+}
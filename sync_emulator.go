@@ -0,0 +1,79 @@
+package mips32
+
+import (
+	"context"
+	"sync"
+)
+
+// A SyncEmulator makes an Emulator safe to use from multiple goroutines.
+//
+// This is useful for running a program on one goroutine while a user interface or a debugger
+// stub reads the registers and memory from another.
+// Every method holds a lock while it uses the Emulator, and Run releases the lock every so often
+// so that other methods do not wait for the program to finish.
+//
+// Once an Emulator is wrapped, it should only be used through the SyncEmulator.
+// This includes its Memory and its Syscalls handler, which are only used while the lock is held.
+type SyncEmulator struct {
+	lock     sync.Mutex
+	emulator *Emulator
+}
+
+// NewSyncEmulator wraps an Emulator.
+func NewSyncEmulator(e *Emulator) *SyncEmulator {
+	return &SyncEmulator{emulator: e}
+}
+
+// Do calls f with exclusive access to the Emulator.
+// The Emulator must not be used after f returns.
+func (s *SyncEmulator) Do(f func(e *Emulator)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f(s.emulator)
+}
+
+// Run is like Emulator.Run, but other methods may be called while it is running.
+func (s *SyncEmulator) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.lock.Lock()
+		_, err := s.emulator.StepN(runCheckInterval)
+		done := s.emulator.Done()
+		s.lock.Unlock()
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// StepN is like Emulator.StepN.
+func (s *SyncEmulator) StepN(n int) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.emulator.StepN(n)
+}
+
+// Done is like Emulator.Done.
+func (s *SyncEmulator) Done() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.emulator.Done()
+}
+
+// Registers returns a copy of the register file and the program counter.
+func (s *SyncEmulator) Registers() (RegisterFile, uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.emulator.RegisterFile, s.emulator.ProgramCounter
+}
+
+// ReadMemory copies size bytes of memory, starting at an address.
+func (s *SyncEmulator) ReadMemory(addr uint32, size int) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := make([]byte, size)
+	ReadBytes(s.emulator.Memory, addr, res)
+	return res
+}
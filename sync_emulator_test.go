@@ -0,0 +1,52 @@
+package mips32
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSyncEmulator(t *testing.T) {
+	tokens, err := TokenizeSource(`ADDIU $t0, $0, 0x100
+LOOP:
+ADDIU $t1, $t1, 1
+SW $t1, 0($t0)
+BNE $t1, $t2, LOOP
+NOP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSyncEmulator(&Emulator{Memory: NewLazyMemory(), Executable: exc})
+	s.Do(func(e *Emulator) {
+		e.RegisterFile[10] = 100000
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(context.Background())
+	}()
+
+	// Run with the race detector to make sure these reads are synchronized.
+	var last uint32
+	for i := 0; i < 100; i++ {
+		regs, _ := s.Registers()
+		if regs[9] < last {
+			t.Fatal("register went backwards")
+		}
+		last = regs[9]
+		s.ReadMemory(0x100, 4)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !s.Done() {
+		t.Error("expected program to finish")
+	}
+	if data := s.ReadMemory(0x100, 4); data[1] != 0x01 || data[2] != 0x86 || data[3] != 0xa0 {
+		t.Errorf("unexpected memory: %x", data)
+	}
+}
@@ -0,0 +1,140 @@
+package mips32
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultHeapStart is where SPIMSyscalls allocates memory from by default.
+const DefaultHeapStart = 0x10040000
+
+// A SyscallHandler implements the SYSCALL instruction.
+//
+// Syscall is called after the program counter has been advanced past the SYSCALL, so it may
+// modify registers, memory, and the Halted flag as it sees fit.
+type SyscallHandler interface {
+	Syscall(e *Emulator) error
+}
+
+// SPIMSyscalls implements the console and memory syscalls supported by the SPIM simulator.
+// The syscall number is read from $v0, and arguments come from $a0 and $a1.
+//
+// The supported syscalls are print_int (1), print_string (4), read_int (5), read_string (8),
+// sbrk (9), exit (10), print_char (11), read_char (12), and exit2 (17).
+type SPIMSyscalls struct {
+	Input  io.Reader
+	Output io.Writer
+
+	// HeapPointer is the next address which sbrk will return.
+	// If it is 0, DefaultHeapStart is used.
+	HeapPointer uint32
+
+	// ExitCode is set by the exit2 syscall.
+	ExitCode int
+
+	reader      *bufio.Reader
+	readerInput io.Reader
+}
+
+// Syscall runs the syscall requested by $v0.
+func (s *SPIMSyscalls) Syscall(e *Emulator) error {
+	a0 := e.RegisterFile[4]
+	switch e.RegisterFile[2] {
+	case 1:
+		return s.write(strconv.Itoa(int(int32(a0))))
+	case 4:
+		var str []byte
+		var chunk [64]byte
+		for addr := a0; ; addr += uint32(len(chunk)) {
+			ReadBytes(e.Memory, addr, chunk[:])
+			if end := bytes.IndexByte(chunk[:], 0); end >= 0 {
+				str = append(str, chunk[:end]...)
+				break
+			}
+			str = append(str, chunk[:]...)
+		}
+		return s.write(string(str))
+	case 5:
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		num, err := strconv.ParseInt(strings.TrimSpace(line), 0, 64)
+		if err != nil || num > 0xffffffff || num < -0x80000000 {
+			return errors.New("read_int: invalid integer: " + strings.TrimSpace(line))
+		}
+		e.setReg(2, uint32(num))
+	case 8:
+		length := int(int32(e.RegisterFile[5]))
+		if length < 1 {
+			return nil
+		}
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		if len(line) > length-1 {
+			line = line[:length-1]
+		}
+		for i := 0; i < len(line); i++ {
+			e.storeByte(a0+uint32(i), line[i])
+		}
+		e.storeByte(a0+uint32(len(line)), 0)
+	case 9:
+		if s.HeapPointer == 0 {
+			s.HeapPointer = DefaultHeapStart
+		}
+		e.setReg(2, s.HeapPointer)
+		// Keep allocations word-aligned.
+		s.HeapPointer += (a0 + 3) &^ 3
+	case 10:
+		e.Halted = true
+	case 11:
+		return s.write(string([]byte{byte(a0)}))
+	case 12:
+		b, err := s.input().ReadByte()
+		if err != nil {
+			return err
+		}
+		e.setReg(2, uint32(b))
+	case 17:
+		s.ExitCode = int(int32(a0))
+		e.Halted = true
+	default:
+		return errors.New("unknown syscall: " + strconv.Itoa(int(e.RegisterFile[2])))
+	}
+	return nil
+}
+
+func (s *SPIMSyscalls) write(str string) error {
+	if s.Output == nil {
+		return nil
+	}
+	_, err := io.WriteString(s.Output, str)
+	return err
+}
+
+// readLine reads a line of input, including the trailing newline if there is one.
+func (s *SPIMSyscalls) readLine() (string, error) {
+	line, err := s.input().ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return line, err
+}
+
+func (s *SPIMSyscalls) input() *bufio.Reader {
+	if s.reader == nil || s.readerInput != s.Input {
+		input := s.Input
+		if input == nil {
+			input = strings.NewReader("")
+		}
+		s.reader = bufio.NewReader(input)
+		s.readerInput = s.Input
+	}
+	return s.reader
+}
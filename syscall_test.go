@@ -0,0 +1,69 @@
+package mips32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSPIMSyscalls(t *testing.T) {
+	code := `
+# Read a number and a name, then greet the user.
+ADDIU $v0, $0, 5
+SYSCALL
+ADDU $s0, $v0, $0
+ADDIU $v0, $0, 9
+ADDIU $a0, $0, 16
+SYSCALL
+ADDU $s1, $v0, $0
+ADDU $a0, $s1, $0
+ADDIU $a1, $0, 16
+ADDIU $v0, $0, 8
+SYSCALL
+ADDIU $v0, $0, 4
+ADDU $a0, $s1, $0
+SYSCALL
+ADDIU $v0, $0, 1
+ADDIU $a0, $s0, 1
+SYSCALL
+ADDIU $v0, $0, 11
+ADDIU $a0, $0, 0x21
+SYSCALL
+ADDIU $v0, $0, 17
+ADDIU $a0, $0, 3
+SYSCALL
+ADDIU $s2, $0, 1
+`
+	tokens, err := TokenizeSource(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	handler := &SPIMSyscalls{Input: strings.NewReader("-8\nbob\n"), Output: &output}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc, Syscalls: handler}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if output.String() != "bob\n-7!" {
+		t.Errorf("unexpected output: %q", output.String())
+	}
+	if handler.ExitCode != 3 || !emu.Halted || emu.RegisterFile[18] != 0 {
+		t.Error("program did not exit properly")
+	}
+	if emu.RegisterFile[17] != DefaultHeapStart || handler.HeapPointer != DefaultHeapStart+16 {
+		t.Error("unexpected heap state")
+	}
+}
+
+func TestEmulatorSyscallWithoutHandler(t *testing.T) {
+	emu := &Emulator{Memory: NewLazyMemory()}
+	if err := emu.Execute(&Instruction{Name: "SYSCALL"}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -1,5 +1,7 @@
 package mips32
 
+import "sync/atomic"
+
 type ArgumentType int
 
 const (
@@ -19,45 +21,66 @@ type Template struct {
 }
 
 func (t *Template) Match(tok *TokenizedInstruction) bool {
+	if t.Name != tok.Name || len(t.Arguments) != len(tok.Arguments) {
+		return false
+	}
+	for i, arg := range t.Arguments {
+		if !matchArgument(arg, tok.Arguments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSymbolic is like Match, but it also accepts symbols and expressions for arguments which
+// need constants, and expressions for memory offsets, since ParseExecutable replaces them with
+// their values.
+func (t *Template) matchSymbolic(tok *TokenizedInstruction) bool {
 	if t.Name != tok.Name || len(t.Arguments) != len(tok.Arguments) {
 		return false
 	}
 	for i, arg := range t.Arguments {
 		tokArg := tok.Arguments[i]
 		switch arg {
-		case Register:
-			if _, ok := tokArg.Register(); !ok {
-				return false
-			}
-		case SignedConstant16:
-			if _, ok := tokArg.SignedConstant16(); !ok {
-				return false
-			}
-		case UnsignedConstant16:
-			if _, ok := tokArg.UnsignedConstant16(); !ok {
-				return false
-			}
-		case Constant5:
-			if _, ok := tokArg.Constant5(); !ok {
-				return false
-			}
-		case AbsoluteCodePointer:
-			if _, ok := tokArg.AbsoluteCodePointer(); !ok {
-				return false
-			}
-		case RelativeCodePointer:
-			if _, ok := tokArg.RelativeCodePointer(); !ok {
-				return false
+		case SignedConstant16, UnsignedConstant16, Constant5, AbsoluteCodePointer:
+			if tokArg.isSymbol || (tokArg.expression != "" && !tokArg.isMemory) {
+				continue
 			}
 		case MemoryAddress:
-			if _, ok := tokArg.MemoryReference(); !ok {
-				return false
+			if tokArg.isMemory && tokArg.expression != "" {
+				continue
 			}
 		}
+		if !matchArgument(arg, tokArg) {
+			return false
+		}
 	}
 	return true
 }
 
+func matchArgument(arg ArgumentType, tokArg *ArgToken) bool {
+	var ok bool
+	switch arg {
+	case Register:
+		_, ok = tokArg.Register()
+	case SignedConstant16:
+		_, ok = tokArg.SignedConstant16()
+	case UnsignedConstant16:
+		_, ok = tokArg.UnsignedConstant16()
+	case Constant5:
+		_, ok = tokArg.Constant5()
+	case AbsoluteCodePointer:
+		_, ok = tokArg.AbsoluteCodePointer()
+	case RelativeCodePointer:
+		_, ok = tokArg.RelativeCodePointer()
+	case MemoryAddress:
+		_, ok = tokArg.MemoryReference()
+	default:
+		ok = true
+	}
+	return ok
+}
+
 func (t *Template) RegisterCount() int {
 	count := 0
 	for _, arg := range t.Arguments {
@@ -68,6 +91,11 @@ func (t *Template) RegisterCount() int {
 	return count
 }
 
+// Templates lists every instruction and the arguments it takes.
+// An instruction may have more than one template, in which case the first match is used.
+//
+// Lookups by name are indexed, and the index is rebuilt whenever Templates is replaced or
+// appended to (e.g. by RegisterInstruction), but not when its elements are modified in place.
 var Templates = []Template{
 	{"NOP", []ArgumentType{}},
 	{"ADDIU", []ArgumentType{Register, Register, SignedConstant16}},
@@ -107,6 +135,37 @@ var Templates = []Template{
 	{"SRL", []ArgumentType{Register, Register, Constant5}},
 	{"SRLV", []ArgumentType{Register, Register, Register}},
 	{"SUBU", []ArgumentType{Register, Register, Register}},
+	{"SYSCALL", []ArgumentType{}},
 	{"XOR", []ArgumentType{Register, Register, Register}},
 	{"XORI", []ArgumentType{Register, Register, UnsignedConstant16}},
 }
+
+// A templateIndex groups Templates by name.
+type templateIndex struct {
+	templates []Template
+	byName    map[string][]Template
+}
+
+var templateIndexCache atomic.Value
+
+// templatesNamed returns the templates for an instruction, in the order they appear in
+// Templates.
+func templatesNamed(name string) []Template {
+	index, _ := templateIndexCache.Load().(*templateIndex)
+	if index == nil || !index.current() {
+		index = &templateIndex{templates: Templates, byName: map[string][]Template{}}
+		for _, template := range Templates {
+			index.byName[template.Name] = append(index.byName[template.Name], template)
+		}
+		templateIndexCache.Store(index)
+	}
+	return index.byName[name]
+}
+
+// current checks if the index was built from the current Templates slice.
+func (t *templateIndex) current() bool {
+	if len(t.templates) != len(Templates) {
+		return false
+	}
+	return len(Templates) == 0 || &t.templates[0] == &Templates[0]
+}
@@ -0,0 +1,64 @@
+package mips32
+
+// A Timeline records the instructions which an emulator runs, so that it can be rewound to
+// (and fast-forwarded back from) any earlier point.
+//
+// After a Timeline has been rewound, recording a new instruction discards the instructions
+// which were undone.
+type Timeline struct {
+	// Limit is the maximum number of instructions to remember.
+	// When it is exceeded, the oldest instructions are forgotten.
+	// If it is 0, there is no limit.
+	Limit int
+
+	deltas   []Delta
+	position int
+}
+
+// Record adds the effects of an instruction to the timeline.
+// It should be called after each instruction with the emulator's LastDelta.
+// The Delta is copied, since the emulator reuses LastDelta for the next instruction.
+func (t *Timeline) Record(d Delta) {
+	t.deltas = append(t.deltas[:t.position], d.Clone())
+	t.position++
+	if t.Limit > 0 && len(t.deltas) > t.Limit {
+		extra := len(t.deltas) - t.Limit
+		t.deltas = append([]Delta{}, t.deltas[extra:]...)
+		t.position -= extra
+	}
+}
+
+// Len returns the number of recorded instructions.
+func (t *Timeline) Len() int {
+	return len(t.deltas)
+}
+
+// Position returns the number of recorded instructions that are currently applied.
+// This is Len() unless the timeline has been rewound.
+func (t *Timeline) Position() int {
+	return t.position
+}
+
+// Seek undoes or redoes instructions until the given number of them are applied.
+// The position is clamped to the range [0, Len()].
+func (t *Timeline) Seek(e *Emulator, position int) {
+	if position < 0 {
+		position = 0
+	} else if position > len(t.deltas) {
+		position = len(t.deltas)
+	}
+	for t.position > position {
+		t.position--
+		e.Undo(&t.deltas[t.position])
+	}
+	for t.position < position {
+		e.Redo(&t.deltas[t.position])
+		t.position++
+	}
+}
+
+// Reset forgets every recorded instruction.
+func (t *Timeline) Reset() {
+	t.deltas = nil
+	t.position = 0
+}
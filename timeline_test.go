@@ -0,0 +1,86 @@
+package mips32
+
+import "testing"
+
+func TestTimeline(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	var timeline Timeline
+	emu.AfterExecute = func(inst *Instruction, addr uint32) {
+		timeline.Record(emu.LastDelta)
+	}
+
+	var states []Emulator
+	var memories [][]byte
+	saveState := func() {
+		states = append(states, *emu)
+		mem := make([]byte, 0x20)
+		for i := range mem {
+			mem[i] = emu.Memory.Get(0x1000 - 0x20 + uint32(i))
+		}
+		memories = append(memories, mem)
+	}
+	saveState()
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		saveState()
+	}
+	if timeline.Len() != len(states)-1 || timeline.Position() != timeline.Len() {
+		t.Fatal("unexpected timeline length")
+	}
+
+	checkState := func(position int) {
+		expected := states[position]
+		if emu.RegisterFile != expected.RegisterFile ||
+			emu.ProgramCounter != expected.ProgramCounter ||
+			emu.JumpNext != expected.JumpNext || emu.JumpTarget != expected.JumpTarget ||
+			emu.DelaySlot != expected.DelaySlot {
+			t.Fatalf("position %d: unexpected emulator state", position)
+		}
+		for i, b := range memories[position] {
+			if emu.Memory.Get(0x1000-0x20+uint32(i)) != b {
+				t.Fatalf("position %d: unexpected memory at offset %d", position, i)
+			}
+		}
+	}
+	for _, position := range []int{10, 3, 0, 25, timeline.Len(), 17} {
+		timeline.Seek(emu, position)
+		if timeline.Position() != position {
+			t.Fatal("unexpected position")
+		}
+		checkState(position)
+	}
+
+	// Running from a rewound position should discard the future.
+	if err := emu.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if timeline.Len() != 18 || timeline.Position() != 18 {
+		t.Error("unexpected timeline after branching")
+	}
+	checkState(18)
+}
+
+func TestTimelineLimit(t *testing.T) {
+	emu := steppingTestEmulator(t)
+	timeline := Timeline{Limit: 5}
+	for i := 0; i < 8; i++ {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		timeline.Record(emu.LastDelta)
+	}
+	if timeline.Len() != 5 || timeline.Position() != 5 {
+		t.Fatal("unexpected timeline length")
+	}
+	timeline.Seek(emu, -1)
+	if timeline.Position() != 0 {
+		t.Fatal("seek should clamp the position")
+	}
+	// After 3 instructions, the program has set $sp and $a0 and called FACT.
+	regs := emu.RegisterFile
+	if regs[29] != 0x1000 || regs[4] != 3 || regs[31] != 0x10 || !emu.JumpNext {
+		t.Error("unexpected registers after rewinding")
+	}
+}
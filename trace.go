@@ -0,0 +1,300 @@
+package mips32
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A TraceRecord describes the architectural effects of one instruction.
+//
+// Traces are meant to be compared against other simulators and hardware implementations (for
+// instance, a CPU written in Verilog can print one record per instruction from its testbench).
+// The text form of a record, produced by String and read by ParseTraceRecord, is one line:
+//
+//	00400008 8d090004 r9=0000002a R4:10010004=0000002a
+//
+// The fields are the PC and the instruction word, followed by register writes and memory
+// accesses.
+// Memory accesses start with R (for a read) or W (for a write), followed by the access size in
+// bytes.
+type TraceRecord struct {
+	PC          uint32 `json:"pc"`
+	Instruction uint32 `json:"instruction"`
+
+	// RegisterWrites lists the registers written by the instruction, in increasing order.
+	// Writes to $zero are not included.
+	RegisterWrites []RegisterWrite `json:"registerWrites,omitempty"`
+
+	MemoryAccesses []MemoryAccess `json:"memoryAccesses,omitempty"`
+}
+
+// A RegisterWrite records the value written to a register.
+type RegisterWrite struct {
+	Register int    `json:"register"`
+	Value    uint32 `json:"value"`
+}
+
+// A MemoryAccess records a load or store.
+type MemoryAccess struct {
+	Address uint32 `json:"address"`
+
+	// Size is 1 for a byte or 4 for a word.
+	Size int `json:"size"`
+
+	Write bool `json:"write"`
+
+	// Value is the value loaded or stored.
+	// Byte loads record the byte before it is sign- or zero-extended.
+	Value uint32 `json:"value"`
+}
+
+// NewTraceRecord describes an instruction which the emulator just ran at an address.
+// It should be called from the emulator's AfterExecute hook, since it relies on LastDelta.
+//
+// The instruction word is 0 for NOPs past the end of the executable and for instructions which
+// cannot be encoded.
+func NewTraceRecord(e *Emulator, inst *Instruction, addr uint32) *TraceRecord {
+	res := &TraceRecord{PC: addr}
+	if inst == nil {
+		return res
+	}
+	res.Instruction, _ = inst.Encode(addr, e.symbols())
+
+	// The delta has the old values of any registers which changed.
+	oldRegisters := e.RegisterFile
+	for _, change := range e.LastDelta.registers {
+		oldRegisters[change.register] = change.oldValue
+	}
+
+	written := map[int]bool{}
+	for _, reg := range e.LastDelta.Registers {
+		written[reg] = true
+	}
+	if reg, ok := destinationRegister(inst); ok {
+		switch inst.Name {
+		case "MOVN":
+			written[reg] = written[reg] || oldRegisters[inst.Registers[2]] != 0
+		case "MOVZ":
+			written[reg] = written[reg] || oldRegisters[inst.Registers[2]] == 0
+		default:
+			written[reg] = true
+		}
+	}
+	for reg, ok := range written {
+		if !ok || reg == 0 {
+			continue
+		}
+		res.RegisterWrites = append(res.RegisterWrites, RegisterWrite{
+			Register: reg,
+			Value:    e.RegisterFile[reg],
+		})
+	}
+	sort.Slice(res.RegisterWrites, func(i, j int) bool {
+		return res.RegisterWrites[i].Register < res.RegisterWrites[j].Register
+	})
+
+	switch inst.Name {
+	case "LB", "LBU", "LW", "SB", "SW":
+		ref := inst.MemoryReference
+		access := MemoryAccess{
+			Address: oldRegisters[ref.Register] + uint32(int32(ref.Offset)),
+			Size:    1,
+			Write:   inst.Name[0] == 'S',
+		}
+		if inst.Name == "LW" || inst.Name == "SW" {
+			access.Size = 4
+			access.Value = e.loadWord(access.Address)
+		} else {
+			access.Value = uint32(e.Memory.Get(access.Address))
+		}
+		res.MemoryAccesses = []MemoryAccess{access}
+	}
+	return res
+}
+
+// String formats the record as a line of text, without a trailing newline.
+func (t *TraceRecord) String() string {
+	fields := []string{traceHex(t.PC, 8), traceHex(t.Instruction, 8)}
+	for _, w := range t.RegisterWrites {
+		fields = append(fields, "r"+strconv.Itoa(w.Register)+"="+traceHex(w.Value, 8))
+	}
+	for _, a := range t.MemoryAccesses {
+		kind := "R"
+		if a.Write {
+			kind = "W"
+		}
+		fields = append(fields, kind+strconv.Itoa(a.Size)+":"+traceHex(a.Address, 8)+"="+
+			traceHex(a.Value, a.Size*2))
+	}
+	return strings.Join(fields, " ")
+}
+
+// Equal returns true if two records are identical.
+func (t *TraceRecord) Equal(t1 *TraceRecord) bool {
+	return t.String() == t1.String()
+}
+
+// ParseTraceRecord parses the text form of a record.
+// Hexadecimal digits may be upper or lower case, and register writes and memory accesses may be
+// in any order.
+func ParseTraceRecord(line string) (*TraceRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("trace record needs a PC and an instruction: " + line)
+	}
+	res := &TraceRecord{}
+	var err error
+	if res.PC, err = parseTraceHex(fields[0]); err != nil {
+		return nil, err
+	}
+	if res.Instruction, err = parseTraceHex(fields[1]); err != nil {
+		return nil, err
+	}
+	for _, field := range fields[2:] {
+		eqIdx := strings.IndexByte(field, '=')
+		if eqIdx < 0 {
+			return nil, errors.New("invalid trace field: " + field)
+		}
+		value, err := parseTraceHex(field[eqIdx+1:])
+		if err != nil {
+			return nil, err
+		}
+		switch field[0] {
+		case 'r':
+			reg, err := strconv.Atoi(field[1:eqIdx])
+			if err != nil || reg < 0 || reg > 31 {
+				return nil, errors.New("invalid register in trace: " + field)
+			}
+			res.RegisterWrites = append(res.RegisterWrites, RegisterWrite{reg, value})
+		case 'R', 'W':
+			colonIdx := strings.IndexByte(field, ':')
+			if colonIdx < 0 || colonIdx > eqIdx {
+				return nil, errors.New("invalid memory access in trace: " + field)
+			}
+			size, err := strconv.Atoi(field[1:colonIdx])
+			if err != nil || (size != 1 && size != 4) {
+				return nil, errors.New("invalid access size in trace: " + field)
+			}
+			addr, err := parseTraceHex(field[colonIdx+1 : eqIdx])
+			if err != nil {
+				return nil, err
+			}
+			res.MemoryAccesses = append(res.MemoryAccesses, MemoryAccess{
+				Address: addr,
+				Size:    size,
+				Write:   field[0] == 'W',
+				Value:   value,
+			})
+		default:
+			return nil, errors.New("invalid trace field: " + field)
+		}
+	}
+	sort.SliceStable(res.RegisterWrites, func(i, j int) bool {
+		return res.RegisterWrites[i].Register < res.RegisterWrites[j].Register
+	})
+	return res, nil
+}
+
+// ReadTrace reads records in text form, one per line.
+// Blank lines and lines starting with "#" are ignored.
+func ReadTrace(r io.Reader) ([]*TraceRecord, error) {
+	var res []*TraceRecord
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := ParseTraceRecord(line)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(lineNum) + ": " + err.Error())
+		}
+		res = append(res, record)
+	}
+	return res, scanner.Err()
+}
+
+// A TraceWriter writes the text form of a TraceRecord for each instruction an emulator runs.
+//
+// To use a TraceWriter, call Observe after each instruction is executed, typically from an
+// Emulator's AfterExecute hook.
+type TraceWriter struct {
+	w   io.Writer
+	err error
+}
+
+// NewTraceWriter creates a TraceWriter which writes to w.
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	return &TraceWriter{w: w}
+}
+
+// Observe writes a record for an instruction which has been executed at an address.
+func (t *TraceWriter) Observe(e *Emulator, inst *Instruction, addr uint32) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = io.WriteString(t.w, NewTraceRecord(e, inst, addr).String()+"\n")
+}
+
+// Err returns the first error encountered while writing, if any.
+func (t *TraceWriter) Err() error {
+	return t.err
+}
+
+// A TraceMismatch describes the first difference between two traces.
+type TraceMismatch struct {
+	// Index is the index of the first record which differs.
+	Index int
+
+	// Expected and Actual are the records at Index.
+	// One of them is nil if its trace ended before the other.
+	Expected *TraceRecord
+	Actual   *TraceRecord
+}
+
+func (t *TraceMismatch) String() string {
+	res := "record " + strconv.Itoa(t.Index) + ": "
+	if t.Expected == nil {
+		return res + "unexpected record " + t.Actual.String()
+	} else if t.Actual == nil {
+		return res + "trace ended early; expected " + t.Expected.String()
+	}
+	return res + "expected " + t.Expected.String() + " but got " + t.Actual.String()
+}
+
+// CompareTraces finds the first record where two traces differ.
+// It returns nil if the traces are identical.
+func CompareTraces(expected, actual []*TraceRecord) *TraceMismatch {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if i >= len(expected) {
+			return &TraceMismatch{Index: i, Actual: actual[i]}
+		} else if i >= len(actual) {
+			return &TraceMismatch{Index: i, Expected: expected[i]}
+		} else if !expected[i].Equal(actual[i]) {
+			return &TraceMismatch{Index: i, Expected: expected[i], Actual: actual[i]}
+		}
+	}
+	return nil
+}
+
+func traceHex(n uint32, digits int) string {
+	s := strconv.FormatUint(uint64(n), 16)
+	for len(s) < digits {
+		s = "0" + s
+	}
+	return s
+}
+
+func parseTraceHex(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, errors.New("invalid hexadecimal number in trace: " + s)
+	}
+	return uint32(n), nil
+}
@@ -0,0 +1,103 @@
+package mips32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTraceWriter(t *testing.T) {
+	lines, err := TokenizeSource(`ADDIU $t0, $0, 0x100
+ADDIU $t1, $0, -2
+SW $t1, 4($t0)
+LBU $t0, 7($t0)
+MOVZ $t2, $t1, $t0
+MOVN $t2, $t1, $t0
+ADDIU $t3, $0, 0
+JAL END
+NOP
+END:
+NOP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc, err := ParseExecutable(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := &Emulator{Memory: NewLazyMemory(), Executable: exc}
+	var buf bytes.Buffer
+	writer := NewTraceWriter(&buf)
+	emu.AfterExecute = func(inst *Instruction, addr uint32) {
+		writer.Observe(emu, inst, addr)
+	}
+	for !emu.Done() {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if writer.Err() != nil {
+		t.Fatal(writer.Err())
+	}
+
+	expected := `00000000 24080100 r8=00000100
+00000004 2409fffe r9=fffffffe
+00000008 ad090004 W4:00000104=fffffffe
+0000000c 91080007 r8=000000fe R1:00000107=fe
+00000010 0128500a
+00000014 0128500b r10=fffffffe
+00000018 240b0000 r11=00000000
+0000001c 0c000009 r31=00000024
+00000020 00000000
+00000024 00000000
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected trace:\n%s", buf.String())
+	}
+
+	records, err := ReadTrace(strings.NewReader("# comment\n" +
+		strings.Replace(expected, "fffffffe", "FFFFFFFE", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reformatted bytes.Buffer
+	for _, record := range records {
+		reformatted.WriteString(record.String() + "\n")
+	}
+	if reformatted.String() != expected {
+		t.Errorf("unexpected parsed trace:\n%s", reformatted.String())
+	}
+}
+
+func TestCompareTraces(t *testing.T) {
+	parse := func(text string) []*TraceRecord {
+		records, err := ReadTrace(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+	expected := parse("0 0\n4 24080001 r8=1\n8 0\n")
+	if m := CompareTraces(expected, parse("0 0\n4 24080001 r8=00000001\n8 0\n")); m != nil {
+		t.Error("unexpected mismatch:", m)
+	}
+
+	m := CompareTraces(expected, parse("0 0\n4 24080001 r8=2\n8 0\n"))
+	if m == nil || m.Index != 1 {
+		t.Fatal("unexpected mismatch:", m)
+	}
+	if m.String() != "record 1: expected 00000004 24080001 r8=00000001 but got "+
+		"00000004 24080001 r8=00000002" {
+		t.Error("unexpected message:", m.String())
+	}
+
+	m = CompareTraces(expected, expected[:2])
+	if m == nil || m.Index != 2 || m.Actual != nil {
+		t.Error("unexpected mismatch:", m)
+	}
+
+	if _, err := ReadTrace(strings.NewReader("0 0\n4 0 x=1\n")); err == nil ||
+		!strings.HasPrefix(err.Error(), "line 2:") {
+		t.Error("unexpected error:", err)
+	}
+}
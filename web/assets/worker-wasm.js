@@ -0,0 +1,7 @@
+// Loads the WebAssembly build of the app into a Web Worker, where the debugger runs programs in
+// the background. Pages which use the WebAssembly build point MIPS32WorkerScript at this file.
+importScripts('wasm_exec.js');
+var go = new Go();
+WebAssembly.instantiateStreaming(fetch('src.wasm'), go.importObject).then(function(result) {
+  go.run(result.instance);
+});
@@ -0,0 +1,9 @@
+# Build the WebAssembly version of the frontend (loaded by index-wasm.html) and of the
+# emulator's JavaScript API, as alternatives to the GopherJS build.
+GOOS=js GOARCH=wasm go build -o assets/src.wasm ./src
+GOOS=js GOARCH=wasm go build -o assets/mips32.wasm ./wasm
+WASM_EXEC="$(go env GOROOT)/lib/wasm/wasm_exec.js"
+if [ ! -f "$WASM_EXEC" ]; then
+  WASM_EXEC="$(go env GOROOT)/misc/wasm/wasm_exec.js"
+fi
+cp "$WASM_EXEC" assets/wasm_exec.js
@@ -0,0 +1,221 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// panelElements maps the names of panels which can be hidden in an embedded widget to the IDs of
+// the elements which make them up.
+var panelElements = map[string][]string{
+	"nav":          {"mips32-nav"},
+	"assembler":    {"assembler", "assembler-link"},
+	"disassembler": {"disassembler", "disassembler-link"},
+	"files":        {"assembler-file-controls"},
+	"share":        {"assembler-share-controls", "assembler-share-link"},
+	"export":       {"assembler-export-controls"},
+	"code":         {"debugger-code-view"},
+	"inspector":    {"debugger-inspector-container"},
+	"source":       {"debugger-source-view"},
+	"callstack":    {"debugger-call-stack"},
+	"stack":        {"debugger-stack"},
+	"symbols":      {"debugger-symbol-navigator"},
+	"watch":        {"debugger-watch"},
+	"timeline":     {"debugger-timeline-container", "debugger-step-back"},
+	"console":      {"debugger-console"},
+	"registers":    {"debugger-register-options", "debugger-registers"},
+	"memory":       {"debugger-memory"},
+	"snapshot":     {"debugger-snapshot"},
+	"presets":      {"debugger-preset"},
+	"coverage":     {"debugger-coverage"},
+}
+
+// An App is the assembler, debugger, and disassembler mounted in a container element.
+//
+// A standalone app fills the page and uses the URL's hash to switch between panes.
+// An embedded app (see MIPS32Widget) switches panes without touching the URL, so that it does
+// not interfere with the page around it.
+type App struct {
+	container  js.Value
+	standalone bool
+	pane       string
+}
+
+var GlobalApp *App
+
+// MountApp inserts the app's markup into the container and creates the global components.
+// Only one app may be mounted per page, since the components find their elements by ID.
+func MountApp(container js.Value, standalone bool, store *ProgramStore) *App {
+	res := &App{container: container, standalone: standalone}
+	container.Set("innerHTML", appHTML)
+	GlobalApp = res
+	res.setPane("assembler")
+
+	GlobalDebugger = NewDebugger()
+	GlobalAssembler = NewAssembler(store)
+	GlobalDisassembler = NewDisassembler()
+
+	if standalone {
+		addListener(js.Global().Get("window"), "hashchange", func(js.Value) { res.hashChanged() })
+		res.hashChanged()
+	} else {
+		for _, pane := range []string{"assembler", "debugger", "disassembler"} {
+			name := pane
+			link := js.Global().Get(name + "-link")
+			addListener(link, "click", func(event js.Value) {
+				event.Call("preventDefault")
+				res.ShowPane(name)
+			})
+		}
+	}
+	return res
+}
+
+// ShowPane switches to the "assembler", "debugger", or "disassembler" pane.
+func (a *App) ShowPane(name string) {
+	if a.standalone {
+		js.Global().Get("location").Set("hash", "#"+name)
+	} else {
+		a.setPane(name)
+	}
+}
+
+// Pane returns the name of the pane which is showing.
+func (a *App) Pane() string {
+	return a.pane
+}
+
+// HidePanel hides one of the panels listed in panelElements.
+func (a *App) HidePanel(name string) bool {
+	ids, ok := panelElements[name]
+	if !ok {
+		return false
+	}
+	for _, id := range ids {
+		js.Global().Get(id).Get("style").Set("display", "none")
+	}
+	return true
+}
+
+func (a *App) hashChanged() {
+	switch js.Global().Get("location").Get("hash").String() {
+	case "", "#assembler":
+		a.setPane("assembler")
+	case "#disassembler":
+		a.setPane("disassembler")
+	case "#debugger":
+		a.setPane("debugger")
+	}
+}
+
+func (a *App) setPane(name string) {
+	a.pane = name
+	a.container.Set("className", "mips32-app showing-"+name)
+}
+
+// mountWidget implements the MIPS32Widget constructor.
+//
+// The options object may contain:
+//
+//   - program: the source code to load and assemble.
+//   - hiddenPanels: an array of panel names (see panelElements) to hide.
+//   - readOnly: if true, the program cannot be edited.
+//   - presets: an object mapping preset names to register assignments, like
+//     {"Sort": "$a0=0x10010000, $a1=8"}.
+//   - preset: the name of the register preset to apply before the program runs.
+//
+// Programs in a widget are not saved, so embedding a widget does not disturb the programs that
+// the user saved on the standalone page.
+//
+// The returned object lets the page control the widget after it is mounted.
+func mountWidget(container, options js.Value) map[string]interface{} {
+	if container.Get("nodeType").IsUndefined() {
+		container = js.Global().Get("document").Call("querySelector", container)
+	}
+	if isMissing(options) {
+		options = js.Global().Get("Object").New()
+	}
+
+	// The returned methods wait until the widget has been mounted.
+	ready := make(chan struct{})
+
+	go func() {
+		if GlobalApp != nil {
+			js.Global().Get("console").Call("error",
+				"mips32: only one widget can be mounted per page")
+			return
+		}
+		app := MountApp(container, false, NewProgramStore(false))
+
+		program := defaultProgram
+		if p := options.Get("program"); !isMissing(p) {
+			program = p.String()
+		}
+		GlobalAssembler.Restore(program)
+
+		if options.Get("readOnly").Truthy() {
+			GlobalAssembler.SetReadOnly(true)
+			app.HidePanel("files")
+		}
+
+		if presets := options.Get("presets"); !isMissing(presets) {
+			names := js.Global().Get("Object").Call("keys", presets)
+			for i := 0; i < names.Length(); i++ {
+				name := names.Index(i).String()
+				preset, err := mips32.ParseRegisterPreset(name, presets.Get(name).String())
+				if err != nil {
+					js.Global().Get("console").Call("warn", "mips32: preset "+name+": "+err.Error())
+					continue
+				}
+				GlobalDebugger.presetPicker.AddPreset(preset)
+			}
+		}
+		if preset := options.Get("preset"); !isMissing(preset) {
+			if !GlobalDebugger.presetPicker.Select(preset.String()) {
+				js.Global().Get("console").Call("warn", "mips32: unknown preset: "+preset.String())
+			}
+		}
+
+		showDebugger := false
+		if hidden := options.Get("hiddenPanels"); !isMissing(hidden) {
+			for i := 0; i < hidden.Length(); i++ {
+				name := strings.ToLower(hidden.Index(i).String())
+				if !app.HidePanel(name) {
+					js.Global().Get("console").Call("warn", "mips32: unknown panel: "+name)
+				}
+				showDebugger = showDebugger || name == "assembler"
+			}
+		}
+
+		// Without an assembler, the debugger is the only useful pane.
+		if GlobalAssembler.Assemble() && showDebugger {
+			app.ShowPane("debugger")
+		}
+		close(ready)
+	}()
+
+	return map[string]interface{}{
+		"setProgram": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			code := argument(args, 0).String()
+			go func() {
+				<-ready
+				GlobalAssembler.Replace(code)
+				GlobalAssembler.Assemble()
+			}()
+			return nil
+		}),
+		"showPane": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			name := argument(args, 0).String()
+			go func() {
+				<-ready
+				GlobalApp.ShowPane(name)
+			}()
+			return nil
+		}),
+	}
+}
@@ -1,53 +1,146 @@
+//go:build js
+// +build js
+
 package main
 
 import (
-	"github.com/gopherjs/gopherjs/js"
+	"strconv"
+	"syscall/js"
+
 	"github.com/unixpickle/mips32"
 )
 
 const maxAssembleSize = 0x1000
 
+const defaultFileName = "untitled"
+
+// liveCheckDelay is how long, in milliseconds, to wait after an edit before checking for errors.
+const liveCheckDelay = 300
+
 type Assembler struct {
-	textarea  *js.Object
-	errorView *js.Object
+	editor     *Editor
+	errorView  js.Value
+	fileSelect js.Value
+
+	diagnostics        js.Value
+	examplePicker      js.Value
+	exampleDescription js.Value
+
+	store *ProgramStore
+
+	// checkTimer is the JavaScript timeout for the next live check, or undefined.
+	checkTimer js.Value
+	checkFunc  js.Func
+
+	// diagnosticListeners belong to the items of the diagnostics list.
+	diagnosticListeners listenerSet
 }
 
-func NewAssembler() *Assembler {
+func NewAssembler(store *ProgramStore) *Assembler {
 	res := &Assembler{
-		textarea:  js.Global.Get("assembler-code"),
-		errorView: js.Global.Get("assembler-error"),
+		editor:             NewEditor(),
+		errorView:          js.Global().Get("assembler-error"),
+		fileSelect:         js.Global().Get("assembler-files"),
+		diagnostics:        js.Global().Get("assembler-diagnostics"),
+		examplePicker:      js.Global().Get("assembler-examples"),
+		exampleDescription: js.Global().Get("assembler-example-description"),
+		store:              store,
 	}
-	js.Global.Get("assembler-button").Call("addEventListener", "click", func() {
+	res.checkFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		res.checkTimer = js.Undefined()
+		res.check()
+		return nil
+	})
+	addListener(js.Global().Get("assembler-button"), "click", func(js.Value) {
 		if res.Assemble() {
 			GlobalDebugger.Show()
 		}
 	})
+	res.editor.OnInput(func() {
+		res.scheduleCheck()
+		res.store.Save(res.store.Current(), res.editor.Value())
+	})
+	addListener(res.fileSelect, "change", func(js.Value) {
+		res.OpenFile(res.fileSelect.Get("value").String())
+	})
+	addListener(res.examplePicker, "change", func(js.Value) { res.loadExample() })
+	res.updateExamplePicker()
+	addListener(js.Global().Get("assembler-export"), "click", func(js.Value) {
+		go res.export()
+	})
+	addListener(js.Global().Get("assembler-share"), "click", func(js.Value) { res.share() })
+	addListener(js.Global().Get("assembler-new"), "click", func(js.Value) { res.newFile() })
+	addListener(js.Global().Get("assembler-rename"), "click", func(js.Value) { res.renameFile() })
+	addListener(js.Global().Get("assembler-delete"), "click", func(js.Value) { res.deleteFile() })
 	return res
 }
 
-func (a *Assembler) SetCode(code string) {
-	a.textarea.Set("value", code)
+// Restore opens the program that was open when the page was last closed.
+// If there are no saved programs, a new one is created with the given code.
+func (a *Assembler) Restore(defaultCode string) {
+	if _, ok := a.store.Load(a.store.Current()); ok {
+		a.OpenFile(a.store.Current())
+	} else if names := a.store.Names(); len(names) > 0 {
+		a.OpenFile(names[0])
+	} else {
+		a.store.Save(defaultFileName, defaultCode)
+		a.OpenFile(defaultFileName)
+	}
+}
+
+// CreateFile saves code as a new program and opens it.
+// If the name is taken, a number is appended to it.
+func (a *Assembler) CreateFile(name, code string) {
+	uniqueName := name
+	for i := 2; ; i++ {
+		if _, exists := a.store.Load(uniqueName); !exists {
+			break
+		}
+		uniqueName = name + " " + strconv.Itoa(i)
+	}
+	a.store.Save(uniqueName, code)
+	a.OpenFile(uniqueName)
+}
+
+// OpenFile loads a saved program into the editor.
+func (a *Assembler) OpenFile(name string) {
+	code, ok := a.store.Load(name)
+	if !ok {
+		return
+	}
+	a.store.SetCurrent(name)
+	a.editor.SetValue(code)
+	a.exampleDescription.Set("textContent", "")
 	a.hideError()
+	a.updateFileSelect()
+	a.check()
+}
+
+// Replace overwrites the code of the open program.
+func (a *Assembler) Replace(code string) {
+	a.store.Save(a.store.Current(), code)
+	a.OpenFile(a.store.Current())
+}
+
+// SetReadOnly prevents or allows editing the program.
+func (a *Assembler) SetReadOnly(readOnly bool) {
+	a.editor.SetReadOnly(readOnly)
 }
 
 func (a *Assembler) Show() {
-	js.Global.Get("location").Set("hash", "#assembler")
+	GlobalApp.ShowPane("assembler")
 }
 
 func (a *Assembler) Assemble() bool {
-	text := a.textarea.Get("value").String()
+	text := a.editor.Value()
 
-	lines, err := mips32.TokenizeSource(text)
-	if err != nil {
-		a.showError(err)
-		return false
-	}
-	exc, err := mips32.ParseExecutable(lines)
+	exc, err := mips32.Assemble(text, &mips32.AssembleOptions{LittleEndian: true})
 	if err != nil {
 		a.showError(err)
 		return false
 	}
 	a.hideError()
+	GlobalDebugger.SetSource(text)
 	GlobalDebugger.SetExecutable(exc)
 
 	if exc.End() < maxAssembleSize {
@@ -64,6 +157,161 @@ func (a *Assembler) Assemble() bool {
 	return true
 }
 
+// export assembles the code and downloads it in the format chosen by the user.
+func (a *Assembler) export() {
+	if !a.Assemble() {
+		return
+	}
+	format := js.Global().Get("assembler-export-format").Get("value").String()
+	little := js.Global().Get("assembler-export-endian").Get("value").String() == "little"
+	data, err := ExportExecutable(GlobalDebugger.Executable(), format, little)
+	if err != nil {
+		a.showError(err)
+		return
+	}
+	DownloadFile(a.store.Current()+exportFormats[format], data)
+}
+
+// scheduleCheck checks the code for errors once the user stops typing for a moment.
+func (a *Assembler) scheduleCheck() {
+	if !a.checkTimer.IsUndefined() {
+		js.Global().Call("clearTimeout", a.checkTimer)
+	}
+	a.checkTimer = js.Global().Call("setTimeout", a.checkFunc, liveCheckDelay)
+}
+
+// check finds all of the errors in the code, underlines them, and lists them below the editor.
+func (a *Assembler) check() {
+	errs := mips32.CheckSource(a.editor.Value())
+	a.editor.SetErrors(errs)
+
+	document := js.Global().Get("document")
+	a.diagnosticListeners.release()
+	a.diagnostics.Set("innerHTML", "")
+	addItem := func(text string, line int) {
+		item := document.Call("createElement", "li")
+		item.Set("textContent", text)
+		a.diagnosticListeners.add(item, "click", func(js.Value) {
+			a.editor.SelectLine(line)
+		})
+		a.diagnostics.Call("appendChild", item)
+	}
+	for _, err := range errs {
+		addItem(err.Error(), err.Line)
+	}
+	if len(errs) == 0 {
+		if _, warnings, err := mips32.AssembleWithWarnings(a.editor.Value(), nil); err == nil {
+			for _, warning := range warnings {
+				addItem("warning: "+warning.String(), warning.Line)
+			}
+		}
+	}
+}
+
+func (a *Assembler) share() {
+	settings := js.Global().Get("assembler-share-settings").Get("checked").Bool()
+	link, err := Permalink(a.editor.Value(), settings)
+	if err != nil {
+		a.showError(err)
+		return
+	}
+	linkField := js.Global().Get("assembler-share-link")
+	linkField.Set("value", link)
+	linkField.Get("style").Set("display", "inline-block")
+	linkField.Call("select")
+	if clipboard := js.Global().Get("navigator").Get("clipboard"); !clipboard.IsUndefined() {
+		clipboard.Call("writeText", link)
+	}
+}
+
+// loadExample opens the selected example program in a new file.
+func (a *Assembler) loadExample() {
+	example := mips32.ExampleNamed(a.examplePicker.Get("value").String())
+	a.examplePicker.Set("value", "")
+	if example == nil {
+		return
+	}
+	a.CreateFile(example.Name, example.Source)
+	a.exampleDescription.Set("textContent", example.Description)
+}
+
+func (a *Assembler) updateExamplePicker() {
+	document := js.Global().Get("document")
+	placeholder := document.Call("createElement", "option")
+	placeholder.Set("textContent", "Open an example")
+	placeholder.Set("value", "")
+	a.examplePicker.Call("appendChild", placeholder)
+	for _, example := range mips32.Examples {
+		option := document.Call("createElement", "option")
+		option.Set("textContent", example.Name+" - "+example.Description)
+		option.Set("value", example.Name)
+		a.examplePicker.Call("appendChild", option)
+	}
+}
+
+func (a *Assembler) newFile() {
+	name := a.promptName("Name for the new program:", "")
+	if name == "" {
+		return
+	}
+	a.store.Save(name, "")
+	a.OpenFile(name)
+}
+
+func (a *Assembler) renameFile() {
+	oldName := a.store.Current()
+	name := a.promptName("New name for "+oldName+":", oldName)
+	if name == "" || name == oldName {
+		return
+	}
+	code, _ := a.store.Load(oldName)
+	a.store.Save(name, code)
+	a.store.Delete(oldName)
+	a.OpenFile(name)
+}
+
+func (a *Assembler) deleteFile() {
+	name := a.store.Current()
+	if !js.Global().Call("confirm", "Delete "+name+"?").Bool() {
+		return
+	}
+	a.store.Delete(name)
+	if names := a.store.Names(); len(names) > 0 {
+		a.OpenFile(names[0])
+	} else {
+		a.store.Save(defaultFileName, "")
+		a.OpenFile(defaultFileName)
+	}
+}
+
+// promptName asks the user for a file name which isn't taken.
+// It returns "" if the user cancels.
+func (a *Assembler) promptName(message, defaultName string) string {
+	for {
+		result := js.Global().Call("prompt", message, defaultName)
+		if result.IsNull() || result.String() == "" {
+			return ""
+		}
+		name := result.String()
+		if _, exists := a.store.Load(name); !exists || name == defaultName {
+			return name
+		}
+		message = "A program named " + name + " already exists. Pick another name:"
+	}
+}
+
+func (a *Assembler) updateFileSelect() {
+	document := js.Global().Get("document")
+	a.fileSelect.Set("innerHTML", "")
+	for _, name := range a.store.Names() {
+		option := document.Call("createElement", "option")
+		option.Set("textContent", name)
+		option.Set("value", name)
+		option.Set("selected", name == a.store.Current())
+		a.fileSelect.Call("appendChild", option)
+	}
+}
+
 func (a *Assembler) hideError() {
 	a.errorView.Set("className", "error-view")
 }
@@ -0,0 +1,135 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"sync"
+	"syscall/js"
+)
+
+// A BackgroundRunner runs programs in a Web Worker, so that long runs do not block the page.
+// See runWorker for the messages which it exchanges with the worker.
+//
+// The worker is started when it is first needed, and it is terminated to stop a run.
+type BackgroundRunner struct {
+	lock sync.Mutex
+
+	scriptURL string
+	worker    js.Value
+	onMessage js.Func
+	onError   js.Func
+	ready     bool
+
+	// pending is a "run" message which is waiting for the worker to be ready.
+	pending js.Value
+
+	// messages holds the "progress" messages which have not been received yet, and notify gets
+	// a value when a message is added.
+	messages []js.Value
+	notify   chan struct{}
+}
+
+// NewBackgroundRunner creates a runner whose worker loads a script.
+// If the script is "", or if the browser does not have Web Workers, the runner is unavailable.
+func NewBackgroundRunner(scriptURL string) *BackgroundRunner {
+	res := &BackgroundRunner{
+		scriptURL: scriptURL,
+		worker:    js.Undefined(),
+		pending:   js.Undefined(),
+		notify:    make(chan struct{}, 1),
+	}
+	res.onMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		res.handleMessage(this, argument(args, 0).Get("data"))
+		return nil
+	})
+	res.onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		// Report the failure as the end of the run, so that the debugger stops waiting.
+		message := "background worker failed"
+		if text := argument(args, 0).Get("message"); text.Type() == js.TypeString {
+			message += ": " + text.String()
+		}
+		res.handleMessage(this, js.ValueOf(map[string]interface{}{
+			"type":     "progress",
+			"finished": true,
+			"error":    message,
+		}))
+		return nil
+	})
+	return res
+}
+
+// Available checks if programs can be run in the background.
+func (b *BackgroundRunner) Available() bool {
+	return b.scriptURL != "" && !js.Global().Get("Worker").IsUndefined()
+}
+
+// Run sends a "run" message to the worker, starting the worker if necessary.
+func (b *BackgroundRunner) Run(request js.Value) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.worker.IsUndefined() {
+		b.worker = js.Global().Get("Worker").New(b.scriptURL)
+		b.worker.Set("onmessage", b.onMessage)
+		b.worker.Set("onerror", b.onError)
+	}
+	if b.ready {
+		b.worker.Call("postMessage", request)
+	} else {
+		b.pending = request
+	}
+}
+
+// Notify returns a channel which gets a value when there may be messages to Receive.
+func (b *BackgroundRunner) Notify() <-chan struct{} {
+	return b.notify
+}
+
+// Receive returns the oldest "progress" message which has not been received.
+func (b *BackgroundRunner) Receive() (js.Value, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.messages) == 0 {
+		return js.Undefined(), false
+	}
+	res := b.messages[0]
+	b.messages = b.messages[1:]
+	return res, true
+}
+
+// Stop terminates the worker, interrupting its run.
+// Messages which have not been received are dropped.
+func (b *BackgroundRunner) Stop() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.worker.IsUndefined() {
+		b.worker.Call("terminate")
+		b.worker = js.Undefined()
+	}
+	b.ready = false
+	b.pending = js.Undefined()
+	b.messages = nil
+}
+
+func (b *BackgroundRunner) handleMessage(worker, message js.Value) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !worker.Equal(b.worker) {
+		// The message was sent before the worker was terminated.
+		return
+	}
+	switch message.Get("type").String() {
+	case "ready":
+		b.ready = true
+		if !b.pending.IsUndefined() {
+			b.worker.Call("postMessage", b.pending)
+			b.pending = js.Undefined()
+		}
+	case "progress":
+		b.messages = append(b.messages, message)
+		select {
+		case b.notify <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// A CallStackView lists the active function calls, innermost first.
+// Clicking a call shows its return address in the code view.
+type CallStackView struct {
+	element   js.Value
+	listeners listenerSet
+}
+
+func NewCallStackView() *CallStackView {
+	return &CallStackView{
+		element: js.Global().Get("debugger-call-stack"),
+	}
+}
+
+func (c *CallStackView) Update(stack *mips32.CallStack, exc *mips32.Executable,
+	codeView *CodeView) {
+	c.listeners.release()
+	c.element.Set("innerHTML", "<tr><td>Function</td><td>Returns to</td></tr>")
+	document := js.Global().Get("document")
+	for i := len(stack.Frames) - 1; i >= 0; i-- {
+		frame := stack.Frames[i]
+		row := document.Call("createElement", "tr")
+		row.Set("className", "debugger-call-stack-frame")
+
+		funcColumn := document.Call("createElement", "td")
+		funcColumn.Set("textContent", exc.SymbolicAddress(frame.Function))
+		row.Call("appendChild", funcColumn)
+
+		returnColumn := document.Call("createElement", "td")
+		returnColumn.Set("textContent", exc.SymbolicAddress(frame.ReturnAddress))
+		row.Call("appendChild", returnColumn)
+
+		c.listeners.add(row, "click", func(js.Value) {
+			codeView.ShowAddress(frame.ReturnAddress)
+		})
+		c.element.Call("appendChild", row)
+	}
+}
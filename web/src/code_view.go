@@ -1,42 +1,141 @@
+//go:build js
+// +build js
+
 package main
 
 import (
-	"github.com/gopherjs/gopherjs/js"
+	"syscall/js"
+
 	"github.com/unixpickle/mips32"
 )
 
 const PreviewLineCount = 7
 
+// runToCursorKey is the key which runs the program until it reaches the selected line.
+const runToCursorKey = "r"
+
 type CodeView struct {
-	element *js.Object
+	element js.Value
+
+	emulator    *mips32.Emulator
+	breakpoints *mips32.Breakpoints
+	center      uint32
+
+	cursor    uint32
+	hasCursor bool
+
+	onSelect func(e *mips32.Emulator, addr uint32)
+
+	// coverage, if non-nil, is used to mark the instructions which have run.
+	coverage *mips32.Coverage
+
+	// listeners belong to the rows of the view.
+	listeners listenerSet
 }
 
 func NewCodeView() *CodeView {
-	return &CodeView{
-		element: js.Global.Get("debugger-code-view"),
+	res := &CodeView{
+		element: js.Global().Get("debugger-code-view"),
 	}
+	document := js.Global().Get("document")
+	addListener(document, "keydown", func(event js.Value) {
+		target := event.Get("target").Get("tagName").String()
+		if target == "INPUT" || target == "TEXTAREA" || target == "SELECT" {
+			return
+		}
+		key := event.Get("key").String()
+		if GlobalApp.Pane() == "debugger" && res.hasCursor && key == runToCursorKey {
+			go GlobalDebugger.RunToAddress(res.cursor)
+		}
+	})
+	return res
 }
 
-func (c *CodeView) Update(e *mips32.Emulator) {
+// OnSelect registers a function to call when the user selects an instruction.
+func (c *CodeView) OnSelect(f func(e *mips32.Emulator, addr uint32)) {
+	c.onSelect = f
+}
+
+// SetCoverage marks the instructions which have or have not run, starting with the next update.
+// Passing nil removes the marks.
+func (c *CodeView) SetCoverage(coverage *mips32.Coverage) {
+	c.coverage = coverage
+}
+
+// Update shows the instructions around the program counter.
+// Clicking the gutter next to an instruction toggles a breakpoint there.
+func (c *CodeView) Update(e *mips32.Emulator, b *mips32.Breakpoints) {
+	c.emulator = e
+	c.breakpoints = b
+	c.ShowAddress(e.ProgramCounter)
+}
+
+// ShowAddress shows the instructions around an address until the next update.
+//
+// Clicking an instruction selects it, and pressing "r" or right-clicking an instruction runs the
+// program until it gets there. Hovering over the next instruction explains what it will do.
+func (c *CodeView) ShowAddress(center uint32) {
+	e, b := c.emulator, c.breakpoints
+	if e == nil {
+		return
+	}
+	c.center = center
 	var startAddress uint32
-	if (e.ProgramCounter / 4) > (PreviewLineCount / 2) {
-		startAddress = e.ProgramCounter - (PreviewLineCount/2)*4
+	if (center / 4) > (PreviewLineCount / 2) {
+		startAddress = (center &^ 3) - (PreviewLineCount/2)*4
 	}
-	c.element.Set("innerHTML", "<tr><td>Addr</td><td>Assembly</td><td>Code</td></tr>")
+	c.listeners.release()
+	c.element.Set("innerHTML", "<tr><td></td><td>Addr</td><td>Assembly</td><td>Code</td></tr>")
 	for i := 0; i < PreviewLineCount; i++ {
 		addr := startAddress + uint32(i*4)
-		row := createCodeViewLine(e, addr)
+		row := createCodeViewLine(e, addr, b.Has(addr), &c.listeners)
 		if addr == e.ProgramCounter {
 			row.Set("className", row.Get("className").String()+" debugger-code-view-current")
+			row.Set("title", "Next: "+mips32.ExplainInstruction(e.Executable.Get(addr), e))
+		}
+		if c.coverage != nil && e.Executable.Get(addr) != nil {
+			if c.coverage.Covered(addr) {
+				row.Set("className", row.Get("className").String()+" debugger-code-view-covered")
+			} else {
+				row.Set("className",
+					row.Get("className").String()+" debugger-code-view-uncovered")
+			}
+		}
+		if c.hasCursor && addr == c.cursor {
+			row.Set("className", row.Get("className").String()+" debugger-code-view-cursor")
 		}
+		c.listeners.add(row, "click", func(js.Value) {
+			c.cursor = addr
+			c.hasCursor = true
+			c.ShowAddress(c.center)
+			if c.onSelect != nil {
+				c.onSelect(e, addr)
+			}
+		})
+		c.listeners.add(row, "contextmenu", func(event js.Value) {
+			event.Call("preventDefault")
+			go GlobalDebugger.RunToAddress(addr)
+		})
 		c.element.Call("appendChild", row)
 	}
 }
 
-func createCodeViewLine(e *mips32.Emulator, addr uint32) *js.Object {
-	document := js.Global.Get("document")
+func createCodeViewLine(e *mips32.Emulator, addr uint32, breakpoint bool,
+	listeners *listenerSet) js.Value {
+	document := js.Global().Get("document")
 	row := document.Call("createElement", "tr")
 
+	gutterColumn := document.Call("createElement", "td")
+	gutterColumn.Set("className", "debugger-code-view-gutter")
+	if breakpoint {
+		gutterColumn.Set("textContent", "\u25cf")
+	}
+	listeners.add(gutterColumn, "click", func(event js.Value) {
+		event.Call("stopPropagation")
+		go GlobalDebugger.ToggleBreakpoint(addr)
+	})
+	row.Call("appendChild", gutterColumn)
+
 	addrColumn := document.Call("createElement", "td")
 	addrColumn.Set("textContent", format32BitHex(addr))
 	addrColumn.Set("className", "debugger-code-view-addr")
@@ -0,0 +1,92 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"io"
+	"syscall/js"
+)
+
+// A Console connects the SYSCALL instruction to a terminal panel in the debugger.
+//
+// It implements io.Writer for program output and io.Reader for program input.
+// Reads block until the user enters a line of text.
+type Console struct {
+	output js.Value
+	input  js.Value
+
+	lines   chan string
+	cancel  chan struct{}
+	pending []byte
+}
+
+func NewConsole() *Console {
+	res := &Console{
+		output: js.Global().Get("debugger-console-output"),
+		input:  js.Global().Get("debugger-console-input"),
+		lines:  make(chan string, 16),
+		cancel: make(chan struct{}, 1),
+	}
+	addListener(res.input, "keyup", func(event js.Value) {
+		if event.Get("keyCode").Int() != enterKeyCode {
+			return
+		}
+		line := res.input.Get("value").String() + "\n"
+		res.input.Set("value", "")
+		res.appendOutput(line)
+		select {
+		case res.lines <- line:
+		default:
+		}
+	})
+	return res
+}
+
+// Reset clears the console and discards unread input.
+// If a read is in progress, it fails with io.EOF.
+func (c *Console) Reset() {
+	select {
+	case c.cancel <- struct{}{}:
+	default:
+	}
+	c.pending = nil
+	c.output.Set("textContent", "")
+	for {
+		select {
+		case <-c.lines:
+		default:
+			return
+		}
+	}
+}
+
+func (c *Console) Write(p []byte) (int, error) {
+	c.appendOutput(string(p))
+	return len(p), nil
+}
+
+func (c *Console) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		// Drop any cancellation meant for a read that has already finished.
+		select {
+		case <-c.cancel:
+		default:
+		}
+		c.input.Call("focus")
+		select {
+		case line := <-c.lines:
+			c.pending = []byte(line)
+		case <-c.cancel:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *Console) appendOutput(text string) {
+	c.output.Set("textContent", c.output.Get("textContent").String()+text)
+	c.output.Set("scrollTop", c.output.Get("scrollHeight"))
+}
@@ -0,0 +1,48 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// A CoverageView summarizes which parts of the program have run, and can mark the instructions
+// which have or have not run in the code view.
+//
+// Coverage accumulates across resets, so that a program can be run with several inputs, until a
+// new program is loaded or the user clears it.
+type CoverageView struct {
+	checkbox js.Value
+	summary  js.Value
+	codeView *CodeView
+}
+
+func NewCoverageView(codeView *CodeView) *CoverageView {
+	res := &CoverageView{
+		checkbox: js.Global().Get("debugger-show-coverage"),
+		summary:  js.Global().Get("debugger-coverage-summary"),
+		codeView: codeView,
+	}
+	addListener(res.checkbox, "change", func(js.Value) {
+		go GlobalDebugger.updateUI()
+	})
+	addListener(js.Global().Get("debugger-clear-coverage"), "click", func(js.Value) {
+		go GlobalDebugger.ClearCoverage()
+	})
+	return res
+}
+
+// Update shows the coverage, if the user has asked to see it.
+// It should be called before the code view is updated.
+func (c *CoverageView) Update(coverage *mips32.Coverage) {
+	if !c.checkbox.Get("checked").Bool() {
+		c.summary.Set("textContent", "")
+		c.codeView.SetCoverage(nil)
+		return
+	}
+	c.summary.Set("textContent", coverage.Report().String())
+	c.codeView.SetCoverage(coverage)
+}
@@ -1,33 +1,93 @@
+//go:build js
+// +build js
+
 package main
 
 import (
+	"bytes"
+	"errors"
+	"math"
 	"strconv"
+	"strings"
 	"sync"
-	"time"
+	"syscall/js"
 
-	"github.com/gopherjs/gopherjs/js"
 	"github.com/unixpickle/mips32"
 )
 
+// defaultSpeedSlider is the initial position of the speed slider (4 instructions per second).
+const defaultSpeedSlider = 4
+
+// maxSpeedSlider is the last position of the speed slider, which runs the program as fast as
+// possible in a Web Worker.
+const maxSpeedSlider = 35
+
+// maxFrameCatchUp is the most time, in seconds, that a single animation frame will make up for.
+const maxFrameCatchUp = 0.25
+
+// maxStepLineInstructions limits how long "Step line", "Step over", "Step out", and run-to-cursor
+// can run, in case the program never gets where it is going.
+const maxStepLineInstructions = 100000
+
+// maxTimelineLength is the number of instructions which can be undone with the timeline slider.
+const maxTimelineLength = 10000
+
 type Debugger struct {
 	lock sync.Mutex
 
-	frequency   int
+	speed       float64
 	controlChan chan debuggerCommand
+
+	// frames receives the timestamps of animation frames requested with frameFunc.
+	frames    chan float64
+	frameFunc js.Func
+
+	// runner runs the program when the speed is unlimited.
+	runner *BackgroundRunner
+
 	emulator    *mips32.Emulator
 	stepCount   int
+	breakpoints mips32.Breakpoints
+	source      []string
+	callStack   mips32.CallStack
+	coverage    *mips32.Coverage
+	runTarget   uint32
+
+	// timeline records the executed instructions so that they can be undone, and stackHistory
+	// stores the call stack at each point in the timeline.
+	timeline     mips32.Timeline
+	stackHistory [][]mips32.Frame
+
+	// image is the binary being debugged, if it was loaded with LoadImage.
+	image *mips32.ELFImage
+
+	// preset is applied to the registers whenever the program is reset, or nil.
+	preset *mips32.RegisterPreset
 
 	registers      *Registers
 	codeView       *CodeView
+	sourceView     *SourceView
+	callStackView  *CallStackView
+	stackView      *StackView
+	inspector      *Inspector
+	watchView      *WatchView
+	symbols        *SymbolNavigator
+	console        *Console
 	memoryView     *MemoryView
-	errorView      *js.Object
-	stepCountLabel *js.Object
+	snapshotView   *SnapshotView
+	coverageView   *CoverageView
+	presetPicker   *PresetPicker
+	errorView      js.Value
+	stepCountLabel js.Value
+	timelineSlider js.Value
 }
 
 func NewDebugger() *Debugger {
 	res := &Debugger{
-		frequency:   4,
+		speed:       speedForSlider(defaultSpeedSlider),
 		controlChan: make(chan debuggerCommand, 0),
+		frames:      make(chan float64, 1),
+		runner:      NewBackgroundRunner(workerScript),
 		emulator: &mips32.Emulator{
 			Memory: mips32.NewLazyMemory(),
 			Executable: &mips32.Executable{
@@ -38,11 +98,31 @@ func NewDebugger() *Debugger {
 		},
 		registers:      NewRegisters(),
 		codeView:       NewCodeView(),
+		sourceView:     NewSourceView(),
+		callStackView:  NewCallStackView(),
+		stackView:      NewStackView(),
+		inspector:      NewInspector(),
+		watchView:      NewWatchView(),
+		console:        NewConsole(),
 		memoryView:     NewMemoryView(),
-		errorView:      js.Global.Get("debugger-error"),
-		stepCountLabel: js.Global.Get("debugger-step-count"),
+		presetPicker:   NewPresetPicker(),
+		errorView:      js.Global().Get("debugger-error"),
+		stepCountLabel: js.Global().Get("debugger-step-count"),
+		timelineSlider: js.Global().Get("debugger-timeline"),
 	}
 
+	res.symbols = NewSymbolNavigator(res.codeView, res.memoryView)
+	res.codeView.OnSelect(res.inspector.Inspect)
+	res.snapshotView = NewSnapshotView(res.memoryView)
+	res.coverageView = NewCoverageView(res.codeView)
+	res.frameFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		select {
+		case res.frames <- argument(args, 0).Float():
+		default:
+		}
+		return nil
+	})
+
 	go res.debugLoop()
 
 	res.registers.SetCallback(func(reg int, val uint32) {
@@ -51,6 +131,12 @@ func NewDebugger() *Debugger {
 		res.emulator.RegisterFile[reg] = val
 	})
 
+	res.emulator.Syscalls = &mips32.SPIMSyscalls{Input: res.console, Output: res.console}
+	res.timeline.Limit = maxTimelineLength
+	res.coverage = mips32.NewCoverage(res.emulator.Executable)
+	res.trackCalls()
+	res.memoryView.SetExecutable(res.emulator.Executable)
+	res.symbols.SetExecutable(res.emulator.Executable)
 	res.registerUIEvents()
 	res.updateUI()
 
@@ -59,26 +145,169 @@ func NewDebugger() *Debugger {
 
 // SetExecutable loads a new executable in the debugger.
 // It deletes all saved state (i.e. registers and memory).
+// Breakpoints are moved to the corresponding places in the new executable.
 // If the given executable is nil, the current executable will be reloaded.
 func (d *Debugger) SetExecutable(e *mips32.Executable) {
 	d.hideError()
+
+	// Interrupt the program if it is waiting for console input.
+	d.console.Reset()
+
 	d.controlChan <- stopDebugger
 	d.lock.Lock()
-	if e == nil {
+	newProgram := e != nil
+	if !newProgram {
 		e = d.emulator.Executable
+	} else {
+		d.breakpoints.Relocate(d.emulator.Executable, e)
+		d.image = nil
 	}
+	memory := mips32.NewLazyMemory()
+	loadErr := e.LoadInto(memory, true)
 	d.emulator = &mips32.Emulator{
-		Memory:       mips32.NewLazyMemory(),
+		Memory:       memory,
 		Executable:   e,
 		LittleEndian: true,
+		Syscalls:     &mips32.SPIMSyscalls{Input: d.console, Output: d.console},
+	}
+	if d.preset != nil {
+		d.preset.Apply(d.emulator)
+	}
+	if d.image != nil {
+		d.loadImage()
 	}
 	d.stepCount = 0
+	if newProgram {
+		d.coverage = mips32.NewCoverage(e)
+	}
+	d.trackCalls()
 	d.lock.Unlock()
+	d.memoryView.SetExecutable(e)
+	d.symbols.SetExecutable(e)
+	if newProgram {
+		d.snapshotView.Reset()
+	}
 	d.updateUI()
+	if loadErr != nil {
+		d.handleError(loadErr)
+	}
+}
+
+// LoadImage starts a debugging session for a program which has no source code.
+// The image's data is copied into memory and execution starts at its entry point.
+func (d *Debugger) LoadImage(e *mips32.Executable, image *mips32.ELFImage) {
+	d.SetSource("")
+	d.SetExecutable(e)
+	d.lock.Lock()
+	d.image = image
+	d.loadImage()
+	d.lock.Unlock()
+	d.updateUI()
+}
+
+// loadImage copies the current image into memory and jumps to its entry point.
+// The caller should hold d.lock.
+func (d *Debugger) loadImage() {
+	for start, data := range d.image.Chunks {
+		mips32.WriteBytes(d.emulator.Memory, start, data)
+	}
+	d.emulator.ProgramCounter = d.image.Entry
 }
 
 func (d *Debugger) Show() {
-	js.Global.Get("location").Set("hash", "#debugger")
+	GlobalApp.ShowPane("debugger")
+}
+
+// trackCalls resets the call stack and timeline and starts updating them as the emulator runs.
+// The caller should hold d.lock.
+func (d *Debugger) trackCalls() {
+	d.callStack.Reset()
+	d.timeline.Reset()
+	d.stackHistory = [][]mips32.Frame{nil}
+	emulator := d.emulator
+	emulator.AfterExecute = func(inst *mips32.Instruction, addr uint32) {
+		d.callStack.Observe(emulator, inst, addr)
+		d.coverage.Observe(emulator, inst, addr)
+
+		position := d.timeline.Position()
+		d.timeline.Record(emulator.LastDelta)
+		frames := append([]mips32.Frame{}, d.callStack.Frames...)
+		d.stackHistory = append(d.stackHistory[:position+1], frames)
+		if extra := len(d.stackHistory) - (d.timeline.Len() + 1); extra > 0 {
+			d.stackHistory = d.stackHistory[extra:]
+		}
+	}
+}
+
+// SeekTimeline rewinds or fast-forwards the program to a point in its timeline.
+func (d *Debugger) SeekTimeline(position int) {
+	d.lock.Lock()
+	oldPosition := d.timeline.Position()
+	d.timeline.Seek(d.emulator, position)
+	d.stepCount += d.timeline.Position() - oldPosition
+	d.callStack.Frames = append([]mips32.Frame{}, d.stackHistory[d.timeline.Position()]...)
+	d.lock.Unlock()
+	d.hideError()
+	d.updateUI()
+}
+
+// SetPreset chooses the registers to set before the program runs, and resets the program.
+// If the preset is nil, every register starts at zero.
+func (d *Debugger) SetPreset(p *mips32.RegisterPreset) {
+	d.lock.Lock()
+	d.preset = p
+	d.lock.Unlock()
+	d.SetExecutable(nil)
+}
+
+// SetSource sets the assembly code for the executable, so that the debugger can show which line
+// is running.
+// This should be called before SetExecutable.
+func (d *Debugger) SetSource(source string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.source = strings.Split(source, "\n")
+}
+
+// Executable returns the executable being debugged.
+func (d *Debugger) Executable() *mips32.Executable {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.emulator.Executable
+}
+
+// ToggleBreakpoint adds or removes a breakpoint at an address.
+func (d *Debugger) ToggleBreakpoint(addr uint32) {
+	d.lock.Lock()
+	d.breakpoints.Toggle(addr)
+	d.lock.Unlock()
+	d.updateUI()
+}
+
+// Breakpoints returns the addresses of all the breakpoints.
+func (d *Debugger) Breakpoints() []uint32 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.breakpoints.List()
+}
+
+// SetBreakpoints replaces all of the breakpoints.
+func (d *Debugger) SetBreakpoints(addrs []uint32) {
+	d.lock.Lock()
+	d.breakpoints = mips32.Breakpoints{}
+	for _, addr := range addrs {
+		d.breakpoints.Set(addr)
+	}
+	d.lock.Unlock()
+	d.updateUI()
+}
+
+// RunToAddress runs the program until the program counter reaches an address.
+func (d *Debugger) RunToAddress(addr uint32) {
+	d.lock.Lock()
+	d.runTarget = addr
+	d.lock.Unlock()
+	d.controlChan <- runToDebugger
 }
 
 // Get returns the byte at a given memory address in the debugger's RAM.
@@ -95,39 +324,90 @@ func (d *Debugger) Set(ptr uint32, b byte) {
 	d.emulator.Memory.Set(ptr, b)
 }
 
+// Snapshot copies the debugger's RAM.
+func (d *Debugger) Snapshot() *mips32.MemorySnapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.emulator.Memory.(*mips32.LazyMemory).Snapshot()
+}
+
+// ClearCoverage forgets which instructions have run.
+func (d *Debugger) ClearCoverage() {
+	d.lock.Lock()
+	d.coverage.Reset()
+	d.lock.Unlock()
+	d.updateUI()
+}
+
+// DiffSnapshot finds the words in RAM which have changed since a snapshot was taken.
+func (d *Debugger) DiffSnapshot(s *mips32.MemorySnapshot) []mips32.MemoryChange {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	current := d.emulator.Memory.(*mips32.LazyMemory).Snapshot()
+	return mips32.DiffSnapshots(s, current, d.emulator.LittleEndian)
+}
+
 func (d *Debugger) debugLoop() {
 	for command := range d.controlChan {
 		if command == stepDebugger {
 			d.stepDebugger()
+		} else if command == stepLineDebugger {
+			d.stepLineDebugger()
+		} else if command == stepOverDebugger || command == stepOutDebugger {
+			d.stepCallDebugger(command == stepOutDebugger)
+		} else if command == runToDebugger {
+			d.runToDebugger()
 		} else if command == startDebugger {
-			d.runDebugger()
+			if d.runsInBackground() {
+				d.runInBackground()
+			} else {
+				d.runDebugger()
+			}
 		}
 	}
 }
 
+// runDebugger runs the program in the background, executing enough instructions on each
+// animation frame to match the speed from the slider.
 func (d *Debugger) runDebugger() {
-	frameDuration, cyclesPerFrame := d.tickInfo()
-	ticker := time.NewTicker(frameDuration)
-	defer func() {
-		ticker.Stop()
-	}()
+	// Drop a frame left over from an earlier run.
+	select {
+	case <-d.frames:
+	default:
+	}
+	requestFrame := func() {
+		js.Global().Call("requestAnimationFrame", d.frameFunc)
+	}
+	requestFrame()
+
 	defer d.updateButtonState(false)
 	d.updateButtonState(true)
 	d.hideError()
+
+	// Start with a full instruction so that slow speeds respond right away.
+	budget := 1.0
+	var lastTime float64
 	for {
 		select {
-		case <-ticker.C:
+		case timestamp := <-d.frames:
+			if lastTime != 0 {
+				budget += d.currentSpeed() * (timestamp - lastTime) / 1000
+			}
+			lastTime = timestamp
 		case msg := <-d.controlChan:
 			if msg == stopDebugger {
 				return
-			} else if msg == updateDebuggerFreq {
-				ticker.Stop()
-				frameDuration, cyclesPerFrame = d.tickInfo()
-				ticker = time.NewTicker(frameDuration)
 			}
+			continue
+		}
+
+		// Don't try to catch up after the page has been in the background.
+		if maxBudget := d.currentSpeed() * maxFrameCatchUp; budget > maxBudget && maxBudget > 1 {
+			budget = maxBudget
 		}
+
 		d.lock.Lock()
-		for i := 0; i < cyclesPerFrame; i++ {
+		for ; budget >= 1; budget-- {
 			err := d.emulator.Step()
 			d.stepCount++
 			if err != nil {
@@ -136,7 +416,7 @@ func (d *Debugger) runDebugger() {
 				d.handleError(err)
 				return
 			}
-			if d.emulator.Done() {
+			if d.emulator.Done() || d.breakpoints.Has(d.emulator.ProgramCounter) {
 				d.lock.Unlock()
 				d.updateUI()
 				return
@@ -144,9 +424,136 @@ func (d *Debugger) runDebugger() {
 		}
 		d.lock.Unlock()
 		d.updateUI()
+		d.flashDelta()
+		requestFrame()
 	}
 }
 
+// runInBackground runs the program in a Web Worker as fast as possible, showing its progress as
+// the worker reports it.
+// Syscalls which read console input run on the page, and then the worker carries on.
+func (d *Debugger) runInBackground() {
+	defer d.updateButtonState(false)
+	d.updateButtonState(true)
+	d.hideError()
+
+	for {
+		d.lock.Lock()
+		request, err := d.backgroundRequest()
+		d.lock.Unlock()
+		if err != nil {
+			d.handleError(err)
+			return
+		}
+		d.runner.Run(request)
+		result, ok := d.waitForBackgroundRun()
+		if !ok {
+			return
+		}
+		if errText := result.Get("error"); errText.Type() == js.TypeString {
+			d.handleError(errors.New(errText.String()))
+			return
+		} else if !result.Get("needInput").Truthy() {
+			return
+		}
+
+		d.lock.Lock()
+		err = d.emulator.Step()
+		d.stepCount++
+		stop := err != nil || d.emulator.Done() || d.breakpoints.Has(d.emulator.ProgramCounter)
+		d.lock.Unlock()
+		d.updateUI()
+		if err != nil {
+			d.handleError(err)
+		}
+		if stop {
+			return
+		}
+	}
+}
+
+// backgroundRequest creates a "run" message for the worker.
+// The caller should hold d.lock.
+func (d *Debugger) backgroundRequest() (js.Value, error) {
+	segments, symbols, err := encodeWorkerExecutable(d.emulator.Executable)
+	if err != nil {
+		return js.Undefined(), err
+	}
+	var snapshot bytes.Buffer
+	if err := d.emulator.WriteSnapshot(&snapshot); err != nil {
+		return js.Undefined(), err
+	}
+	var breakpoints []interface{}
+	for _, addr := range d.breakpoints.List() {
+		breakpoints = append(breakpoints, addr)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"type":        "run",
+		"segments":    segments,
+		"symbols":     symbols,
+		"snapshot":    bytesToJS(snapshot.Bytes()),
+		"frames":      encodeFrames(d.callStack.Frames),
+		"breakpoints": breakpoints,
+	}), nil
+}
+
+// waitForBackgroundRun shows the progress of a run in the worker.
+// It returns the last message of the run, or false if the run was stopped first.
+func (d *Debugger) waitForBackgroundRun() (js.Value, bool) {
+	for {
+		select {
+		case <-d.runner.Notify():
+		case msg := <-d.controlChan:
+			if msg == stopDebugger {
+				d.runner.Stop()
+				return js.Undefined(), false
+			}
+			continue
+		}
+		for {
+			message, ok := d.runner.Receive()
+			if !ok {
+				break
+			}
+			if err := d.applyProgress(message); err != nil {
+				d.runner.Stop()
+				d.handleError(err)
+				return js.Undefined(), false
+			}
+			if message.Get("finished").Truthy() {
+				return message, true
+			}
+		}
+	}
+}
+
+// applyProgress updates the debugger with a "progress" message from the worker.
+func (d *Debugger) applyProgress(message js.Value) error {
+	snapshot := message.Get("snapshot")
+	if isMissing(snapshot) {
+		return nil
+	}
+	d.lock.Lock()
+	err := d.emulator.ReadSnapshot(bytes.NewReader(bytesFromJS(snapshot)))
+	if err != nil {
+		d.lock.Unlock()
+		return err
+	}
+	d.emulator.LastDelta = mips32.Delta{}
+	d.stepCount += message.Get("steps").Int()
+	d.callStack.Frames = decodeFrames(message.Get("frames"))
+	addCoverage(d.coverage, message.Get("coverage"))
+
+	// The instructions which ran in the worker cannot be undone.
+	d.timeline.Reset()
+	d.stackHistory = [][]mips32.Frame{append([]mips32.Frame{}, d.callStack.Frames...)}
+	d.lock.Unlock()
+
+	d.console.Write([]byte(message.Get("output").String()))
+	d.updateUI()
+	return nil
+}
+
 func (d *Debugger) stepDebugger() {
 	d.lock.Lock()
 	err := d.emulator.Step()
@@ -157,6 +564,69 @@ func (d *Debugger) stepDebugger() {
 	} else {
 		d.hideError()
 		d.updateUI()
+		d.flashDelta()
+	}
+}
+
+// stepLineDebugger runs until the program reaches a different line of source code.
+func (d *Debugger) stepLineDebugger() {
+	d.lock.Lock()
+	lines := d.emulator.Executable.LineNumbers
+	startLine := lines[d.emulator.ProgramCounter]
+	var err error
+	for i := 0; i < maxStepLineInstructions; i++ {
+		err = d.emulator.Step()
+		d.stepCount++
+		if err != nil || d.emulator.Done() || d.breakpoints.Has(d.emulator.ProgramCounter) {
+			break
+		}
+		if line, ok := lines[d.emulator.ProgramCounter]; ok && line != startLine {
+			break
+		}
+	}
+	d.lock.Unlock()
+	if err != nil {
+		d.updateUI()
+		d.handleError(err)
+	} else {
+		d.hideError()
+		d.updateUI()
+		d.flashDelta()
+	}
+}
+
+// stepCallDebugger steps over the next instruction, or out of the current function.
+func (d *Debugger) stepCallDebugger(out bool) {
+	d.lock.Lock()
+	var steps int
+	var err error
+	if out {
+		steps, err = d.emulator.StepOut(maxStepLineInstructions, &d.breakpoints)
+	} else {
+		steps, err = d.emulator.StepOver(maxStepLineInstructions, &d.breakpoints)
+	}
+	d.stepCount += steps
+	d.lock.Unlock()
+	d.updateUI()
+	d.flashDelta()
+	if err != nil {
+		d.handleError(err)
+	} else {
+		d.hideError()
+	}
+}
+
+func (d *Debugger) runToDebugger() {
+	d.lock.Lock()
+	steps, err := d.emulator.RunUntil(d.runTarget, maxStepLineInstructions, &d.breakpoints)
+	d.stepCount += steps
+	d.lock.Unlock()
+	d.updateUI()
+	d.flashDelta()
+	if err != nil {
+		d.handleError(err)
+	} else {
+		d.hideError()
 	}
 }
 
@@ -181,15 +651,30 @@ func (d *Debugger) updateUI() {
 	defer d.lock.Unlock()
 
 	d.registers.Update(d.emulator.RegisterFile)
-	d.codeView.Update(d.emulator)
+	d.coverageView.Update(d.coverage)
+	d.codeView.Update(d.emulator, &d.breakpoints)
+	d.sourceView.Update(d.emulator, d.source)
+	d.callStackView.Update(&d.callStack, d.emulator.Executable, d.codeView)
+	d.stackView.Update(&d.callStack, d.emulator)
+	d.watchView.Update(d.emulator)
 	d.stepCountLabel.Set("textContent", "Steps: "+strconv.Itoa(d.stepCount))
+	d.timelineSlider.Set("max", d.timeline.Len())
+	d.timelineSlider.Set("value", d.timeline.Position())
+}
+
+// flashDelta highlights the registers and memory changed by the last instruction.
+func (d *Debugger) flashDelta() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.registers.Flash(d.emulator.LastDelta.Registers)
+	d.memoryView.Flash(d.emulator.LastDelta.Memory)
 }
 
 func (d *Debugger) updateButtonState(running bool) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	button := js.Global.Get("debugger-play")
+	button := js.Global().Get("debugger-play")
 	if running {
 		button.Set("textContent", "Stop")
 	} else {
@@ -197,23 +682,64 @@ func (d *Debugger) updateButtonState(running bool) {
 	}
 }
 
-func (d *Debugger) tickInfo() (framePause time.Duration, instsPerFrame int) {
+// currentSpeed returns the number of instructions per second for runDebugger.
+func (d *Debugger) currentSpeed() float64 {
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	if d.frequency > 32 {
-		return time.Second / 32, d.frequency / 32
-	} else {
-		return time.Second / time.Duration(d.frequency), 1
+	if math.IsInf(d.speed, 1) {
+		// Without a worker, the last position of the slider is like the one before it.
+		return speedForSlider(maxSpeedSlider - 1)
 	}
+	return d.speed
+}
+
+// runsInBackground checks if the program should run in a worker rather than on the page.
+func (d *Debugger) runsInBackground() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return math.IsInf(d.speed, 1) && d.runner.Available()
 }
 
 func (d *Debugger) registerUIEvents() {
-	js.Global.Get("debugger-step").Call("addEventListener", "click", func() {
-		d.controlChan <- stepDebugger
+	addListener(d.timelineSlider, "input", func(js.Value) {
+		position := d.timelineSlider.Get("valueAsNumber").Int()
+		go func() {
+			d.controlChan <- stopDebugger
+			d.SeekTimeline(position)
+		}()
+	})
+	addListener(js.Global().Get("debugger-step-back"), "click", func(js.Value) {
+		go func() {
+			d.controlChan <- stopDebugger
+			d.lock.Lock()
+			position := d.timeline.Position() - 1
+			d.lock.Unlock()
+			d.SeekTimeline(position)
+		}()
+	})
+	addListener(js.Global().Get("debugger-step"), "click", func(js.Value) {
+		go func() {
+			d.controlChan <- stepDebugger
+		}()
+	})
+	addListener(js.Global().Get("debugger-step-line"), "click", func(js.Value) {
+		go func() {
+			d.controlChan <- stepLineDebugger
+		}()
+	})
+	addListener(js.Global().Get("debugger-step-over"), "click", func(js.Value) {
+		go func() {
+			d.controlChan <- stepOverDebugger
+		}()
+	})
+	addListener(js.Global().Get("debugger-step-out"), "click", func(js.Value) {
+		go func() {
+			d.controlChan <- stepOutDebugger
+		}()
 	})
 
-	playButton := js.Global.Get("debugger-play")
-	playButton.Call("addEventListener", "click", func() {
+	playButton := js.Global().Get("debugger-play")
+	addListener(playButton, "click", func(js.Value) {
 		go func() {
 			if playButton.Get("textContent").String() == "Stop" {
 				d.controlChan <- stopDebugger
@@ -223,19 +749,21 @@ func (d *Debugger) registerUIEvents() {
 		}()
 	})
 
-	ratePicker := js.Global.Get("debugger-rate")
-	ratePicker.Call("addEventListener", "change", func() {
+	speedSlider := js.Global().Get("debugger-speed")
+	speedLabel := js.Global().Get("debugger-speed-label")
+	speedSlider.Set("value", defaultSpeedSlider)
+	speedLabel.Set("textContent", formatSpeed(speedForSlider(defaultSpeedSlider)))
+	addListener(speedSlider, "input", func(js.Value) {
+		speed := speedForSlider(speedSlider.Get("valueAsNumber").Int())
+		speedLabel.Set("textContent", formatSpeed(speed))
 		go func() {
-			rate := ratePicker.Get("value").String()
-			num, _ := strconv.Atoi(rate)
 			d.lock.Lock()
-			d.frequency = num
+			d.speed = speed
 			d.lock.Unlock()
-			d.controlChan <- updateDebuggerFreq
 		}()
 	})
 
-	js.Global.Get("debugger-reset").Call("addEventListener", "click", func() {
+	addListener(js.Global().Get("debugger-reset"), "click", func(js.Value) {
 		go d.SetExecutable(nil)
 	})
 }
@@ -246,5 +774,24 @@ const (
 	stopDebugger debuggerCommand = iota
 	startDebugger
 	stepDebugger
-	updateDebuggerFreq
+	stepLineDebugger
+	stepOverDebugger
+	stepOutDebugger
+	runToDebugger
 )
+
+// speedForSlider converts a position on the speed slider to instructions per second.
+// Every two notches double the speed, and the last position is unlimited.
+func speedForSlider(value int) float64 {
+	if value >= maxSpeedSlider {
+		return math.Inf(1)
+	}
+	return math.Pow(2, float64(value)/2)
+}
+
+func formatSpeed(speed float64) string {
+	if math.IsInf(speed, 1) {
+		return "Unlimited"
+	}
+	return strconv.FormatFloat(speed, 'f', 0, 64) + " instructions/sec"
+}
@@ -1,3 +1,6 @@
+//go:build js
+// +build js
+
 package main
 
 import (
@@ -5,22 +8,24 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"syscall/js"
 
-	"github.com/gopherjs/gopherjs/js"
 	"github.com/unixpickle/mips32"
 )
 
 type Disassembler struct {
-	textarea  *js.Object
-	errorView *js.Object
+	textarea  js.Value
+	errorView js.Value
 }
 
 func NewDisassembler() *Disassembler {
 	res := &Disassembler{
-		textarea:  js.Global.Get("disassembler-data"),
-		errorView: js.Global.Get("disassembler-error"),
+		textarea:  js.Global().Get("disassembler-data"),
+		errorView: js.Global().Get("disassembler-error"),
 	}
-	js.Global.Get("disassembler-button").Call("addEventListener", "click", res.disassemble)
+	addListener(js.Global().Get("disassembler-button"), "click", func(js.Value) {
+		res.disassemble()
+	})
 	return res
 }
 
@@ -67,7 +72,7 @@ func (d *Disassembler) disassemble() {
 	}
 
 	d.hideError()
-	GlobalAssembler.SetCode(strings.Join(instStrs, "\n"))
+	GlobalAssembler.CreateFile("disassembly", strings.Join(instStrs, "\n"))
 	GlobalAssembler.Show()
 }
 
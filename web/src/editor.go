@@ -0,0 +1,142 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"html"
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// An Editor is a code editor with syntax highlighting.
+//
+// The code is typed into a transparent textarea, which sits on top of a <pre> element that
+// shows the highlighted code.
+type Editor struct {
+	textarea  js.Value
+	highlight js.Value
+
+	errors []*mips32.SourceError
+}
+
+func NewEditor() *Editor {
+	res := &Editor{
+		textarea:  js.Global().Get("assembler-code"),
+		highlight: js.Global().Get("assembler-highlight"),
+	}
+	addListener(res.textarea, "input", func(js.Value) { res.updateHighlight() })
+	addListener(res.textarea, "scroll", func(js.Value) { res.syncScroll() })
+	return res
+}
+
+// Value returns the code in the editor.
+func (e *Editor) Value() string {
+	return e.textarea.Get("value").String()
+}
+
+// SetValue replaces the code in the editor.
+func (e *Editor) SetValue(code string) {
+	e.textarea.Set("value", code)
+	e.updateHighlight()
+}
+
+// OnInput registers a function to call whenever the user changes the code.
+func (e *Editor) OnInput(f func()) {
+	addListener(e.textarea, "input", func(js.Value) { f() })
+}
+
+// SetReadOnly prevents or allows editing the code.
+func (e *Editor) SetReadOnly(readOnly bool) {
+	e.textarea.Set("readOnly", readOnly)
+}
+
+// SetErrors underlines the code which caused each error.
+func (e *Editor) SetErrors(errs []*mips32.SourceError) {
+	e.errors = errs
+	e.updateHighlight()
+}
+
+// SelectLine moves the cursor to the start of a 1-based line.
+func (e *Editor) SelectLine(line int) {
+	offset := 0
+	for i, text := range strings.Split(e.Value(), "\n") {
+		if i+1 == line {
+			break
+		}
+		offset += len(text) + 1
+	}
+	e.textarea.Call("focus")
+	e.textarea.Call("setSelectionRange", offset, offset)
+}
+
+func (e *Editor) updateHighlight() {
+	code := e.Value()
+	errorMask := e.errorMask(code)
+	var parts []string
+	var lastEnd int
+	for _, token := range mips32.ClassifyTokens(code) {
+		parts = append(parts, highlightRange(code, errorMask, lastEnd, token.Start))
+		parts = append(parts, `<span class="token-`+token.Class.String()+`">`+
+			highlightRange(code, errorMask, token.Start, token.End)+"</span>")
+		lastEnd = token.End
+	}
+	parts = append(parts, highlightRange(code, errorMask, lastEnd, len(code)))
+
+	// A trailing newline is not rendered by a <pre>, so the highlighting would be one line shorter
+	// than the textarea's contents.
+	parts = append(parts, "\n")
+
+	e.highlight.Set("innerHTML", strings.Join(parts, ""))
+	e.syncScroll()
+}
+
+// errorMask returns a slice indicating which bytes of the code should be underlined.
+func (e *Editor) errorMask(code string) []bool {
+	mask := make([]bool, len(code))
+	lines := strings.Split(code, "\n")
+	lineStarts := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		lineStarts[i] = lineStarts[i-1] + len(lines[i-1]) + 1
+	}
+	for _, err := range e.errors {
+		if err.Line < 1 || err.Line > len(lines) {
+			continue
+		}
+		line := lines[err.Line-1]
+		start, end := err.Column, err.EndColumn
+		if end == 0 {
+			start = len(line) - len(strings.TrimLeft(line, " \t"))
+			end = len(strings.TrimRight(line, " \t\r"))
+		}
+		for i := start; i < end && i < len(line); i++ {
+			mask[lineStarts[err.Line-1]+i] = true
+		}
+	}
+	return mask
+}
+
+// highlightRange escapes a range of code, underlining parts that have errors.
+func highlightRange(code string, errorMask []bool, start, end int) string {
+	var res string
+	for start < end {
+		runEnd := start + 1
+		for runEnd < end && errorMask[runEnd] == errorMask[start] {
+			runEnd++
+		}
+		text := html.EscapeString(code[start:runEnd])
+		if errorMask[start] {
+			text = `<span class="token-error">` + text + "</span>"
+		}
+		res += text
+		start = runEnd
+	}
+	return res
+}
+
+func (e *Editor) syncScroll() {
+	e.highlight.Set("scrollTop", e.textarea.Get("scrollTop"))
+	e.highlight.Set("scrollLeft", e.textarea.Get("scrollLeft"))
+}
@@ -1,25 +1,34 @@
+//go:build js
+// +build js
+
 package main
 
 import (
 	"strconv"
+	"strings"
+	"syscall/js"
 
-	"github.com/gopherjs/gopherjs/js"
+	"github.com/unixpickle/mips32"
 )
 
 const enterKeyCode = 13
 const escapeKeyCode = 27
 
 type entryPopup struct {
-	shieldElement *js.Object
-	popupElement  *js.Object
-	inputElement  *js.Object
+	shieldElement js.Value
+	popupElement  js.Value
+	inputElement  js.Value
+	errorElement  js.Value
 	callback      func(i uint32)
+
+	// listeners are released when the popup closes.
+	listeners listenerSet
 }
 
 func NewEntryPopup(prompt string, callback func(i uint32)) {
 	e := &entryPopup{}
 
-	document := js.Global.Get("document")
+	document := js.Global().Get("document")
 
 	e.shieldElement = document.Call("createElement", "div")
 	e.shieldElement.Set("className", "popup-shield")
@@ -36,6 +45,10 @@ func NewEntryPopup(prompt string, callback func(i uint32)) {
 	e.inputElement.Set("className", "popup-input")
 	e.popupElement.Call("appendChild", e.inputElement)
 
+	e.errorElement = document.Call("createElement", "label")
+	e.errorElement.Set("className", "error-view")
+	e.popupElement.Call("appendChild", e.errorElement)
+
 	br := document.Call("createElement", "br")
 	e.popupElement.Call("appendChild", br)
 
@@ -49,11 +62,11 @@ func NewEntryPopup(prompt string, callback func(i uint32)) {
 	okButton.Set("textContent", "OK")
 	e.popupElement.Call("appendChild", okButton)
 
-	e.shieldElement.Call("addEventListener", "click", e.close)
-	cancelButton.Call("addEventListener", "click", e.close)
-	okButton.Call("addEventListener", "click", e.ok)
+	e.listeners.add(e.shieldElement, "click", func(js.Value) { e.close() })
+	e.listeners.add(cancelButton, "click", func(js.Value) { e.close() })
+	e.listeners.add(okButton, "click", func(js.Value) { e.ok() })
 
-	e.inputElement.Call("addEventListener", "keyup", func(event *js.Object) {
+	e.listeners.add(e.inputElement, "keyup", func(event js.Value) {
 		keyCode := event.Get("keyCode").Int()
 		if keyCode == enterKeyCode {
 			e.ok()
@@ -75,17 +88,34 @@ func NewEntryPopup(prompt string, callback func(i uint32)) {
 }
 
 func (e *entryPopup) close() {
-	document := js.Global.Get("document")
+	document := js.Global().Get("document")
 	document.Get("body").Call("removeChild", e.shieldElement)
 	document.Get("body").Call("removeChild", e.popupElement)
+	e.listeners.release()
 }
 
 func (e *entryPopup) ok() {
-	defer e.close()
-	value := e.inputElement.Get("value").String()
+	value := strings.TrimSpace(e.inputElement.Get("value").String())
 	if len(value) == 0 {
+		e.close()
+		return
+	}
+	number, err := parseEntry(value)
+	if err != nil {
+		e.errorElement.Set("className", "error-view showing-error")
+		e.errorElement.Set("textContent", err.Error())
 		return
 	}
-	number, _ := strconv.ParseInt(value, 0, 64)
-	e.callback(uint32(number))
+	e.close()
+	e.callback(number)
+}
+
+// parseEntry parses a hex or decimal number, a symbol from the debugger's executable, or a
+// symbol plus or minus an offset.
+func parseEntry(value string) (uint32, error) {
+	exc := &mips32.Executable{}
+	if GlobalDebugger != nil {
+		exc = GlobalDebugger.Executable()
+	}
+	return exc.ResolveLocation(value)
 }
@@ -0,0 +1,123 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// exportFormats maps each export format to its file extension.
+var exportFormats = map[string]string{
+	"hex":     ".hex",
+	"srec":    ".srec",
+	"bin":     ".bin",
+	"listing": ".lst",
+}
+
+// ExportExecutable encodes an executable in one of the formats from exportFormats.
+func ExportExecutable(e *mips32.Executable, format string, little bool) ([]byte, error) {
+	if format == "listing" {
+		return exportListing(e)
+	}
+	chunks, err := e.EncodeBytes(little)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "hex":
+		err = mips32.WriteIntelHex(&buf, chunks)
+	case "srec":
+		err = mips32.WriteSRecord(&buf, chunks)
+	case "bin":
+		err = writeRawBinary(&buf, chunks)
+	default:
+		err = errors.New("unknown export format: " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadFile makes the browser save some data as a file.
+func DownloadFile(name string, data []byte) {
+	blob := js.Global().Get("Blob").New([]interface{}{bytesToJS(data)}, map[string]interface{}{
+		"type": "application/octet-stream",
+	})
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	link := js.Global().Get("document").Call("createElement", "a")
+	link.Set("href", url)
+	link.Set("download", name)
+	js.Global().Get("document").Get("body").Call("appendChild", link)
+	link.Call("click")
+	link.Call("remove")
+	js.Global().Get("URL").Call("revokeObjectURL", url)
+}
+
+// writeRawBinary writes the chunks from the lowest address to the highest, filling the gaps
+// between them with zeroes.
+func writeRawBinary(buf *bytes.Buffer, chunks map[uint32][]byte) error {
+	starts := sortedChunkStarts(chunks)
+	if len(starts) == 0 {
+		return nil
+	}
+	end := starts[0]
+	for _, start := range starts {
+		if start < end {
+			return errors.New("overlapping segments at " + format32BitHex(start))
+		}
+		buf.Write(make([]byte, start-end))
+		buf.Write(chunks[start])
+		end = start + uint32(len(chunks[start]))
+	}
+	return nil
+}
+
+func exportListing(e *mips32.Executable) ([]byte, error) {
+	symbolsByAddress := map[uint32][]string{}
+	for sym, addr := range e.Symbols {
+		symbolsByAddress[addr] = append(symbolsByAddress[addr], sym)
+	}
+	var lines []string
+	for _, segment := range e.OrderedSegments() {
+		for i, inst := range segment.Instructions {
+			addr := segment.Address + uint32(i*4)
+			syms := symbolsByAddress[addr]
+			sort.Strings(syms)
+			for _, sym := range syms {
+				lines = append(lines, sym+":")
+			}
+			enc, err := inst.Encode(addr, e.Symbols)
+			if err != nil {
+				return nil, errors.New("failed to encode instruction at " +
+					format32BitHex(addr) + ": " + err.Error())
+			}
+			rendered, err := inst.Render()
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, format32BitHex(addr)[2:]+"  "+format32BitHex(enc)[2:]+"  "+
+				rendered.String())
+		}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func sortedChunkStarts(chunks map[uint32][]byte) []uint32 {
+	starts := make([]uint32, 0, len(chunks))
+	for start := range chunks {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+	return starts
+}
@@ -0,0 +1,83 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// RegisterFileDrop lets the user drop files onto the page.
+//
+// Assembly files are opened in the assembler.
+// ELF, Intel HEX, and S-record files are disassembled and loaded into the debugger.
+func RegisterFileDrop() {
+	body := js.Global().Get("document").Get("body")
+	addListener(body, "dragover", func(event js.Value) {
+		event.Call("preventDefault")
+		event.Get("dataTransfer").Set("dropEffect", "copy")
+	})
+	addListener(body, "drop", func(event js.Value) {
+		event.Call("preventDefault")
+		files := event.Get("dataTransfer").Get("files")
+		if files.Length() == 0 {
+			return
+		}
+		file := files.Index(0)
+		reader := js.Global().Get("FileReader").New()
+		var onLoad js.Func
+		onLoad = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			onLoad.Release()
+			array := js.Global().Get("Uint8Array").New(reader.Get("result"))
+			go openDroppedFile(file.Get("name").String(), bytesFromJS(array))
+			return nil
+		})
+		reader.Set("onload", onLoad)
+		reader.Call("readAsArrayBuffer", file)
+	})
+}
+
+func openDroppedFile(name string, data []byte) {
+	ext := strings.ToLower(path.Ext(name))
+	var image *mips32.ELFImage
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("\x7fELF")):
+		image, err = mips32.ReadELF(bytes.NewReader(data))
+	case ext == ".hex" || ext == ".ihex":
+		image = &mips32.ELFImage{LittleEndian: true}
+		image.Chunks, err = mips32.ReadIntelHex(bytes.NewReader(data))
+	case ext == ".srec" || ext == ".s19" || ext == ".s28" || ext == ".s37" || ext == ".mot":
+		image = &mips32.ELFImage{LittleEndian: true}
+		image.Chunks, err = mips32.ReadSRecord(bytes.NewReader(data))
+	default:
+		GlobalAssembler.CreateFile(strings.TrimSuffix(name, path.Ext(name)), string(data))
+		GlobalAssembler.Show()
+		return
+	}
+	if err != nil {
+		GlobalAssembler.showError(errors.New(name + ": " + err.Error()))
+		GlobalAssembler.Show()
+		return
+	}
+	if len(image.Chunks) == 0 {
+		GlobalAssembler.showError(errors.New(name + ": file has no data"))
+		GlobalAssembler.Show()
+		return
+	}
+	if image.Entry == 0 {
+		image.Entry = lowestChunkAddress(image.Chunks)
+	}
+	GlobalDebugger.LoadImage(image.Executable(), image)
+	GlobalDebugger.Show()
+}
+
+func lowestChunkAddress(chunks map[uint32][]byte) uint32 {
+	return sortedChunkStarts(chunks)[0]
+}
@@ -0,0 +1,65 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// An Inspector shows the bit fields of the instruction selected in the code view.
+type Inspector struct {
+	element js.Value
+	title   js.Value
+}
+
+func NewInspector() *Inspector {
+	return &Inspector{
+		element: js.Global().Get("debugger-inspector"),
+		title:   js.Global().Get("debugger-inspector-title"),
+	}
+}
+
+// Inspect shows the instruction at an address.
+func (i *Inspector) Inspect(e *mips32.Emulator, addr uint32) {
+	i.element.Set("innerHTML", "")
+	var word uint32
+	if inst := e.Executable.Get(addr); inst != nil {
+		var err error
+		word, err = inst.Encode(addr, e.Executable.Symbols)
+		if err != nil {
+			i.title.Set("textContent", "Cannot encode instruction: "+err.Error())
+			return
+		}
+	}
+	format, fields := mips32.InstructionFields(word)
+	i.title.Set("textContent", format32BitHex(addr)+": "+format.String()+"-format instruction "+
+		format32BitHex(word))
+
+	document := js.Global().Get("document")
+	addRow := func(className string, cell func(field mips32.BitField) string) {
+		row := document.Call("createElement", "tr")
+		row.Set("className", className)
+		for _, field := range fields {
+			column := document.Call("createElement", "td")
+			column.Set("textContent", cell(field))
+			row.Call("appendChild", column)
+		}
+		i.element.Call("appendChild", row)
+	}
+	addRow("debugger-inspector-names", func(field mips32.BitField) string {
+		return field.Name
+	})
+	addRow("debugger-inspector-ranges", func(field mips32.BitField) string {
+		return strconv.Itoa(field.High) + "-" + strconv.Itoa(field.Low)
+	})
+	addRow("debugger-inspector-bits", func(field mips32.BitField) string {
+		return field.Binary()
+	})
+	addRow("debugger-inspector-values", func(field mips32.BitField) string {
+		return strconv.FormatUint(uint64(field.Value), 10)
+	})
+}
@@ -0,0 +1,133 @@
+//go:build js
+// +build js
+
+package main
+
+// appHTML is the markup for the assembler, debugger, and disassembler.
+// It is inserted into the container element when the app is mounted.
+const appHTML = `
+<nav id="mips32-nav">
+  <a href="#assembler" id="assembler-link" class="nav-link nav-link-first">Assembler</a>
+  <a href="#debugger" id="debugger-link" class="nav-link">Debugger</a>
+  <a href="#disassembler" id="disassembler-link" class="nav-link">Disassembler</a>
+</nav>
+<div id="assembler" class="content-pane">
+  <div id="assembler-file-controls">
+    <select id="assembler-files"></select>
+    <button id="assembler-new">New</button>
+    <button id="assembler-rename">Rename</button>
+    <button id="assembler-delete">Delete</button>
+    <select id="assembler-examples"></select>
+  </div>
+  <div id="assembler-example-description"></div>
+  <div id="assembler-editor">
+    <pre id="assembler-highlight" aria-hidden="true"></pre>
+    <textarea id="assembler-code" spellcheck="false"></textarea>
+  </div>
+  <ul id="assembler-diagnostics"></ul>
+  <button id="assembler-button">Assemble</button>
+  <span id="assembler-share-controls">
+    <button id="assembler-share">Share link</button>
+    <label>
+      <input type="checkbox" id="assembler-share-settings"> Include breakpoints and settings
+    </label>
+  </span>
+  <br>
+  <span id="assembler-export-controls">
+    <select id="assembler-export-format">
+      <option value="hex">Intel HEX</option>
+      <option value="srec">S-record</option>
+      <option value="bin">Raw binary</option>
+      <option value="listing">Listing</option>
+    </select>
+    <select id="assembler-export-endian">
+      <option value="little" selected>Little endian</option>
+      <option value="big">Big endian</option>
+    </select>
+    <button id="assembler-export">Download</button>
+  </span>
+  <br>
+  <input id="assembler-share-link" readonly>
+  <br>
+  <label id="assembler-error" class="error-view"></label>
+</div>
+<div id="debugger" class="content-pane">
+  <div id="debugger-controls">
+    <button id="debugger-step-back">Step back</button>
+    <button id="debugger-step">Step</button>
+    <button id="debugger-step-line">Step line</button>
+    <button id="debugger-step-over">Step over</button>
+    <button id="debugger-step-out">Step out</button>
+    <button id="debugger-play">Play</button>
+    <button id="debugger-reset">Reset</button>
+    <select id="debugger-preset"></select>
+    <input type="range" id="debugger-speed" min="0" max="35">
+    <label id="debugger-speed-label"></label>
+  </div>
+  <label id="debugger-error" class="error-view"></label>
+  <table id="debugger-code-view"></table>
+  <div id="debugger-inspector-container">
+    <label id="debugger-inspector-title">Click an instruction to see its encoding</label>
+    <table id="debugger-inspector"></table>
+  </div>
+  <table id="debugger-source-view"></table>
+  <table id="debugger-call-stack"></table>
+  <table id="debugger-stack"></table>
+  <div id="debugger-symbol-navigator">
+    <input id="debugger-symbol-search" placeholder="Go to symbol">
+    <div id="debugger-symbols-scroll">
+      <table id="debugger-symbols"></table>
+    </div>
+  </div>
+  <div id="debugger-watch">
+    <input id="debugger-watch-input" placeholder="Watch expression, e.g. [$sp+4]">
+    <table id="debugger-watches"></table>
+  </div>
+  <label id="debugger-step-count">Steps: 0</label>
+  <div id="debugger-coverage">
+    <label><input type="checkbox" id="debugger-show-coverage"> Show coverage</label>
+    <span id="debugger-coverage-summary"></span>
+    <button id="debugger-clear-coverage">Clear</button>
+  </div>
+  <div id="debugger-timeline-container">
+    Timeline <input type="range" id="debugger-timeline" min="0" max="0" value="0">
+  </div>
+  <div id="debugger-console">
+    <pre id="debugger-console-output"></pre>
+    <input id="debugger-console-input" placeholder="Program input">
+  </div>
+  <div id="debugger-register-options">
+    <select id="debugger-register-format">
+      <option value="hex" selected>Hex</option>
+      <option value="signed">Signed</option>
+      <option value="unsigned">Unsigned</option>
+      <option value="all">All formats</option>
+    </select>
+    <label><input type="checkbox" id="debugger-abi-names"> ABI names</label>
+  </div>
+  <table id="debugger-registers"></table>
+  <br>
+  <div id="debugger-memory">
+    Memory at <label id="debugger-memory-base">0x00000000</label>
+    <input id="debugger-memory-goto" placeholder="Address or symbol">
+    <select id="debugger-memory-symbols"></select>
+    <br>
+    <div id="debugger-memory-scroll">
+      <table id="debugger-memory-contents"></table>
+    </div>
+  </div>
+  <div id="debugger-snapshot">
+    <button id="debugger-snapshot-take">Take snapshot</button>
+    <button id="debugger-snapshot-compare" disabled>Compare with snapshot</button>
+    <label id="debugger-snapshot-status"></label>
+    <table id="debugger-snapshot-diff"></table>
+  </div>
+</div>
+<div id="disassembler" class="content-pane">
+  <textarea id="disassembler-data"></textarea>
+  <br>
+  <button id="disassembler-button">Disassemble</button>
+  <br>
+  <label id="disassembler-error" class="error-view"></label>
+</div>
+`
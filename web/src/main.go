@@ -1,11 +1,19 @@
+//go:build js
+// +build js
+
 package main
 
-import "github.com/gopherjs/gopherjs/js"
+import "syscall/js"
 
 var GlobalDebugger *Debugger
 var GlobalAssembler *Assembler
 var GlobalDisassembler *Disassembler
 
+// workerScript is the script which the debugger loads into a Web Worker to run programs in the
+// background, or "" if it is unknown.
+// It is the page's MIPS32WorkerScript variable if it has one, and otherwise this app's script.
+var workerScript string
+
 var defaultProgram = `# Put your code here, then hit Assemble.
 # Large programs may take a moment or two to assemble.
 
@@ -14,13 +22,46 @@ LUI $r1, 0xDEAD
 ORI $r1, $r1, 0xBEEF`
 
 func main() {
-	js.Global.Get("window").Call("addEventListener", "load", func() {
-		go func() {
-			GlobalDebugger = NewDebugger()
-			GlobalAssembler = NewAssembler()
-			GlobalDisassembler = NewDisassembler()
-			GlobalAssembler.SetCode(defaultProgram)
-			GlobalAssembler.Assemble()
-		}()
-	})
+	document := js.Global().Get("document")
+	if document.IsUndefined() {
+		// This app was loaded by the debugger as a Web Worker.
+		runWorker()
+		select {}
+	}
+	if script := js.Global().Get("MIPS32WorkerScript"); script.Type() == js.TypeString {
+		workerScript = script.String()
+	} else if script := document.Get("currentScript"); !isMissing(script) {
+		workerScript = script.Get("src").String()
+	}
+
+	js.Global().Set("MIPS32Widget", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return mountWidget(argument(args, 0), argument(args, 1))
+	}))
+
+	// A WebAssembly build may start after the page has loaded.
+	if document.Get("readyState").String() == "complete" {
+		go mountPage()
+	} else {
+		addListener(js.Global().Get("window"), "load", func(js.Value) {
+			go mountPage()
+		})
+	}
+
+	// Keep the program alive, since its functions are called by the page.
+	select {}
+}
+
+// mountPage mounts the app into the page's app element, if it has one.
+func mountPage() {
+	// Pages which embed a widget have no app element.
+	container := js.Global().Get("document").Call("getElementById", "mips32-app")
+	if container.IsNull() {
+		return
+	}
+	MountApp(container, true, NewProgramStore(true))
+	RegisterFileDrop()
+	if !OpenPermalink() && !OpenRemoteProgram() {
+		GlobalAssembler.Restore(defaultProgram)
+		GlobalAssembler.Assemble()
+	}
 }
@@ -1,31 +1,45 @@
+//go:build js
+// +build js
+
 package main
 
 import (
-	"github.com/gopherjs/gopherjs/js"
+	"sort"
+	"syscall/js"
+
 	"github.com/unixpickle/mips32"
 )
 
-const memoryViewRows = 6
-const memoryViewColumns = 8
+const memoryViewRows = 32
+const memoryViewColumns = 16
 
 type MemoryView struct {
-	baseLabel    *js.Object
+	baseLabel    js.Value
+	gotoInput    js.Value
+	symbolPicker js.Value
 	baseAddress  uint32
-	memoryCells  []*js.Object
-	addressCells []*js.Object
+	memoryCells  []js.Value
+	addressCells []js.Value
+	asciiCells   []js.Value
+
+	memory     mips32.Memory
+	executable *mips32.Executable
 
-	memory mips32.Memory
+	// lastValues stores the bytes from the previous update, so that changes can be highlighted.
+	lastValues []byte
+	haveValues bool
 }
 
 func NewMemoryView() *MemoryView {
 	res := &MemoryView{
-		baseLabel:    js.Global.Get("debugger-memory-base"),
-		memoryCells:  []*js.Object{},
-		addressCells: []*js.Object{},
+		baseLabel:    js.Global().Get("debugger-memory-base"),
+		gotoInput:    js.Global().Get("debugger-memory-goto"),
+		symbolPicker: js.Global().Get("debugger-memory-symbols"),
+		lastValues:   make([]byte, memoryViewRows*memoryViewColumns),
 	}
 
-	document := js.Global.Get("document")
-	table := js.Global.Get("debugger-memory-contents")
+	document := js.Global().Get("document")
+	table := js.Global().Get("debugger-memory-contents")
 	for i := 0; i < memoryViewRows; i++ {
 		row := document.Call("createElement", "tr")
 		row.Set("className", "debugger-memory-row")
@@ -42,36 +56,114 @@ func NewMemoryView() *MemoryView {
 			res.memoryCells = append(res.memoryCells, valueCell)
 
 			offset := i*memoryViewColumns + j
-			valueCell.Call("addEventListener", "click", func() {
+			addListener(valueCell, "dblclick", func(js.Value) {
 				res.clickedCell(offset)
 			})
 		}
+
+		asciiCell := document.Call("createElement", "td")
+		asciiCell.Set("className", "debugger-memory-ascii")
+		row.Call("appendChild", asciiCell)
+		res.asciiCells = append(res.asciiCells, asciiCell)
+
 		res.addressCells = append(res.addressCells, addressCell)
 		table.Call("appendChild", row)
 	}
 
-	res.baseLabel.Call("addEventListener", "click", func() {
+	addListener(res.baseLabel, "click", func(js.Value) {
 		NewEntryPopup("View memory at address", func(num uint32) {
 			go res.updateBase(num)
 		})
 	})
+	addListener(res.gotoInput, "keyup", func(event js.Value) {
+		if event.Get("keyCode").Int() != enterKeyCode || res.executable == nil {
+			return
+		}
+		addr, err := res.executable.ResolveLocation(res.gotoInput.Get("value").String())
+		if err == nil {
+			go res.updateBase(addr)
+		}
+	})
+	addListener(res.symbolPicker, "change", func(js.Value) {
+		if res.executable == nil {
+			return
+		}
+		name := res.symbolPicker.Get("value").String()
+		if addr, ok := res.executable.Symbols[name]; ok {
+			go res.updateBase(addr)
+		}
+	})
 
 	res.updateAddressCells()
 	return res
 }
 
+// SetExecutable updates the list of symbols which the view can jump to.
+func (m *MemoryView) SetExecutable(e *mips32.Executable) {
+	m.executable = e
+	m.haveValues = false
+
+	names := make([]string, 0, len(e.Symbols))
+	for name := range e.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	document := js.Global().Get("document")
+	m.symbolPicker.Set("innerHTML", "")
+	placeholder := document.Call("createElement", "option")
+	placeholder.Set("textContent", "Jump to symbol")
+	placeholder.Set("value", "")
+	m.symbolPicker.Call("appendChild", placeholder)
+	for _, name := range names {
+		option := document.Call("createElement", "option")
+		option.Set("textContent", name)
+		option.Set("value", name)
+		m.symbolPicker.Call("appendChild", option)
+	}
+}
+
+// Update refreshes the displayed bytes.
+// Bytes which changed since the last update are highlighted.
 func (m *MemoryView) Update(mem mips32.Memory) {
 	m.memory = mem
 
-	addr := m.baseAddress
+	var values [memoryViewRows * memoryViewColumns]byte
+	mips32.ReadBytes(mem, m.baseAddress, values[:])
 	idx := 0
 	for i := 0; i < memoryViewRows; i++ {
+		var ascii []byte
 		for j := 0; j < memoryViewColumns; j++ {
-			val := m.memory.Get(addr)
-			m.memoryCells[idx].Set("textContent", format8BitHex(uint8(val)))
-			addr += 1
+			val := values[idx]
+			cell := m.memoryCells[idx]
+			cell.Set("textContent", format8BitHex(uint8(val)))
+			if m.haveValues && m.lastValues[idx] != val {
+				cell.Set("className", "debugger-memory-value debugger-memory-changed")
+			} else {
+				cell.Set("className", "debugger-memory-value")
+			}
+			m.lastValues[idx] = val
+			if val >= 0x20 && val < 0x7f {
+				ascii = append(ascii, val)
+			} else {
+				ascii = append(ascii, '.')
+			}
 			idx += 1
 		}
+		m.asciiCells[i].Set("textContent", string(ascii))
+	}
+	m.haveValues = true
+}
+
+// Flash briefly highlights the visible bytes of some memory words.
+func (m *MemoryView) Flash(wordAddrs []uint32) {
+	for _, wordAddr := range wordAddrs {
+		for addr := wordAddr; addr < wordAddr+4; addr++ {
+			offset := addr - m.baseAddress
+			if addr >= m.baseAddress && offset < uint32(len(m.memoryCells)) {
+				flashElement(m.memoryCells[offset])
+			}
+		}
 	}
 }
 
@@ -97,6 +189,7 @@ func (m *MemoryView) updateBase(addr uint32) {
 	}
 
 	m.baseAddress = addr
+	m.haveValues = false
 	m.baseLabel.Set("textContent", format32BitHex(addr))
 	m.updateAddressCells()
 	m.Update(m.memory)
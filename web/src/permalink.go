@@ -0,0 +1,111 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// permalinkPrefix starts the URL fragment of a shared program.
+const permalinkPrefix = "#share="
+
+// A sharedProgram is the information stored in a permalink.
+// Short JSON keys keep the links short.
+type sharedProgram struct {
+	Source      string               `json:"s"`
+	Breakpoints []uint32             `json:"b,omitempty"`
+	Formats     []mips32.ValueFormat `json:"f,omitempty"`
+	ABINames    bool                 `json:"a,omitempty"`
+}
+
+// encodePermalink compresses a program into a string that is safe to use in a URL.
+func encodePermalink(p *sharedProgram) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodePermalink(s string) (*sharedProgram, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, err
+	}
+	var res sharedProgram
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Permalink returns a URL which opens the given program.
+// If settings is true, the debugger's breakpoints and display preferences are included.
+func Permalink(source string, settings bool) (string, error) {
+	p := &sharedProgram{Source: source}
+	if settings {
+		p.Breakpoints = GlobalDebugger.Breakpoints()
+		p.Formats = GlobalPreferences.RegisterFormats
+		p.ABINames = GlobalPreferences.ABINames
+	}
+	encoded, err := encodePermalink(p)
+	if err != nil {
+		return "", err
+	}
+	location := js.Global().Get("location")
+	base := location.Get("origin").String() + location.Get("pathname").String()
+	return base + permalinkPrefix + encoded, nil
+}
+
+// OpenPermalink loads the program from the page's URL fragment, if there is one.
+// It returns false if the URL is not a permalink.
+func OpenPermalink() bool {
+	location := js.Global().Get("location")
+	hash := location.Get("hash").String()
+	if !strings.HasPrefix(hash, permalinkPrefix) {
+		return false
+	}
+	// Switch to the assembler so that reloading the page doesn't create another copy.
+	location.Set("hash", "#assembler")
+
+	p, err := decodePermalink(hash[len(permalinkPrefix):])
+	if err != nil {
+		GlobalAssembler.Restore(defaultProgram)
+		GlobalAssembler.showError(errors.New("invalid shared link: " + err.Error()))
+		return true
+	}
+	if len(p.Formats) > 0 {
+		GlobalPreferences.SetRegisterFormats(p.Formats...)
+	}
+	GlobalPreferences.SetABINames(p.ABINames)
+	GlobalAssembler.CreateFile("shared", p.Source)
+	if GlobalAssembler.Assemble() {
+		GlobalDebugger.SetBreakpoints(p.Breakpoints)
+	}
+	return true
+}
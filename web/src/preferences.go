@@ -0,0 +1,44 @@
+//go:build js
+// +build js
+
+package main
+
+import "github.com/unixpickle/mips32"
+
+var GlobalPreferences = &Preferences{
+	RegisterFormats: []mips32.ValueFormat{mips32.HexFormat},
+}
+
+// Preferences stores the user's display settings.
+//
+// Preferences should be modified with setter methods so that the UI is notified.
+type Preferences struct {
+	// RegisterFormats lists the formats in which each register is shown, side by side.
+	RegisterFormats []mips32.ValueFormat
+
+	// ABINames is true if registers should be labeled with names like $t0 as well as numbers.
+	ABINames bool
+
+	listeners []func()
+}
+
+func (p *Preferences) SetRegisterFormats(formats ...mips32.ValueFormat) {
+	p.RegisterFormats = formats
+	p.changed()
+}
+
+func (p *Preferences) SetABINames(flag bool) {
+	p.ABINames = flag
+	p.changed()
+}
+
+// OnChange registers a function to call whenever a preference changes.
+func (p *Preferences) OnChange(f func()) {
+	p.listeners = append(p.listeners, f)
+}
+
+func (p *Preferences) changed() {
+	for _, f := range p.listeners {
+		f()
+	}
+}
@@ -0,0 +1,112 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+const newPresetOption = "new"
+
+// A PresetPicker lets the user choose the registers which are set before the program runs.
+// Choosing a preset resets the debugger.
+type PresetPicker struct {
+	element js.Value
+	presets []*mips32.RegisterPreset
+
+	// selected is the index of the selected preset, or -1 for no preset.
+	selected int
+}
+
+func NewPresetPicker() *PresetPicker {
+	res := &PresetPicker{
+		element:  js.Global().Get("debugger-preset"),
+		presets:  append([]*mips32.RegisterPreset{}, mips32.DefaultPresets...),
+		selected: -1,
+	}
+	addListener(res.element, "change", func(js.Value) {
+		value := res.element.Get("value").String()
+		if value == newPresetOption {
+			res.promptPreset()
+			return
+		}
+		res.selected, _ = strconv.Atoi(value)
+		go GlobalDebugger.SetPreset(res.Selected())
+	})
+	res.render()
+	return res
+}
+
+// AddPreset adds a preset to the list.
+// If a preset with the same name exists, it is replaced.
+func (p *PresetPicker) AddPreset(preset *mips32.RegisterPreset) {
+	for i, existing := range p.presets {
+		if existing.Name == preset.Name {
+			p.presets[i] = preset
+			p.render()
+			return
+		}
+	}
+	p.presets = append(p.presets, preset)
+	p.render()
+}
+
+// Select chooses the preset with the given name and resets the debugger.
+// It returns false if there is no such preset.
+func (p *PresetPicker) Select(name string) bool {
+	for i, preset := range p.presets {
+		if preset.Name == name {
+			p.selected = i
+			p.render()
+			go GlobalDebugger.SetPreset(preset)
+			return true
+		}
+	}
+	return false
+}
+
+// Selected returns the selected preset, or nil if none is selected.
+func (p *PresetPicker) Selected() *mips32.RegisterPreset {
+	if p.selected < 0 {
+		return nil
+	}
+	return p.presets[p.selected]
+}
+
+func (p *PresetPicker) promptPreset() {
+	defer p.render()
+	window := js.Global().Get("window")
+	assignments := window.Call("prompt", "Registers to set (e.g. $a0=0x10010000, $sp=0x7fffeffc):")
+	if assignments.IsNull() || assignments.String() == "" {
+		return
+	}
+	name := "Custom " + strconv.Itoa(len(p.presets)-len(mips32.DefaultPresets)+1)
+	preset, err := mips32.ParseRegisterPreset(name, assignments.String())
+	if err != nil {
+		window.Call("alert", err.Error())
+		return
+	}
+	p.AddPreset(preset)
+	p.Select(name)
+}
+
+func (p *PresetPicker) render() {
+	p.element.Set("innerHTML", "")
+	document := js.Global().Get("document")
+	addOption := func(value, text string) {
+		option := document.Call("createElement", "option")
+		option.Set("value", value)
+		option.Set("textContent", text)
+		p.element.Call("appendChild", option)
+	}
+	addOption("-1", "No register preset")
+	for i, preset := range p.presets {
+		addOption(strconv.Itoa(i), preset.Name+": "+preset.String())
+	}
+	addOption(newPresetOption, "New preset...")
+	p.element.Set("value", strconv.Itoa(p.selected))
+}
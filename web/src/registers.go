@@ -1,24 +1,37 @@
+//go:build js
+// +build js
+
 package main
 
 import (
 	"strconv"
+	"strings"
+	"syscall/js"
 
-	"github.com/gopherjs/gopherjs/js"
 	"github.com/unixpickle/mips32"
 )
 
+var registerFormatOptions = map[string][]mips32.ValueFormat{
+	"hex":      {mips32.HexFormat},
+	"signed":   {mips32.SignedFormat},
+	"unsigned": {mips32.UnsignedFormat},
+	"all":      {mips32.HexFormat, mips32.SignedFormat, mips32.UnsignedFormat},
+}
+
 type Registers struct {
-	regCells [32]*js.Object
-	callback func(reg int, val uint32)
+	nameCells [32]js.Value
+	regCells  [32]js.Value
+	file      mips32.RegisterFile
+	callback  func(reg int, val uint32)
 }
 
 func NewRegisters() *Registers {
 	res := &Registers{}
-	regTable := js.Global.Get("debugger-registers")
-	document := js.Global.Get("document")
+	regTable := js.Global().Get("debugger-registers")
+	document := js.Global().Get("document")
 	for i := 0; i < 16; i++ {
 		row := document.Call("createElement", "tr")
-		tds := make([]*js.Object, 4)
+		tds := make([]js.Value, 4)
 		for j := range tds {
 			tds[j] = document.Call("createElement", "td")
 			if j%2 == 0 {
@@ -28,27 +41,60 @@ func NewRegisters() *Registers {
 			}
 			row.Call("appendChild", tds[j])
 		}
-		tds[0].Set("textContent", "$r"+strconv.Itoa(i))
-		tds[2].Set("textContent", "$r"+strconv.Itoa(i+16))
-		tds[1].Set("textContent", format32BitHex(0))
-		tds[3].Set("textContent", format32BitHex(0))
+		res.nameCells[i] = tds[0]
+		res.nameCells[i+16] = tds[2]
 		res.regCells[i] = tds[1]
 		res.regCells[i+16] = tds[3]
 		regTable.Call("appendChild", row)
 	}
 	for i := 0; i < 32; i++ {
 		func(num int) {
-			res.regCells[num].Call("addEventListener", "click", func() {
+			addListener(res.regCells[num], "dblclick", func(js.Value) {
 				res.editRegister(num)
 			})
 		}(i)
 	}
+
+	formatPicker := js.Global().Get("debugger-register-format")
+	addListener(formatPicker, "change", func(js.Value) {
+		formats := registerFormatOptions[formatPicker.Get("value").String()]
+		GlobalPreferences.SetRegisterFormats(formats...)
+	})
+	abiCheckbox := js.Global().Get("debugger-abi-names")
+	addListener(abiCheckbox, "change", func(js.Value) {
+		GlobalPreferences.SetABINames(abiCheckbox.Get("checked").Bool())
+	})
+	GlobalPreferences.OnChange(func() {
+		// Keep the controls in sync when preferences are changed elsewhere, e.g. by a permalink.
+		for name, formats := range registerFormatOptions {
+			if sameFormats(formats, GlobalPreferences.RegisterFormats) {
+				formatPicker.Set("value", name)
+			}
+		}
+		abiCheckbox.Set("checked", GlobalPreferences.ABINames)
+		res.Update(res.file)
+	})
+
+	res.Update(res.file)
 	return res
 }
 
 func (r *Registers) Update(file mips32.RegisterFile) {
+	r.file = file
 	for i := 0; i < 32; i++ {
-		r.regCells[i].Set("textContent", format32BitHex(file[i]))
+		r.nameCells[i].Set("textContent", registerLabel(i))
+		var values []string
+		for _, format := range GlobalPreferences.RegisterFormats {
+			values = append(values, format.Format(file[i]))
+		}
+		r.regCells[i].Set("textContent", strings.Join(values, " / "))
+	}
+}
+
+// Flash briefly highlights the values of some registers, e.g. to show that they changed.
+func (r *Registers) Flash(regs []int) {
+	for _, reg := range regs {
+		flashElement(r.regCells[reg])
 	}
 }
 
@@ -57,10 +103,31 @@ func (r *Registers) SetCallback(f func(reg int, val uint32)) {
 }
 
 func (r *Registers) editRegister(i int) {
-	NewEntryPopup("Enter value for $r"+strconv.Itoa(i), func(v uint32) {
-		r.regCells[i].Set("textContent", format32BitHex(v))
+	NewEntryPopup("Enter value for "+registerLabel(i), func(v uint32) {
+		r.file[i] = v
+		r.Update(r.file)
 		if r.callback != nil {
 			r.callback(i, v)
 		}
 	})
 }
+
+func registerLabel(i int) string {
+	label := "$r" + strconv.Itoa(i)
+	if GlobalPreferences.ABINames {
+		label += " $" + mips32.ABIRegisterNames[i]
+	}
+	return label
+}
+
+func sameFormats(f1, f2 []mips32.ValueFormat) bool {
+	if len(f1) != len(f2) {
+		return false
+	}
+	for i, f := range f1 {
+		if f2[i] != f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"syscall/js"
+)
+
+const remoteSourceParam = "src"
+
+// OpenRemoteProgram fetches, assembles, and opens the program at the URL given by the page's
+// ?src= query parameter.
+// It returns false if there is no such parameter.
+func OpenRemoteProgram() bool {
+	location := js.Global().Get("location")
+	params := js.Global().Get("URLSearchParams").New(location.Get("search"))
+	if !params.Call("has", remoteSourceParam).Bool() {
+		return false
+	}
+	url := params.Call("get", remoteSourceParam).String()
+
+	// Remove the parameter so that reloading the page keeps the user's edits instead of creating
+	// another copy of the program.
+	params.Call("delete", remoteSourceParam)
+	newURL := location.Get("pathname").String()
+	if query := params.Call("toString").String(); query != "" {
+		newURL += "?" + query
+	}
+	newURL += location.Get("hash").String()
+	js.Global().Get("history").Call("replaceState", nil, "", newURL)
+
+	code, err := fetchText(url)
+	if err != nil {
+		GlobalAssembler.Restore(defaultProgram)
+		GlobalAssembler.showError(errors.New("could not load " + url + ": " + err.Error()))
+		return true
+	}
+	GlobalAssembler.CreateFile(remoteFileName(url), code)
+	GlobalAssembler.Assemble()
+	return true
+}
+
+// fetchText downloads a text file.
+//
+// Browsers do not say why a cross-origin request failed, so any network error is reported as
+// a possible CORS problem.
+func fetchText(url string) (string, error) {
+	type result struct {
+		text string
+		err  error
+	}
+	resChan := make(chan result, 1)
+	req := js.Global().Get("XMLHttpRequest").New()
+	req.Call("open", "GET", url)
+	var listeners listenerSet
+	defer listeners.release()
+	listeners.add(req, "load", func(js.Value) {
+		status := req.Get("status").Int()
+		if status < 200 || status >= 300 {
+			resChan <- result{err: errors.New("server responded with " + strconv.Itoa(status) +
+				" " + req.Get("statusText").String())}
+		} else {
+			resChan <- result{text: req.Get("responseText").String()}
+		}
+	})
+	listeners.add(req, "error", func(js.Value) {
+		resChan <- result{err: errors.New("network error (the server may not allow " +
+			"cross-origin requests; it must send an Access-Control-Allow-Origin header)")}
+	})
+	req.Call("send")
+	res := <-resChan
+	return res.text, res.err
+}
+
+// remoteFileName picks a name for a program from its URL.
+func remoteFileName(url string) string {
+	if idx := strings.IndexAny(url, "?#"); idx >= 0 {
+		url = url[:idx]
+	}
+	name := path.Base(url)
+	if name == "." || name == "/" || strings.HasSuffix(url, "/") {
+		return "remote"
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// maxSnapshotChanges limits how many changed words are listed, since a program which fills a
+// large buffer could otherwise produce a huge table.
+const maxSnapshotChanges = 500
+
+// A SnapshotView lets the user capture the debugger's memory and later see which words have
+// changed since then.
+// Clicking a changed word shows it in the memory view.
+type SnapshotView struct {
+	table         js.Value
+	status        js.Value
+	compareButton js.Value
+
+	memoryView *MemoryView
+	snapshot   *mips32.MemorySnapshot
+
+	// listeners belong to the rows of the table.
+	listeners listenerSet
+}
+
+func NewSnapshotView(memoryView *MemoryView) *SnapshotView {
+	res := &SnapshotView{
+		table:         js.Global().Get("debugger-snapshot-diff"),
+		status:        js.Global().Get("debugger-snapshot-status"),
+		compareButton: js.Global().Get("debugger-snapshot-compare"),
+		memoryView:    memoryView,
+	}
+	addListener(js.Global().Get("debugger-snapshot-take"), "click", func(js.Value) {
+		go func() {
+			res.snapshot = GlobalDebugger.Snapshot()
+			res.table.Set("innerHTML", "")
+			res.status.Set("textContent", "Snapshot taken")
+			res.compareButton.Set("disabled", false)
+		}()
+	})
+	addListener(res.compareButton, "click", func(js.Value) {
+		go res.compare()
+	})
+	return res
+}
+
+// Reset forgets the snapshot, e.g. because a new program was loaded.
+func (s *SnapshotView) Reset() {
+	s.snapshot = nil
+	s.listeners.release()
+	s.table.Set("innerHTML", "")
+	s.status.Set("textContent", "")
+	s.compareButton.Set("disabled", true)
+}
+
+func (s *SnapshotView) compare() {
+	if s.snapshot == nil {
+		return
+	}
+	changes := GlobalDebugger.DiffSnapshot(s.snapshot)
+	switch len(changes) {
+	case 0:
+		s.status.Set("textContent", "No words have changed")
+	case 1:
+		s.status.Set("textContent", "1 word has changed")
+	default:
+		s.status.Set("textContent", strconv.Itoa(len(changes))+" words have changed")
+	}
+
+	s.listeners.release()
+	s.table.Set("innerHTML", "")
+	document := js.Global().Get("document")
+	header := document.Call("createElement", "tr")
+	for _, title := range []string{"Address", "Old", "New"} {
+		column := document.Call("createElement", "th")
+		column.Set("textContent", title)
+		header.Call("appendChild", column)
+	}
+	s.table.Call("appendChild", header)
+
+	if len(changes) > maxSnapshotChanges {
+		changes = changes[:maxSnapshotChanges]
+	}
+	for _, change := range changes {
+		row := document.Call("createElement", "tr")
+		row.Set("className", "debugger-snapshot-change")
+		for _, value := range []uint32{change.Address, change.Old, change.New} {
+			column := document.Call("createElement", "td")
+			column.Set("textContent", format32BitHex(value))
+			row.Call("appendChild", column)
+		}
+		addr := change.Address
+		s.listeners.add(row, "click", func(js.Value) {
+			go s.memoryView.updateBase(addr)
+		})
+		s.table.Call("appendChild", row)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+const sourceViewLines = 9
+
+// A SourceView shows the assembly source around the line which is being executed.
+type SourceView struct {
+	element js.Value
+}
+
+func NewSourceView() *SourceView {
+	return &SourceView{
+		element: js.Global().Get("debugger-source-view"),
+	}
+}
+
+func (s *SourceView) Update(e *mips32.Emulator, source []string) {
+	s.element.Set("innerHTML", "")
+	current, ok := e.Executable.LineNumbers[e.ProgramCounter]
+	if !ok || len(source) == 0 {
+		return
+	}
+	start := current - sourceViewLines/2
+	if start+sourceViewLines > len(source)+1 {
+		start = len(source) + 1 - sourceViewLines
+	}
+	if start < 1 {
+		start = 1
+	}
+
+	document := js.Global().Get("document")
+	for line := start; line < start+sourceViewLines && line <= len(source); line++ {
+		row := document.Call("createElement", "tr")
+		if line == current {
+			row.Set("className", "debugger-source-view-current")
+		}
+		numColumn := document.Call("createElement", "td")
+		numColumn.Set("className", "debugger-source-view-line")
+		numColumn.Set("textContent", strconv.Itoa(line))
+		row.Call("appendChild", numColumn)
+		codeColumn := document.Call("createElement", "td")
+		codeColumn.Set("className", "debugger-source-view-code")
+		codeColumn.Set("textContent", source[line-1])
+		row.Call("appendChild", codeColumn)
+		s.element.Call("appendChild", row)
+	}
+}
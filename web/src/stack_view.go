@@ -0,0 +1,67 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// stackViewWords is the number of words shown in the stack view, starting at $sp.
+const stackViewWords = 32
+
+// A StackView shows the memory around $sp, divided into the frames of the active calls.
+// Words where a function saved a register are labeled with the register's name.
+type StackView struct {
+	element js.Value
+}
+
+func NewStackView() *StackView {
+	return &StackView{element: js.Global().Get("debugger-stack")}
+}
+
+func (s *StackView) Update(stack *mips32.CallStack, e *mips32.Emulator) {
+	s.element.Set("innerHTML", "")
+	document := js.Global().Get("document")
+	lastFrame := -2
+	for i, word := range stack.StackWords(e, stackViewWords) {
+		if word.Frame != lastFrame {
+			lastFrame = word.Frame
+			header := document.Call("createElement", "tr")
+			header.Set("className", "debugger-stack-frame")
+			column := document.Call("createElement", "td")
+			column.Set("colSpan", 3)
+			if word.Frame < 0 {
+				column.Set("textContent", "Caller")
+			} else {
+				frame := stack.Frames[word.Frame]
+				column.Set("textContent", e.Executable.SymbolicAddress(frame.Function)+
+					" (returns to "+e.Executable.SymbolicAddress(frame.ReturnAddress)+")")
+			}
+			header.Call("appendChild", column)
+			s.element.Call("appendChild", header)
+		}
+
+		row := document.Call("createElement", "tr")
+		var note string
+		if i == 0 {
+			note = "← $sp"
+		}
+		if word.SavedRegister >= 0 {
+			if note != "" {
+				note += ", "
+			}
+			note += "saved $" + mips32.ABIRegisterNames[word.SavedRegister]
+			row.Set("className", "debugger-stack-saved")
+		}
+		for _, text := range []string{format32BitHex(word.Address), format32BitHex(word.Value),
+			note} {
+			column := document.Call("createElement", "td")
+			column.Set("textContent", text)
+			row.Call("appendChild", column)
+		}
+		s.element.Call("appendChild", row)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"syscall/js"
+)
+
+const (
+	programsStorageKey    = "mips32-programs"
+	currentFileStorageKey = "mips32-current-file"
+)
+
+// A ProgramStore saves named programs in the browser's localStorage.
+//
+// If localStorage is unavailable (e.g. in some private browsing modes), or the
+// store is not persistent, the programs are kept in memory and lost when the
+// page is closed.
+type ProgramStore struct {
+	storage  js.Value
+	programs map[string]string
+	current  string
+}
+
+func NewProgramStore(persistent bool) *ProgramStore {
+	res := &ProgramStore{programs: map[string]string{}}
+	if !persistent {
+		return res
+	}
+	res.storage = js.Global().Get("localStorage")
+	if data := res.getItem(programsStorageKey); data != "" {
+		// Corrupted data is ignored rather than breaking the whole page.
+		json.Unmarshal([]byte(data), &res.programs)
+	}
+	res.current = res.getItem(currentFileStorageKey)
+	return res
+}
+
+// Names returns the names of the saved programs in alphabetical order.
+func (p *ProgramStore) Names() []string {
+	var res []string
+	for name := range p.programs {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Load returns the source code of a saved program.
+func (p *ProgramStore) Load(name string) (string, bool) {
+	code, ok := p.programs[name]
+	return code, ok
+}
+
+// Save creates or overwrites a program.
+func (p *ProgramStore) Save(name, code string) {
+	p.programs[name] = code
+	p.flush()
+}
+
+// Delete removes a program.
+func (p *ProgramStore) Delete(name string) {
+	delete(p.programs, name)
+	p.flush()
+}
+
+// Current returns the name of the program which was last opened.
+func (p *ProgramStore) Current() string {
+	return p.current
+}
+
+func (p *ProgramStore) SetCurrent(name string) {
+	p.current = name
+	p.setItem(currentFileStorageKey, name)
+}
+
+func (p *ProgramStore) flush() {
+	data, _ := json.Marshal(p.programs)
+	p.setItem(programsStorageKey, string(data))
+}
+
+func (p *ProgramStore) getItem(key string) string {
+	if isMissing(p.storage) {
+		return ""
+	}
+	value := p.storage.Call("getItem", key)
+	if value.IsNull() {
+		return ""
+	}
+	return value.String()
+}
+
+func (p *ProgramStore) setItem(key, value string) {
+	if !isMissing(p.storage) {
+		p.storage.Call("setItem", key, value)
+	}
+}
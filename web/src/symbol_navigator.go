@@ -0,0 +1,143 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// A SymbolNavigator lists the symbols of the executable, sorted by address.
+// Clicking a symbol shows it in the code view and the memory view.
+type SymbolNavigator struct {
+	table  js.Value
+	search js.Value
+
+	codeView   *CodeView
+	memoryView *MemoryView
+	symbols    []symbolEntry
+
+	// listeners belong to the rows of the table.
+	listeners listenerSet
+}
+
+type symbolEntry struct {
+	name string
+	addr uint32
+
+	// info is only valid if hasInfo is true.
+	info    mips32.SymbolInfo
+	hasInfo bool
+}
+
+func NewSymbolNavigator(codeView *CodeView, memoryView *MemoryView) *SymbolNavigator {
+	res := &SymbolNavigator{
+		table:      js.Global().Get("debugger-symbols"),
+		search:     js.Global().Get("debugger-symbol-search"),
+		codeView:   codeView,
+		memoryView: memoryView,
+	}
+	addListener(res.search, "input", func(js.Value) { res.render() })
+	addListener(res.search, "keyup", func(event js.Value) {
+		// Pressing enter goes to the best match.
+		if event.Get("keyCode").Int() == enterKeyCode {
+			if matches := res.matches(); len(matches) > 0 {
+				res.show(matches[0])
+			}
+		}
+	})
+	return res
+}
+
+// SetExecutable updates the list of symbols.
+func (s *SymbolNavigator) SetExecutable(e *mips32.Executable) {
+	s.symbols = s.symbols[:0]
+	for name, addr := range e.Symbols {
+		info, hasInfo := e.SymbolInfo[name]
+		s.symbols = append(s.symbols, symbolEntry{
+			name:    name,
+			addr:    addr,
+			info:    info,
+			hasInfo: hasInfo,
+		})
+	}
+	sort.Slice(s.symbols, func(i, j int) bool {
+		if s.symbols[i].addr == s.symbols[j].addr {
+			return s.symbols[i].name < s.symbols[j].name
+		}
+		return s.symbols[i].addr < s.symbols[j].addr
+	})
+	s.render()
+}
+
+func (s *SymbolNavigator) render() {
+	s.listeners.release()
+	s.table.Set("innerHTML", "")
+	document := js.Global().Get("document")
+	for _, entry := range s.matches() {
+		row := document.Call("createElement", "tr")
+		row.Set("className", "debugger-symbol")
+
+		nameColumn := document.Call("createElement", "td")
+		nameColumn.Set("textContent", entry.name)
+		row.Call("appendChild", nameColumn)
+
+		addrColumn := document.Call("createElement", "td")
+		addrColumn.Set("textContent", format32BitHex(entry.addr))
+		row.Call("appendChild", addrColumn)
+
+		infoColumn := document.Call("createElement", "td")
+		if entry.hasInfo {
+			text := entry.info.Kind.String() + ", " + strconv.Itoa(int(entry.info.Size)) + " bytes"
+			if entry.info.Global {
+				text += ", global"
+			}
+			infoColumn.Set("textContent", text)
+		}
+		row.Call("appendChild", infoColumn)
+
+		symbol := entry
+		s.listeners.add(row, "click", func(js.Value) {
+			s.show(symbol)
+		})
+		s.table.Call("appendChild", row)
+	}
+}
+
+// matches returns the symbols which fuzzy-match the search box.
+// Symbols that start with the search text come first.
+func (s *SymbolNavigator) matches() []symbolEntry {
+	query := strings.ToLower(s.search.Get("value").String())
+	var prefixMatches, otherMatches []symbolEntry
+	for _, entry := range s.symbols {
+		name := strings.ToLower(entry.name)
+		if strings.HasPrefix(name, query) {
+			prefixMatches = append(prefixMatches, entry)
+		} else if fuzzyMatch(name, query) {
+			otherMatches = append(otherMatches, entry)
+		}
+	}
+	return append(prefixMatches, otherMatches...)
+}
+
+func (s *SymbolNavigator) show(entry symbolEntry) {
+	s.codeView.ShowAddress(entry.addr)
+	go s.memoryView.updateBase(entry.addr)
+}
+
+// fuzzyMatch checks if the characters of a query appear in order in a name.
+func fuzzyMatch(name, query string) bool {
+	for i := 0; i < len(query); i++ {
+		idx := strings.IndexByte(name, query[i])
+		if idx < 0 {
+			return false
+		}
+		name = name[idx+1:]
+	}
+	return true
+}
@@ -1,6 +1,87 @@
+//go:build js
+// +build js
+
 package main
 
-import "strconv"
+import (
+	"strconv"
+	"syscall/js"
+)
+
+// addListener calls a function whenever an element receives an event.
+//
+// The listener lasts as long as the page, so it should only be added to elements which are never
+// replaced. Elements which a view redraws should get their listeners from a listenerSet.
+func addListener(element js.Value, event string, f func(event js.Value)) {
+	element.Call("addEventListener", event, eventFunc(f))
+}
+
+// A listenerSet adds event listeners to the elements of a view which is redrawn, so that their
+// functions can be released when the elements are replaced.
+type listenerSet struct {
+	funcs []js.Func
+}
+
+// add is like addListener, but the listener is released by the next call to release.
+func (l *listenerSet) add(element js.Value, event string, f func(event js.Value)) {
+	fn := eventFunc(f)
+	l.funcs = append(l.funcs, fn)
+	element.Call("addEventListener", event, fn)
+}
+
+// release frees the functions of every listener which has been added.
+// The elements they were added to should be discarded.
+func (l *listenerSet) release() {
+	for _, fn := range l.funcs {
+		fn.Release()
+	}
+	l.funcs = nil
+}
+
+// eventFunc wraps a Go event handler in a JavaScript function.
+func eventFunc(f func(event js.Value)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		f(argument(args, 0))
+		return nil
+	})
+}
+
+// argument returns an argument passed from JavaScript, or undefined if it was not passed.
+func argument(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// isMissing checks if a value is undefined or null.
+func isMissing(v js.Value) bool {
+	return v.IsUndefined() || v.IsNull()
+}
+
+// bytesToJS copies data into a new Uint8Array.
+func bytesToJS(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// bytesFromJS copies the contents of a Uint8Array.
+func bytesFromJS(array js.Value) []byte {
+	data := make([]byte, array.Length())
+	js.CopyBytesToGo(data, array)
+	return data
+}
+
+// flashElement restarts the "flash" CSS animation on an element.
+func flashElement(element js.Value) {
+	classList := element.Get("classList")
+	classList.Call("remove", "flash")
+	// Reading offsetWidth forces a reflow, so that removing and re-adding the class restarts the
+	// animation.
+	element.Get("offsetWidth")
+	classList.Call("add", "flash")
+}
 
 func format32BitHex(u uint32) string {
 	res := strconv.FormatUint(uint64(u), 16)
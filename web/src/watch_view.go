@@ -0,0 +1,88 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// A WatchView shows the values of expressions which the user wants to keep an eye on.
+// The expressions are re-evaluated every time the debugger updates.
+type WatchView struct {
+	table js.Value
+	input js.Value
+
+	expressions []string
+
+	// listeners belong to the rows of the table.
+	listeners listenerSet
+}
+
+func NewWatchView() *WatchView {
+	res := &WatchView{
+		table: js.Global().Get("debugger-watches"),
+		input: js.Global().Get("debugger-watch-input"),
+	}
+	addListener(res.input, "keyup", func(event js.Value) {
+		if event.Get("keyCode").Int() != enterKeyCode {
+			return
+		}
+		expr := strings.TrimSpace(res.input.Get("value").String())
+		if expr == "" {
+			return
+		}
+		res.input.Set("value", "")
+		res.expressions = append(res.expressions, expr)
+		go GlobalDebugger.updateUI()
+	})
+	GlobalPreferences.OnChange(func() {
+		go GlobalDebugger.updateUI()
+	})
+	return res
+}
+
+// Update evaluates the expressions with the emulator's current state.
+func (w *WatchView) Update(emulator *mips32.Emulator) {
+	w.listeners.release()
+	w.table.Set("innerHTML", "")
+	document := js.Global().Get("document")
+	for i, expr := range w.expressions {
+		row := document.Call("createElement", "tr")
+
+		exprColumn := document.Call("createElement", "td")
+		exprColumn.Set("className", "debugger-watch-expression")
+		exprColumn.Set("textContent", expr)
+		row.Call("appendChild", exprColumn)
+
+		valueColumn := document.Call("createElement", "td")
+		if val, err := emulator.Evaluate(expr); err != nil {
+			valueColumn.Set("className", "debugger-watch-error")
+			valueColumn.Set("textContent", err.Error())
+		} else {
+			var values []string
+			for _, format := range GlobalPreferences.RegisterFormats {
+				values = append(values, format.Format(val))
+			}
+			valueColumn.Set("textContent", strings.Join(values, " / "))
+		}
+		row.Call("appendChild", valueColumn)
+
+		removeColumn := document.Call("createElement", "td")
+		removeButton := document.Call("createElement", "button")
+		removeButton.Set("className", "debugger-watch-remove")
+		removeButton.Set("textContent", "×")
+		index := i
+		w.listeners.add(removeButton, "click", func(js.Value) {
+			w.expressions = append(w.expressions[:index], w.expressions[index+1:]...)
+			go GlobalDebugger.updateUI()
+		})
+		removeColumn.Call("appendChild", removeButton)
+		row.Call("appendChild", removeColumn)
+
+		w.table.Call("appendChild", row)
+	}
+}
@@ -0,0 +1,241 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall/js"
+	"time"
+
+	"github.com/unixpickle/mips32"
+)
+
+// workerChunkSize is the number of instructions which a worker runs between checks of the clock.
+const workerChunkSize = 10000
+
+// workerProgressInterval is how often a worker reports on a program which is still running.
+const workerProgressInterval = 100 * time.Millisecond
+
+// runWorker answers requests from a BackgroundRunner, when the app is loaded in a Web Worker.
+//
+// The UI and the worker talk with messages, each of which is an object with a "type".
+// The worker starts by sending a "ready" message. After that, the UI sends "run" messages:
+//
+//	segments     the executable's code, as objects with an "address" and a little-endian
+//	             Uint8Array of "data"
+//	symbols      an object mapping symbol names to addresses
+//	snapshot     a Uint8Array from Emulator.WriteSnapshot
+//	frames       the call stack, flattened into function, return address, and stack pointer
+//	breakpoints  an array of addresses
+//
+// The worker runs the program, sending a "progress" message every so often:
+//
+//	snapshot   the emulator's new state
+//	steps      the number of instructions run since the last message
+//	output     the text written by the program since the last message
+//	frames     the new call stack
+//	coverage   the instructions run since the last message, flattened into the address, the
+//	           number of times it ran, and the number of times its branch was taken
+//	finished   true if the run is over, in which case the following are set too
+//	needInput  true if the run stopped because the next instruction reads console input
+//	error      the error which stopped the program, or null
+//
+// A run goes on until the program finishes, reaches a breakpoint, fails, or needs input.
+// It cannot be interrupted, so the UI stops it by terminating the worker.
+func runWorker() {
+	js.Global().Set("onmessage", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		request := argument(args, 0).Get("data")
+		if request.Get("type").String() == "run" {
+			go runWorkerRequest(request)
+		}
+		return nil
+	}))
+	postWorkerMessage(map[string]interface{}{"type": "ready"})
+}
+
+func postWorkerMessage(message map[string]interface{}) {
+	js.Global().Call("postMessage", message)
+}
+
+func runWorkerRequest(request js.Value) {
+	var output bytes.Buffer
+	var callStack mips32.CallStack
+	var breakpoints mips32.Breakpoints
+	executable := decodeWorkerExecutable(request.Get("segments"), request.Get("symbols"))
+	coverage := mips32.NewCoverage(executable)
+	emulator := &mips32.Emulator{
+		Executable:   executable,
+		LittleEndian: true,
+		Syscalls:     &mips32.SPIMSyscalls{Output: &output},
+	}
+	emulator.AfterExecute = func(inst *mips32.Instruction, addr uint32) {
+		callStack.Observe(emulator, inst, addr)
+		coverage.Observe(emulator, inst, addr)
+	}
+	snapshot := bytesFromJS(request.Get("snapshot"))
+	if err := emulator.ReadSnapshot(bytes.NewReader(snapshot)); err != nil {
+		postWorkerMessage(map[string]interface{}{
+			"type":     "progress",
+			"finished": true,
+			"error":    err.Error(),
+		})
+		return
+	}
+	callStack.Frames = decodeFrames(request.Get("frames"))
+	addrs := request.Get("breakpoints")
+	for i := 0; i < addrs.Length(); i++ {
+		breakpoints.Set(uint32(addrs.Index(i).Float()))
+	}
+
+	var steps int
+	lastProgress := time.Now()
+	for {
+		var err error
+		var finished, needInput bool
+		for i := 0; i < workerChunkSize; i++ {
+			if needsConsoleInput(emulator) {
+				finished, needInput = true, true
+				break
+			}
+			err = emulator.Step()
+			steps++
+			if err != nil || emulator.Done() || breakpoints.Has(emulator.ProgramCounter) {
+				finished = true
+				break
+			}
+		}
+		if !finished && time.Since(lastProgress) < workerProgressInterval {
+			continue
+		}
+
+		message := map[string]interface{}{
+			"type":      "progress",
+			"snapshot":  nil,
+			"steps":     steps,
+			"output":    output.String(),
+			"frames":    encodeFrames(callStack.Frames),
+			"coverage":  encodeCoverage(coverage),
+			"finished":  finished,
+			"needInput": needInput,
+			"error":     nil,
+		}
+		var buf bytes.Buffer
+		if writeErr := emulator.WriteSnapshot(&buf); writeErr != nil {
+			message["finished"] = true
+			message["error"] = writeErr.Error()
+			postWorkerMessage(message)
+			return
+		}
+		message["snapshot"] = bytesToJS(buf.Bytes())
+		if err != nil {
+			message["error"] = err.Error()
+		}
+		postWorkerMessage(message)
+		if finished {
+			return
+		}
+		steps = 0
+		output.Reset()
+		coverage.Reset()
+		lastProgress = time.Now()
+	}
+}
+
+// needsConsoleInput checks if the next instruction is a syscall which reads from the console.
+// Workers leave these to the UI, which has the console.
+func needsConsoleInput(e *mips32.Emulator) bool {
+	inst := e.Executable.Get(e.ProgramCounter)
+	if inst == nil || inst.Name != "SYSCALL" {
+		return false
+	}
+	switch e.RegisterFile[2] {
+	case 5, 8, 12:
+		return true
+	}
+	return false
+}
+
+// encodeWorkerExecutable converts an executable's code into the "segments" and "symbols" of a
+// "run" message.
+func encodeWorkerExecutable(e *mips32.Executable) (segments, symbols js.Value, err error) {
+	chunks, err := e.EncodeBytes(true)
+	if err != nil {
+		return js.Undefined(), js.Undefined(), err
+	}
+	var segmentList []interface{}
+	for addr, data := range chunks {
+		segmentList = append(segmentList, map[string]interface{}{
+			"address": addr,
+			"data":    bytesToJS(data),
+		})
+	}
+	symbolMap := map[string]interface{}{}
+	for name, addr := range e.Symbols {
+		symbolMap[name] = addr
+	}
+	return js.ValueOf(segmentList), js.ValueOf(symbolMap), nil
+}
+
+// decodeWorkerExecutable reverses encodeWorkerExecutable.
+func decodeWorkerExecutable(segments, symbols js.Value) *mips32.Executable {
+	res := &mips32.Executable{
+		Segments: map[uint32][]mips32.Instruction{},
+		Symbols:  map[string]uint32{},
+	}
+	for i := 0; i < segments.Length(); i++ {
+		segment := segments.Index(i)
+		data := bytesFromJS(segment.Get("data"))
+		insts := make([]mips32.Instruction, len(data)/4)
+		for j := range insts {
+			insts[j] = *mips32.DecodeInstruction(binary.LittleEndian.Uint32(data[j*4:]))
+		}
+		res.Segments[uint32(segment.Get("address").Float())] = insts
+	}
+	names := js.Global().Get("Object").Call("keys", symbols)
+	for i := 0; i < names.Length(); i++ {
+		name := names.Index(i).String()
+		res.Symbols[name] = uint32(symbols.Get(name).Float())
+	}
+	return res
+}
+
+func encodeFrames(frames []mips32.Frame) []interface{} {
+	res := make([]interface{}, 0, len(frames)*3)
+	for _, frame := range frames {
+		res = append(res, frame.Function, frame.ReturnAddress, frame.StackPointer)
+	}
+	return res
+}
+
+func decodeFrames(array js.Value) []mips32.Frame {
+	var res []mips32.Frame
+	for i := 0; i+2 < array.Length(); i += 3 {
+		res = append(res, mips32.Frame{
+			Function:      uint32(array.Index(i).Float()),
+			ReturnAddress: uint32(array.Index(i + 1).Float()),
+			StackPointer:  uint32(array.Index(i + 2).Float()),
+		})
+	}
+	return res
+}
+
+func encodeCoverage(c *mips32.Coverage) []interface{} {
+	res := make([]interface{}, 0, len(c.Counts)*3)
+	for addr, count := range c.Counts {
+		res = append(res, addr, count, c.Taken[addr])
+	}
+	return res
+}
+
+// addCoverage adds coverage from a "progress" message to a Coverage.
+func addCoverage(c *mips32.Coverage, array js.Value) {
+	for i := 0; i+2 < array.Length(); i += 3 {
+		addr := uint32(array.Index(i).Float())
+		c.Counts[addr] += array.Index(i + 1).Int()
+		if taken := array.Index(i + 2).Int(); taken > 0 {
+			c.Taken[addr] += taken
+		}
+	}
+}
@@ -0,0 +1,143 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm is a WebAssembly build of the emulator with a small JavaScript API.
+//
+// It defines a global "mips32" object with the following functions:
+//
+//	assemble(source)         load a program and clear the I/O, returning an error message or null
+//	step(count)              run up to count instructions (default 1), returning an error or null
+//	done()                   check if the program has finished
+//	readRegisters()          get an array of the 32 registers, followed by the program counter
+//	readMemory(addr, length) get a Uint8Array of memory contents, or an error message
+//	writeInput(text)         add text to the input which the program's syscalls read
+//	readOutput()             get and clear the text which the program's syscalls wrote
+//
+// The program's syscalls are the SPIM syscalls. Input must be written before the program reads
+// it; reading past the end of the input is an error, just like reading past the end of a file.
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"syscall/js"
+
+	"github.com/unixpickle/mips32"
+)
+
+// maxReadLength is the largest number of bytes which readMemory returns at once.
+const maxReadLength = 1 << 24
+
+var (
+	input  bytes.Buffer
+	output bytes.Buffer
+)
+
+var emulator = newEmulator(&mips32.Executable{
+	Segments: map[uint32][]mips32.Instruction{},
+	Symbols:  map[string]uint32{},
+})
+
+func main() {
+	api := js.Global().Get("Object").New()
+	api.Set("assemble", js.FuncOf(assemble))
+	api.Set("step", js.FuncOf(step))
+	api.Set("done", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return emulator.Done()
+	}))
+	api.Set("readRegisters", js.FuncOf(readRegisters))
+	api.Set("readMemory", js.FuncOf(readMemory))
+	api.Set("writeInput", js.FuncOf(writeInput))
+	api.Set("readOutput", js.FuncOf(readOutput))
+	js.Global().Set("mips32", api)
+
+	// Keep the functions alive for as long as the page is open.
+	select {}
+}
+
+func newEmulator(e *mips32.Executable) *mips32.Emulator {
+	memory := mips32.NewLazyMemory()
+	// Assemble checks every branch and jump, so the executable can always be encoded.
+	e.LoadInto(memory, true)
+	return &mips32.Emulator{
+		Memory:       memory,
+		Executable:   e,
+		Syscalls:     &mips32.SPIMSyscalls{Input: &input, Output: &output},
+		LittleEndian: true,
+	}
+}
+
+func assemble(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return "expected one string argument"
+	}
+	opts := &mips32.AssembleOptions{LittleEndian: true}
+	exc, err := mips32.Assemble(args[0].String(), opts)
+	if err != nil {
+		return err.Error()
+	}
+	emulator = newEmulator(exc)
+	input.Reset()
+	output.Reset()
+	return nil
+}
+
+func step(this js.Value, args []js.Value) interface{} {
+	count := 1
+	if len(args) > 0 {
+		if args[0].Type() != js.TypeNumber || args[0].Int() < 0 {
+			return "count must be a non-negative number"
+		}
+		count = args[0].Int()
+	}
+	if _, err := emulator.StepN(count); err != nil {
+		return err.Error()
+	}
+	return nil
+}
+
+func readRegisters(this js.Value, args []js.Value) interface{} {
+	res := make([]interface{}, 0, 33)
+	for _, val := range emulator.RegisterFile {
+		res = append(res, val)
+	}
+	return append(res, emulator.ProgramCounter)
+}
+
+func readMemory(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 || args[0].Type() != js.TypeNumber || args[1].Type() != js.TypeNumber {
+		return "expected an address and a length"
+	}
+	addr := args[0].Float()
+	if addr < 0 || addr > 0xffffffff || addr != float64(uint32(addr)) {
+		return "invalid address: " + formatNumber(addr)
+	}
+	length := args[1].Float()
+	if length < 0 || length > maxReadLength || length != float64(int(length)) {
+		return "invalid length: " + formatNumber(length) + " (maximum " +
+			strconv.Itoa(maxReadLength) + ")"
+	}
+	data := make([]byte, int(length))
+	mips32.ReadBytes(emulator.Memory, uint32(addr), data)
+	res := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(res, data)
+	return res
+}
+
+func writeInput(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return "expected one string argument"
+	}
+	input.WriteString(args[0].String())
+	return nil
+}
+
+func readOutput(this js.Value, args []js.Value) interface{} {
+	res := output.String()
+	output.Reset()
+	return res
+}
+
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}